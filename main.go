@@ -7,6 +7,7 @@ import (
 	caprconfigserver "github.com/rancher/cluster-api-provider-rancher/pkg/configserver"
 	caprcontext "github.com/rancher/cluster-api-provider-rancher/pkg/context"
 	"github.com/rancher/cluster-api-provider-rancher/pkg/controllers/bootstrap"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/controllers/etcdsnapshotschedule"
 	"github.com/rancher/cluster-api-provider-rancher/pkg/controllers/machinenodelookup"
 	"github.com/rancher/cluster-api-provider-rancher/pkg/controllers/plansecret"
 	"github.com/rancher/cluster-api-provider-rancher/pkg/installer"
@@ -154,6 +155,8 @@ func run(c *cli.Context) error {
 
 	plansecret.Register(wContext)
 
+	etcdsnapshotschedule.Register(wContext)
+
 	standalonekubeconfig.Register(wContext)
 
 	if err := wContext.Start(3); err != nil {
@@ -0,0 +1,56 @@
+package v1
+
+const (
+	AuditWebhookModeBatch          = "batch"
+	AuditWebhookModeBlocking       = "blocking"
+	AuditWebhookModeBlockingStrict = "blocking-strict"
+)
+
+// AuditLog configures audit logging of the kube-apiserver on the controlplane machines. The planner renders the policy
+// and the webhook configuration into files and the matching kube-apiserver arguments, changes are rolled out like any
+// other change of the distro configuration.
+type AuditLog struct {
+	// Policy is the audit policy (audit.k8s.io/v1 Policy) in YAML or JSON. Exactly one of Policy and PolicyConfigMap must
+	// be set.
+	// +optional
+	Policy string `json:"policy,omitempty"`
+	// PolicyConfigMap references a configmap in the namespace of the controlplane that contains the audit policy. The
+	// configmap must authorize the cluster with the rke.cattle.io/object-authorized-for-clusters annotation.
+	// +optional
+	PolicyConfigMap *AuditPolicyConfigMap `json:"policyConfigMap,omitempty"`
+	// MaxAge is the maximum number of days to retain old audit log files. If 0, the default of the distro is used.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxAge int `json:"maxAge,omitempty"`
+	// MaxBackup is the maximum number of old audit log files to retain. If 0, the default of the distro is used.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxBackup int `json:"maxBackup,omitempty"`
+	// MaxSize is the maximum size in megabytes of the audit log file before it gets rotated. If 0, the default of the
+	// distro is used.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxSize int `json:"maxSize,omitempty"`
+	// Webhook sends audit events to a remote API in addition to the audit log.
+	// +optional
+	Webhook *AuditWebhook `json:"webhook,omitempty"`
+}
+
+type AuditPolicyConfigMap struct {
+	// Name is the name of the configmap.
+	Name string `json:"name"`
+	// Key is the key of the audit policy in the configmap. If empty, "policy" is used.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+type AuditWebhook struct {
+	// ConfigSecretName is the name of a secret in the namespace of the controlplane that contains the kubeconfig of the
+	// webhook under the "config" key. The secret must authorize the cluster with the
+	// rke.cattle.io/object-authorized-for-clusters annotation.
+	ConfigSecretName string `json:"configSecretName"`
+	// Mode is the strategy for sending audit events to the webhook. If empty, batch is used.
+	// +kubebuilder:validation:Enum=batch;blocking;blocking-strict
+	// +optional
+	Mode string `json:"mode,omitempty"`
+}
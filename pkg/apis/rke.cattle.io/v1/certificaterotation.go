@@ -1,6 +1,89 @@
 package v1
 
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 type RotateCertificates struct {
 	Generation int64    `json:"generation,omitempty"`
 	Services   []string `json:"services,omitempty"`
 }
+
+// CertificateExpiry configures when the certificates on the machines are considered to be expiring soon, and whether they
+// are rotated automatically.
+type CertificateExpiry struct {
+	// WindowDays is the number of days before their expiry that certificates are considered to be expiring soon. If 0, 30
+	// days are used.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	WindowDays int `json:"windowDays,omitempty"`
+	// AutomaticRotation rotates the certificates of the services whose certificates are expiring soon through the
+	// certificate rotation of the controlplane.
+	// +optional
+	AutomaticRotation bool `json:"automaticRotation,omitempty"`
+}
+
+// MachineCertificateExpiry is the expiry of the certificates on a machine, which is collected periodically on the
+// machine.
+type MachineCertificateExpiry struct {
+	MachineName string `json:"machineName,omitempty"`
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+	// Services are the earliest expiry of the certificates of every service on the machine, sorted by service.
+	// +optional
+	Services []ServiceCertificateExpiry `json:"services,omitempty"`
+	// Error is the reason the expiry of the certificates could not be collected.
+	// +optional
+	Error string `json:"error,omitempty"`
+	// LastUpdateTime is the time the expiry of the certificates was last collected.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// ServiceCertificateExpiry is the earliest expiry of the certificates of a service on a machine.
+type ServiceCertificateExpiry struct {
+	// Service is the name of the service as accepted by the services of the certificate rotation, or the name of the
+	// certificate file if it does not belong to a known service.
+	Service string `json:"service"`
+	// ExpiryTime is the time the first certificate of the service expires.
+	ExpiryTime metav1.Time `json:"expiryTime"`
+}
+
+// CertificateAuthorities are secrets in the namespace of the controlplane with the certificate authorities the cluster is
+// created with, instead of the self-signed certificate authorities that the distro generates. Every secret contains the
+// certificate of the CA, optionally followed by the certificates of its issuers, in tls.crt, and the key of the CA in
+// tls.key. The distro generates the certificate authorities that are not set. The certificate authorities are only
+// delivered to the init node before the cluster is initialized, changing them afterwards only takes effect with a
+// certificate authority rotation. The chain of the server CA is trusted by the kubeconfigs of the cluster.
+type CertificateAuthorities struct {
+	// +optional
+	ServerCASecretName string `json:"serverCASecretName,omitempty"`
+	// +optional
+	ClientCASecretName string `json:"clientCASecretName,omitempty"`
+	// +optional
+	RequestHeaderCASecretName string `json:"requestHeaderCASecretName,omitempty"`
+	// ETCDCASecretName is the certificate authority of both the server and the peer certificates of etcd.
+	// +optional
+	ETCDCASecretName string `json:"etcdCASecretName,omitempty"`
+}
+
+type RotateCertificateAuthoritiesPhase string
+
+const (
+	RotateCertificateAuthoritiesPhasePrepare        RotateCertificateAuthoritiesPhase = "Prepare"
+	RotateCertificateAuthoritiesPhaseRestartServers RotateCertificateAuthoritiesPhase = "RestartServers"
+	RotateCertificateAuthoritiesPhaseRestartAgents  RotateCertificateAuthoritiesPhase = "RestartAgents"
+	RotateCertificateAuthoritiesPhaseDone           RotateCertificateAuthoritiesPhase = "Done"
+	RotateCertificateAuthoritiesPhaseFailed         RotateCertificateAuthoritiesPhase = "Failed"
+)
+
+// RotateCertificateAuthorities replaces the certificate authorities of the cluster through the certificate rotate-ca
+// command of the distro. The new certificate authorities are taken from the secrets of the certificate authorities of the
+// controlplane if they are set, the others are generated on the init node.
+type RotateCertificateAuthorities struct {
+	// Changing the Generation is the only thing required to initiate a certificate authority rotation.
+	Generation int64 `json:"generation,omitempty"`
+	// Force replaces the certificate authorities with new self-signed certificate authorities instead of certificate
+	// authorities that are cross-signed by the current ones. This is required if a certificate authority was compromised,
+	// and invalidates all certificates and kubeconfigs that were issued by the current certificate authorities.
+	// +optional
+	Force bool `json:"force,omitempty"`
+}
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/rancher/wrangler/v3/pkg/genericcondition"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -81,6 +82,10 @@ type RKEClusterSpecCommon struct {
 	MachineSelectorConfig []RKESystemConfig `json:"machineSelectorConfig,omitempty"`
 	// +optional
 	MachineSelectorFiles []RKEProvisioningFiles `json:"machineSelectorFiles,omitempty"`
+	// MachineSelectorProbes contains additional probes that are added to the plan of every machine matching the
+	// corresponding machine label selector, in addition to the built-in probes rendered by the planner.
+	// +optional
+	MachineSelectorProbes []RKEProvisioningProbes `json:"machineSelectorProbes,omitempty"`
 	// +optional
 	AdditionalManifest string `json:"additionalManifest,omitempty"`
 	// +optional
@@ -108,6 +113,39 @@ type LocalClusterAuthEndpoint struct {
 	FQDN string `json:"fqdn,omitempty"`
 	// +optional
 	CACerts string `json:"caCerts,omitempty"`
+	// OIDC authenticates users of the kube-apiserver with OpenID Connect ID tokens instead of the authentication webhook
+	// of Rancher, which is not available in standalone mode.
+	// +optional
+	OIDC *LocalClusterAuthEndpointOIDC `json:"oidc,omitempty"`
+}
+
+// LocalClusterAuthEndpointOIDC configures the OpenID Connect authentication of the kube-apiserver.
+type LocalClusterAuthEndpointOIDC struct {
+	// IssuerURL is the URL of the OpenID provider. It must use https and match the iss claim of the ID tokens.
+	// +kubebuilder:validation:Pattern=`^https://`
+	IssuerURL string `json:"issuerURL"`
+	// ClientID is the client ID the ID tokens must be issued for.
+	// +kubebuilder:validation:MinLength=1
+	ClientID string `json:"clientID"`
+	// UsernameClaim is the claim that is used as the username. If empty, the sub claim is used.
+	// +optional
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	// UsernamePrefix is prepended to usernames to prevent clashes with other authentication methods.
+	// +optional
+	UsernamePrefix string `json:"usernamePrefix,omitempty"`
+	// GroupsClaim is the claim that is used as the groups of the user.
+	// +optional
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// GroupsPrefix is prepended to groups to prevent clashes with other authentication methods.
+	// +optional
+	GroupsPrefix string `json:"groupsPrefix,omitempty"`
+	// RequiredClaims are claims that must be present in the ID tokens with the given values.
+	// +optional
+	RequiredClaims map[string]string `json:"requiredClaims,omitempty"`
+	// CACerts is the PEM encoded certificate authority bundle the serving certificate of the OpenID provider is verified
+	// against. If empty, the trusted certificate authorities of the host are used.
+	// +optional
+	CACerts string `json:"caCerts,omitempty"`
 }
 
 type RKESystemConfig struct {
@@ -124,6 +162,16 @@ type RKEProvisioningFiles struct {
 	FileSources []ProvisioningFileSource `json:"fileSources,omitempty"`
 }
 
+type RKEProvisioningProbes struct {
+	// +optional
+	MachineLabelSelector *metav1.LabelSelector `json:"machineLabelSelector,omitempty"`
+	// Probes is a map of probe names to probe definitions. A "%s" in the URL is replaced with the loopback address of
+	// the node, and a "%s" in the CACert, ClientCert, or ClientKey is replaced with the data directory of the distro.
+	// Probe names must not conflict with the built-in probes.
+	// +optional
+	Probes map[string]plan.Probe `json:"probes,omitempty"`
+}
+
 type RKEClusterSpec struct {
 	// Not used in anyway, just here to make cluster-api happy
 	// +optional
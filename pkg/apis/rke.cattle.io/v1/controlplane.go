@@ -109,10 +109,38 @@ type RKEControlPlaneSpec struct {
 	// +optional
 	ETCDSnapshotRestore *ETCDSnapshotRestore `json:"etcdSnapshotRestore,omitempty"`
 	// +optional
+	ETCDSnapshotUpload *ETCDSnapshotUpload `json:"etcdSnapshotUpload,omitempty"`
+	// +optional
+	ETCDSnapshotBeforeChange *ETCDSnapshotBeforeChange `json:"etcdSnapshotBeforeChange,omitempty"`
+	// +optional
+	ETCDMaintenance *ETCDMaintenance `json:"etcdMaintenance,omitempty"`
+	// ETCDSnapshotBootstrap is an etcd snapshot the cluster is restored from when it is created. It is ignored once the
+	// cluster was initialized.
+	// +optional
+	ETCDSnapshotBootstrap *ETCDSnapshotBootstrap `json:"etcdSnapshotBootstrap,omitempty"`
+	// +optional
+	ETCDDisasterRecovery *ETCDDisasterRecovery `json:"etcdDisasterRecovery,omitempty"`
+	// +optional
 	RotateCertificates *RotateCertificates `json:"rotateCertificates,omitempty"`
 	// +optional
+	CertificateExpiry *CertificateExpiry `json:"certificateExpiry,omitempty"`
+	// +optional
+	CertificateAuthorities *CertificateAuthorities `json:"certificateAuthorities,omitempty"`
+	// +optional
+	RotateCertificateAuthorities *RotateCertificateAuthorities `json:"rotateCertificateAuthorities,omitempty"`
+	// +optional
+	RotateJoinToken *RotateJoinToken `json:"rotateJoinToken,omitempty"`
+	// +optional
 	RotateEncryptionKeys *RotateEncryptionKeys `json:"rotateEncryptionKeys,omitempty"`
 	// +optional
+	EncryptionKeyRotationPolicy *EncryptionKeyRotationPolicy `json:"encryptionKeyRotationPolicy,omitempty"`
+	// +optional
+	SecretsEncryptionKMS *SecretsEncryptionKMS `json:"secretsEncryptionKMS,omitempty"`
+	// +optional
+	HardeningProfile *HardeningProfile `json:"hardeningProfile,omitempty"`
+	// +optional
+	AuditLog *AuditLog `json:"auditLog,omitempty"`
+	// +optional
 	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
 	// +optional
 	ClusterName string `json:"clusterName,omitempty" wrangler:"required"`
@@ -154,21 +182,88 @@ type RKEControlPlaneStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration"`
 	// +optional
 	CertificateRotationGeneration int64 `json:"certificateRotationGeneration"`
+	// CertificateRotationTime is the time the last certificate rotation finished.
+	// +optional
+	CertificateRotationTime *metav1.Time `json:"certificateRotationTime,omitempty"`
+	// CertificateExpiry is the expiry of the certificates on the machines of the cluster, sorted by machine name.
+	// +optional
+	CertificateExpiry []MachineCertificateExpiry `json:"certificateExpiry,omitempty"`
+	// CertificateExpiryRotation is the certificate rotation that was requested by the automatic rotation of expiring
+	// certificates. It is run instead of the certificate rotation of the spec while its generation is newer.
+	// +optional
+	CertificateExpiryRotation *RotateCertificates `json:"certificateExpiryRotation,omitempty"`
+	// +optional
+	RotateCertificateAuthorities *RotateCertificateAuthorities `json:"rotateCertificateAuthorities,omitempty"`
+	// +optional
+	RotateCertificateAuthoritiesPhase RotateCertificateAuthoritiesPhase `json:"rotateCertificateAuthoritiesPhase,omitempty"`
+	// ServerCAData is the PEM encoded certificate authority bundle the serving certificates of the kube-apiserver are
+	// verified against after the last certificate authority rotation.
+	// +optional
+	ServerCAData string `json:"serverCAData,omitempty"`
+	// +optional
+	RotateJoinToken *RotateJoinToken `json:"rotateJoinToken,omitempty"`
+	// +optional
+	RotateJoinTokenPhase RotateJoinTokenPhase `json:"rotateJoinTokenPhase,omitempty"`
 	// +optional
 	RotateEncryptionKeys *RotateEncryptionKeys `json:"rotateEncryptionKeys,omitempty"`
 	// +optional
 	RotateEncryptionKeysPhase RotateEncryptionKeysPhase `json:"rotateEncryptionKeysPhase,omitempty"`
 	// +optional
 	RotateEncryptionKeysLeader string `json:"rotateEncryptionKeysLeader,omitempty"`
+	// RotateEncryptionKeysHistory are the outcomes of the most recent encryption key rotations, oldest first.
+	// +optional
+	RotateEncryptionKeysHistory []EncryptionKeyRotationRecord `json:"rotateEncryptionKeysHistory,omitempty"`
+	// ScheduledRotateEncryptionKeys is the encryption key rotation that was requested by the encryption key rotation
+	// policy. It is run instead of the encryption key rotation of the spec while its generation is newer.
+	// +optional
+	ScheduledRotateEncryptionKeys *RotateEncryptionKeys `json:"scheduledRotateEncryptionKeys,omitempty"`
+	// SecretsEncryptionEnabled is true if secrets are encrypted by the distro. A change of the secrets-encryption
+	// setting of the MachineGlobalConfig is applied by the planner once the controlplane is ready.
+	// +optional
+	SecretsEncryptionEnabled *bool `json:"secretsEncryptionEnabled,omitempty"`
+	// +optional
+	SecretsEncryptionPhase SecretsEncryptionPhase `json:"secretsEncryptionPhase,omitempty"`
+	// SecretsEncryptionGeneration is the generation of the controlplane the last change of secrets encryption was
+	// started for.
+	// +optional
+	SecretsEncryptionGeneration int64 `json:"secretsEncryptionGeneration,omitempty"`
+	// +optional
+	SecretsEncryptionLeader string `json:"secretsEncryptionLeader,omitempty"`
 	// +optional
 	ETCDSnapshotRestore *ETCDSnapshotRestore `json:"etcdSnapshotRestore,omitempty"`
 	// +optional
 	ETCDSnapshotRestorePhase ETCDSnapshotPhase `json:"etcdSnapshotRestorePhase,omitempty"`
+	// ETCDSnapshotRestoreMessage is the reason the etcd snapshot restore was refused during validation.
+	// +optional
+	ETCDSnapshotRestoreMessage string `json:"etcdSnapshotRestoreMessage,omitempty"`
 	// +optional
 	ETCDSnapshotCreate *ETCDSnapshotCreate `json:"etcdSnapshotCreate,omitempty"`
 	// +optional
 	ETCDSnapshotCreatePhase ETCDSnapshotPhase `json:"etcdSnapshotCreatePhase,omitempty"`
 	// +optional
+	ETCDSnapshotBootstrapPhase ETCDSnapshotPhase `json:"etcdSnapshotBootstrapPhase,omitempty"`
+	// +optional
+	ETCDSnapshotUpload *ETCDSnapshotUpload `json:"etcdSnapshotUpload,omitempty"`
+	// +optional
+	ETCDSnapshotUploadPhase ETCDSnapshotPhase `json:"etcdSnapshotUploadPhase,omitempty"`
+	// ETCDSnapshotUploadFiles are the local etcd snapshots in the order they are uploaded during the etcd snapshot upload.
+	// +optional
+	ETCDSnapshotUploadFiles []ETCDSnapshotUploadFile `json:"etcdSnapshotUploadFiles,omitempty"`
+	// +optional
+	ETCDSnapshotBeforeChange *ETCDSnapshotBeforeChangeStatus `json:"etcdSnapshotBeforeChange,omitempty"`
+	// +optional
+	ETCDMaintenance *ETCDMaintenance `json:"etcdMaintenance,omitempty"`
+	// +optional
+	ETCDMaintenancePhase ETCDMaintenancePhase `json:"etcdMaintenancePhase,omitempty"`
+	// ETCDMaintenanceMembers are the etcd members in the order they are defragmented during the etcd maintenance.
+	// +optional
+	ETCDMaintenanceMembers []ETCDMaintenanceMember `json:"etcdMaintenanceMembers,omitempty"`
+	// ETCDMembers is the status of the etcd members of the cluster, which is collected periodically on the etcd machines.
+	// +optional
+	ETCDMembers []ETCDMemberStatus `json:"etcdMembers,omitempty"`
+	// +optional
+	ETCDDisasterRecovery *ETCDDisasterRecoveryStatus `json:"etcdDisasterRecovery,omitempty"`
+	// +optional
 	ConfigGeneration int64 `json:"configGeneration,omitempty"`
 	// +optional
 	Initialized bool `json:"initialized,omitempty"`
@@ -1,5 +1,7 @@
 package v1
 
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 type RotateEncryptionKeysPhase string
 
 const (
@@ -16,3 +18,22 @@ const (
 type RotateEncryptionKeys struct {
 	Generation int64 `json:"generation,omitempty"`
 }
+
+// EncryptionKeyRotationPolicy rotates the secrets encryption keys of the cluster periodically. A rotation is requested by
+// recording a newer generation of the encryption key rotation in the status once the interval has passed since the last
+// rotation, secrets encryption is enabled and the cluster is ready.
+type EncryptionKeyRotationPolicy struct {
+	// IntervalDays is the number of days between encryption key rotations.
+	// +kubebuilder:validation:Minimum=1
+	IntervalDays int `json:"intervalDays"`
+}
+
+// EncryptionKeyRotationRecord is the outcome of a past encryption key rotation.
+type EncryptionKeyRotationRecord struct {
+	// Generation is the generation of the encryption key rotation that triggered the rotation.
+	Generation int64 `json:"generation"`
+	// Phase is the phase the rotation ended in, either Done or Failed.
+	Phase RotateEncryptionKeysPhase `json:"phase"`
+	// Time is the time the rotation ended.
+	Time metav1.Time `json:"time"`
+}
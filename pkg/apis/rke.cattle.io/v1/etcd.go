@@ -6,29 +6,219 @@ type ETCDSnapshotPhase string
 
 const (
 	ETCDSnapshotPhaseStarted                ETCDSnapshotPhase = "Started"
+	ETCDSnapshotPhaseValidate               ETCDSnapshotPhase = "Validate"
+	ETCDSnapshotPhaseValidationFailed       ETCDSnapshotPhase = "ValidationFailed"
 	ETCDSnapshotPhaseShutdown               ETCDSnapshotPhase = "Shutdown"
 	ETCDSnapshotPhaseRestore                ETCDSnapshotPhase = "Restore"
 	ETCDSnapshotPhasePostRestorePodCleanup  ETCDSnapshotPhase = "PostRestorePodCleanup"
 	ETCDSnapshotPhaseInitialRestartCluster  ETCDSnapshotPhase = "InitialRestartCluster"
 	ETCDSnapshotPhasePostRestoreNodeCleanup ETCDSnapshotPhase = "PostRestoreNodeCleanup"
 	ETCDSnapshotPhaseRestartCluster         ETCDSnapshotPhase = "RestartCluster"
+	ETCDSnapshotPhaseUpload                 ETCDSnapshotPhase = "Upload"
 	ETCDSnapshotPhaseFinished               ETCDSnapshotPhase = "Finished"
 	ETCDSnapshotPhaseFailed                 ETCDSnapshotPhase = "Failed"
 )
 
 type ETCDSnapshotS3 struct {
-	Endpoint            string `json:"endpoint,omitempty"`
-	EndpointCA          string `json:"endpointCA,omitempty"`
-	SkipSSLVerify       bool   `json:"skipSSLVerify,omitempty"`
-	Bucket              string `json:"bucket,omitempty"`
-	Region              string `json:"region,omitempty"`
+	Endpoint      string `json:"endpoint,omitempty"`
+	EndpointCA    string `json:"endpointCA,omitempty"`
+	SkipSSLVerify bool   `json:"skipSSLVerify,omitempty"`
+	Bucket        string `json:"bucket,omitempty"`
+	Region        string `json:"region,omitempty"`
+	// CloudCredentialName is the name of a secret that contains the S3 credential, optionally prefixed with the namespace
+	// of global cloud credentials. Either a Rancher cloud credential, or a secret with the keys accessKey and secretKey
+	// and the optional keys region, endpoint, endpointCA, skipSSLVerify, bucket, and folder is accepted. The settings of
+	// the ETCDSnapshotS3 take precedence over the optional keys of the secret.
 	CloudCredentialName string `json:"cloudCredentialName,omitempty"`
 	Folder              string `json:"folder,omitempty"`
+	// UseInstanceCredentials authenticates to S3 with the credentials that the machines obtain from their environment,
+	// such as an instance profile, instead of a cloud credential. It must not be set together with CloudCredentialName.
+	// +optional
+	UseInstanceCredentials bool `json:"useInstanceCredentials,omitempty"`
 }
 
+// ETCDSnapshotS3Target is an additional S3 location that etcd snapshots created through the controlplane are uploaded
+// to. The distro uploads its own scheduled snapshots only to the S3 location of the etcd configuration of the cluster.
+// A snapshot is created once and the same snapshot is uploaded to every target, which requires an access key, as
+// instance credentials are not supported for the upload.
+// +kubebuilder:validation:XValidation:rule="!has(self.useInstanceCredentials) || !self.useInstanceCredentials",message="instance credentials are not supported for S3 targets, cloudCredentialName must be set instead"
+type ETCDSnapshotS3Target struct {
+	// Name identifies the target, and must be unique within the targets of the cluster.
+	// +kubebuilder:validation:Pattern="^[a-z0-9]([-a-z0-9]*[a-z0-9])?$"
+	// +kubebuilder:validation:MaxLength=32
+	Name string `json:"name"`
+	// ETCDSnapshotS3 is the location of the target. The cloud credential of the etcd configuration of the cluster is used
+	// if the target does not reference one, which requires the etcd configuration to reference one as well.
+	ETCDSnapshotS3 `json:",inline"`
+	// Retention is the number of snapshots with the same name prefix to retain in the target after a snapshot was
+	// uploaded to it. If 0, the retention of the etcd snapshot creation is used.
+	// +optional
+	Retention int `json:"retention,omitempty"`
+}
+
+type ETCDSnapshotTarget string
+
+const (
+	ETCDSnapshotTargetLocal ETCDSnapshotTarget = "local"
+	ETCDSnapshotTargetS3    ETCDSnapshotTarget = "s3"
+	ETCDSnapshotTargetBoth  ETCDSnapshotTarget = "both"
+)
+
 type ETCDSnapshotCreate struct {
 	// Changing the Generation is the only thing required to initiate a snapshot creation.
 	Generation int `json:"generation,omitempty"`
+	// Name is the prefix used for the name of the snapshot. If empty, the default prefix of the distro is used.
+	Name string `json:"name,omitempty"`
+	// Target is the storage the snapshot is saved to, and can be local, s3, or both. If empty, the snapshot is saved
+	// according to the etcd configuration of the cluster. As the distro always writes a local copy of the snapshot before
+	// uploading it, only the most recent local copy is retained for the s3 target.
+	Target ETCDSnapshotTarget `json:"target,omitempty"`
+	// Retention is the number of snapshots with the same name prefix to retain after the snapshot was created. If 0,
+	// snapshots are not pruned.
+	Retention int `json:"retention,omitempty"`
+	// S3Targets are the names of the S3 targets of the etcd configuration of the cluster the snapshot is uploaded to, in
+	// addition to the S3 location of the etcd configuration. If empty, the snapshot is uploaded to all targets. The
+	// snapshot is not uploaded to any target for the local target.
+	// +optional
+	S3Targets []string `json:"s3Targets,omitempty"`
+}
+
+// ETCDSnapshotUpload copies local etcd snapshots from the machines they are stored on to S3, so that they survive the
+// loss of the machines. The uploaded snapshots are added to the S3 etcd snapshots of the cluster once the S3 location is
+// listed again.
+type ETCDSnapshotUpload struct {
+	// Changing the Generation is the only thing required to initiate an upload.
+	Generation int `json:"generation,omitempty"`
+	// Names are the names of the local etcdsnapshot objects to upload. If empty, all local etcd snapshots of the cluster
+	// are uploaded.
+	// +optional
+	Names []string `json:"names,omitempty"`
+	// S3Target is the name of the S3 target of the etcd configuration of the cluster the snapshots are uploaded to. If
+	// empty, the snapshots are uploaded to the S3 location of the etcd configuration.
+	// +optional
+	S3Target string `json:"s3Target,omitempty"`
+}
+
+// ETCDSnapshotUploadFile is the result of the upload of a single local etcd snapshot.
+type ETCDSnapshotUploadFile struct {
+	// Name is the name of the etcdsnapshot object.
+	Name string `json:"name,omitempty"`
+	// SnapshotName is the file name of the snapshot.
+	SnapshotName string `json:"snapshotName,omitempty"`
+	// Location is the location of the snapshot file on the machine.
+	// +optional
+	Location string `json:"location,omitempty"`
+	// MachineName is the name of the machine the snapshot is stored on.
+	MachineName string `json:"machineName,omitempty"`
+	// Uploaded is true once the snapshot was uploaded.
+	// +optional
+	Uploaded bool `json:"uploaded,omitempty"`
+}
+
+// ETCDSnapshotBeforeChange is a policy that creates an etcd snapshot and waits for it to complete before a change of the
+// Kubernetes version or of selected configuration is applied to the cluster.
+type ETCDSnapshotBeforeChange struct {
+	// Enabled enables the creation of etcd snapshots before changes.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// ConfigKeys is a list of machineGlobalConfig keys whose changes trigger an etcd snapshot, in addition to changes of
+	// the Kubernetes version.
+	// +optional
+	ConfigKeys []string `json:"configKeys,omitempty"`
+	// Name is the prefix used for the name of the snapshots. Defaults to pre-change.
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Target is the storage the snapshots are saved to, and can be local, s3, or both.
+	// +optional
+	Target ETCDSnapshotTarget `json:"target,omitempty"`
+}
+
+// ETCDSnapshotBeforeChangeStatus records the etcd snapshot that was created before the most recent change.
+type ETCDSnapshotBeforeChangeStatus struct {
+	// Hash is the hash of the Kubernetes version and selected configuration of the change the snapshot was created for.
+	Hash string `json:"hash,omitempty"`
+	// Generation is the generation of the etcd snapshot creation that was triggered for the change.
+	Generation int `json:"generation,omitempty"`
+	// SnapshotName is the name the snapshot was saved with, which the distro suffixes with the node name and creation
+	// timestamp for every snapshot file. It is set once the snapshot was created.
+	SnapshotName string `json:"snapshotName,omitempty"`
+}
+
+type ETCDMaintenancePhase string
+
+const (
+	ETCDMaintenancePhaseStarted    ETCDMaintenancePhase = "Started"
+	ETCDMaintenancePhaseDefragment ETCDMaintenancePhase = "Defragment"
+	ETCDMaintenancePhaseFinished   ETCDMaintenancePhase = "Finished"
+	ETCDMaintenancePhaseFailed     ETCDMaintenancePhase = "Failed"
+)
+
+// ETCDMaintenance defragments the etcd members of the cluster one at a time to reclaim the space of the etcd database
+// that is fragmented by deleted and compacted revisions. k3s embeds etcd, so etcdctl must be installed on the etcd
+// machines of k3s clusters, the etcd maintenance fails otherwise.
+type ETCDMaintenance struct {
+	// Changing the Generation is the only thing required to initiate an etcd maintenance.
+	Generation int `json:"generation,omitempty"`
+	// Compact compacts the etcd keyspace to the current revision before the first member is defragmented, which discards
+	// the history of all keys.
+	// +optional
+	Compact bool `json:"compact,omitempty"`
+}
+
+// ETCDMaintenanceMember is the result of the etcd maintenance of a single etcd member.
+type ETCDMaintenanceMember struct {
+	MachineName string `json:"machineName,omitempty"`
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+	// DBSizeBefore is the size of the etcd database of the member in bytes before it was defragmented.
+	// +optional
+	DBSizeBefore int64 `json:"dbSizeBefore,omitempty"`
+	// DBSizeAfter is the size of the etcd database of the member in bytes after it was defragmented.
+	// +optional
+	DBSizeAfter int64 `json:"dbSizeAfter,omitempty"`
+	// Defragmented is true once the member was defragmented and its etcd probe passed.
+	// +optional
+	Defragmented bool `json:"defragmented,omitempty"`
+}
+
+// ETCDMemberStatus is the status of an etcd member as reported by etcdctl endpoint status on its machine.
+type ETCDMemberStatus struct {
+	MachineName string `json:"machineName,omitempty"`
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+	// MemberID is the hexadecimal ID of the etcd member.
+	// +optional
+	MemberID string `json:"memberID,omitempty"`
+	// Leader is true if the member is the leader of the etcd cluster.
+	// +optional
+	Leader bool `json:"leader,omitempty"`
+	// +optional
+	IsLearner bool `json:"isLearner,omitempty"`
+	// +optional
+	Version string `json:"version,omitempty"`
+	// DBSize is the size of the etcd database of the member in bytes.
+	// +optional
+	DBSize int64 `json:"dbSize,omitempty"`
+	// DBSizeInUse is the size of the etcd database of the member in bytes that is in use, the remainder can be reclaimed
+	// by defragmenting the member.
+	// +optional
+	DBSizeInUse int64 `json:"dbSizeInUse,omitempty"`
+	// +optional
+	Revision int64 `json:"revision,omitempty"`
+	// +optional
+	RaftTerm int64 `json:"raftTerm,omitempty"`
+	// +optional
+	RaftIndex int64 `json:"raftIndex,omitempty"`
+	// Alarms are the alarms that are raised in the etcd cluster, such as NOSPACE or CORRUPT.
+	// +optional
+	Alarms []string `json:"alarms,omitempty"`
+	// Errors are the errors that were reported for the member, including the alarms, or the reason the status of the
+	// member could not be collected.
+	// +optional
+	Errors []string `json:"errors,omitempty"`
+	// LastUpdateTime is the time the status of the member was last collected.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
 }
 
 type ETCDSnapshotRestore struct {
@@ -41,6 +231,74 @@ type ETCDSnapshotRestore struct {
 	RestoreRKEConfig string `json:"restoreRKEConfig,omitempty"`
 }
 
+// ETCDSnapshotBootstrap describes an etcd snapshot that a new cluster is restored from when it is created. The etcd
+// member list of the snapshot is reset, and nodes of the cluster the snapshot was taken from are removed.
+type ETCDSnapshotBootstrap struct {
+	// Name refers to the name of an S3 etcdsnapshot object in the namespace of the controlplane, which may belong to
+	// another cluster.
+	// +optional
+	Name string `json:"name,omitempty"`
+	// SnapshotName is the file name of a snapshot in S3, and is required if Name is empty.
+	// +optional
+	SnapshotName string `json:"snapshotName,omitempty"`
+	// S3 is the location of the snapshot referenced by SnapshotName. If empty, the S3 configuration of the cluster is
+	// used.
+	// +optional
+	S3 *ETCDSnapshotS3 `json:"s3,omitempty"`
+	// TokenSecretName is the name of a secret in the namespace of the controlplane that contains the serverToken and
+	// optionally the agentToken of the cluster the snapshot was taken from, which are required to decrypt the bootstrap
+	// data of the snapshot. If empty, the state secret of the cluster referenced by the etcdsnapshot object is used.
+	// +optional
+	TokenSecretName string `json:"tokenSecretName,omitempty"`
+	// RotateToken rotates the server token of the restored cluster to a newly generated token, instead of retaining the
+	// server token of the cluster the snapshot was taken from. The agent token is always retained.
+	// +optional
+	RotateToken bool `json:"rotateToken,omitempty"`
+}
+
+type ETCDDisasterRecoveryPhase string
+
+const (
+	ETCDDisasterRecoveryPhaseProvision ETCDDisasterRecoveryPhase = "Provision"
+	ETCDDisasterRecoveryPhaseRestore   ETCDDisasterRecoveryPhase = "Restore"
+	ETCDDisasterRecoveryPhaseRejoin    ETCDDisasterRecoveryPhase = "Rejoin"
+	ETCDDisasterRecoveryPhaseFinished  ETCDDisasterRecoveryPhase = "Finished"
+	ETCDDisasterRecoveryPhaseFailed    ETCDDisasterRecoveryPhase = "Failed"
+)
+
+// ETCDDisasterRecovery recovers the etcd plane of an initialized cluster from the newest S3 etcd snapshot if every etcd
+// machine of the cluster was lost.
+type ETCDDisasterRecovery struct {
+	// Enabled enables the automated recovery of the etcd plane. A failed recovery is not retried until it was disabled
+	// and enabled again.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// ETCDDisasterRecoveryStatus records the progress of the most recent recovery of the etcd plane.
+type ETCDDisasterRecoveryStatus struct {
+	Phase ETCDDisasterRecoveryPhase `json:"phase,omitempty"`
+	// SnapshotName is the name of the etcdsnapshot object that is restored.
+	// +optional
+	SnapshotName string `json:"snapshotName,omitempty"`
+	// RejectedSnapshotNames are the names of the etcdsnapshot objects whose restore was refused during validation.
+	// +optional
+	RejectedSnapshotNames []string `json:"rejectedSnapshotNames,omitempty"`
+	// RestoreGeneration is the generation of the etcd snapshot restore that was requested for the recovery.
+	// +optional
+	RestoreGeneration int `json:"restoreGeneration,omitempty"`
+	// Steps are the steps of the recovery in the order they were taken.
+	// +optional
+	Steps []ETCDDisasterRecoveryStep `json:"steps,omitempty"`
+}
+
+// ETCDDisasterRecoveryStep is a single step of the recovery of the etcd plane.
+type ETCDDisasterRecoveryStep struct {
+	Phase   ETCDDisasterRecoveryPhase `json:"phase,omitempty"`
+	Message string                    `json:"message,omitempty"`
+	Time    metav1.Time               `json:"time,omitempty"`
+}
+
 // +genclient
 // +kubebuilder:subresource:status
 // +kubebuilder:metadata:labels=cluster.x-k8s.io/v1beta1=v1
@@ -74,9 +332,31 @@ type ETCDSnapshotStatus struct {
 	Missing bool `json:"missing"`
 }
 
+// +kubebuilder:validation:XValidation:rule="!has(self.s3Targets) || !has(self.s3) || !has(self.s3.useInstanceCredentials) || !self.s3.useInstanceCredentials || self.s3Targets.all(t, has(t.cloudCredentialName))",message="S3 targets can not inherit the instance credentials of the S3 configuration, cloudCredentialName must be set for every S3 target"
+// +kubebuilder:validation:XValidation:rule="!has(self.snapshotEncryption) || !has(self.s3) || (has(self.disableSnapshots) && self.disableSnapshots)",message="the scheduled snapshots of the distro can not be encrypted, disableSnapshots must be set to upload encrypted snapshots to S3"
 type ETCD struct {
 	DisableSnapshots     bool            `json:"disableSnapshots,omitempty"`
 	SnapshotScheduleCron string          `json:"snapshotScheduleCron,omitempty"`
 	SnapshotRetention    int             `json:"snapshotRetention,omitempty"`
 	S3                   *ETCDSnapshotS3 `json:"s3,omitempty"`
+	// S3Targets are additional S3 locations that etcd snapshots created through the controlplane are uploaded to.
+	// +optional
+	S3Targets []ETCDSnapshotS3Target `json:"s3Targets,omitempty"`
+	// SnapshotEncryption encrypts etcd snapshots on the machines before they are uploaded to S3. As the distro can not
+	// encrypt snapshots, S3 is not configured for the distro while it is set, and only the snapshots that are created or
+	// uploaded through the controlplane are uploaded to S3. The scheduled snapshots of the distro must be disabled while
+	// it is set together with S3, as they would silently stay local; an ETCDSnapshotSchedule creates encrypted snapshots
+	// on a schedule instead.
+	// +optional
+	SnapshotEncryption *ETCDSnapshotEncryption `json:"snapshotEncryption,omitempty"`
+}
+
+// ETCDSnapshotEncryption configures the client-side encryption of etcd snapshots that are uploaded to S3.
+type ETCDSnapshotEncryption struct {
+	// SecretName is the name of a secret in the namespace of the controlplane that contains the encryption key in the
+	// key "key", and optionally its identifier in the key "keyID". If no identifier is set, the key is identified by the
+	// first 16 characters of the hex encoded SHA-256 hash of the key. The identifier is recorded in the metadata of every
+	// encrypted snapshot, and an encrypted snapshot can only be restored with the key of the same identifier. Encrypted
+	// snapshots are authenticated with a HMAC derived from the key, and are not decrypted if the HMAC does not match.
+	SecretName string `json:"secretName"`
 }
@@ -0,0 +1,54 @@
+package v1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// +genclient
+// +kubebuilder:subresource:status
+// +kubebuilder:metadata:labels=cluster.x-k8s.io/v1beta1=v1
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ETCDSnapshotSchedule periodically creates etcd snapshots of a cluster through the etcd snapshot creation of the
+// corresponding RKEControlPlane, and prunes the snapshots that were created by it.
+type ETCDSnapshotSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              ETCDSnapshotScheduleSpec   `json:"spec"`
+	Status            ETCDSnapshotScheduleStatus `json:"status,omitempty"`
+}
+
+type ETCDSnapshotScheduleSpec struct {
+	// ClusterName is the name of the RKEControlPlane in the same namespace to create snapshots for.
+	ClusterName string `json:"clusterName" wrangler:"required"`
+	// Schedule is the standard cron expression that determines when snapshots are created.
+	Schedule string `json:"schedule" wrangler:"required"`
+	// Retention is the number of snapshots created by this schedule to retain on each node and in S3. If 0, snapshots
+	// are not pruned.
+	// +optional
+	Retention int `json:"retention,omitempty"`
+	// Target is the storage snapshots are saved to, and can be local, s3, or both. If empty, snapshots are saved
+	// according to the etcd configuration of the cluster.
+	// +optional
+	Target ETCDSnapshotTarget `json:"target,omitempty"`
+	// NamePrefix is the prefix used for the name of the snapshots. Defaults to the name of the schedule.
+	// +optional
+	NamePrefix string `json:"namePrefix,omitempty"`
+	// S3Targets are the names of the S3 targets of the etcd configuration of the cluster snapshots are uploaded to. If
+	// empty, snapshots are uploaded to all targets.
+	// +optional
+	S3Targets []string `json:"s3Targets,omitempty"`
+}
+
+type ETCDSnapshotScheduleStatus struct {
+	// ActiveGeneration is the generation of the etcd snapshot creation that was triggered by this schedule and has not
+	// finished yet.
+	// +optional
+	ActiveGeneration int `json:"activeGeneration,omitempty"`
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// +optional
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+	// +optional
+	LastFailureMessage string `json:"lastFailureMessage,omitempty"`
+}
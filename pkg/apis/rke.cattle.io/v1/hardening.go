@@ -0,0 +1,19 @@
+package v1
+
+const (
+	HardeningProfileCIS = "cis"
+)
+
+// HardeningProfile hardens the cluster according to a security benchmark. The planner manages the node prerequisites of
+// the profile, applies and validates the kernel parameters of each machine before the distro is started with the profile, and
+// enforces the restricted pod security standard through pod security admission.
+type HardeningProfile struct {
+	// Profile is the security benchmark the cluster is hardened for. rke2 is started with the CIS profile that is expected
+	// by its version.
+	// +kubebuilder:validation:Enum=cis
+	Profile string `json:"profile"`
+	// ExemptNamespaces are namespaces that are exempt from the restricted pod security standard in addition to the
+	// system namespaces of the distro and Rancher.
+	// +optional
+	ExemptNamespaces []string `json:"exemptNamespaces,omitempty"`
+}
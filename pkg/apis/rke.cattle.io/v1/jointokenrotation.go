@@ -0,0 +1,24 @@
+package v1
+
+type RotateJoinTokenPhase string
+
+const (
+	RotateJoinTokenPhasePrepare        RotateJoinTokenPhase = "Prepare"
+	RotateJoinTokenPhaseRotate         RotateJoinTokenPhase = "Rotate"
+	RotateJoinTokenPhaseRestartServers RotateJoinTokenPhase = "RestartServers"
+	RotateJoinTokenPhaseRestartAgents  RotateJoinTokenPhase = "RestartAgents"
+	RotateJoinTokenPhaseDone           RotateJoinTokenPhase = "Done"
+	RotateJoinTokenPhaseFailed         RotateJoinTokenPhase = "Failed"
+)
+
+// RotateJoinToken replaces the server and agent token of the cluster. The server token is rotated through the token
+// rotate command of the distro, and the machines are restarted with the new tokens without being drained. The previous
+// tokens can no longer be used to join machines to the cluster. The tokens that were replaced by the last rotations are
+// recorded, so that an etcd snapshot that was taken before a rotation is restored with the tokens it was taken with. The
+// join token is rotated back to the current tokens after such a restore, which is recorded in status with the next
+// generation that a later rotation must exceed. Etcd snapshots that were taken with tokens that are no longer recorded
+// can not be restored.
+type RotateJoinToken struct {
+	// Changing the Generation is the only thing required to initiate a join token rotation.
+	Generation int64 `json:"generation,omitempty"`
+}
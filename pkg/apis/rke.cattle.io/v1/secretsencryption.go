@@ -0,0 +1,38 @@
+package v1
+
+// SecretsEncryptionKMS encrypts secrets with a KMS v2 plugin instead of the encryption keys that are managed by the
+// distro. Secrets encryption of the distro is disabled while it is set, and must not be enabled with the
+// secrets-encryption setting. The KMS provider is added in front of the providers the distro used before, so that secrets
+// that were encrypted by the distro or not encrypted at all remain readable. The encryption keys of KMS-backed clusters
+// are rotated by the KMS, an encryption key rotation of the controlplane reencrypts all secrets with the current key of
+// the KMS.
+type SecretsEncryptionKMS struct {
+	// Name is the name of the KMS provider in the encryption configuration of the kube-apiserver. It must not be changed
+	// once secrets were encrypted with the provider.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// Endpoint is the unix socket of the KMS plugin on the controlplane machines, e.g. unix:///var/run/kmsplugin/socket.sock.
+	// +kubebuilder:validation:Pattern=`^unix:///.+`
+	Endpoint string `json:"endpoint"`
+	// TimeoutSeconds is the timeout of the calls of the kube-apiserver to the KMS plugin. If 0, 3 seconds are used.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// StaticPod is the manifest of a static pod that runs the KMS plugin on the controlplane machines.
+	// +optional
+	StaticPod string `json:"staticPod,omitempty"`
+	// SystemdUnit is the unit file of a systemd service that runs the KMS plugin on the controlplane machines.
+	// +optional
+	SystemdUnit string `json:"systemdUnit,omitempty"`
+}
+
+type SecretsEncryptionPhase string
+
+const (
+	SecretsEncryptionPhaseToggle               SecretsEncryptionPhase = "Toggle"
+	SecretsEncryptionPhasePostToggleRestart    SecretsEncryptionPhase = "PostToggleRestart"
+	SecretsEncryptionPhaseReencrypt            SecretsEncryptionPhase = "Reencrypt"
+	SecretsEncryptionPhasePostReencryptRestart SecretsEncryptionPhase = "PostReencryptRestart"
+	SecretsEncryptionPhaseDone                 SecretsEncryptionPhase = "Done"
+	SecretsEncryptionPhaseFailed               SecretsEncryptionPhase = "Failed"
+)
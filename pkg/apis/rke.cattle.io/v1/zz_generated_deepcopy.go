@@ -22,6 +22,7 @@ limitations under the License.
 package v1
 
 import (
+	plan "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	genericcondition "github.com/rancher/wrangler/v3/pkg/genericcondition"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,6 +31,64 @@ import (
 	v1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditLog) DeepCopyInto(out *AuditLog) {
+	*out = *in
+	if in.PolicyConfigMap != nil {
+		in, out := &in.PolicyConfigMap, &out.PolicyConfigMap
+		*out = new(AuditPolicyConfigMap)
+		**out = **in
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(AuditWebhook)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditLog.
+func (in *AuditLog) DeepCopy() *AuditLog {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditLog)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditPolicyConfigMap) DeepCopyInto(out *AuditPolicyConfigMap) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditPolicyConfigMap.
+func (in *AuditPolicyConfigMap) DeepCopy() *AuditPolicyConfigMap {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditPolicyConfigMap)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditWebhook) DeepCopyInto(out *AuditWebhook) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditWebhook.
+func (in *AuditWebhook) DeepCopy() *AuditWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AuthConfig) DeepCopyInto(out *AuthConfig) {
 	*out = *in
@@ -46,6 +105,38 @@ func (in *AuthConfig) DeepCopy() *AuthConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateAuthorities) DeepCopyInto(out *CertificateAuthorities) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateAuthorities.
+func (in *CertificateAuthorities) DeepCopy() *CertificateAuthorities {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateAuthorities)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateExpiry) DeepCopyInto(out *CertificateExpiry) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateExpiry.
+func (in *CertificateExpiry) DeepCopy() *CertificateExpiry {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateExpiry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterUpgradeStrategy) DeepCopyInto(out *ClusterUpgradeStrategy) {
 	*out = *in
@@ -238,6 +329,16 @@ func (in *ETCD) DeepCopyInto(out *ETCD) {
 		*out = new(ETCDSnapshotS3)
 		**out = **in
 	}
+	if in.S3Targets != nil {
+		in, out := &in.S3Targets, &out.S3Targets
+		*out = make([]ETCDSnapshotS3Target, len(*in))
+		copy(*out, *in)
+	}
+	if in.SnapshotEncryption != nil {
+		in, out := &in.SnapshotEncryption, &out.SnapshotEncryption
+		*out = new(ETCDSnapshotEncryption)
+		**out = **in
+	}
 	return
 }
 
@@ -251,6 +352,129 @@ func (in *ETCD) DeepCopy() *ETCD {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDDisasterRecovery) DeepCopyInto(out *ETCDDisasterRecovery) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDDisasterRecovery.
+func (in *ETCDDisasterRecovery) DeepCopy() *ETCDDisasterRecovery {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDDisasterRecovery)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDDisasterRecoveryStatus) DeepCopyInto(out *ETCDDisasterRecoveryStatus) {
+	*out = *in
+	if in.RejectedSnapshotNames != nil {
+		in, out := &in.RejectedSnapshotNames, &out.RejectedSnapshotNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]ETCDDisasterRecoveryStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDDisasterRecoveryStatus.
+func (in *ETCDDisasterRecoveryStatus) DeepCopy() *ETCDDisasterRecoveryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDDisasterRecoveryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDDisasterRecoveryStep) DeepCopyInto(out *ETCDDisasterRecoveryStep) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDDisasterRecoveryStep.
+func (in *ETCDDisasterRecoveryStep) DeepCopy() *ETCDDisasterRecoveryStep {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDDisasterRecoveryStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDMaintenance) DeepCopyInto(out *ETCDMaintenance) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDMaintenance.
+func (in *ETCDMaintenance) DeepCopy() *ETCDMaintenance {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDMaintenance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDMaintenanceMember) DeepCopyInto(out *ETCDMaintenanceMember) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDMaintenanceMember.
+func (in *ETCDMaintenanceMember) DeepCopy() *ETCDMaintenanceMember {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDMaintenanceMember)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDMemberStatus) DeepCopyInto(out *ETCDMemberStatus) {
+	*out = *in
+	if in.Alarms != nil {
+		in, out := &in.Alarms, &out.Alarms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDMemberStatus.
+func (in *ETCDMemberStatus) DeepCopy() *ETCDMemberStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDMemberStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ETCDSnapshot) DeepCopyInto(out *ETCDSnapshot) {
 	*out = *in
@@ -280,9 +504,72 @@ func (in *ETCDSnapshot) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotBeforeChange) DeepCopyInto(out *ETCDSnapshotBeforeChange) {
+	*out = *in
+	if in.ConfigKeys != nil {
+		in, out := &in.ConfigKeys, &out.ConfigKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotBeforeChange.
+func (in *ETCDSnapshotBeforeChange) DeepCopy() *ETCDSnapshotBeforeChange {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotBeforeChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotBeforeChangeStatus) DeepCopyInto(out *ETCDSnapshotBeforeChangeStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotBeforeChangeStatus.
+func (in *ETCDSnapshotBeforeChangeStatus) DeepCopy() *ETCDSnapshotBeforeChangeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotBeforeChangeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotBootstrap) DeepCopyInto(out *ETCDSnapshotBootstrap) {
+	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(ETCDSnapshotS3)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotBootstrap.
+func (in *ETCDSnapshotBootstrap) DeepCopy() *ETCDSnapshotBootstrap {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotBootstrap)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ETCDSnapshotCreate) DeepCopyInto(out *ETCDSnapshotCreate) {
 	*out = *in
+	if in.S3Targets != nil {
+		in, out := &in.S3Targets, &out.S3Targets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -291,129 +578,342 @@ func (in *ETCDSnapshotCreate) DeepCopy() *ETCDSnapshotCreate {
 	if in == nil {
 		return nil
 	}
-	out := new(ETCDSnapshotCreate)
+	out := new(ETCDSnapshotCreate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotEncryption) DeepCopyInto(out *ETCDSnapshotEncryption) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotEncryption.
+func (in *ETCDSnapshotEncryption) DeepCopy() *ETCDSnapshotEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotFile) DeepCopyInto(out *ETCDSnapshotFile) {
+	*out = *in
+	if in.CreatedAt != nil {
+		in, out := &in.CreatedAt, &out.CreatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(ETCDSnapshotS3)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotFile.
+func (in *ETCDSnapshotFile) DeepCopy() *ETCDSnapshotFile {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotList) DeepCopyInto(out *ETCDSnapshotList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ETCDSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotList.
+func (in *ETCDSnapshotList) DeepCopy() *ETCDSnapshotList {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ETCDSnapshotList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotRestore) DeepCopyInto(out *ETCDSnapshotRestore) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotRestore.
+func (in *ETCDSnapshotRestore) DeepCopy() *ETCDSnapshotRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotS3) DeepCopyInto(out *ETCDSnapshotS3) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotS3.
+func (in *ETCDSnapshotS3) DeepCopy() *ETCDSnapshotS3 {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotS3)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotS3Target) DeepCopyInto(out *ETCDSnapshotS3Target) {
+	*out = *in
+	out.ETCDSnapshotS3 = in.ETCDSnapshotS3
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotS3Target.
+func (in *ETCDSnapshotS3Target) DeepCopy() *ETCDSnapshotS3Target {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotS3Target)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotSchedule) DeepCopyInto(out *ETCDSnapshotSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotSchedule.
+func (in *ETCDSnapshotSchedule) DeepCopy() *ETCDSnapshotSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ETCDSnapshotSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotScheduleList) DeepCopyInto(out *ETCDSnapshotScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ETCDSnapshotSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotScheduleList.
+func (in *ETCDSnapshotScheduleList) DeepCopy() *ETCDSnapshotScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ETCDSnapshotScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotScheduleSpec) DeepCopyInto(out *ETCDSnapshotScheduleSpec) {
+	*out = *in
+	if in.S3Targets != nil {
+		in, out := &in.S3Targets, &out.S3Targets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotScheduleSpec.
+func (in *ETCDSnapshotScheduleSpec) DeepCopy() *ETCDSnapshotScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ETCDSnapshotScheduleStatus) DeepCopyInto(out *ETCDSnapshotScheduleStatus) {
+	*out = *in
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessTime != nil {
+		in, out := &in.LastSuccessTime, &out.LastSuccessTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotScheduleStatus.
+func (in *ETCDSnapshotScheduleStatus) DeepCopy() *ETCDSnapshotScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ETCDSnapshotScheduleStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ETCDSnapshotFile) DeepCopyInto(out *ETCDSnapshotFile) {
+func (in *ETCDSnapshotSpec) DeepCopyInto(out *ETCDSnapshotSpec) {
 	*out = *in
-	if in.CreatedAt != nil {
-		in, out := &in.CreatedAt, &out.CreatedAt
-		*out = (*in).DeepCopy()
-	}
-	if in.S3 != nil {
-		in, out := &in.S3, &out.S3
-		*out = new(ETCDSnapshotS3)
-		**out = **in
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotFile.
-func (in *ETCDSnapshotFile) DeepCopy() *ETCDSnapshotFile {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotSpec.
+func (in *ETCDSnapshotSpec) DeepCopy() *ETCDSnapshotSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ETCDSnapshotFile)
+	out := new(ETCDSnapshotSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ETCDSnapshotList) DeepCopyInto(out *ETCDSnapshotList) {
+func (in *ETCDSnapshotStatus) DeepCopyInto(out *ETCDSnapshotStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]ETCDSnapshot, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotList.
-func (in *ETCDSnapshotList) DeepCopy() *ETCDSnapshotList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotStatus.
+func (in *ETCDSnapshotStatus) DeepCopy() *ETCDSnapshotStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ETCDSnapshotList)
+	out := new(ETCDSnapshotStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ETCDSnapshotList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ETCDSnapshotRestore) DeepCopyInto(out *ETCDSnapshotRestore) {
+func (in *ETCDSnapshotUpload) DeepCopyInto(out *ETCDSnapshotUpload) {
 	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotRestore.
-func (in *ETCDSnapshotRestore) DeepCopy() *ETCDSnapshotRestore {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotUpload.
+func (in *ETCDSnapshotUpload) DeepCopy() *ETCDSnapshotUpload {
 	if in == nil {
 		return nil
 	}
-	out := new(ETCDSnapshotRestore)
+	out := new(ETCDSnapshotUpload)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ETCDSnapshotS3) DeepCopyInto(out *ETCDSnapshotS3) {
+func (in *ETCDSnapshotUploadFile) DeepCopyInto(out *ETCDSnapshotUploadFile) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotS3.
-func (in *ETCDSnapshotS3) DeepCopy() *ETCDSnapshotS3 {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotUploadFile.
+func (in *ETCDSnapshotUploadFile) DeepCopy() *ETCDSnapshotUploadFile {
 	if in == nil {
 		return nil
 	}
-	out := new(ETCDSnapshotS3)
+	out := new(ETCDSnapshotUploadFile)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ETCDSnapshotSpec) DeepCopyInto(out *ETCDSnapshotSpec) {
+func (in *EncryptionKeyRotationPolicy) DeepCopyInto(out *EncryptionKeyRotationPolicy) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotSpec.
-func (in *ETCDSnapshotSpec) DeepCopy() *ETCDSnapshotSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EncryptionKeyRotationPolicy.
+func (in *EncryptionKeyRotationPolicy) DeepCopy() *EncryptionKeyRotationPolicy {
 	if in == nil {
 		return nil
 	}
-	out := new(ETCDSnapshotSpec)
+	out := new(EncryptionKeyRotationPolicy)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ETCDSnapshotStatus) DeepCopyInto(out *ETCDSnapshotStatus) {
+func (in *EncryptionKeyRotationRecord) DeepCopyInto(out *EncryptionKeyRotationRecord) {
 	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ETCDSnapshotStatus.
-func (in *ETCDSnapshotStatus) DeepCopy() *ETCDSnapshotStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EncryptionKeyRotationRecord.
+func (in *EncryptionKeyRotationRecord) DeepCopy() *EncryptionKeyRotationRecord {
 	if in == nil {
 		return nil
 	}
-	out := new(ETCDSnapshotStatus)
+	out := new(EncryptionKeyRotationRecord)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -444,6 +944,27 @@ func (in *GenericMap) DeepCopy() *GenericMap {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardeningProfile) DeepCopyInto(out *HardeningProfile) {
+	*out = *in
+	if in.ExemptNamespaces != nil {
+		in, out := &in.ExemptNamespaces, &out.ExemptNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardeningProfile.
+func (in *HardeningProfile) DeepCopy() *HardeningProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(HardeningProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K8sObjectFileSource) DeepCopyInto(out *K8sObjectFileSource) {
 	*out = *in
@@ -484,6 +1005,11 @@ func (in *KeyToPath) DeepCopy() *KeyToPath {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LocalClusterAuthEndpoint) DeepCopyInto(out *LocalClusterAuthEndpoint) {
 	*out = *in
+	if in.OIDC != nil {
+		in, out := &in.OIDC, &out.OIDC
+		*out = new(LocalClusterAuthEndpointOIDC)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -497,6 +1023,56 @@ func (in *LocalClusterAuthEndpoint) DeepCopy() *LocalClusterAuthEndpoint {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalClusterAuthEndpointOIDC) DeepCopyInto(out *LocalClusterAuthEndpointOIDC) {
+	*out = *in
+	if in.RequiredClaims != nil {
+		in, out := &in.RequiredClaims, &out.RequiredClaims
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalClusterAuthEndpointOIDC.
+func (in *LocalClusterAuthEndpointOIDC) DeepCopy() *LocalClusterAuthEndpointOIDC {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalClusterAuthEndpointOIDC)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineCertificateExpiry) DeepCopyInto(out *MachineCertificateExpiry) {
+	*out = *in
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]ServiceCertificateExpiry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineCertificateExpiry.
+func (in *MachineCertificateExpiry) DeepCopy() *MachineCertificateExpiry {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineCertificateExpiry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Mirror) DeepCopyInto(out *Mirror) {
 	*out = *in
@@ -836,6 +1412,13 @@ func (in *RKEClusterSpecCommon) DeepCopyInto(out *RKEClusterSpecCommon) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.MachineSelectorProbes != nil {
+		in, out := &in.MachineSelectorProbes, &out.MachineSelectorProbes
+		*out = make([]RKEProvisioningProbes, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Registries != nil {
 		in, out := &in.Registries, &out.Registries
 		*out = new(Registry)
@@ -1009,27 +1592,92 @@ func (in *RKEControlPlaneSpec) DeepCopyInto(out *RKEControlPlaneSpec) {
 		*out = make([]EnvVar, len(*in))
 		copy(*out, *in)
 	}
-	out.LocalClusterAuthEndpoint = in.LocalClusterAuthEndpoint
+	in.LocalClusterAuthEndpoint.DeepCopyInto(&out.LocalClusterAuthEndpoint)
 	if in.ETCDSnapshotCreate != nil {
 		in, out := &in.ETCDSnapshotCreate, &out.ETCDSnapshotCreate
 		*out = new(ETCDSnapshotCreate)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.ETCDSnapshotRestore != nil {
 		in, out := &in.ETCDSnapshotRestore, &out.ETCDSnapshotRestore
 		*out = new(ETCDSnapshotRestore)
 		**out = **in
 	}
+	if in.ETCDSnapshotUpload != nil {
+		in, out := &in.ETCDSnapshotUpload, &out.ETCDSnapshotUpload
+		*out = new(ETCDSnapshotUpload)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ETCDSnapshotBeforeChange != nil {
+		in, out := &in.ETCDSnapshotBeforeChange, &out.ETCDSnapshotBeforeChange
+		*out = new(ETCDSnapshotBeforeChange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ETCDMaintenance != nil {
+		in, out := &in.ETCDMaintenance, &out.ETCDMaintenance
+		*out = new(ETCDMaintenance)
+		**out = **in
+	}
+	if in.ETCDSnapshotBootstrap != nil {
+		in, out := &in.ETCDSnapshotBootstrap, &out.ETCDSnapshotBootstrap
+		*out = new(ETCDSnapshotBootstrap)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ETCDDisasterRecovery != nil {
+		in, out := &in.ETCDDisasterRecovery, &out.ETCDDisasterRecovery
+		*out = new(ETCDDisasterRecovery)
+		**out = **in
+	}
 	if in.RotateCertificates != nil {
 		in, out := &in.RotateCertificates, &out.RotateCertificates
 		*out = new(RotateCertificates)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CertificateExpiry != nil {
+		in, out := &in.CertificateExpiry, &out.CertificateExpiry
+		*out = new(CertificateExpiry)
+		**out = **in
+	}
+	if in.CertificateAuthorities != nil {
+		in, out := &in.CertificateAuthorities, &out.CertificateAuthorities
+		*out = new(CertificateAuthorities)
+		**out = **in
+	}
+	if in.RotateCertificateAuthorities != nil {
+		in, out := &in.RotateCertificateAuthorities, &out.RotateCertificateAuthorities
+		*out = new(RotateCertificateAuthorities)
+		**out = **in
+	}
+	if in.RotateJoinToken != nil {
+		in, out := &in.RotateJoinToken, &out.RotateJoinToken
+		*out = new(RotateJoinToken)
+		**out = **in
+	}
 	if in.RotateEncryptionKeys != nil {
 		in, out := &in.RotateEncryptionKeys, &out.RotateEncryptionKeys
 		*out = new(RotateEncryptionKeys)
 		**out = **in
 	}
+	if in.EncryptionKeyRotationPolicy != nil {
+		in, out := &in.EncryptionKeyRotationPolicy, &out.EncryptionKeyRotationPolicy
+		*out = new(EncryptionKeyRotationPolicy)
+		**out = **in
+	}
+	if in.SecretsEncryptionKMS != nil {
+		in, out := &in.SecretsEncryptionKMS, &out.SecretsEncryptionKMS
+		*out = new(SecretsEncryptionKMS)
+		**out = **in
+	}
+	if in.HardeningProfile != nil {
+		in, out := &in.HardeningProfile, &out.HardeningProfile
+		*out = new(HardeningProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AuditLog != nil {
+		in, out := &in.AuditLog, &out.AuditLog
+		*out = new(AuditLog)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Replicas != nil {
 		in, out := &in.Replicas, &out.Replicas
 		*out = new(int32)
@@ -1068,11 +1716,54 @@ func (in *RKEControlPlaneStatus) DeepCopyInto(out *RKEControlPlaneStatus) {
 		*out = make([]genericcondition.GenericCondition, len(*in))
 		copy(*out, *in)
 	}
+	if in.CertificateRotationTime != nil {
+		in, out := &in.CertificateRotationTime, &out.CertificateRotationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CertificateExpiry != nil {
+		in, out := &in.CertificateExpiry, &out.CertificateExpiry
+		*out = make([]MachineCertificateExpiry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CertificateExpiryRotation != nil {
+		in, out := &in.CertificateExpiryRotation, &out.CertificateExpiryRotation
+		*out = new(RotateCertificates)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RotateCertificateAuthorities != nil {
+		in, out := &in.RotateCertificateAuthorities, &out.RotateCertificateAuthorities
+		*out = new(RotateCertificateAuthorities)
+		**out = **in
+	}
+	if in.RotateJoinToken != nil {
+		in, out := &in.RotateJoinToken, &out.RotateJoinToken
+		*out = new(RotateJoinToken)
+		**out = **in
+	}
 	if in.RotateEncryptionKeys != nil {
 		in, out := &in.RotateEncryptionKeys, &out.RotateEncryptionKeys
 		*out = new(RotateEncryptionKeys)
 		**out = **in
 	}
+	if in.RotateEncryptionKeysHistory != nil {
+		in, out := &in.RotateEncryptionKeysHistory, &out.RotateEncryptionKeysHistory
+		*out = make([]EncryptionKeyRotationRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ScheduledRotateEncryptionKeys != nil {
+		in, out := &in.ScheduledRotateEncryptionKeys, &out.ScheduledRotateEncryptionKeys
+		*out = new(RotateEncryptionKeys)
+		**out = **in
+	}
+	if in.SecretsEncryptionEnabled != nil {
+		in, out := &in.SecretsEncryptionEnabled, &out.SecretsEncryptionEnabled
+		*out = new(bool)
+		**out = **in
+	}
 	if in.ETCDSnapshotRestore != nil {
 		in, out := &in.ETCDSnapshotRestore, &out.ETCDSnapshotRestore
 		*out = new(ETCDSnapshotRestore)
@@ -1081,8 +1772,45 @@ func (in *RKEControlPlaneStatus) DeepCopyInto(out *RKEControlPlaneStatus) {
 	if in.ETCDSnapshotCreate != nil {
 		in, out := &in.ETCDSnapshotCreate, &out.ETCDSnapshotCreate
 		*out = new(ETCDSnapshotCreate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ETCDSnapshotUpload != nil {
+		in, out := &in.ETCDSnapshotUpload, &out.ETCDSnapshotUpload
+		*out = new(ETCDSnapshotUpload)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ETCDSnapshotUploadFiles != nil {
+		in, out := &in.ETCDSnapshotUploadFiles, &out.ETCDSnapshotUploadFiles
+		*out = make([]ETCDSnapshotUploadFile, len(*in))
+		copy(*out, *in)
+	}
+	if in.ETCDSnapshotBeforeChange != nil {
+		in, out := &in.ETCDSnapshotBeforeChange, &out.ETCDSnapshotBeforeChange
+		*out = new(ETCDSnapshotBeforeChangeStatus)
+		**out = **in
+	}
+	if in.ETCDMaintenance != nil {
+		in, out := &in.ETCDMaintenance, &out.ETCDMaintenance
+		*out = new(ETCDMaintenance)
 		**out = **in
 	}
+	if in.ETCDMaintenanceMembers != nil {
+		in, out := &in.ETCDMaintenanceMembers, &out.ETCDMaintenanceMembers
+		*out = make([]ETCDMaintenanceMember, len(*in))
+		copy(*out, *in)
+	}
+	if in.ETCDMembers != nil {
+		in, out := &in.ETCDMembers, &out.ETCDMembers
+		*out = make([]ETCDMemberStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ETCDDisasterRecovery != nil {
+		in, out := &in.ETCDDisasterRecovery, &out.ETCDDisasterRecovery
+		*out = new(ETCDDisasterRecoveryStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Version != nil {
 		in, out := &in.Version, &out.Version
 		*out = new(string)
@@ -1155,6 +1883,34 @@ func (in *RKEProvisioningFiles) DeepCopy() *RKEProvisioningFiles {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RKEProvisioningProbes) DeepCopyInto(out *RKEProvisioningProbes) {
+	*out = *in
+	if in.MachineLabelSelector != nil {
+		in, out := &in.MachineLabelSelector, &out.MachineLabelSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = make(map[string]plan.Probe, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RKEProvisioningProbes.
+func (in *RKEProvisioningProbes) DeepCopy() *RKEProvisioningProbes {
+	if in == nil {
+		return nil
+	}
+	out := new(RKEProvisioningProbes)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RKESystemConfig) DeepCopyInto(out *RKESystemConfig) {
 	*out = *in
@@ -1270,6 +2026,22 @@ func (in *RolloutStrategy) DeepCopy() *RolloutStrategy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RotateCertificateAuthorities) DeepCopyInto(out *RotateCertificateAuthorities) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RotateCertificateAuthorities.
+func (in *RotateCertificateAuthorities) DeepCopy() *RotateCertificateAuthorities {
+	if in == nil {
+		return nil
+	}
+	out := new(RotateCertificateAuthorities)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RotateCertificates) DeepCopyInto(out *RotateCertificates) {
 	*out = *in
@@ -1306,3 +2078,52 @@ func (in *RotateEncryptionKeys) DeepCopy() *RotateEncryptionKeys {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RotateJoinToken) DeepCopyInto(out *RotateJoinToken) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RotateJoinToken.
+func (in *RotateJoinToken) DeepCopy() *RotateJoinToken {
+	if in == nil {
+		return nil
+	}
+	out := new(RotateJoinToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretsEncryptionKMS) DeepCopyInto(out *SecretsEncryptionKMS) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretsEncryptionKMS.
+func (in *SecretsEncryptionKMS) DeepCopy() *SecretsEncryptionKMS {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretsEncryptionKMS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceCertificateExpiry) DeepCopyInto(out *ServiceCertificateExpiry) {
+	*out = *in
+	in.ExpiryTime.DeepCopyInto(&out.ExpiryTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceCertificateExpiry.
+func (in *ServiceCertificateExpiry) DeepCopy() *ServiceCertificateExpiry {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceCertificateExpiry)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -30,6 +30,7 @@ import (
 var (
 	CustomMachineResourceName        = "custommachines"
 	ETCDSnapshotResourceName         = "etcdsnapshots"
+	ETCDSnapshotScheduleResourceName = "etcdsnapshotschedules"
 	RKEBootstrapResourceName         = "rkebootstraps"
 	RKEBootstrapTemplateResourceName = "rkebootstraptemplates"
 	RKEClusterResourceName           = "rkeclusters"
@@ -61,6 +62,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&CustomMachineList{},
 		&ETCDSnapshot{},
 		&ETCDSnapshotList{},
+		&ETCDSnapshotSchedule{},
+		&ETCDSnapshotScheduleList{},
 		&RKEBootstrap{},
 		&RKEBootstrapList{},
 		&RKEBootstrapTemplate{},
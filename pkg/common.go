@@ -52,6 +52,7 @@ const (
 	DrainDoneAnnotation                        = "rke.cattle.io/drain-done"
 	DrainErrorAnnotation                       = "rke.cattle.io/drain-error"
 	EtcdRoleLabel                              = "rke.cattle.io/etcd-role"
+	EtcdSnapshotS3TargetLabel                  = "rke.cattle.io/etcd-snapshot-s3-target"
 	ForceRemoveEtcdAnnotation                  = "rke.cattle.io/etcd-force-remove"
 	HostnameLengthLimitAnnotation              = "rke.cattle.io/hostname-length-limit"
 	InitNodeLabel                              = "rke.cattle.io/init-node"
@@ -111,6 +112,8 @@ const (
 	InfrastructureReady          = condition.Cond(capi.InfrastructureReadyCondition)
 	SystemUpgradeControllerReady = condition.Cond("SystemUpgradeControllerReady")
 	Bootstrapped                 = condition.Cond("Bootstrapped")
+	EtcdHealthy                  = condition.Cond("EtcdHealthy")
+	CertificatesExpiringSoon     = condition.Cond("CertificatesExpiringSoon")
 
 	RuntimeK3S  = "k3s"
 	RuntimeRKE2 = "rke2"
@@ -0,0 +1,174 @@
+package etcdsnapshotschedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	caprcontext "github.com/rancher/cluster-api-provider-rancher/pkg/context"
+	rkecontrollers "github.com/rancher/cluster-api-provider-rancher/pkg/generated/controllers/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/planner"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"github.com/robfig/cron"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// busyRequeueInterval is the interval after which a schedule is re-enqueued when the controlplane is busy with another
+// etcd snapshot creation.
+const busyRequeueInterval = 30 * time.Second
+
+type handler struct {
+	schedules          rkecontrollers.ETCDSnapshotScheduleController
+	schedulesCache     rkecontrollers.ETCDSnapshotScheduleCache
+	controlPlanes      rkecontrollers.RKEControlPlaneClient
+	controlPlanesCache rkecontrollers.RKEControlPlaneCache
+}
+
+func Register(wContext *caprcontext.Context) {
+	h := handler{
+		schedules:          wContext.RKE.ETCDSnapshotSchedule(),
+		schedulesCache:     wContext.RKE.ETCDSnapshotSchedule().Cache(),
+		controlPlanes:      wContext.RKE.RKEControlPlane(),
+		controlPlanesCache: wContext.RKE.RKEControlPlane().Cache(),
+	}
+	rkecontrollers.RegisterETCDSnapshotScheduleStatusHandler(wContext.Ctx, wContext.RKE.ETCDSnapshotSchedule(),
+		"", "etcd-snapshot-schedule", h.OnChange)
+	relatedresource.Watch(wContext.Ctx, "etcd-snapshot-schedule", h.controlPlaneWatch, wContext.RKE.ETCDSnapshotSchedule(), wContext.RKE.RKEControlPlane())
+}
+
+// controlPlaneWatch enqueues all schedules of a controlplane when the controlplane changes, so that the outcome of a
+// triggered etcd snapshot creation is observed.
+func (h *handler) controlPlaneWatch(namespace, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+	cp, ok := obj.(*rkev1.RKEControlPlane)
+	if !ok {
+		return nil, nil
+	}
+	schedules, err := h.schedulesCache.List(cp.Namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var keys []relatedresource.Key
+	for _, schedule := range schedules {
+		if schedule.Spec.ClusterName == cp.Name {
+			keys = append(keys, relatedresource.Key{
+				Namespace: schedule.Namespace,
+				Name:      schedule.Name,
+			})
+		}
+	}
+	return keys, nil
+}
+
+func (h *handler) OnChange(schedule *rkev1.ETCDSnapshotSchedule, status rkev1.ETCDSnapshotScheduleStatus) (rkev1.ETCDSnapshotScheduleStatus, error) {
+	if schedule == nil || !schedule.DeletionTimestamp.IsZero() {
+		return status, nil
+	}
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	if err != nil {
+		if msg := fmt.Sprintf("invalid schedule %s: %v", schedule.Spec.Schedule, err); status.LastFailureMessage != msg {
+			status = setFailure(status, msg)
+		}
+		return status, nil
+	}
+
+	cp, err := h.controlPlanesCache.Get(schedule.Namespace, schedule.Spec.ClusterName)
+	if apierrors.IsNotFound(err) {
+		logrus.Debugf("[etcdsnapshotschedule] %s/%s: controlplane %s not found", schedule.Namespace, schedule.Name, schedule.Spec.ClusterName)
+		return status, nil
+	} else if err != nil {
+		return status, err
+	}
+
+	if status.ActiveGeneration != 0 {
+		return h.reconcileActiveSnapshot(cp, status)
+	}
+
+	now := time.Now()
+	last := schedule.CreationTimestamp.Time
+	if status.LastScheduleTime != nil {
+		last = status.LastScheduleTime.Time
+	}
+	next := cronSchedule.Next(last)
+	if now.Before(next) {
+		h.schedules.EnqueueAfter(schedule.Namespace, schedule.Name, next.Sub(now))
+		return status, nil
+	}
+
+	if !cp.Status.Initialized || !capr.Bootstrapped.IsTrue(&cp.Status) {
+		logrus.Debugf("[etcdsnapshotschedule] %s/%s: skipping etcd snapshot as controlplane %s/%s is not initialized", schedule.Namespace, schedule.Name, cp.Namespace, cp.Name)
+		status.LastScheduleTime = &metav1.Time{Time: now}
+		h.schedules.EnqueueAfter(schedule.Namespace, schedule.Name, cronSchedule.Next(now).Sub(now))
+		return status, nil
+	}
+
+	if planner.ETCDSnapshotCreateInProgress(cp) {
+		logrus.Debugf("[etcdsnapshotschedule] %s/%s: controlplane %s/%s is busy with another etcd snapshot creation", schedule.Namespace, schedule.Name, cp.Namespace, cp.Name)
+		h.schedules.EnqueueAfter(schedule.Namespace, schedule.Name, busyRequeueInterval)
+		return status, nil
+	}
+
+	create := &rkev1.ETCDSnapshotCreate{
+		Generation: planner.ETCDSnapshotCreateGeneration(cp) + 1,
+		Name:       namePrefix(schedule),
+		Target:     schedule.Spec.Target,
+		Retention:  schedule.Spec.Retention,
+		S3Targets:  schedule.Spec.S3Targets,
+	}
+	cp = cp.DeepCopy()
+	cp.Spec.ETCDSnapshotCreate = create
+	if _, err := h.controlPlanes.Update(cp); err != nil {
+		return status, err
+	}
+
+	logrus.Infof("[etcdsnapshotschedule] %s/%s: triggered etcd snapshot creation with generation %d for controlplane %s/%s", schedule.Namespace, schedule.Name, create.Generation, cp.Namespace, cp.Name)
+	status.ActiveGeneration = create.Generation
+	status.LastScheduleTime = &metav1.Time{Time: now}
+	return status, nil
+}
+
+// reconcileActiveSnapshot records the outcome of the etcd snapshot creation that was triggered by the schedule. Snapshots
+// exceeding the retention of the schedule are pruned by the distro as part of the etcd snapshot creation, so that the
+// snapshot files are removed from the nodes and S3 along with their etcd snapshot objects.
+func (h *handler) reconcileActiveSnapshot(cp *rkev1.RKEControlPlane, status rkev1.ETCDSnapshotScheduleStatus) (rkev1.ETCDSnapshotScheduleStatus, error) {
+	if planner.ETCDSnapshotCreateGeneration(cp) != status.ActiveGeneration {
+		status.ActiveGeneration = 0
+		return setFailure(status, "etcd snapshot creation was superseded before it finished"), nil
+	}
+
+	if cp.Status.ETCDSnapshotCreate == nil || cp.Status.ETCDSnapshotCreate.Generation != status.ActiveGeneration {
+		// the planner has not picked up the etcd snapshot creation yet
+		return status, nil
+	}
+
+	switch cp.Status.ETCDSnapshotCreatePhase {
+	case rkev1.ETCDSnapshotPhaseFinished:
+		status.ActiveGeneration = 0
+		status.LastSuccessTime = &metav1.Time{Time: time.Now()}
+		return status, nil
+	case rkev1.ETCDSnapshotPhaseFailed:
+		status.ActiveGeneration = 0
+		return setFailure(status, fmt.Sprintf("etcd snapshot creation failed for controlplane %s/%s", cp.Namespace, cp.Name)), nil
+	}
+	return status, nil
+}
+
+// namePrefix returns the name prefix of the snapshots created by the schedule.
+func namePrefix(schedule *rkev1.ETCDSnapshotSchedule) string {
+	if schedule.Spec.NamePrefix != "" {
+		return schedule.Spec.NamePrefix
+	}
+	return schedule.Name
+}
+
+// setFailure records a failure with the given message in the status of the schedule.
+func setFailure(status rkev1.ETCDSnapshotScheduleStatus, message string) rkev1.ETCDSnapshotScheduleStatus {
+	status.LastFailureTime = &metav1.Time{Time: time.Now()}
+	status.LastFailureMessage = message
+	return status
+}
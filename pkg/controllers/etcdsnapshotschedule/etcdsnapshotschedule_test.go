@@ -0,0 +1,77 @@
+package etcdsnapshotschedule
+
+import (
+	"testing"
+
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcileActiveSnapshot(t *testing.T) {
+	tests := []struct {
+		name            string
+		spec            *rkev1.ETCDSnapshotCreate
+		status          *rkev1.ETCDSnapshotCreate
+		phase           rkev1.ETCDSnapshotPhase
+		expectedActive  int
+		expectedSuccess bool
+		expectedFailure bool
+	}{
+		{
+			name:            "superseded",
+			spec:            &rkev1.ETCDSnapshotCreate{Generation: 3},
+			expectedActive:  0,
+			expectedFailure: true,
+		},
+		{
+			name:           "pending",
+			spec:           &rkev1.ETCDSnapshotCreate{Generation: 2},
+			status:         &rkev1.ETCDSnapshotCreate{Generation: 1},
+			phase:          rkev1.ETCDSnapshotPhaseFinished,
+			expectedActive: 2,
+		},
+		{
+			name:           "running",
+			spec:           &rkev1.ETCDSnapshotCreate{Generation: 2},
+			status:         &rkev1.ETCDSnapshotCreate{Generation: 2},
+			phase:          rkev1.ETCDSnapshotPhaseRestartCluster,
+			expectedActive: 2,
+		},
+		{
+			name:            "finished",
+			spec:            &rkev1.ETCDSnapshotCreate{Generation: 2},
+			status:          &rkev1.ETCDSnapshotCreate{Generation: 2},
+			phase:           rkev1.ETCDSnapshotPhaseFinished,
+			expectedActive:  0,
+			expectedSuccess: true,
+		},
+		{
+			name:            "failed",
+			spec:            &rkev1.ETCDSnapshotCreate{Generation: 2},
+			status:          &rkev1.ETCDSnapshotCreate{Generation: 2},
+			phase:           rkev1.ETCDSnapshotPhaseFailed,
+			expectedActive:  0,
+			expectedFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{}
+			cp := &rkev1.RKEControlPlane{
+				Spec: rkev1.RKEControlPlaneSpec{
+					ETCDSnapshotCreate: tt.spec,
+				},
+				Status: rkev1.RKEControlPlaneStatus{
+					ETCDSnapshotCreate:      tt.status,
+					ETCDSnapshotCreatePhase: tt.phase,
+				},
+			}
+			status, err := h.reconcileActiveSnapshot(cp, rkev1.ETCDSnapshotScheduleStatus{ActiveGeneration: 2})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedActive, status.ActiveGeneration)
+			assert.Equal(t, tt.expectedSuccess, status.LastSuccessTime != nil)
+			assert.Equal(t, tt.expectedFailure, status.LastFailureTime != nil)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package plansecret
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	v1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/planner"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+)
+
+// opensslDateLayout is the layout of the dates that openssl x509 prints, e.g. "Jan  2 15:04:05 2006 GMT".
+const opensslDateLayout = "Jan _2 15:04:05 2006 MST"
+
+// certificateServices maps the names of the certificate files of the distro without their extension to the services
+// they are rotated with. The files of the controller and server services of the distro are mapped separately, as they
+// contain the name of the distro.
+var certificateServices = map[string]string{
+	"client-admin":            "admin",
+	"client-auth-proxy":       "auth-proxy",
+	"client-controller":       "controller-manager",
+	"client-kube-apiserver":   "api-server",
+	"serving-kube-apiserver":  "api-server",
+	"client-kube-proxy":       "kube-proxy",
+	"client-kubelet":          "kubelet",
+	"serving-kubelet":         "kubelet",
+	"client-scheduler":        "scheduler",
+	"kube-controller-manager": "controller-manager",
+	"kube-scheduler":          "scheduler",
+}
+
+// reconcileCertificateExpiry updates the expiry of the certificates of the machine of the plan secret in the status of
+// its controlplane, and the CertificatesExpiringSoon condition of the controlplane according to the expiry of the
+// certificates of all machines.
+func (h *handler) reconcileCertificateExpiry(secret *corev1.Secret, output plan.PeriodicInstructionOutput) error {
+	cnl := secret.Labels[capr.ClusterNameLabel]
+	if len(cnl) == 0 {
+		return fmt.Errorf("node secret did not have label %s", capr.ClusterNameLabel)
+	}
+
+	machineName, ok := secret.Labels[capr.MachineNameLabel]
+	if !ok {
+		return fmt.Errorf("did not find machine label on secret %s/%s", secret.Namespace, secret.Name)
+	}
+
+	machines, err := h.machinesCache.List(secret.Namespace, labels.SelectorFromSet(map[string]string{
+		capr.ClusterNameLabel: cnl,
+	}))
+	if err != nil {
+		return err
+	}
+
+	var nodeName string
+	clusterMachines := map[string]bool{}
+	for _, machine := range machines {
+		if machine.DeletionTimestamp != nil || machine.Status.NodeRef == nil {
+			continue
+		}
+		clusterMachines[machine.Name] = true
+		if machine.Name == machineName {
+			nodeName = machine.Status.NodeRef.Name
+		}
+	}
+
+	attempt := 0
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var cp *v1.RKEControlPlane
+		var err error
+		if attempt == 0 {
+			cp, err = h.controlPlaneCache.Get(secret.Namespace, cnl)
+		} else {
+			// the cached controlplane was outdated
+			cp, err = h.controlPlanes.Get(secret.Namespace, cnl, metav1.GetOptions{})
+		}
+		attempt++
+		if err != nil {
+			return err
+		}
+
+		expiry := outputToMachineCertificateExpiry(output, capr.GetRuntime(cp.Spec.KubernetesVersion))
+		expiry.MachineName = machineName
+		expiry.NodeName = nodeName
+
+		status := cp.Status.DeepCopy()
+		status.CertificateExpiry = mergeMachineCertificateExpiry(status.CertificateExpiry, expiry, clusterMachines)
+		window := planner.CertificateExpiryWindow(cp)
+		expiringMachines, expiringServices := planner.ExpiringCertificates(status.CertificateExpiry, status.CertificateRotationTime, window, time.Now())
+		if len(expiringServices) > 0 {
+			capr.CertificatesExpiringSoon.True(status)
+			capr.CertificatesExpiringSoon.Reason(status, "ExpiringSoon")
+			capr.CertificatesExpiringSoon.Message(status, fmt.Sprintf("certificates of [%s] on machines [%s] expire within %d days",
+				strings.Join(expiringServices, ", "), strings.Join(expiringMachines, ", "), int(window.Hours()/24)))
+		} else {
+			capr.CertificatesExpiringSoon.False(status)
+			capr.CertificatesExpiringSoon.Reason(status, "")
+			capr.CertificatesExpiringSoon.Message(status, "")
+		}
+
+		if equality.Semantic.DeepEqual(cp.Status, *status) {
+			return nil
+		}
+		logrus.Debugf("[plansecret] rkecontrolplane %s/%s: updating certificate expiry of machine %s", cp.Namespace, cp.Name, machineName)
+		cp = cp.DeepCopy()
+		cp.Status = *status
+		_, err = h.controlPlanes.UpdateStatus(cp)
+		return err
+	})
+}
+
+// outputToMachineCertificateExpiry converts the output of the certificate expiry instruction to the expiry of the
+// certificates of a machine, with the earliest expiry of the certificates of every service. If the instruction failed or
+// its output can not be parsed, the reason is recorded as the error of the machine.
+func outputToMachineCertificateExpiry(output plan.PeriodicInstructionOutput, runtime string) v1.MachineCertificateExpiry {
+	// the expiry is only considered up to date with the time of the last successful run, as it must not be mistaken for
+	// the expiry after a certificate rotation.
+	var expiry v1.MachineCertificateExpiry
+	if output.LastSuccessfulRunTime != "" {
+		if t, err := time.Parse(time.UnixDate, output.LastSuccessfulRunTime); err == nil {
+			expiry.LastUpdateTime = &metav1.Time{Time: t.UTC()}
+		}
+	}
+
+	if output.ExitCode != 0 {
+		expiry.Error = fmt.Sprintf("unable to collect certificate expiry (exit code %d): %s", output.ExitCode, strings.TrimSpace(string(output.Stderr)))
+		return expiry
+	}
+
+	services := map[string]metav1.Time{}
+	scanner := bufio.NewScanner(bytes.NewReader(output.Stdout))
+	for scanner.Scan() {
+		file, date, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "\t")
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(opensslDateLayout, strings.TrimSpace(date))
+		if err != nil {
+			expiry.Error = fmt.Sprintf("unable to parse expiry of certificate %s: %s", file, date)
+			continue
+		}
+		service := certificateService(runtime, file)
+		if current, ok := services[service]; !ok || t.Before(current.Time) {
+			services[service] = metav1.Time{Time: t.UTC()}
+		}
+	}
+
+	for service, t := range services {
+		expiry.Services = append(expiry.Services, v1.ServiceCertificateExpiry{
+			Service:    service,
+			ExpiryTime: t,
+		})
+	}
+	sort.Slice(expiry.Services, func(i, j int) bool {
+		return expiry.Services[i].Service < expiry.Services[j].Service
+	})
+	return expiry
+}
+
+// certificateService returns the service that the certificate file at the given path on a machine of the given distro
+// is rotated with, or the name of the file if it does not belong to a known service.
+func certificateService(runtime, file string) string {
+	name := strings.TrimSuffix(path.Base(file), ".crt")
+	if path.Base(path.Dir(file)) == "etcd" {
+		return "etcd"
+	}
+	if service, ok := certificateServices[name]; ok {
+		return service
+	}
+	switch name {
+	case "client-" + runtime + "-controller":
+		return runtime + "-controller"
+	case "client-" + runtime + "-cloud-controller":
+		return "cloud-controller"
+	case "client-supervisor", "serving-" + runtime:
+		return runtime + "-server"
+	}
+	return name
+}
+
+// mergeMachineCertificateExpiry replaces the certificate expiry of the same machine with the given expiry, and removes
+// the expiry of machines that are no longer part of the cluster. The result is sorted by machine name.
+func mergeMachineCertificateExpiry(expiries []v1.MachineCertificateExpiry, expiry v1.MachineCertificateExpiry, clusterMachines map[string]bool) []v1.MachineCertificateExpiry {
+	var result []v1.MachineCertificateExpiry
+	for _, e := range expiries {
+		if e.MachineName == expiry.MachineName || !clusterMachines[e.MachineName] {
+			continue
+		}
+		result = append(result, e)
+	}
+	if clusterMachines[expiry.MachineName] {
+		result = append(result, expiry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MachineName < result[j].MachineName
+	})
+	return result
+}
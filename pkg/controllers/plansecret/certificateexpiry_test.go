@@ -0,0 +1,62 @@
+package plansecret
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOutputToMachineCertificateExpiry(t *testing.T) {
+	output := plan.PeriodicInstructionOutput{
+		Stdout: []byte("/var/lib/rancher/rke2/server/tls/client-kube-apiserver.crt\tNov 14 22:13:20 2024 GMT\n" +
+			"/var/lib/rancher/rke2/server/tls/serving-kube-apiserver.crt\tNov  4 22:13:20 2024 GMT\n" +
+			"/var/lib/rancher/rke2/server/tls/etcd/peer-server-client.crt\tDec  1 00:00:00 2024 GMT\n" +
+			"/var/lib/rancher/rke2/agent/client-rke2-controller.crt\tDec  2 00:00:00 2024 GMT\n"),
+		LastSuccessfulRunTime: "Tue Nov 14 22:13:20 UTC 2023",
+	}
+
+	expiry := outputToMachineCertificateExpiry(output, "rke2")
+	assert.Empty(t, expiry.Error)
+	assert.True(t, expiry.LastUpdateTime.Equal(&metav1.Time{Time: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)}))
+	assert.Equal(t, []v1.ServiceCertificateExpiry{
+		{Service: "api-server", ExpiryTime: metav1.Time{Time: time.Date(2024, 11, 4, 22, 13, 20, 0, time.UTC)}},
+		{Service: "etcd", ExpiryTime: metav1.Time{Time: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)}},
+		{Service: "rke2-controller", ExpiryTime: metav1.Time{Time: time.Date(2024, 12, 2, 0, 0, 0, 0, time.UTC)}},
+	}, expiry.Services)
+
+	expiry = outputToMachineCertificateExpiry(plan.PeriodicInstructionOutput{ExitCode: 1, Stderr: []byte("openssl is required to collect the expiry of certificates\n")}, "rke2")
+	assert.Nil(t, expiry.LastUpdateTime)
+	assert.Equal(t, "unable to collect certificate expiry (exit code 1): openssl is required to collect the expiry of certificates", expiry.Error)
+}
+
+func TestCertificateService(t *testing.T) {
+	tests := map[string]string{
+		"/var/lib/rancher/k3s/server/tls/client-admin.crt":                                    "admin",
+		"/var/lib/rancher/k3s/server/tls/client-k3s-controller.crt":                           "k3s-controller",
+		"/var/lib/rancher/k3s/server/tls/client-k3s-cloud-controller.crt":                     "cloud-controller",
+		"/var/lib/rancher/k3s/server/tls/client-supervisor.crt":                               "k3s-server",
+		"/var/lib/rancher/k3s/server/tls/etcd/client.crt":                                     "etcd",
+		"/var/lib/rancher/k3s/server/tls/kube-scheduler/kube-scheduler.crt":                   "scheduler",
+		"/var/lib/rancher/k3s/server/tls/kube-controller-manager/kube-controller-manager.crt": "controller-manager",
+		"/var/lib/rancher/k3s/agent/serving-kubelet.crt":                                      "kubelet",
+		"/var/lib/rancher/k3s/server/tls/dynamic-cert.crt":                                    "dynamic-cert",
+	}
+	for file, expected := range tests {
+		assert.Equal(t, expected, certificateService("k3s", file), file)
+	}
+}
+
+func TestMergeMachineCertificateExpiry(t *testing.T) {
+	expiries := []v1.MachineCertificateExpiry{{MachineName: "b"}, {MachineName: "removed"}}
+	clusterMachines := map[string]bool{"a": true, "b": true}
+
+	merged := mergeMachineCertificateExpiry(expiries, v1.MachineCertificateExpiry{MachineName: "a", Error: "failed"}, clusterMachines)
+	assert.Equal(t, []v1.MachineCertificateExpiry{{MachineName: "a", Error: "failed"}, {MachineName: "b"}}, merged)
+
+	merged = mergeMachineCertificateExpiry(merged, v1.MachineCertificateExpiry{MachineName: "a"}, clusterMachines)
+	assert.Equal(t, []v1.MachineCertificateExpiry{{MachineName: "a"}, {MachineName: "b"}}, merged)
+}
@@ -0,0 +1,222 @@
+package plansecret
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	v1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/planner"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+)
+
+// etcdMemberStatusStaleAfter is the duration after which the status of an etcd member that was not collected again is
+// considered unhealthy.
+const etcdMemberStatusStaleAfter = 3 * planner.ETCDEndpointStatusPeriodSeconds * time.Second
+
+// etcdEndpointStatus is an entry of the JSON output of etcdctl endpoint status.
+type etcdEndpointStatus struct {
+	Endpoint string `json:"Endpoint"`
+	Status   struct {
+		Header struct {
+			MemberID uint64 `json:"member_id"`
+			Revision int64  `json:"revision"`
+		} `json:"header"`
+		Version     string   `json:"version"`
+		DBSize      int64    `json:"dbSize"`
+		DBSizeInUse int64    `json:"dbSizeInUse"`
+		Leader      uint64   `json:"leader"`
+		RaftIndex   int64    `json:"raftIndex"`
+		RaftTerm    int64    `json:"raftTerm"`
+		IsLearner   bool     `json:"isLearner"`
+		Errors      []string `json:"errors"`
+	} `json:"Status"`
+}
+
+// reconcileEtcdMemberStatus updates the status of the etcd member of the machine of the plan secret in the status of its
+// controlplane, and the EtcdHealthy condition of the controlplane according to the status of all etcd members.
+func (h *handler) reconcileEtcdMemberStatus(secret *corev1.Secret, output plan.PeriodicInstructionOutput) error {
+	cnl := secret.Labels[capr.ClusterNameLabel]
+	if len(cnl) == 0 {
+		return fmt.Errorf("node secret did not have label %s", capr.ClusterNameLabel)
+	}
+
+	machineName, ok := secret.Labels[capr.MachineNameLabel]
+	if !ok {
+		return fmt.Errorf("did not find machine label on secret %s/%s", secret.Namespace, secret.Name)
+	}
+
+	member := outputToEtcdMemberStatus(output)
+	member.MachineName = machineName
+
+	machines, err := h.machinesCache.List(secret.Namespace, labels.SelectorFromSet(map[string]string{
+		capr.ClusterNameLabel: cnl,
+		capr.EtcdRoleLabel:    "true",
+	}))
+	if err != nil {
+		return err
+	}
+
+	// etcdMachines are the names of the etcd machines that joined the cluster
+	etcdMachines := map[string]bool{}
+	for _, machine := range machines {
+		if machine.DeletionTimestamp != nil || machine.Status.NodeRef == nil {
+			continue
+		}
+		etcdMachines[machine.Name] = true
+		if machine.Name == machineName {
+			member.NodeName = machine.Status.NodeRef.Name
+		}
+	}
+
+	attempt := 0
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var cp *v1.RKEControlPlane
+		var err error
+		if attempt == 0 {
+			cp, err = h.controlPlaneCache.Get(secret.Namespace, cnl)
+		} else {
+			// the cached controlplane was outdated
+			cp, err = h.controlPlanes.Get(secret.Namespace, cnl, metav1.GetOptions{})
+		}
+		attempt++
+		if err != nil {
+			return err
+		}
+
+		status := cp.Status.DeepCopy()
+		status.ETCDMembers = mergeEtcdMemberStatus(status.ETCDMembers, member, etcdMachines)
+		healthy, message := etcdMembersHealth(status.ETCDMembers, len(etcdMachines), time.Now())
+		if healthy {
+			capr.EtcdHealthy.True(status)
+			capr.EtcdHealthy.Reason(status, "")
+		} else {
+			capr.EtcdHealthy.False(status)
+			capr.EtcdHealthy.Reason(status, "Unhealthy")
+		}
+		capr.EtcdHealthy.Message(status, message)
+
+		if equality.Semantic.DeepEqual(cp.Status, *status) {
+			return nil
+		}
+		logrus.Debugf("[plansecret] rkecontrolplane %s/%s: updating etcd member status of machine %s", cp.Namespace, cp.Name, machineName)
+		cp = cp.DeepCopy()
+		cp.Status = *status
+		_, err = h.controlPlanes.UpdateStatus(cp)
+		return err
+	})
+}
+
+// outputToEtcdMemberStatus converts the output of the etcd endpoint status instruction to the status of an etcd member. If
+// the instruction failed or its output can not be parsed, the reason is recorded as an error of the member.
+func outputToEtcdMemberStatus(output plan.PeriodicInstructionOutput) v1.ETCDMemberStatus {
+	member := v1.ETCDMemberStatus{
+		LastUpdateTime: &metav1.Time{Time: time.Now().UTC().Truncate(time.Second)},
+	}
+	if output.LastSuccessfulRunTime != "" {
+		if t, err := time.Parse(time.UnixDate, output.LastSuccessfulRunTime); err == nil {
+			member.LastUpdateTime = &metav1.Time{Time: t.UTC()}
+		}
+	}
+
+	if output.ExitCode != 0 {
+		member.Errors = []string{fmt.Sprintf("unable to collect etcd endpoint status (exit code %d): %s", output.ExitCode, strings.TrimSpace(string(output.Stderr)))}
+		return member
+	}
+
+	var statuses []etcdEndpointStatus
+	if err := json.Unmarshal(output.Stdout, &statuses); err != nil || len(statuses) == 0 {
+		member.Errors = []string{fmt.Sprintf("unable to parse etcd endpoint status: %s", strings.TrimSpace(string(output.Stdout)))}
+		return member
+	}
+
+	status := statuses[0].Status
+	member.MemberID = fmt.Sprintf("%x", status.Header.MemberID)
+	member.Leader = status.Leader != 0 && status.Leader == status.Header.MemberID
+	member.IsLearner = status.IsLearner
+	member.Version = status.Version
+	member.DBSize = status.DBSize
+	member.DBSizeInUse = status.DBSizeInUse
+	member.Revision = status.Header.Revision
+	member.RaftTerm = status.RaftTerm
+	member.RaftIndex = status.RaftIndex
+	member.Errors = status.Errors
+	for _, e := range status.Errors {
+		// alarms are reported as "memberID:<id> alarm:<type>"
+		if _, alarm, ok := strings.Cut(e, "alarm:"); ok {
+			member.Alarms = append(member.Alarms, strings.TrimSpace(alarm))
+		}
+	}
+	if status.Leader == 0 {
+		member.Errors = append(member.Errors, "etcd member has no leader")
+	}
+	return member
+}
+
+// mergeEtcdMemberStatus replaces the status of the etcd member of the same machine with the given status, and removes the
+// status of members whose machine is no longer an etcd machine of the cluster. The result is sorted by machine name.
+func mergeEtcdMemberStatus(members []v1.ETCDMemberStatus, member v1.ETCDMemberStatus, etcdMachines map[string]bool) []v1.ETCDMemberStatus {
+	var result []v1.ETCDMemberStatus
+	for _, m := range members {
+		if m.MachineName == member.MachineName || !etcdMachines[m.MachineName] {
+			continue
+		}
+		result = append(result, m)
+	}
+	if etcdMachines[member.MachineName] {
+		result = append(result, member)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MachineName < result[j].MachineName
+	})
+	return result
+}
+
+// etcdMembersHealth returns whether the etcd cluster is healthy, and a message describing why it isn't. The etcd cluster
+// is unhealthy if an alarm is raised, or if the quorum of the expected number of voting members is lost or would be lost
+// by the failure of another member. Members that reported errors, or whose status is stale or was not collected yet, are
+// considered unhealthy.
+func etcdMembersHealth(members []v1.ETCDMemberStatus, expected int, now time.Time) (bool, string) {
+	var alarms, unhealthy []string
+	var learners, healthyVoters int
+	for _, m := range members {
+		if len(m.Alarms) > 0 {
+			alarms = append(alarms, fmt.Sprintf("%s: %s", m.MachineName, strings.Join(m.Alarms, ",")))
+		}
+		if m.IsLearner {
+			learners++
+			continue
+		}
+		if len(m.Errors) > 0 || m.LastUpdateTime == nil || now.Sub(m.LastUpdateTime.Time) > etcdMemberStatusStaleAfter {
+			unhealthy = append(unhealthy, m.MachineName)
+			continue
+		}
+		healthyVoters++
+	}
+
+	if len(alarms) > 0 {
+		return false, fmt.Sprintf("etcd alarms are raised on [%s]", strings.Join(alarms, "; "))
+	}
+
+	voters := expected - learners
+	if voters <= 0 {
+		return true, ""
+	}
+	quorum := voters/2 + 1
+	if healthyVoters < quorum {
+		return false, fmt.Sprintf("etcd quorum is lost, %d of %d members are healthy, unhealthy members: [%s]", healthyVoters, voters, strings.Join(unhealthy, ", "))
+	}
+	if healthyVoters < voters && healthyVoters == quorum {
+		return false, fmt.Sprintf("etcd quorum is at risk, %d of %d members are healthy, unhealthy members: [%s]", healthyVoters, voters, strings.Join(unhealthy, ", "))
+	}
+	return true, ""
+}
@@ -0,0 +1,130 @@
+package plansecret
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOutputToEtcdMemberStatus(t *testing.T) {
+	output := plan.PeriodicInstructionOutput{
+		Stdout:                []byte(`[{"Endpoint":"https://127.0.0.1:2379","Status":{"header":{"cluster_id":14841639068965178418,"member_id":10276657743932975437,"revision":1042,"raft_term":3},"version":"3.5.9","dbSize":20480000,"leader":10276657743932975437,"raftIndex":2210,"raftTerm":3,"raftAppliedIndex":2210,"errors":["memberID:10276657743932975437 alarm:NOSPACE "],"dbSizeInUse":10240000}}]`),
+		LastSuccessfulRunTime: "Tue Nov 14 22:13:20 UTC 2023",
+	}
+
+	member := outputToEtcdMemberStatus(output)
+	assert.Equal(t, "8e9e05c52164694d", member.MemberID)
+	assert.True(t, member.Leader)
+	assert.Equal(t, "3.5.9", member.Version)
+	assert.Equal(t, int64(20480000), member.DBSize)
+	assert.Equal(t, int64(10240000), member.DBSizeInUse)
+	assert.Equal(t, int64(1042), member.Revision)
+	assert.Equal(t, int64(2210), member.RaftIndex)
+	assert.Equal(t, []string{"NOSPACE"}, member.Alarms)
+	assert.Len(t, member.Errors, 1)
+	assert.True(t, member.LastUpdateTime.Equal(&metav1.Time{Time: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)}))
+
+	member = outputToEtcdMemberStatus(plan.PeriodicInstructionOutput{ExitCode: 1, Stderr: []byte("context deadline exceeded\n")})
+	assert.Empty(t, member.MemberID)
+	assert.Equal(t, []string{"unable to collect etcd endpoint status (exit code 1): context deadline exceeded"}, member.Errors)
+
+	member = outputToEtcdMemberStatus(plan.PeriodicInstructionOutput{Stdout: []byte(`[{"Endpoint":"https://127.0.0.1:2379","Status":{"header":{"member_id":1},"leader":0}}]`)})
+	assert.False(t, member.Leader)
+	assert.Equal(t, []string{"etcd member has no leader"}, member.Errors)
+}
+
+func TestMergeEtcdMemberStatus(t *testing.T) {
+	members := []v1.ETCDMemberStatus{
+		{MachineName: "etcd-b", DBSize: 1},
+		{MachineName: "etcd-removed"},
+	}
+	etcdMachines := map[string]bool{"etcd-a": true, "etcd-b": true}
+
+	merged := mergeEtcdMemberStatus(members, v1.ETCDMemberStatus{MachineName: "etcd-a"}, etcdMachines)
+	assert.Equal(t, []v1.ETCDMemberStatus{{MachineName: "etcd-a"}, {MachineName: "etcd-b", DBSize: 1}}, merged)
+
+	merged = mergeEtcdMemberStatus(merged, v1.ETCDMemberStatus{MachineName: "etcd-b", DBSize: 2}, etcdMachines)
+	assert.Equal(t, []v1.ETCDMemberStatus{{MachineName: "etcd-a"}, {MachineName: "etcd-b", DBSize: 2}}, merged)
+}
+
+func TestEtcdMembersHealth(t *testing.T) {
+	now := time.Now()
+	healthy := func(name string) v1.ETCDMemberStatus {
+		return v1.ETCDMemberStatus{MachineName: name, LastUpdateTime: &metav1.Time{Time: now}}
+	}
+	failed := healthy("etcd-c")
+	failed.Errors = []string{"etcd member has no leader"}
+	stale := v1.ETCDMemberStatus{MachineName: "etcd-c", LastUpdateTime: &metav1.Time{Time: now.Add(-time.Hour)}}
+	alarm := healthy("etcd-a")
+	alarm.Alarms = []string{"NOSPACE"}
+	learner := healthy("etcd-d")
+	learner.IsLearner = true
+
+	tests := []struct {
+		name     string
+		members  []v1.ETCDMemberStatus
+		expected int
+		healthy  bool
+	}{
+		{
+			name:     "single member",
+			members:  []v1.ETCDMemberStatus{healthy("etcd-a")},
+			expected: 1,
+			healthy:  true,
+		},
+		{
+			name:     "all members healthy",
+			members:  []v1.ETCDMemberStatus{healthy("etcd-a"), healthy("etcd-b"), healthy("etcd-c")},
+			expected: 3,
+			healthy:  true,
+		},
+		{
+			name:     "quorum at risk",
+			members:  []v1.ETCDMemberStatus{healthy("etcd-a"), healthy("etcd-b"), failed},
+			expected: 3,
+			healthy:  false,
+		},
+		{
+			name:     "stale member",
+			members:  []v1.ETCDMemberStatus{healthy("etcd-a"), healthy("etcd-b"), stale},
+			expected: 3,
+			healthy:  false,
+		},
+		{
+			name:     "quorum lost",
+			members:  []v1.ETCDMemberStatus{healthy("etcd-a")},
+			expected: 3,
+			healthy:  false,
+		},
+		{
+			name:     "one of five members unhealthy",
+			members:  []v1.ETCDMemberStatus{healthy("etcd-a"), healthy("etcd-b"), failed, healthy("etcd-d"), healthy("etcd-e")},
+			expected: 5,
+			healthy:  true,
+		},
+		{
+			name:     "learner",
+			members:  []v1.ETCDMemberStatus{healthy("etcd-a"), learner},
+			expected: 2,
+			healthy:  true,
+		},
+		{
+			name:     "alarm",
+			members:  []v1.ETCDMemberStatus{alarm},
+			expected: 1,
+			healthy:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthy, message := etcdMembersHealth(tt.members, tt.expected, now)
+			assert.Equal(t, tt.healthy, healthy)
+			assert.Equal(t, tt.healthy, message == "")
+		})
+	}
+}
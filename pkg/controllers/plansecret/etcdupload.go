@@ -0,0 +1,133 @@
+package plansecret
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	v1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/planner"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reconcileEtcdSnapshotUpload creates the S3 etcd snapshot objects of the local snapshots that the running etcd snapshot
+// upload of the controlplane reported as uploaded from the machine of the plan secret, so that they can be restored
+// without waiting for the S3 location to be listed again. The listing still reconciles the snapshot files of the
+// created objects, and creates the objects that could not be created here. The identifier of the encryption key of
+// encrypted snapshots is taken from the upload instruction of the plan, as it is not known before the metadata of the
+// snapshots is listed.
+func (h *handler) reconcileEtcdSnapshotUpload(secret *corev1.Secret, nodePlan plan.NodePlan, output []byte) error {
+	cnl := secret.Labels[capr.ClusterNameLabel]
+	if len(cnl) == 0 {
+		return fmt.Errorf("node secret did not have label %s", capr.ClusterNameLabel)
+	}
+
+	machineName, ok := secret.Labels[capr.MachineNameLabel]
+	if !ok {
+		return fmt.Errorf("did not find machine label on secret %s/%s", secret.Namespace, secret.Name)
+	}
+
+	cp, err := h.controlPlaneCache.Get(secret.Namespace, cnl)
+	if err != nil {
+		return err
+	}
+	upload := cp.Spec.ETCDSnapshotUpload
+	if upload == nil || cp.Status.ETCDSnapshotUploadPhase != v1.ETCDSnapshotPhaseUpload || !equality.Semantic.DeepEqual(upload, cp.Status.ETCDSnapshotUpload) {
+		// the output of a previous upload may still be in the plan secret, and must not recreate snapshots that were
+		// removed from S3 since
+		return nil
+	}
+
+	s3Config, err := etcdSnapshotS3Config(cp, upload.S3Target)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := etcdSnapshotUploadMetadata(nodePlan)
+	if err != nil {
+		return err
+	}
+
+	uploaded := planner.ParseEtcdSnapshotUploadOutput(output)
+	for _, file := range cp.Status.ETCDSnapshotUploadFiles {
+		if file.MachineName != machineName || !uploaded[file.SnapshotName] {
+			continue
+		}
+		snapshot := newUploadedEtcdSnapshot(cnl, secret.Namespace, upload.S3Target, s3Config, file)
+		snapshot.SnapshotFile.Metadata = metadata
+		if _, err := h.etcdSnapshotsCache.Get(snapshot.Namespace, snapshot.Name); err == nil {
+			continue
+		} else if !apierrors.IsNotFound(err) {
+			return err
+		}
+		if local, err := h.etcdSnapshotsCache.Get(secret.Namespace, file.Name); err == nil {
+			snapshot.SnapshotFile.Size = local.SnapshotFile.Size
+			snapshot.SnapshotFile.CreatedAt = local.SnapshotFile.CreatedAt
+		} else if !apierrors.IsNotFound(err) {
+			return err
+		}
+		logrus.Debugf("[plansecret] creating uploaded S3 etcd snapshot %s/%s for cluster %s", snapshot.Namespace, snapshot.Name, cnl)
+		if _, err := h.etcdSnapshotsClient.Create(snapshot); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("error while creating etcd snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// newUploadedEtcdSnapshot returns the S3 etcd snapshot object of the given uploaded local snapshot, as it is created
+// when the S3 location is listed.
+func newUploadedEtcdSnapshot(clusterName, namespace, s3Target string, s3Config *v1.ETCDSnapshotS3, file v1.ETCDSnapshotUploadFile) *v1.ETCDSnapshot {
+	snapshot := &v1.ETCDSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      etcdSnapshotObjectName(clusterName, file.SnapshotName, true, s3Target),
+			Namespace: namespace,
+			Labels: map[string]string{
+				capr.ClusterNameLabel: clusterName,
+				capr.NodeNameLabel:    "s3",
+			},
+			Annotations: map[string]string{
+				SnapshotNameKey:      file.SnapshotName,
+				StorageAnnotationKey: StorageS3,
+			},
+		},
+		Spec: v1.ETCDSnapshotSpec{
+			ClusterName: clusterName,
+		},
+		SnapshotFile: v1.ETCDSnapshotFile{
+			Name:   file.SnapshotName,
+			Status: snapshotStatusSuccessful,
+			S3:     s3Config,
+		},
+	}
+	if s3Target != "" {
+		snapshot.Labels[capr.EtcdSnapshotS3TargetLabel] = s3Target
+	}
+	return snapshot
+}
+
+// etcdSnapshotUploadMetadata returns the base64 encoded JSON metadata with the identifier of the key the etcd snapshot
+// upload instruction of the given plan encrypts snapshots with, or an empty string if it does not encrypt them.
+func etcdSnapshotUploadMetadata(nodePlan plan.NodePlan) (string, error) {
+	for _, instruction := range nodePlan.Instructions {
+		if instruction.Name != planner.ETCDSnapshotUploadInstructionName {
+			continue
+		}
+		for _, env := range instruction.Env {
+			if k, v, _ := strings.Cut(env, "="); k == planner.ETCDSnapshotEncryptionKeyIDEnvVar && v != "" {
+				metadata, err := json.Marshal(map[string]string{planner.ETCDSnapshotEncryptionKeyIDMetadataKey: v})
+				if err != nil {
+					return "", err
+				}
+				return base64.StdEncoding.EncodeToString(metadata), nil
+			}
+		}
+	}
+	return "", nil
+}
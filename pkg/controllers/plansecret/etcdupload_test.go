@@ -0,0 +1,53 @@
+package plansecret
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	v1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/planner"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestNewUploadedEtcdSnapshot(t *testing.T) {
+	s3Config := &v1.ETCDSnapshotS3{Bucket: "offsite-bucket"}
+	file := v1.ETCDSnapshotUploadFile{
+		Name:         "cluster-on-demand-node1-1700000000-local",
+		SnapshotName: "on-demand-node1-1700000000",
+		MachineName:  "machine-1",
+	}
+
+	snapshot := newUploadedEtcdSnapshot("cluster", "fleet-default", "offsite", s3Config, file)
+
+	listed, err := outputToEtcdSnapshots("cluster", true, "offsite", []byte("on-demand-node1-1700000000 9035808 2023-11-14T22:13:20Z\n"))
+	assert.NoError(t, err)
+	assert.Contains(t, listed, snapshot.Name, "the uploaded snapshot must be named like the listed snapshot")
+	assert.Equal(t, "fleet-default", snapshot.Namespace)
+	assert.Equal(t, "offsite", snapshot.Labels[capr.EtcdSnapshotS3TargetLabel])
+	assert.Equal(t, "s3", snapshot.Labels[capr.NodeNameLabel])
+	assert.Equal(t, StorageS3, snapshot.Annotations[StorageAnnotationKey])
+	assert.Equal(t, "on-demand-node1-1700000000", snapshot.SnapshotFile.Name)
+	assert.Equal(t, s3Config, snapshot.SnapshotFile.S3)
+
+	selector, err := s3EtcdSnapshotSelector("cluster", "offsite")
+	assert.NoError(t, err)
+	assert.True(t, selector.Matches(labels.Set(snapshot.Labels)))
+}
+
+func TestEtcdSnapshotUploadMetadata(t *testing.T) {
+	nodePlan := plan.NodePlan{Instructions: []plan.OneTimeInstruction{
+		{Name: "create", Env: []string{"ETCD_SNAPSHOT_ENCRYPTION_KEY_ID=other"}},
+		{Name: planner.ETCDSnapshotUploadInstructionName, Env: []string{"ETCD_S3_BUCKET=backups", "ETCD_SNAPSHOT_ENCRYPTION_KEY_ID=2024-01"}},
+	}}
+	metadata, err := etcdSnapshotUploadMetadata(nodePlan)
+	assert.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(`{"encryption-key-id":"2024-01"}`)), metadata)
+
+	nodePlan.Instructions[1].Env = []string{"ETCD_S3_BUCKET=backups"}
+	metadata, err = etcdSnapshotUploadMetadata(nodePlan)
+	assert.NoError(t, err)
+	assert.Equal(t, "", metadata, "unencrypted uploads have no metadata")
+}
@@ -3,14 +3,18 @@ package plansecret
 import (
 	"bufio"
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	caprcontext "github.com/rancher/cluster-api-provider-rancher/pkg/context"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/rancher/cluster-api-provider-rancher/pkg"
 	v1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	capicontrollers "github.com/rancher/cluster-api-provider-rancher/pkg/generated/controllers/cluster.x-k8s.io/v1beta1"
 	rkev1controllers "github.com/rancher/cluster-api-provider-rancher/pkg/generated/controllers/rke.cattle.io/v1"
 	"github.com/rancher/cluster-api-provider-rancher/pkg/planner"
@@ -18,9 +22,12 @@ import (
 	"github.com/rancher/wrangler/v3/pkg/name"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
 )
@@ -32,6 +39,9 @@ const (
 	SnapshotBackpopulateReconciledKey = "etcdsnapshot.rke.io/snapshotbackpopulate-reconciled"
 	StorageS3                         = "s3"
 	StorageLocal                      = "local"
+
+	snapshotStatusSuccessful = "successful"
+	snapshotStatusFailed     = "failed"
 )
 
 var (
@@ -49,6 +59,8 @@ type handler struct {
 	clusterCache        capicontrollers.ClusterCache
 	etcdSnapshotsClient rkev1controllers.ETCDSnapshotClient
 	etcdSnapshotsCache  rkev1controllers.ETCDSnapshotCache
+	controlPlaneCache   rkev1controllers.RKEControlPlaneCache
+	controlPlanes       rkev1controllers.RKEControlPlaneClient
 }
 
 func Register(wContext *caprcontext.Context) {
@@ -59,6 +71,8 @@ func Register(wContext *caprcontext.Context) {
 		clusterCache:        wContext.CAPI.Cluster().Cache(),
 		etcdSnapshotsClient: wContext.RKE.ETCDSnapshot(),
 		etcdSnapshotsCache:  wContext.RKE.ETCDSnapshot().Cache(),
+		controlPlaneCache:   wContext.RKE.RKEControlPlane().Cache(),
+		controlPlanes:       wContext.RKE.RKEControlPlane(),
 	}
 	wContext.Core.Secret().OnChange(wContext.Ctx, "plan-secret", h.OnChange)
 }
@@ -76,17 +90,47 @@ func (h *handler) OnChange(key string, secret *corev1.Secret) (*corev1.Secret, e
 	}
 
 	if v, ok := node.PeriodicOutput["etcd-snapshot-list-local"]; ok && v.ExitCode == 0 && len(v.Stdout) > 0 {
-		if err := h.reconcileEtcdSnapshotList(secret, false, v.Stdout); err != nil {
+		if err := h.reconcileEtcdSnapshotList(secret, false, "", v.Stdout, periodicOutputTime(v)); err != nil {
 			logrus.Errorf("[plansecret] error reconciling local snapshot list for secret %s/%s: %v", secret.Namespace, secret.Name, err)
 		}
 	}
 
 	if v, ok := node.PeriodicOutput["etcd-snapshot-list-s3"]; ok && v.ExitCode == 0 && len(v.Stdout) > 0 && secret.Labels[capr.InitNodeLabel] == "true" {
-		if err := h.reconcileEtcdSnapshotList(secret, true, v.Stdout); err != nil {
+		if err := h.reconcileEtcdSnapshotList(secret, true, "", v.Stdout, periodicOutputTime(v)); err != nil {
 			logrus.Errorf("[plansecret] error reconciling S3 snapshot list for secret %s/%s: %v", secret.Namespace, secret.Name, err)
 		}
 	}
 
+	if secret.Labels[capr.InitNodeLabel] == "true" {
+		for k, v := range node.PeriodicOutput {
+			target, ok := strings.CutPrefix(k, planner.ETCDSnapshotListS3TargetInstructionPrefix)
+			if !ok || v.ExitCode != 0 || len(v.Stdout) == 0 {
+				continue
+			}
+			if err := h.reconcileEtcdSnapshotList(secret, true, target, v.Stdout, periodicOutputTime(v)); err != nil {
+				logrus.Errorf("[plansecret] error reconciling snapshot list of S3 target %s for secret %s/%s: %v", target, secret.Namespace, secret.Name, err)
+			}
+		}
+	}
+
+	if v, ok := node.Output[planner.ETCDSnapshotUploadInstructionName]; ok && len(v) > 0 {
+		if err := h.reconcileEtcdSnapshotUpload(secret, node.Plan, v); err != nil {
+			logrus.Errorf("[plansecret] error reconciling etcd snapshot upload for secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		}
+	}
+
+	if v, ok := node.PeriodicOutput[planner.ETCDEndpointStatusInstructionName]; ok {
+		if err := h.reconcileEtcdMemberStatus(secret, v); err != nil {
+			logrus.Errorf("[plansecret] error reconciling etcd member status for secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		}
+	}
+
+	if v, ok := node.PeriodicOutput[planner.CertificateExpiryInstructionName]; ok {
+		if err := h.reconcileCertificateExpiry(secret, v); err != nil {
+			logrus.Errorf("[plansecret] error reconciling certificate expiry for secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		}
+	}
+
 	appliedChecksum := string(secret.Data["applied-checksum"])
 	failedChecksum := string(secret.Data["failed-checksum"])
 	plan := secret.Data["plan"]
@@ -157,7 +201,7 @@ func (h *handler) reconcileMachinePlanAppliedCondition(secret *corev1.Secret, pl
 			!conditions.IsFalse(machine, condition) ||
 			conditions.GetReason(machine, condition) != "Error") {
 		logrus.Debugf("[plansecret] machine %s/%s: marking PlanApplied as false", machine.Namespace, machine.Name)
-		conditions.MarkFalse(machine, condition, "Error", capi.ConditionSeverityError, planAppliedErr.Error())
+		conditions.MarkFalse(machine, condition, "Error", capi.ConditionSeverityError, "%s", planAppliedErr.Error())
 		needsUpdate = true
 	} else if planAppliedErr == nil && !conditions.IsTrue(machine, condition) {
 		logrus.Debugf("[plansecret] machine %s/%s: marking PlanApplied as true", machine.Namespace, machine.Name)
@@ -184,7 +228,11 @@ func machineOwnerRef(machine capi.Machine) metav1.OwnerReference {
 	}
 }
 
-func (h *handler) reconcileEtcdSnapshotList(secret *corev1.Secret, s3 bool, listStdout []byte) error {
+// reconcileEtcdSnapshotList reconciles the etcd snapshot objects of the node of the plan secret, or of the S3 location of
+// the cluster if s3 is true, with the output of the etcd snapshot list command. If s3Target is set, the etcd snapshot
+// objects of the S3 target with that name are reconciled instead of the S3 location of the etcd configuration. Etcd
+// snapshot objects that were created after the snapshots were listed at listedAt are left alone until they are listed.
+func (h *handler) reconcileEtcdSnapshotList(secret *corev1.Secret, s3 bool, s3Target string, listStdout []byte, listedAt time.Time) error {
 	cnl := secret.Labels[capr.ClusterNameLabel]
 	if len(cnl) == 0 {
 		return fmt.Errorf("node secret did not have label %s", capr.ClusterNameLabel)
@@ -195,16 +243,24 @@ func (h *handler) reconcileEtcdSnapshotList(secret *corev1.Secret, s3 bool, list
 		return fmt.Errorf("did not find machine label on secret %s/%s", secret.Namespace, secret.Name)
 	}
 
-	ls := labels.SelectorFromSet(map[string]string{
-		capr.ClusterNameLabel: cnl,
-		capr.NodeNameLabel:    "s3",
-	})
+	ls, err := s3EtcdSnapshotSelector(cnl, s3Target)
+	if err != nil {
+		return err
+	}
 
 	var machine *capi.Machine
 	var machineID string
-	var err error
+	var s3Config *v1.ETCDSnapshotS3
 
-	if !s3 {
+	if s3 {
+		cp, err := h.controlPlaneCache.Get(secret.Namespace, cnl)
+		if err != nil {
+			return err
+		}
+		if s3Config, err = etcdSnapshotS3Config(cp, s3Target); err != nil {
+			return err
+		}
+	} else {
 		machine, err = h.machinesCache.Get(secret.Namespace, machineName)
 		if err != nil {
 			return err
@@ -220,7 +276,11 @@ func (h *handler) reconcileEtcdSnapshotList(secret *corev1.Secret, s3 bool, list
 		}
 	}
 
-	etcdSnapshotsOnNode := outputToEtcdSnapshots(cnl, listStdout)
+	etcdSnapshotsOnNode, err := outputToEtcdSnapshots(cnl, s3, s3Target, listStdout)
+	if err != nil {
+		// the existing etcd snapshots must not be marked missing because of an unparseable list
+		return fmt.Errorf("error parsing etcd snapshot list: %w", err)
+	}
 
 	etcdSnapshots, err := h.etcdSnapshotsCache.List(secret.Namespace, ls)
 	if err != nil {
@@ -231,68 +291,299 @@ func (h *handler) reconcileEtcdSnapshotList(secret *corev1.Secret, s3 bool, list
 	indexedEtcdSnapshots := map[string]*v1.ETCDSnapshot{}
 
 	for _, v := range etcdSnapshots {
-		if _, ok := etcdSnapshotsOnNode[v.Name]; !ok && v.Status.Missing {
-			// delete the etcd snapshot as it is missing
+		indexedEtcdSnapshots[v.Name] = v
+		ss, ok := etcdSnapshotsOnNode[v.Name]
+		if !ok && !listedAt.IsZero() && listedAt.Before(v.CreationTimestamp.Time) {
+			// the etcd snapshot object was created after the snapshots were listed, e.g. once it was uploaded
+			continue
+		}
+		if !ok && v.Status.Missing {
+			// delete the etcd snapshot as it was already marked missing and its file is still gone
 			logrus.Infof("[plansecret] Deleting etcd snapshot %s/%s", v.Namespace, v.Name)
-			if err := h.etcdSnapshotsClient.Delete(v.Namespace, v.Name, &metav1.DeleteOptions{}); err != nil {
+			if err := h.etcdSnapshotsClient.Delete(v.Namespace, v.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+		if !ok || v.Status.Missing {
+			// the file of the etcd snapshot disappeared or re-appeared
+			logrus.Debugf("[plansecret] marking etcd snapshot %s/%s missing: %t", v.Namespace, v.Name, !ok)
+			v = v.DeepCopy()
+			v.Status.Missing = !ok
+			if v, err = h.etcdSnapshotsClient.UpdateStatus(v); err != nil {
+				return err
+			}
+		}
+		if ok {
+			if err := h.updateEtcdSnapshotFile(v, ss); err != nil {
 				return err
 			}
 		}
-		indexedEtcdSnapshots[v.Name] = v
 	}
 
-	if !s3 && machine.Status.NodeRef != nil {
-		for k, v := range etcdSnapshotsOnNode {
-			if _, ok := indexedEtcdSnapshots[k]; !ok {
-				// create the etcdsnapshot object as it was not in the list of etcdsnapshots and not an S3 snapshot
-				snapshot := v1.ETCDSnapshot{
-					ObjectMeta: metav1.ObjectMeta{
-						Namespace: secret.Namespace,
-						Labels: map[string]string{
-							capr.ClusterNameLabel: cnl,
-							capr.MachineIDLabel:   machineID,
-						},
-						Annotations: map[string]string{
-							SnapshotNameKey:      v.Name,
-							StorageAnnotationKey: StorageLocal,
-						},
-						OwnerReferences: []metav1.OwnerReference{
-							machineOwnerRef(*machine),
-						},
-					},
-					Spec: v1.ETCDSnapshotSpec{
-						ClusterName: cnl,
-					},
-					SnapshotFile: v1.ETCDSnapshotFile{
-						Name:     v.Name,
-						Location: v.Location,
-						NodeName: machine.Status.NodeRef.Name,
-					},
-				}
-				snapshot.Name = name.SafeConcatName(cnl, snapshot.SnapshotFile.Name, StorageLocal)
-				logrus.Debugf("[plansecret] machine %s/%s: creating etcd snapshot %s for cluster %s", machine.Namespace, machine.Name, snapshot.Name, cnl)
-				_, err = h.etcdSnapshotsClient.Create(&snapshot)
-				if err != nil && !apierrors.IsAlreadyExists(err) {
-					return fmt.Errorf("error while creating etcd snapshot: %w", err)
-				}
+	if !s3 && machine.Status.NodeRef == nil {
+		return nil
+	}
+
+	for k, v := range etcdSnapshotsOnNode {
+		if _, ok := indexedEtcdSnapshots[k]; ok {
+			continue
+		}
+		// create the etcdsnapshot object as it was not in the list of etcdsnapshots
+		snapshot := v1.ETCDSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      k,
+				Namespace: secret.Namespace,
+				Annotations: map[string]string{
+					SnapshotNameKey: v.Name,
+				},
+			},
+			Spec: v1.ETCDSnapshotSpec{
+				ClusterName: cnl,
+			},
+			SnapshotFile: v.toSnapshotFile(),
+		}
+		if s3 {
+			snapshot.Labels = map[string]string{
+				capr.ClusterNameLabel: cnl,
+				capr.NodeNameLabel:    "s3",
+			}
+			if s3Target != "" {
+				snapshot.Labels[capr.EtcdSnapshotS3TargetLabel] = s3Target
 			}
+			snapshot.Annotations[StorageAnnotationKey] = StorageS3
+			snapshot.SnapshotFile.S3 = s3Config
+			logrus.Debugf("[plansecret] creating S3 etcd snapshot %s/%s for cluster %s", snapshot.Namespace, snapshot.Name, cnl)
+		} else {
+			snapshot.Labels = map[string]string{
+				capr.ClusterNameLabel: cnl,
+				capr.MachineIDLabel:   machineID,
+			}
+			snapshot.Annotations[StorageAnnotationKey] = StorageLocal
+			snapshot.OwnerReferences = []metav1.OwnerReference{
+				machineOwnerRef(*machine),
+			}
+			snapshot.SnapshotFile.NodeName = machine.Status.NodeRef.Name
+			logrus.Debugf("[plansecret] machine %s/%s: creating etcd snapshot %s for cluster %s", machine.Namespace, machine.Name, snapshot.Name, cnl)
+		}
+		_, err = h.etcdSnapshotsClient.Create(&snapshot)
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("error while creating etcd snapshot: %w", err)
 		}
 	}
 
 	return nil
 }
 
+// etcdSnapshotS3Config returns the S3 configuration of the etcd snapshot objects of the S3 target with the given name, or
+// of the S3 location of the etcd configuration if s3Target is empty.
+func etcdSnapshotS3Config(cp *v1.RKEControlPlane, s3Target string) (*v1.ETCDSnapshotS3, error) {
+	if s3Target == "" {
+		if cp.Spec.ETCD == nil || !planner.S3Enabled(cp.Spec.ETCD.S3) {
+			return nil, fmt.Errorf("S3 is not configured for controlplane %s/%s", cp.Namespace, cp.Name)
+		}
+		return cp.Spec.ETCD.S3.DeepCopy(), nil
+	}
+	target, ok := planner.S3Target(cp, s3Target)
+	if !ok {
+		return nil, fmt.Errorf("S3 target %s is not configured for controlplane %s/%s", s3Target, cp.Namespace, cp.Name)
+	}
+	s3Config := target.ETCDSnapshotS3.DeepCopy()
+	if s3Config.CloudCredentialName == "" && !s3Config.UseInstanceCredentials && cp.Spec.ETCD.S3 != nil {
+		// the target uses the cloud credential of the etcd configuration, which must be retained for restores
+		s3Config.CloudCredentialName = cp.Spec.ETCD.S3.CloudCredentialName
+	}
+	return s3Config, nil
+}
+
+// periodicOutputTime returns the time the given periodic instruction last ran successfully, or the zero time if it is
+// unknown.
+func periodicOutputTime(output plan.PeriodicInstructionOutput) time.Time {
+	if output.LastSuccessfulRunTime == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.UnixDate, output.LastSuccessfulRunTime)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// s3EtcdSnapshotSelector returns the selector of the S3 etcd snapshot objects of the given cluster that are stored in the
+// S3 target with the given name, or in the S3 location of the etcd configuration if s3Target is empty.
+func s3EtcdSnapshotSelector(clusterName, s3Target string) (labels.Selector, error) {
+	ls := labels.SelectorFromSet(map[string]string{
+		capr.ClusterNameLabel: clusterName,
+		capr.NodeNameLabel:    "s3",
+	})
+	op, values := selection.DoesNotExist, []string(nil)
+	if s3Target != "" {
+		op, values = selection.Equals, []string{s3Target}
+	}
+	target, err := labels.NewRequirement(capr.EtcdSnapshotS3TargetLabel, op, values)
+	if err != nil {
+		return nil, err
+	}
+	return ls.Add(*target), nil
+}
+
+// updateEtcdSnapshotFile updates the location, metadata, size, creation time, status, and message of the snapshot file of
+// an existing etcd snapshot object if they differ from the listed snapshot. The location and metadata are kept if they
+// were not listed.
+func (h *handler) updateEtcdSnapshotFile(etcdSnapshot *v1.ETCDSnapshot, ss *snapshot) error {
+	file := ss.toSnapshotFile()
+	if (file.Location == "" || etcdSnapshot.SnapshotFile.Location == file.Location) &&
+		(file.Metadata == "" || etcdSnapshot.SnapshotFile.Metadata == file.Metadata) &&
+		etcdSnapshot.SnapshotFile.Size == file.Size &&
+		etcdSnapshot.SnapshotFile.Status == file.Status &&
+		etcdSnapshot.SnapshotFile.Message == file.Message &&
+		equality.Semantic.DeepEqual(etcdSnapshot.SnapshotFile.CreatedAt, file.CreatedAt) {
+		return nil
+	}
+	etcdSnapshot = etcdSnapshot.DeepCopy()
+	if file.Location != "" {
+		etcdSnapshot.SnapshotFile.Location = file.Location
+	}
+	if file.Metadata != "" {
+		etcdSnapshot.SnapshotFile.Metadata = file.Metadata
+	}
+	etcdSnapshot.SnapshotFile.Size = file.Size
+	etcdSnapshot.SnapshotFile.Status = file.Status
+	etcdSnapshot.SnapshotFile.CreatedAt = file.CreatedAt
+	etcdSnapshot.SnapshotFile.Message = file.Message
+	_, err := h.etcdSnapshotsClient.Update(etcdSnapshot)
+	return err
+}
+
 type snapshot struct {
-	Name     string
-	Location string
-	Size     string
-	Created  string
-	S3       bool
+	Name      string
+	Location  string
+	Size      int64
+	CreatedAt *metav1.Time
+	S3        bool
+	Status    string
+	Message   string
+	// Metadata is the base64 encoded JSON of the metadata of the snapshot, e.g. the identifier of its encryption key.
+	Metadata string
 }
 
-func outputToEtcdSnapshots(clusterName string, collectedOutput []byte) map[string]*snapshot {
-	scanner := bufio.NewScanner(bytes.NewBuffer(collectedOutput))
+// toSnapshotFile converts the listed snapshot to an etcd snapshot file.
+func (s *snapshot) toSnapshotFile() v1.ETCDSnapshotFile {
+	return v1.ETCDSnapshotFile{
+		Name:      s.Name,
+		Location:  s.Location,
+		Size:      s.Size,
+		CreatedAt: s.CreatedAt,
+		Status:    s.Status,
+		Message:   s.Message,
+		Metadata:  s.Metadata,
+	}
+}
+
+// etcdSnapshotFileList is the subset of the JSON output of the etcd snapshot list command that is used to reconcile etcd
+// snapshots.
+type etcdSnapshotFileList struct {
+	Items []etcdSnapshotFile `json:"items"`
+}
+
+type etcdSnapshotFile struct {
+	Spec struct {
+		SnapshotName string            `json:"snapshotName"`
+		Location     string            `json:"location"`
+		Metadata     map[string]string `json:"metadata"`
+		S3           *json.RawMessage  `json:"s3"`
+	} `json:"spec"`
+	Status struct {
+		Size         *resource.Quantity `json:"size"`
+		CreationTime *metav1.Time       `json:"creationTime"`
+		ReadyToUse   *bool              `json:"readyToUse"`
+		Error        *struct {
+			Message *string `json:"message"`
+		} `json:"error"`
+	} `json:"status"`
+}
+
+// outputToEtcdSnapshots parses the output of the etcd snapshot list command into a map of snapshots keyed by the name of
+// their etcd snapshot object. Only snapshots that are stored in S3 are returned if s3 is true, and only local snapshots
+// otherwise. The names of the snapshots of an S3 target are suffixed with the name of the target, so that every copy of a
+// snapshot is tracked separately. JSON output is parsed if present, otherwise the column output of older distro versions
+// is parsed. An error is returned if the JSON output can not be parsed.
+func outputToEtcdSnapshots(clusterName string, s3 bool, s3Target string, collectedOutput []byte) (map[string]*snapshot, error) {
+	var listed []*snapshot
+	if trimmed := bytes.TrimSpace(collectedOutput); bytes.HasPrefix(trimmed, []byte("{")) {
+		var err error
+		if listed, err = jsonOutputToEtcdSnapshots(trimmed); err != nil {
+			return nil, err
+		}
+	} else {
+		listed = columnOutputToEtcdSnapshots(collectedOutput)
+	}
+
 	snapshots := make(map[string]*snapshot)
+	for _, ss := range listed {
+		if ss.S3 != s3 {
+			continue
+		}
+		snapshots[etcdSnapshotObjectName(clusterName, ss.Name, ss.S3, s3Target)] = ss
+	}
+	return snapshots, nil
+}
+
+// etcdSnapshotObjectName returns the name of the etcd snapshot object of the snapshot with the given file name. The
+// names of the snapshots of an S3 target are suffixed with the name of the target.
+func etcdSnapshotObjectName(clusterName, snapshotName string, s3 bool, s3Target string) string {
+	suffix := StorageLocal
+	if s3 {
+		suffix = StorageS3
+		if s3Target != "" {
+			suffix = StorageS3 + "-" + s3Target
+		}
+	}
+	return name.SafeConcatName(clusterName, strings.ToLower(InvalidKeyChars.ReplaceAllString(snapshotName, "-")), suffix)
+}
+
+func jsonOutputToEtcdSnapshots(collectedOutput []byte) ([]*snapshot, error) {
+	var list etcdSnapshotFileList
+	if err := json.Unmarshal(collectedOutput, &list); err != nil {
+		return nil, err
+	}
+	var snapshots []*snapshot
+	for _, item := range list.Items {
+		if item.Spec.SnapshotName == "" {
+			continue
+		}
+		ss := &snapshot{
+			Name:      item.Spec.SnapshotName,
+			Location:  item.Spec.Location,
+			CreatedAt: item.Status.CreationTime,
+			S3:        item.Spec.S3 != nil,
+			Status:    snapshotStatusSuccessful,
+		}
+		if item.Status.Size != nil {
+			ss.Size = item.Status.Size.Value()
+		}
+		if len(item.Spec.Metadata) > 0 {
+			metadata, err := json.Marshal(item.Spec.Metadata)
+			if err != nil {
+				return nil, err
+			}
+			ss.Metadata = base64.StdEncoding.EncodeToString(metadata)
+		}
+		if item.Status.ReadyToUse != nil && !*item.Status.ReadyToUse {
+			ss.Status = snapshotStatusFailed
+		}
+		if item.Status.Error != nil && item.Status.Error.Message != nil {
+			ss.Status = snapshotStatusFailed
+			ss.Message = *item.Status.Error.Message
+		}
+		snapshots = append(snapshots, ss)
+	}
+	return snapshots, nil
+}
+
+func columnOutputToEtcdSnapshots(collectedOutput []byte) []*snapshot {
+	scanner := bufio.NewScanner(bytes.NewBuffer(collectedOutput))
+	var snapshots []*snapshot
 	for scanner.Scan() {
 		line := scanner.Text()
 		if s := strings.Fields(line); len(s) == 3 || len(s) == 4 {
@@ -316,34 +607,41 @@ func outputToEtcdSnapshots(clusterName string, collectedOutput []byte) map[strin
 				logrus.Errorf("error parsing etcd snapshot output (%s) to etcd snapshot: %v", line, err)
 				continue
 			}
-			suffix := "local"
-			if ss.S3 {
-				suffix = "s3"
-			}
-			snapshots[fmt.Sprintf("%s-%s-%s", clusterName, ss.Name, suffix)] = ss
+			snapshots = append(snapshots, ss)
 		}
 	}
 	return snapshots
 }
 
+// generateEtcdSnapshotFromListOutput parses a line of the column output of the etcd snapshot list command. Listed
+// snapshots are always successful, and the size and creation time are left empty if they can't be parsed.
 func generateEtcdSnapshotFromListOutput(input string) (*snapshot, error) {
+	var ss *snapshot
+	var size, created string
 	snapshotData := strings.Fields(input)
 	switch len(snapshotData) {
 	case 3:
-		return &snapshot{
-			Name:    strings.ToLower(InvalidKeyChars.ReplaceAllString(snapshotData[0], "-")),
-			Size:    snapshotData[1],
-			Created: snapshotData[2],
-			S3:      true,
-		}, nil
+		ss = &snapshot{
+			Name: snapshotData[0],
+			S3:   true,
+		}
+		size, created = snapshotData[1], snapshotData[2]
 	case 4:
-		return &snapshot{
-			Name:     strings.ToLower(InvalidKeyChars.ReplaceAllString(snapshotData[0], "-")),
+		ss = &snapshot{
+			Name:     snapshotData[0],
 			Location: snapshotData[1],
-			Size:     snapshotData[2],
-			Created:  snapshotData[3],
 			S3:       false,
-		}, nil
+		}
+		size, created = snapshotData[2], snapshotData[3]
+	default:
+		return nil, fmt.Errorf("input (%s) did not have 3 or 4 fields", input)
+	}
+	ss.Status = snapshotStatusSuccessful
+	if parsed, err := strconv.ParseInt(size, 10, 64); err == nil {
+		ss.Size = parsed
+	}
+	if parsed, err := time.Parse(time.RFC3339, created); err == nil {
+		ss.CreatedAt = &metav1.Time{Time: parsed}
 	}
-	return nil, fmt.Errorf("input (%s) did not have 3 or 4 fields", input)
+	return ss, nil
 }
@@ -0,0 +1,167 @@
+package plansecret
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestOutputToEtcdSnapshots(t *testing.T) {
+	created := &metav1.Time{Time: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)}
+	tests := []struct {
+		name        string
+		s3          bool
+		s3Target    string
+		output      string
+		expected    map[string]*snapshot
+		expectedErr bool
+	}{
+		{
+			name: "local snapshots",
+			output: `Name                               Location                                                                               Size     Created
+on-demand-node1-1700000000         file:///var/lib/rancher/rke2/server/db/snapshots/on-demand-node1-1700000000         9035808  2023-11-14T22:13:20Z
+`,
+			expected: map[string]*snapshot{
+				"cluster-on-demand-node1-1700000000-local": {
+					Name:      "on-demand-node1-1700000000",
+					Location:  "file:///var/lib/rancher/rke2/server/db/snapshots/on-demand-node1-1700000000",
+					Size:      9035808,
+					CreatedAt: created,
+					Status:    snapshotStatusSuccessful,
+				},
+			},
+		},
+		{
+			name: "s3 snapshots",
+			s3:   true,
+			output: `Name                               Size     Created
+etcd-snapshot-Node1-1700000000     9035808  2023-11-14T22:13:20Z
+`,
+			expected: map[string]*snapshot{
+				"cluster-etcd-snapshot-node1-1700000000-s3": {
+					Name:      "etcd-snapshot-Node1-1700000000",
+					Size:      9035808,
+					CreatedAt: created,
+					S3:        true,
+					Status:    snapshotStatusSuccessful,
+				},
+			},
+		},
+		{
+			name:     "s3 target snapshots",
+			s3:       true,
+			s3Target: "offsite",
+			output: `Name                                       Size     Created
+on-demand-offsite-node1-1700000000         9035808  2023-11-14T22:13:20Z
+`,
+			expected: map[string]*snapshot{
+				"cluster-on-demand-offsite-node1-1700000000-s3-offsite": {
+					Name:      "on-demand-offsite-node1-1700000000",
+					Size:      9035808,
+					CreatedAt: created,
+					S3:        true,
+					Status:    snapshotStatusSuccessful,
+				},
+			},
+		},
+		{
+			name: "json snapshots",
+			s3:   true,
+			output: `{
+  "kind": "List",
+  "apiVersion": "v1",
+  "metadata": {},
+  "items": [
+    {
+      "kind": "ETCDSnapshotFile",
+      "apiVersion": "k3s.cattle.io/v1",
+      "metadata": {"name": "local-on-demand-node1-1700000000"},
+      "spec": {"snapshotName": "on-demand-node1-1700000000", "nodeName": "node1", "location": "file:///var/lib/rancher/rke2/server/db/snapshots/on-demand-node1-1700000000"},
+      "status": {"size": "9035808", "creationTime": "2023-11-14T22:13:20Z", "readyToUse": true}
+    },
+    {
+      "kind": "ETCDSnapshotFile",
+      "apiVersion": "k3s.cattle.io/v1",
+      "metadata": {"name": "s3-on-demand node1-1700000000"},
+      "spec": {"snapshotName": "on-demand node1-1700000000", "nodeName": "s3", "location": "s3://bucket/on-demand node1-1700000000", "metadata": {"encryption-key-id": "2024-01"}, "s3": {"bucket": "bucket"}},
+      "status": {"size": "9035808", "creationTime": "2023-11-14T22:13:20Z", "readyToUse": false, "error": {"message": "upload failed"}}
+    }
+  ]
+}
+`,
+			expected: map[string]*snapshot{
+				"cluster-on-demand-node1-1700000000-s3": {
+					Name:      "on-demand node1-1700000000",
+					Location:  "s3://bucket/on-demand node1-1700000000",
+					Size:      9035808,
+					CreatedAt: created,
+					S3:        true,
+					Status:    snapshotStatusFailed,
+					Message:   "upload failed",
+					Metadata:  base64.StdEncoding.EncodeToString([]byte(`{"encryption-key-id":"2024-01"}`)),
+				},
+			},
+		},
+		{
+			name:        "invalid json output",
+			output:      `{"items": [`,
+			expectedErr: true,
+		},
+		{
+			name:     "invalid output",
+			output:   "level=info msg=\"Managed etcd cluster not yet initialized\"",
+			expected: map[string]*snapshot{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshots, err := outputToEtcdSnapshots("cluster", tt.s3, tt.s3Target, []byte(tt.output))
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, snapshots, len(tt.expected))
+			for k, expected := range tt.expected {
+				if assert.Contains(t, snapshots, k) {
+					actual := snapshots[k]
+					assert.True(t, expected.CreatedAt.Equal(actual.CreatedAt))
+					expected.CreatedAt, actual.CreatedAt = nil, nil
+					assert.Equal(t, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateEtcdSnapshotFromListOutput(t *testing.T) {
+	ss, err := generateEtcdSnapshotFromListOutput("etcd-snapshot-node1-1700000000 unknown unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), ss.Size)
+	assert.Nil(t, ss.CreatedAt)
+	assert.Equal(t, snapshotStatusSuccessful, ss.Status)
+
+	_, err = generateEtcdSnapshotFromListOutput("etcd-snapshot-node1-1700000000")
+	assert.Error(t, err)
+}
+
+func TestS3EtcdSnapshotSelector(t *testing.T) {
+	primary, err := s3EtcdSnapshotSelector("cluster", "")
+	assert.NoError(t, err)
+	target, err := s3EtcdSnapshotSelector("cluster", "offsite")
+	assert.NoError(t, err)
+
+	primaryLabels := labels.Set{capr.ClusterNameLabel: "cluster", capr.NodeNameLabel: "s3"}
+	targetLabels := labels.Set{capr.ClusterNameLabel: "cluster", capr.NodeNameLabel: "s3", capr.EtcdSnapshotS3TargetLabel: "offsite"}
+
+	assert.True(t, primary.Matches(primaryLabels))
+	assert.False(t, primary.Matches(targetLabels))
+	assert.False(t, target.Matches(primaryLabels))
+	assert.True(t, target.Matches(targetLabels))
+}
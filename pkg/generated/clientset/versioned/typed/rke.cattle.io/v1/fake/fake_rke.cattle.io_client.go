@@ -36,6 +36,10 @@ func (c *FakeRkeV1) ETCDSnapshots(namespace string) v1.ETCDSnapshotInterface {
 	return &FakeETCDSnapshots{c, namespace}
 }
 
+func (c *FakeRkeV1) ETCDSnapshotSchedules(namespace string) v1.ETCDSnapshotScheduleInterface {
+	return &FakeETCDSnapshotSchedules{c, namespace}
+}
+
 func (c *FakeRkeV1) RKEBootstraps(namespace string) v1.RKEBootstrapInterface {
 	return &FakeRKEBootstraps{c, namespace}
 }
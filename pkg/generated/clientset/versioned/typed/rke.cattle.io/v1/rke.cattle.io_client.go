@@ -30,6 +30,7 @@ type RkeV1Interface interface {
 	RESTClient() rest.Interface
 	CustomMachinesGetter
 	ETCDSnapshotsGetter
+	ETCDSnapshotSchedulesGetter
 	RKEBootstrapsGetter
 	RKEBootstrapTemplatesGetter
 	RKEClustersGetter
@@ -49,6 +50,10 @@ func (c *RkeV1Client) ETCDSnapshots(namespace string) ETCDSnapshotInterface {
 	return newETCDSnapshots(c, namespace)
 }
 
+func (c *RkeV1Client) ETCDSnapshotSchedules(namespace string) ETCDSnapshotScheduleInterface {
+	return newETCDSnapshotSchedules(c, namespace)
+}
+
 func (c *RkeV1Client) RKEBootstraps(namespace string) RKEBootstrapInterface {
 	return newRKEBootstraps(c, namespace)
 }
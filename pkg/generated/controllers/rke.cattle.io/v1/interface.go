@@ -33,6 +33,7 @@ func init() {
 type Interface interface {
 	CustomMachine() CustomMachineController
 	ETCDSnapshot() ETCDSnapshotController
+	ETCDSnapshotSchedule() ETCDSnapshotScheduleController
 	RKEBootstrap() RKEBootstrapController
 	RKEBootstrapTemplate() RKEBootstrapTemplateController
 	RKECluster() RKEClusterController
@@ -57,6 +58,10 @@ func (v *version) ETCDSnapshot() ETCDSnapshotController {
 	return generic.NewController[*v1.ETCDSnapshot, *v1.ETCDSnapshotList](schema.GroupVersionKind{Group: "rke.cattle.io", Version: "v1", Kind: "ETCDSnapshot"}, "etcdsnapshots", true, v.controllerFactory)
 }
 
+func (v *version) ETCDSnapshotSchedule() ETCDSnapshotScheduleController {
+	return generic.NewController[*v1.ETCDSnapshotSchedule, *v1.ETCDSnapshotScheduleList](schema.GroupVersionKind{Group: "rke.cattle.io", Version: "v1", Kind: "ETCDSnapshotSchedule"}, "etcdsnapshotschedules", true, v.controllerFactory)
+}
+
 func (v *version) RKEBootstrap() RKEBootstrapController {
 	return generic.NewController[*v1.RKEBootstrap, *v1.RKEBootstrapList](schema.GroupVersionKind{Group: "rke.cattle.io", Version: "v1", Kind: "RKEBootstrap"}, "rkebootstraps", true, v.controllerFactory)
 }
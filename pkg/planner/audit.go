@@ -0,0 +1,138 @@
+package planner
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/wrangler/v3/pkg/data/convert"
+)
+
+const (
+	auditPolicyConfigMapKey    = "policy"
+	auditWebhookConfigKey      = "config"
+	auditWebhookConfigFileName = "audit-webhook-config.yaml"
+	auditLogPath               = "server/logs/audit.log"
+)
+
+// auditPolicy retrieves the audit policy of the controlplane, either inline or from the referenced configmap.
+func (p *Planner) auditPolicy(controlPlane *rkev1.RKEControlPlane) (string, error) {
+	auditLog := controlPlane.Spec.AuditLog
+	if (auditLog.Policy == "") == (auditLog.PolicyConfigMap == nil) {
+		return "", fmt.Errorf("exactly one of policy and policyConfigMap must be set for the audit log of controlplane %s/%s", controlPlane.Namespace, controlPlane.Name)
+	}
+	if auditLog.Policy != "" {
+		return auditLog.Policy, nil
+	}
+
+	ref := auditLog.PolicyConfigMap
+	configmap, err := p.configMapCache.Get(controlPlane.Namespace, ref.Name)
+	if err != nil {
+		return "", fmt.Errorf("error retrieving audit policy configmap %s/%s: %w", controlPlane.Namespace, ref.Name, err)
+	}
+	if authorized, found := clusterObjectAuthorized(configmap, capr.AuthorizedObjectAnnotation, controlPlane.Name); !authorized || !found {
+		return "", fmt.Errorf("cluster %s/%s was not authorized to access audit policy configmap %s/%s", controlPlane.Namespace, controlPlane.Name, controlPlane.Namespace, ref.Name)
+	}
+	key := ref.Key
+	if key == "" {
+		key = auditPolicyConfigMapKey
+	}
+	policy := configmap.Data[key]
+	if policy == "" {
+		return "", fmt.Errorf("audit policy configmap %s/%s does not contain key %s", controlPlane.Namespace, ref.Name, key)
+	}
+	return policy, nil
+}
+
+// auditWebhookConfig retrieves the kubeconfig of the audit webhook of the controlplane from the referenced secret.
+func (p *Planner) auditWebhookConfig(controlPlane *rkev1.RKEControlPlane) ([]byte, error) {
+	name := controlPlane.Spec.AuditLog.Webhook.ConfigSecretName
+	secret, err := p.secretCache.Get(controlPlane.Namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving audit webhook secret %s/%s: %w", controlPlane.Namespace, name, err)
+	}
+	if authorized, found := clusterObjectAuthorized(secret, capr.AuthorizedObjectAnnotation, controlPlane.Name); !authorized || !found {
+		return nil, fmt.Errorf("cluster %s/%s was not authorized to access audit webhook secret %s/%s", controlPlane.Namespace, controlPlane.Name, controlPlane.Namespace, name)
+	}
+	config := secret.Data[auditWebhookConfigKey]
+	if len(config) == 0 {
+		return nil, fmt.Errorf("audit webhook secret %s/%s does not contain key %s", controlPlane.Namespace, name, auditWebhookConfigKey)
+	}
+	return config, nil
+}
+
+// addAuditLogConfig configures the audit log of the kube-apiserver on controlplane machines and returns the files that
+// are referenced by the configuration. The audit policy of rke2 is passed through its audit-policy-file option, which is
+// rendered into a file along with the other file parameters of the config. k3s has no such option, the policy file is
+// passed to the kube-apiserver directly instead. As the files are not dynamic, a change of the policy or the webhook
+// is rolled out to the controlplane machines like any other change of the config.
+func (p *Planner) addAuditLogConfig(config map[string]interface{}, controlPlane *rkev1.RKEControlPlane, entry *planEntry) ([]plan.File, error) {
+	auditLog := controlPlane.Spec.AuditLog
+	if auditLog == nil || !isControlPlane(entry) {
+		return nil, nil
+	}
+
+	policy, err := p.auditPolicy(controlPlane)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		files []plan.File
+		args  []string
+		rke2  = capr.GetRuntime(controlPlane.Spec.KubernetesVersion) == capr.RuntimeRKE2
+	)
+	if rke2 {
+		config[auditPolicyArg] = policy
+	} else {
+		policyFile := configFile(controlPlane, auditPolicyArg)
+		files = append(files, plan.File{
+			Content: base64.StdEncoding.EncodeToString([]byte(policy)),
+			Path:    policyFile,
+		})
+		args = append(args,
+			fmt.Sprintf("audit-policy-file=%s", policyFile),
+			fmt.Sprintf("audit-log-path=%s", path.Join(capr.GetDistroDataDir(controlPlane), auditLogPath)))
+	}
+
+	if auditLog.MaxAge > 0 {
+		args = append(args, fmt.Sprintf("audit-log-maxage=%d", auditLog.MaxAge))
+	}
+	if auditLog.MaxBackup > 0 {
+		args = append(args, fmt.Sprintf("audit-log-maxbackup=%d", auditLog.MaxBackup))
+	}
+	if auditLog.MaxSize > 0 {
+		args = append(args, fmt.Sprintf("audit-log-maxsize=%d", auditLog.MaxSize))
+	}
+
+	if webhook := auditLog.Webhook; webhook != nil {
+		webhookConfig, err := p.auditWebhookConfig(controlPlane)
+		if err != nil {
+			return nil, err
+		}
+		webhookFile := configFile(controlPlane, auditWebhookConfigFileName)
+		files = append(files, plan.File{
+			Content: base64.StdEncoding.EncodeToString(webhookConfig),
+			Path:    webhookFile,
+		})
+		mode := webhook.Mode
+		if mode == "" {
+			mode = rkev1.AuditWebhookModeBatch
+		}
+		args = append(args,
+			fmt.Sprintf("audit-webhook-config-file=%s", webhookFile),
+			fmt.Sprintf("audit-webhook-mode=%s", mode))
+		if rke2 {
+			config["kube-apiserver-extra-mount"] = append(convert.ToStringSlice(config["kube-apiserver-extra-mount"]),
+				fmt.Sprintf("%s:%s:ro", webhookFile, webhookFile))
+		}
+	}
+
+	if len(args) > 0 {
+		config["kube-apiserver-arg"] = append(convert.ToStringSlice(config["kube-apiserver-arg"]), args...)
+	}
+	return files, nil
+}
@@ -0,0 +1,148 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/wrangler/v3/pkg/generic/fake"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddAuditLogConfig(t *testing.T) {
+	controlPlaneEntry := &planEntry{Metadata: &plan.Metadata{Labels: map[string]string{capr.ControlPlaneRoleLabel: "true"}}}
+	etcdEntry := &planEntry{Metadata: &plan.Metadata{Labels: map[string]string{capr.EtcdRoleLabel: "true"}}}
+	authorized := metav1.ObjectMeta{
+		Namespace:   "fleet-default",
+		Annotations: map[string]string{capr.AuthorizedObjectAnnotation: "test"},
+	}
+
+	tests := []struct {
+		name              string
+		kubernetesVersion string
+		auditLog          *rkev1.AuditLog
+		entry             *planEntry
+		configMap         *v1.ConfigMap
+		secret            *v1.Secret
+		expectedConfig    map[string]interface{}
+		expectedFiles     []string
+		expectErr         bool
+	}{
+		{
+			name:              "rke2 inline policy",
+			kubernetesVersion: "v1.30.4+rke2r1",
+			auditLog:          &rkev1.AuditLog{Policy: "rules: []", MaxAge: 30, MaxSize: 100},
+			entry:             controlPlaneEntry,
+			expectedConfig: map[string]interface{}{
+				auditPolicyArg:       "rules: []",
+				"kube-apiserver-arg": []string{"audit-log-maxage=30", "audit-log-maxsize=100"},
+			},
+		},
+		{
+			name:              "k3s configmap policy with webhook",
+			kubernetesVersion: "v1.30.4+k3s1",
+			auditLog: &rkev1.AuditLog{
+				PolicyConfigMap: &rkev1.AuditPolicyConfigMap{Name: "audit", Key: "audit.yaml"},
+				MaxBackup:       5,
+				Webhook:         &rkev1.AuditWebhook{ConfigSecretName: "audit-webhook"},
+			},
+			entry:     controlPlaneEntry,
+			configMap: &v1.ConfigMap{ObjectMeta: authorized, Data: map[string]string{"audit.yaml": "rules: []"}},
+			secret:    &v1.Secret{ObjectMeta: authorized, Data: map[string][]byte{"config": []byte("kind: Config")}},
+			expectedConfig: map[string]interface{}{
+				"kube-apiserver-arg": []string{
+					"audit-policy-file=/var/lib/rancher/k3s/etc/config-files/audit-policy-file",
+					"audit-log-path=/var/lib/rancher/k3s/server/logs/audit.log",
+					"audit-log-maxbackup=5",
+					"audit-webhook-config-file=/var/lib/rancher/k3s/etc/config-files/audit-webhook-config.yaml",
+					"audit-webhook-mode=batch",
+				},
+			},
+			expectedFiles: []string{
+				"/var/lib/rancher/k3s/etc/config-files/audit-policy-file",
+				"/var/lib/rancher/k3s/etc/config-files/audit-webhook-config.yaml",
+			},
+		},
+		{
+			name:              "rke2 webhook is mounted",
+			kubernetesVersion: "v1.30.4+rke2r1",
+			auditLog: &rkev1.AuditLog{
+				Policy:  "rules: []",
+				Webhook: &rkev1.AuditWebhook{ConfigSecretName: "audit-webhook", Mode: rkev1.AuditWebhookModeBlocking},
+			},
+			entry:  controlPlaneEntry,
+			secret: &v1.Secret{ObjectMeta: authorized, Data: map[string][]byte{"config": []byte("kind: Config")}},
+			expectedConfig: map[string]interface{}{
+				auditPolicyArg: "rules: []",
+				"kube-apiserver-arg": []string{
+					"audit-webhook-config-file=/var/lib/rancher/rke2/etc/config-files/audit-webhook-config.yaml",
+					"audit-webhook-mode=blocking",
+				},
+				"kube-apiserver-extra-mount": []string{
+					"/var/lib/rancher/rke2/etc/config-files/audit-webhook-config.yaml:/var/lib/rancher/rke2/etc/config-files/audit-webhook-config.yaml:ro",
+				},
+			},
+			expectedFiles: []string{"/var/lib/rancher/rke2/etc/config-files/audit-webhook-config.yaml"},
+		},
+		{
+			name:              "etcd only",
+			kubernetesVersion: "v1.30.4+rke2r1",
+			auditLog:          &rkev1.AuditLog{Policy: "rules: []"},
+			entry:             etcdEntry,
+			expectedConfig:    map[string]interface{}{},
+		},
+		{
+			name:              "unauthorized configmap",
+			kubernetesVersion: "v1.30.4+rke2r1",
+			auditLog:          &rkev1.AuditLog{PolicyConfigMap: &rkev1.AuditPolicyConfigMap{Name: "audit"}},
+			entry:             controlPlaneEntry,
+			configMap:         &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default"}, Data: map[string]string{"policy": "rules: []"}},
+			expectErr:         true,
+		},
+		{
+			name:              "policy and configmap",
+			kubernetesVersion: "v1.30.4+rke2r1",
+			auditLog:          &rkev1.AuditLog{Policy: "rules: []", PolicyConfigMap: &rkev1.AuditPolicyConfigMap{Name: "audit"}},
+			entry:             controlPlaneEntry,
+			expectErr:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			configMapCache := fake.NewMockCacheInterface[*v1.ConfigMap](ctrl)
+			secretCache := fake.NewMockCacheInterface[*v1.Secret](ctrl)
+			if tt.configMap != nil {
+				configMapCache.EXPECT().Get("fleet-default", tt.auditLog.PolicyConfigMap.Name).Return(tt.configMap, nil)
+			}
+			if tt.secret != nil {
+				secretCache.EXPECT().Get("fleet-default", tt.auditLog.Webhook.ConfigSecretName).Return(tt.secret, nil)
+			}
+			p := &Planner{configMapCache: configMapCache, secretCache: secretCache}
+
+			cp := &rkev1.RKEControlPlane{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "test"}}
+			cp.Spec.KubernetesVersion = tt.kubernetesVersion
+			cp.Spec.AuditLog = tt.auditLog
+
+			config := map[string]interface{}{}
+			files, err := p.addAuditLogConfig(config, cp, tt.entry)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedConfig, config)
+
+			var paths []string
+			for _, file := range files {
+				paths = append(paths, file.Path)
+			}
+			assert.Equal(t, tt.expectedFiles, paths)
+		})
+	}
+}
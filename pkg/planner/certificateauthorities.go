@@ -0,0 +1,98 @@
+package planner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"path"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// certificateAuthorityFile is a certificate authority of the distro that can be provided by a secret.
+type certificateAuthorityFile struct {
+	secretName string
+	// path is the path of the certificate authority relative to the data directory of the distro, without the extension
+	// of its certificate and key files.
+	path string
+}
+
+// certificateAuthorityFiles returns the certificate authorities of the distro that are provided by the secrets of the
+// given certificate authorities. The etcd CA signs both the server and the peer certificates of etcd.
+func certificateAuthorityFiles(cas *rkev1.CertificateAuthorities) []certificateAuthorityFile {
+	var files []certificateAuthorityFile
+	for _, f := range []certificateAuthorityFile{
+		{secretName: cas.ServerCASecretName, path: "server/tls/server-ca"},
+		{secretName: cas.ClientCASecretName, path: "server/tls/client-ca"},
+		{secretName: cas.RequestHeaderCASecretName, path: "server/tls/request-header-ca"},
+		{secretName: cas.ETCDCASecretName, path: "server/tls/etcd/server-ca"},
+		{secretName: cas.ETCDCASecretName, path: "server/tls/etcd/peer-ca"},
+	} {
+		if f.secretName != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// addCertificateAuthorityFiles adds the certificate authorities of the controlplane to the plan of the init node until
+// the cluster is initialized, so that the distro uses them instead of generating its own when it starts for the first
+// time. The files are dynamic, so that removing them from the plan once the cluster is initialized does not restart the
+// distro.
+func (p *Planner) addCertificateAuthorityFiles(nodePlan plan.NodePlan, controlPlane *rkev1.RKEControlPlane, entry *planEntry) (plan.NodePlan, error) {
+	cas := controlPlane.Spec.CertificateAuthorities
+	if cas == nil || controlPlane.Status.Initialized || !isInitNode(entry) {
+		return nodePlan, nil
+	}
+
+	dataDir := capr.GetDistroDataDir(controlPlane)
+	for _, f := range certificateAuthorityFiles(cas) {
+		secret, err := p.secretCache.Get(controlPlane.Namespace, f.secretName)
+		if err != nil {
+			return nodePlan, fmt.Errorf("failed to get certificate authority secret %s/%s: %w", controlPlane.Namespace, f.secretName, err)
+		}
+		if err := validateCertificateAuthoritySecret(secret); err != nil {
+			return nodePlan, fmt.Errorf("invalid certificate authority secret %s/%s: %w", controlPlane.Namespace, f.secretName, err)
+		}
+		nodePlan.Files = append(nodePlan.Files, plan.File{
+			Content:     base64.StdEncoding.EncodeToString(secret.Data[corev1.TLSCertKey]),
+			Path:        path.Join(dataDir, f.path+".crt"),
+			Permissions: "0644",
+			Dynamic:     true,
+			Minor:       true,
+		}, plan.File{
+			Content:     base64.StdEncoding.EncodeToString(secret.Data[corev1.TLSPrivateKeyKey]),
+			Path:        path.Join(dataDir, f.path+".key"),
+			Permissions: "0600",
+			Dynamic:     true,
+			Minor:       true,
+		})
+	}
+	return nodePlan, nil
+}
+
+// validateCertificateAuthoritySecret returns an error if the given secret does not contain a CA certificate and its
+// matching key.
+func validateCertificateAuthoritySecret(secret *corev1.Secret) error {
+	certPEM, keyPEM := secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return fmt.Errorf("%s and %s must be set", corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return err
+	}
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+	if !cert.IsCA {
+		return fmt.Errorf("certificate %s is not a CA certificate", cert.Subject)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package planner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func generateCertificateAuthoritySecret(t *testing.T, isCA bool) *corev1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{Data: map[string][]byte{
+		corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}}
+}
+
+func TestValidateCertificateAuthoritySecret(t *testing.T) {
+	ca := generateCertificateAuthoritySecret(t, true)
+	assert.NoError(t, validateCertificateAuthoritySecret(ca))
+
+	assert.Error(t, validateCertificateAuthoritySecret(generateCertificateAuthoritySecret(t, false)))
+	assert.Error(t, validateCertificateAuthoritySecret(&corev1.Secret{Data: map[string][]byte{
+		corev1.TLSCertKey: ca.Data[corev1.TLSCertKey],
+	}}))
+	assert.Error(t, validateCertificateAuthoritySecret(&corev1.Secret{Data: map[string][]byte{
+		corev1.TLSCertKey:       ca.Data[corev1.TLSCertKey],
+		corev1.TLSPrivateKeyKey: generateCertificateAuthoritySecret(t, true).Data[corev1.TLSPrivateKeyKey],
+	}}))
+}
+
+func TestCertificateAuthorityFiles(t *testing.T) {
+	assert.Empty(t, certificateAuthorityFiles(&rkev1.CertificateAuthorities{}))
+	assert.Equal(t, []certificateAuthorityFile{
+		{secretName: "server", path: "server/tls/server-ca"},
+		{secretName: "etcd", path: "server/tls/etcd/server-ca"},
+		{secretName: "etcd", path: "server/tls/etcd/peer-ca"},
+	}, certificateAuthorityFiles(&rkev1.CertificateAuthorities{
+		ServerCASecretName: "server",
+		ETCDCASecretName:   "etcd",
+	}))
+}
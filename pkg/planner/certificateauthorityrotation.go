@@ -0,0 +1,322 @@
+package planner
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	certificateAuthorityRotationPrefix = "capr/certificate-authority-rotation"
+
+	certificateAuthorityRotationServerCAInstructionName = "certificate-authority-rotation-server-ca"
+
+	certificateAuthorityRotationScriptPath = "bin/rotate_ca.sh"
+	// certificateAuthorityRotationScript stages the new certificate authorities in the rotate-ca directory of the distro
+	// and updates the certificate authorities of the cluster in the datastore with the certificate rotate-ca command of
+	// the distro. Certificate authorities whose names are passed as arguments are copied from the directory of the
+	// provided certificate authorities, the others are generated. Unless the rotation is forced, generated certificate
+	// authorities are cross-signed by the current ones and bundled with them, so that certificates issued by either are
+	// trusted during the rotation.
+	certificateAuthorityRotationScript = `#!/bin/sh
+RUNTIME=$1
+DATA_DIR=$2
+PROVIDED_DIR=$3
+shift 3
+
+TLS_DIR="${DATA_DIR}/server/tls"
+STAGING_DIR="${DATA_DIR}/server/rotate-ca"
+
+rm -rf "${STAGING_DIR}"
+mkdir -p "${STAGING_DIR}/etcd" || exit 1
+chmod 700 "${STAGING_DIR}"
+printf 'basicConstraints=critical,CA:TRUE\nkeyUsage=critical,digitalSignature,keyEncipherment,keyCertSign\n' > "${STAGING_DIR}/ca.ext"
+
+provided() {
+	for ca in "$@"; do
+		if [ "${ca}" = "${CA}" ]; then
+			return 0
+		fi
+	done
+	return 1
+}
+
+for CA in client-ca server-ca request-header-ca etcd/peer-ca etcd/server-ca; do
+	if provided "$@"; then
+		cp "${PROVIDED_DIR}/${CA}.crt" "${PROVIDED_DIR}/${CA}.key" "${STAGING_DIR}/$(dirname "${CA}")/" || exit 1
+		continue
+	fi
+
+	if ! command -v openssl >/dev/null 2>&1; then
+		echo "openssl is required to generate certificate authorities" >&2
+		exit 1
+	fi
+	subject="/CN=${RUNTIME}-$(echo "${CA}" | tr / -)@$(date +%s)"
+	openssl ecparam -name prime256v1 -genkey -noout -out "${STAGING_DIR}/${CA}.key" || exit 1
+	if [ "${CERTIFICATE_AUTHORITY_ROTATION_FORCE}" = "true" ]; then
+		openssl req -new -sha256 -key "${STAGING_DIR}/${CA}.key" -subj "${subject}" |
+			openssl x509 -req -sha256 -days 3650 -signkey "${STAGING_DIR}/${CA}.key" -extfile "${STAGING_DIR}/ca.ext" \
+				-out "${STAGING_DIR}/${CA}.crt" || exit 1
+	else
+		openssl req -new -sha256 -key "${STAGING_DIR}/${CA}.key" -subj "${subject}" |
+			openssl x509 -req -sha256 -days 3650 -CA "${TLS_DIR}/${CA}.crt" -CAkey "${TLS_DIR}/${CA}.key" \
+				-set_serial "0x$(openssl rand -hex 16)" -extfile "${STAGING_DIR}/ca.ext" -out "${STAGING_DIR}/${CA}.crt" || exit 1
+		cat "${TLS_DIR}/${CA}.crt" >> "${STAGING_DIR}/${CA}.crt"
+	fi
+done
+rm -f "${STAGING_DIR}/ca.ext"
+
+# the service account signing key is not a certificate authority, but is required by rotate-ca.
+cp "${TLS_DIR}/service.key" "${STAGING_DIR}/service.key" || exit 1
+
+if [ "${CERTIFICATE_AUTHORITY_ROTATION_FORCE}" = "true" ]; then
+	exec "${RUNTIME}" certificate rotate-ca --path="${STAGING_DIR}" --force
+fi
+exec "${RUNTIME}" certificate rotate-ca --path="${STAGING_DIR}"
+`
+)
+
+func certificateAuthorityRotationPath(controlPlane *rkev1.RKEControlPlane, file string) string {
+	return path.Join(capr.GetDistroDataDir(controlPlane), certificateAuthorityRotationPrefix, file)
+}
+
+func (p *Planner) setCertificateAuthorityRotationState(status rkev1.RKEControlPlaneStatus, rotation *rkev1.RotateCertificateAuthorities, phase rkev1.RotateCertificateAuthoritiesPhase) (rkev1.RKEControlPlaneStatus, error) {
+	if equality.Semantic.DeepEqual(status.RotateCertificateAuthorities, rotation) && status.RotateCertificateAuthoritiesPhase == phase {
+		return status, nil
+	}
+	status.RotateCertificateAuthorities = rotation
+	status.RotateCertificateAuthoritiesPhase = phase
+	return status, errWaiting("refreshing certificate authority rotation state")
+}
+
+func (p *Planner) resetCertificateAuthorityRotationState(status rkev1.RKEControlPlaneStatus) (rkev1.RKEControlPlaneStatus, error) {
+	if status.RotateCertificateAuthorities == nil && status.RotateCertificateAuthoritiesPhase == "" {
+		return status, nil
+	}
+	return p.setCertificateAuthorityRotationState(status, nil, "")
+}
+
+func (p *Planner) startOrRestartCertificateAuthorityRotation(status rkev1.RKEControlPlaneStatus, rotation *rkev1.RotateCertificateAuthorities) (rkev1.RKEControlPlaneStatus, error) {
+	if status.RotateCertificateAuthorities == nil || !equality.Semantic.DeepEqual(rotation, status.RotateCertificateAuthorities) {
+		return p.setCertificateAuthorityRotationState(status, rotation, rkev1.RotateCertificateAuthoritiesPhasePrepare)
+	}
+	return status, nil
+}
+
+// rotateCertificateAuthorities replaces the certificate authorities of the controlplane when the generation of the
+// certificate authority rotation changes. The phases are in order:
+// Prepare -> The new certificate authorities are staged on the init node and written to the datastore with the
+// certificate rotate-ca command of the distro.
+// RestartServers -> The servers are restarted one at a time in the order etcd-only, etcd with controlplane and
+// controlplane-only, so that they load the new certificate authorities from the datastore and reissue their certificates.
+// The server CA bundle of the init node is recorded in status for the kubeconfig of the cluster.
+// RestartAgents -> The agents are restarted one at a time, so that they download the new server CA bundle from the
+// servers and reissue their certificates.
+// Done -> All machines were restarted.
+func (p *Planner) rotateCertificateAuthorities(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
+	if controlPlane.Spec.RotateCertificateAuthorities == nil {
+		return p.resetCertificateAuthorityRotationState(status)
+	}
+
+	if !status.Initialized {
+		logrus.Warnf("[planner] rkecluster %s/%s: skipping certificate authority rotation as cluster was not initialized", controlPlane.Namespace, controlPlane.Name)
+		return status, nil
+	}
+
+	rotation := controlPlane.Spec.RotateCertificateAuthorities
+
+	var err error
+	if status, err = p.startOrRestartCertificateAuthorityRotation(status, rotation); err != nil {
+		return status, err
+	}
+
+	switch status.RotateCertificateAuthoritiesPhase {
+	case rkev1.RotateCertificateAuthoritiesPhaseDone, rkev1.RotateCertificateAuthoritiesPhaseFailed:
+		return status, nil
+	}
+
+	found, joinServer, initNode, err := p.findInitNode(controlPlane, clusterPlan)
+	if err != nil {
+		logrus.Errorf("[planner] rkecluster %s/%s: error encountered while searching for init node during certificate authority rotation: %v", controlPlane.Namespace, controlPlane.Name, err)
+		return status, err
+	}
+	if !found || joinServer == "" {
+		logrus.Warnf("[planner] rkecluster %s/%s: skipping certificate authority rotation as cluster does not have an init node", controlPlane.Namespace, controlPlane.Name)
+		return status, nil
+	}
+
+	switch status.RotateCertificateAuthoritiesPhase {
+	case rkev1.RotateCertificateAuthoritiesPhasePrepare:
+		if err := p.pauseCAPICluster(controlPlane, true); err != nil {
+			return status, errWaiting("pausing CAPI cluster")
+		}
+		preparePlan, joinedServer, err := p.generateCertificateAuthorityRotationPreparePlan(controlPlane, tokensSecret, initNode, joinServer)
+		if err != nil {
+			return status, err
+		}
+		if err := assignAndCheckPlan(p.store, fmt.Sprintf("certificate authority rotation [%s] for machine [%s]", status.RotateCertificateAuthoritiesPhase, initNode.Machine.Name), initNode, preparePlan, joinedServer, 1, 1); err != nil {
+			return p.certificateAuthorityRotationFailed(controlPlane, status, initNode, err)
+		}
+		return p.setCertificateAuthorityRotationState(status, rotation, rkev1.RotateCertificateAuthoritiesPhaseRestartServers)
+	case rkev1.RotateCertificateAuthoritiesPhaseRestartServers:
+		for _, entry := range collectOrderedCertificateRotationEntries(clusterPlan) {
+			if isOnlyWorker(entry) {
+				continue
+			}
+			if status, err = p.certificateAuthorityRotationRestart(controlPlane, status, tokensSecret, entry, joinServer); err != nil {
+				return status, err
+			}
+		}
+		serverCA := strings.TrimSpace(string(initNode.Plan.Output[certificateAuthorityRotationServerCAInstructionName]))
+		if serverCA == "" {
+			return p.certificateAuthorityRotationFailed(controlPlane, status, initNode, fmt.Errorf("server CA bundle was not collected"))
+		}
+		status.ServerCAData = serverCA + "\n"
+		return p.setCertificateAuthorityRotationState(status, rotation, rkev1.RotateCertificateAuthoritiesPhaseRestartAgents)
+	case rkev1.RotateCertificateAuthoritiesPhaseRestartAgents:
+		for _, entry := range collect(clusterPlan, isOnlyWorker) {
+			if status, err = p.certificateAuthorityRotationRestart(controlPlane, status, tokensSecret, entry, joinServer); err != nil {
+				return status, err
+			}
+		}
+		if err := p.pauseCAPICluster(controlPlane, false); err != nil {
+			return status, errWaiting("unpausing CAPI cluster")
+		}
+		logrus.Infof("[planner] rkecluster %s/%s: certificate authority rotation finished", controlPlane.Namespace, controlPlane.Name)
+		// all certificates were reissued by the new certificate authorities
+		status.CertificateRotationTime = &metav1.Time{Time: time.Now().UTC().Truncate(time.Second)}
+		return p.setCertificateAuthorityRotationState(status, rotation, rkev1.RotateCertificateAuthoritiesPhaseDone)
+	default:
+		return p.setCertificateAuthorityRotationState(status, rotation, rkev1.RotateCertificateAuthoritiesPhasePrepare)
+	}
+}
+
+// certificateAuthorityRotationFailed sets the phase of the certificate authority rotation to failed if the plan of the
+// given machine failed, otherwise the error is returned as is.
+func (p *Planner) certificateAuthorityRotationFailed(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, entry *planEntry, err error) (rkev1.RKEControlPlaneStatus, error) {
+	if IsErrWaiting(err) {
+		return status, err
+	}
+	logrus.Errorf("[planner] rkecluster %s/%s: certificate authority rotation failed on machine %s: %v", controlPlane.Namespace, controlPlane.Name, entry.Machine.Name, err)
+	if pauseErr := p.pauseCAPICluster(controlPlane, false); pauseErr != nil {
+		return status, pauseErr
+	}
+	status, _ = p.setCertificateAuthorityRotationState(status, controlPlane.Spec.RotateCertificateAuthorities, rkev1.RotateCertificateAuthoritiesPhaseFailed)
+	return status, err
+}
+
+// generateCertificateAuthorityRotationPreparePlan generates the plan of the init node that stages the new certificate
+// authorities and writes them to the datastore. The provided certificate authorities are delivered with the plan.
+func (p *Planner) generateCertificateAuthorityRotationPreparePlan(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret, entry *planEntry, joinServer string) (plan.NodePlan, string, error) {
+	preparePlan, _, joinedServer, err := p.generatePlanWithConfigFiles(controlPlane, tokensSecret, entry, joinServer, true)
+	if err != nil {
+		return plan.NodePlan{}, joinedServer, err
+	}
+
+	var provided []string
+	if cas := controlPlane.Spec.CertificateAuthorities; cas != nil {
+		for _, f := range certificateAuthorityFiles(cas) {
+			secret, err := p.secretCache.Get(controlPlane.Namespace, f.secretName)
+			if err != nil {
+				return plan.NodePlan{}, joinedServer, fmt.Errorf("failed to get certificate authority secret %s/%s: %w", controlPlane.Namespace, f.secretName, err)
+			}
+			if err := validateCertificateAuthoritySecret(secret); err != nil {
+				return plan.NodePlan{}, joinedServer, fmt.Errorf("invalid certificate authority secret %s/%s: %w", controlPlane.Namespace, f.secretName, err)
+			}
+			name := strings.TrimPrefix(f.path, "server/tls/")
+			provided = append(provided, name)
+			preparePlan.Files = append(preparePlan.Files, plan.File{
+				Content: base64.StdEncoding.EncodeToString(secret.Data[corev1.TLSCertKey]),
+				Path:    certificateAuthorityRotationPath(controlPlane, path.Join("provided", name+".crt")),
+				Dynamic: true,
+			}, plan.File{
+				Content:     base64.StdEncoding.EncodeToString(secret.Data[corev1.TLSPrivateKeyKey]),
+				Path:        certificateAuthorityRotationPath(controlPlane, path.Join("provided", name+".key")),
+				Permissions: "0600",
+				Dynamic:     true,
+			})
+		}
+	}
+
+	preparePlan.Files = append(preparePlan.Files, plan.File{
+		Content: base64.StdEncoding.EncodeToString([]byte(certificateAuthorityRotationScript)),
+		Path:    certificateAuthorityRotationPath(controlPlane, certificateAuthorityRotationScriptPath),
+		Dynamic: true,
+	})
+	preparePlan.Instructions = append(preparePlan.Instructions, idempotentInstruction(
+		controlPlane,
+		"certificate-authority-rotation/prepare",
+		strconv.FormatInt(controlPlane.Spec.RotateCertificateAuthorities.Generation, 10),
+		"/bin/sh",
+		append([]string{
+			certificateAuthorityRotationPath(controlPlane, certificateAuthorityRotationScriptPath),
+			capr.GetRuntime(controlPlane.Spec.KubernetesVersion),
+			capr.GetDistroDataDir(controlPlane),
+			certificateAuthorityRotationPath(controlPlane, "provided"),
+		}, provided...),
+		[]string{
+			fmt.Sprintf("CERTIFICATE_AUTHORITY_ROTATION_FORCE=%t", controlPlane.Spec.RotateCertificateAuthorities.Force),
+		},
+	))
+	return preparePlan, joinedServer, nil
+}
+
+// certificateAuthorityRotationRestart restarts the distro on the given machine and waits for its probes to pass. The
+// server CA bundle of the init node is collected after it was restarted.
+func (p *Planner) certificateAuthorityRotationRestart(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, entry *planEntry, joinServer string) (rkev1.RKEControlPlaneStatus, error) {
+	if isOnlyWorker(entry) {
+		// Don't overwrite the joinURL annotation.
+		joinServer = ""
+	}
+	restartPlan, config, joinedServer, err := p.generatePlanWithConfigFiles(controlPlane, tokensSecret, entry, joinServer, true)
+	if err != nil {
+		return status, err
+	}
+
+	unit := capr.GetRuntimeServerUnit(controlPlane.Spec.KubernetesVersion)
+	if isOnlyWorker(entry) {
+		unit = capr.GetRuntimeAgentUnit(controlPlane.Spec.KubernetesVersion)
+	}
+	if generated, instruction := generateManifestRemovalInstruction(controlPlane, entry); generated && capr.GetRuntime(controlPlane.Spec.KubernetesVersion) == capr.RuntimeRKE2 {
+		restartPlan.Instructions = append(restartPlan.Instructions, convertToIdempotentInstruction(
+			controlPlane,
+			"certificate-authority-rotation/manifest-removal",
+			strconv.FormatInt(controlPlane.Spec.RotateCertificateAuthorities.Generation, 10),
+			instruction))
+	}
+	restartPlan.Instructions = append(restartPlan.Instructions, idempotentRestartInstructions(
+		controlPlane,
+		"certificate-authority-rotation/restart",
+		strconv.FormatInt(controlPlane.Spec.RotateCertificateAuthorities.Generation, 10),
+		unit)...)
+	if isInitNode(entry) {
+		restartPlan.Instructions = append(restartPlan.Instructions, plan.OneTimeInstruction{
+			Name:       certificateAuthorityRotationServerCAInstructionName,
+			Command:    "cat",
+			Args:       []string{path.Join(capr.GetDistroDataDir(controlPlane), "server/tls/server-ca.crt")},
+			SaveOutput: true,
+		})
+	}
+
+	probes, err := p.generateProbes(controlPlane, entry, config)
+	if err != nil {
+		return status, err
+	}
+	restartPlan.Probes = probes
+
+	if err := assignAndCheckPlan(p.store, fmt.Sprintf("certificate authority rotation [%s] for machine [%s]", status.RotateCertificateAuthoritiesPhase, entry.Machine.Name), entry, restartPlan, joinedServer, 5, 5); err != nil {
+		return p.certificateAuthorityRotationFailed(controlPlane, status, entry, err)
+	}
+	return status, nil
+}
@@ -0,0 +1,296 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const testServerCA = "-----BEGIN CERTIFICATE-----\nnew\n-----END CERTIFICATE-----\n-----BEGIN CERTIFICATE-----\ncurrent\n-----END CERTIFICATE-----"
+
+// certificateAuthorityRotationTestPlanner returns a mock planner, a controlplane that requests the given certificate
+// authority rotation, the plan of its machines, which are the init node and a worker, and the CAPI cluster of the
+// controlplane, whose pausing is expected.
+func certificateAuthorityRotationTestPlanner(t *testing.T, rotation *rkev1.RotateCertificateAuthorities) (*mockPlanner, *rkev1.RKEControlPlane, *plan.Plan, *capi.Cluster) {
+	cp := &rkev1.RKEControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "fleet-default",
+			Name:       "test",
+			Generation: 1,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: capi.GroupVersion.String(), Kind: "Cluster", Name: "test", Controller: &[]bool{true}[0]},
+			},
+		},
+		Spec: rkev1.RKEControlPlaneSpec{
+			KubernetesVersion: "v1.30.4+k3s1",
+			UnmanagedConfig:   true,
+		},
+		Status: rkev1.RKEControlPlaneStatus{
+			Initialized: true,
+		},
+	}
+	cp.Spec.RotateCertificateAuthorities = rotation
+	capr.Ready.True(cp)
+
+	machine := func(name string) *capi.Machine {
+		return &capi.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "fleet-default",
+				Name:      name,
+			},
+			Spec: capi.MachineSpec{
+				Bootstrap: capi.Bootstrap{
+					ConfigRef: &corev1.ObjectReference{Kind: "RKEBootstrap", Name: name},
+				},
+			},
+			Status: capi.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: name},
+				Conditions: capi.Conditions{
+					{Type: capi.ReadyCondition, Status: corev1.ConditionTrue},
+					{Type: capi.InfrastructureReadyCondition, Status: corev1.ConditionTrue},
+				},
+			},
+		}
+	}
+	clusterPlan := &plan.Plan{
+		Machines: map[string]*capi.Machine{
+			"server": machine("server"),
+			"worker": machine("worker"),
+		},
+		Metadata: map[string]*plan.Metadata{
+			"server": {
+				Labels: map[string]string{
+					capr.EtcdRoleLabel:         "true",
+					capr.ControlPlaneRoleLabel: "true",
+					capr.InitNodeLabel:         "true",
+				},
+				Annotations: map[string]string{
+					capr.JoinURLAnnotation: "https://server:6443",
+				},
+			},
+			"worker": {
+				Labels: map[string]string{
+					capr.WorkerRoleLabel: "true",
+				},
+				Annotations: map[string]string{},
+			},
+		},
+		Nodes: map[string]*plan.Node{
+			"server": {InSync: true, Healthy: true},
+			"worker": {InSync: true, Healthy: true},
+		},
+	}
+
+	mp := newMockPlanner(t, InfoFunctions{})
+	cluster := &capi.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "test"}}
+	mp.capiClusters.EXPECT().Get("fleet-default", "test").DoAndReturn(func(string, string) (*capi.Cluster, error) {
+		return cluster.DeepCopy(), nil
+	}).AnyTimes()
+	mp.capiClient.EXPECT().Update(gomock.Any()).DoAndReturn(func(c *capi.Cluster) (*capi.Cluster, error) {
+		cluster.Spec.Paused = c.Spec.Paused
+		return c, nil
+	}).AnyTimes()
+	return mp, cp, clusterPlan, cluster
+}
+
+// certificateAuthorityRotationPrepareInstruction returns the instruction of the given plan that stages the new
+// certificate authorities.
+func certificateAuthorityRotationPrepareInstruction(nodePlan *plan.NodePlan) (plan.OneTimeInstruction, bool) {
+	for _, instruction := range nodePlan.Instructions {
+		if strings.HasPrefix(instruction.Name, "idempotent-certificate-authority-rotation/prepare-") {
+			return instruction, true
+		}
+	}
+	return plan.OneTimeInstruction{}, false
+}
+
+func TestRotateCertificateAuthoritiesState(t *testing.T) {
+	p := &Planner{}
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.RotateCertificateAuthorities = &rkev1.RotateCertificateAuthorities{Generation: 2}
+	status := rkev1.RKEControlPlaneStatus{
+		Initialized:                       true,
+		RotateCertificateAuthorities:      &rkev1.RotateCertificateAuthorities{Generation: 1},
+		RotateCertificateAuthoritiesPhase: rkev1.RotateCertificateAuthoritiesPhaseDone,
+		ServerCAData:                      "ca",
+	}
+
+	// a new generation restarts the rotation
+	status, err := p.rotateCertificateAuthorities(cp, status, plan.Secret{}, nil)
+	assert.True(t, IsErrWaiting(err))
+	assert.Equal(t, cp.Spec.RotateCertificateAuthorities, status.RotateCertificateAuthorities)
+	assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhasePrepare, status.RotateCertificateAuthoritiesPhase)
+
+	// a finished rotation is not repeated
+	status.RotateCertificateAuthoritiesPhase = rkev1.RotateCertificateAuthoritiesPhaseFailed
+	status, err = p.rotateCertificateAuthorities(cp, status, plan.Secret{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhaseFailed, status.RotateCertificateAuthoritiesPhase)
+
+	// removing the rotation resets its state, but keeps the server CA bundle
+	cp.Spec.RotateCertificateAuthorities = nil
+	status, err = p.rotateCertificateAuthorities(cp, status, plan.Secret{}, nil)
+	assert.True(t, IsErrWaiting(err))
+	assert.Nil(t, status.RotateCertificateAuthorities)
+	assert.Empty(t, status.RotateCertificateAuthoritiesPhase)
+	assert.Equal(t, "ca", status.ServerCAData)
+}
+
+func TestRotateCertificateAuthoritiesNotInitialized(t *testing.T) {
+	p := &Planner{}
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.RotateCertificateAuthorities = &rkev1.RotateCertificateAuthorities{Generation: 1}
+
+	// the rotation is not started before the cluster is initialized
+	status, err := p.rotateCertificateAuthorities(cp, rkev1.RKEControlPlaneStatus{}, plan.Secret{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, status.RotateCertificateAuthorities)
+	assert.Empty(t, status.RotateCertificateAuthoritiesPhase)
+}
+
+func TestRotateCertificateAuthoritiesPhases(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		name := "cross-signed"
+		if force {
+			name = "force"
+		}
+		t.Run(name, func(t *testing.T) {
+			mp, cp, clusterPlan, cluster := certificateAuthorityRotationTestPlanner(t, &rkev1.RotateCertificateAuthorities{Generation: 1, Force: force})
+			reconcile := func() error {
+				status, err := mp.planner.rotateCertificateAuthorities(cp, cp.Status, plan.Secret{}, clusterPlan)
+				cp.Status = status
+				return err
+			}
+
+			err := reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhasePrepare, cp.Status.RotateCertificateAuthoritiesPhase)
+
+			// the CAPI cluster is paused and the init node stages the new certificate authorities
+			nodePlan := expectMachinePlanUpdate(mp, "server")
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.True(t, cluster.Spec.Paused)
+			assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhasePrepare, cp.Status.RotateCertificateAuthoritiesPhase)
+			prepare, ok := certificateAuthorityRotationPrepareInstruction(nodePlan)
+			if assert.True(t, ok) {
+				assert.Equal(t, []string{fmt.Sprintf("CERTIFICATE_AUTHORITY_ROTATION_FORCE=%t", force)}, prepare.Env)
+			}
+
+			applyMachinePlan(clusterPlan, "server", nodePlan, nil)
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhaseRestartServers, cp.Status.RotateCertificateAuthoritiesPhase)
+
+			// the server is restarted and the server CA bundle of the init node is collected
+			nodePlan = expectMachinePlanUpdate(mp, "server")
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhaseRestartServers, cp.Status.RotateCertificateAuthoritiesPhase)
+			assert.Equal(t, certificateAuthorityRotationServerCAInstructionName, nodePlan.Instructions[len(nodePlan.Instructions)-1].Name)
+
+			applyMachinePlan(clusterPlan, "server", nodePlan, map[string][]byte{
+				certificateAuthorityRotationServerCAInstructionName: []byte(testServerCA + "\n"),
+			})
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhaseRestartAgents, cp.Status.RotateCertificateAuthoritiesPhase)
+			assert.Equal(t, testServerCA+"\n", cp.Status.ServerCAData)
+
+			// the worker is restarted, the CAPI cluster is unpaused and the rotation is done
+			nodePlan = expectMachinePlanUpdate(mp, "worker")
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.True(t, cluster.Spec.Paused)
+			assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhaseRestartAgents, cp.Status.RotateCertificateAuthoritiesPhase)
+
+			applyMachinePlan(clusterPlan, "worker", nodePlan, nil)
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.False(t, cluster.Spec.Paused)
+			assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhaseDone, cp.Status.RotateCertificateAuthoritiesPhase)
+			assert.NotNil(t, cp.Status.CertificateRotationTime)
+
+			// the rotation is not started again
+			err = reconcile()
+			assert.NoError(t, err)
+			assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhaseDone, cp.Status.RotateCertificateAuthoritiesPhase)
+		})
+	}
+}
+
+func TestRotateCertificateAuthoritiesFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		phase   rkev1.RotateCertificateAuthoritiesPhase
+		machine string
+		failed  bool
+	}{
+		{
+			name:    "prepare failed",
+			phase:   rkev1.RotateCertificateAuthoritiesPhasePrepare,
+			machine: "server",
+			failed:  true,
+		},
+		{
+			name:    "server restart failed",
+			phase:   rkev1.RotateCertificateAuthoritiesPhaseRestartServers,
+			machine: "server",
+			failed:  true,
+		},
+		{
+			name:    "server CA bundle not collected",
+			phase:   rkev1.RotateCertificateAuthoritiesPhaseRestartServers,
+			machine: "server",
+		},
+		{
+			name:    "agent restart failed",
+			phase:   rkev1.RotateCertificateAuthoritiesPhaseRestartAgents,
+			machine: "worker",
+			failed:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rotation := &rkev1.RotateCertificateAuthorities{Generation: 1}
+			mp, cp, clusterPlan, cluster := certificateAuthorityRotationTestPlanner(t, rotation)
+			cp.Status.RotateCertificateAuthorities = rotation
+			cp.Status.RotateCertificateAuthoritiesPhase = tt.phase
+			cluster.Spec.Paused = true
+			serverCA := cp.Status.ServerCAData
+			reconcile := func() error {
+				status, err := mp.planner.rotateCertificateAuthorities(cp, cp.Status, plan.Secret{}, clusterPlan)
+				cp.Status = status
+				return err
+			}
+
+			// the plan of the phase is assigned to and applied by the machine
+			nodePlan := expectMachinePlanUpdate(mp, tt.machine)
+			assert.True(t, IsErrWaiting(reconcile()))
+			applyMachinePlan(clusterPlan, tt.machine, nodePlan, nil)
+			clusterPlan.Nodes[tt.machine].Failed = tt.failed
+
+			err := reconcile()
+			assert.Error(t, err)
+			assert.False(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhaseFailed, cp.Status.RotateCertificateAuthoritiesPhase)
+			assert.Equal(t, serverCA, cp.Status.ServerCAData)
+			// the CAPI cluster is unpaused, so that the cluster can be reconciled again
+			assert.False(t, cluster.Spec.Paused)
+
+			// the failed rotation is not retried until its generation changes
+			assert.NoError(t, reconcile())
+			assert.Equal(t, rkev1.RotateCertificateAuthoritiesPhaseFailed, cp.Status.RotateCertificateAuthoritiesPhase)
+		})
+	}
+}
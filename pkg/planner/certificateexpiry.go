@@ -0,0 +1,138 @@
+package planner
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultCertificateExpiryWindowDays is the default number of days before their expiry that certificates are considered
+// to be expiring soon.
+const defaultCertificateExpiryWindowDays = 30
+
+// certificateRotationServices are the services whose certificates can be rotated individually by the distro, in addition
+// to the controller and server services of the distro.
+var certificateRotationServices = map[string]bool{
+	"admin":              true,
+	"api-server":         true,
+	"auth-proxy":         true,
+	"cloud-controller":   true,
+	"controller-manager": true,
+	"etcd":               true,
+	"kube-proxy":         true,
+	"kubelet":            true,
+	"scheduler":          true,
+}
+
+// CertificateExpiryWindow returns the duration before their expiry that the certificates of the controlplane are
+// considered to be expiring soon.
+func CertificateExpiryWindow(controlPlane *rkev1.RKEControlPlane) time.Duration {
+	days := defaultCertificateExpiryWindowDays
+	if controlPlane.Spec.CertificateExpiry != nil && controlPlane.Spec.CertificateExpiry.WindowDays > 0 {
+		days = controlPlane.Spec.CertificateExpiry.WindowDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ExpiringCertificates returns the machines and the services whose certificates expire within the given window, sorted
+// by name. The expiry of the certificates of a machine that was collected before the last certificate rotation finished
+// is outdated and ignored.
+func ExpiringCertificates(expiry []rkev1.MachineCertificateExpiry, rotationTime *metav1.Time, window time.Duration, now time.Time) ([]string, []string) {
+	machines := map[string]bool{}
+	services := map[string]bool{}
+	for _, m := range expiry {
+		if m.LastUpdateTime == nil || (rotationTime != nil && !m.LastUpdateTime.After(rotationTime.Time)) {
+			continue
+		}
+		for _, s := range m.Services {
+			if s.ExpiryTime.Time.Sub(now) < window {
+				machines[m.MachineName] = true
+				services[s.Service] = true
+			}
+		}
+	}
+	return sortedKeys(machines), sortedKeys(services)
+}
+
+// sortedKeys returns the keys of the given map in ascending order.
+func sortedKeys(m map[string]bool) []string {
+	var result []string
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// rotateExpiringCertificates requests a certificate rotation of the services whose certificates are expiring soon if
+// the automatic certificate rotation is enabled. All certificates are rotated if a certificate that does not belong to a
+// service that can be rotated individually is expiring. The rotation is requested in status, as the spec of the
+// controlplane is owned by the user. It returns an errWaiting after the rotation was requested.
+func (p *Planner) rotateExpiringCertificates(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) (rkev1.RKEControlPlaneStatus, error) {
+	if controlPlane.Spec.CertificateExpiry == nil || !controlPlane.Spec.CertificateExpiry.AutomaticRotation || !status.Initialized {
+		return status, nil
+	}
+
+	window := CertificateExpiryWindow(controlPlane)
+	machines, services := ExpiringCertificates(status.CertificateExpiry, status.CertificateRotationTime, window, time.Now())
+	// the certificates of services that were renewed by the last rotation, but still expire within the window, are issued
+	// with a lifetime that is shorter than the window, so rotating them again would not stop them from expiring soon
+	shortLived := shortLivedCertificateServices(status.CertificateExpiry, status.CertificateRotationTime, window)
+	lastRotation := certificateRotation(controlPlane, status)
+	var rotate []string
+	for _, service := range services {
+		if shortLived[service] && rotationContainsService(lastRotation, service) {
+			logrus.Warnf("[planner] rkecluster %s/%s: not rotating certificates of service %s again as they expire within the certificate expiry window of %s after they were rotated", controlPlane.Namespace, controlPlane.Name, service, window)
+			continue
+		}
+		rotate = append(rotate, service)
+	}
+	if len(rotate) == 0 {
+		return status, nil
+	}
+	services = rotate
+
+	logrus.Infof("[planner] rkecluster %s/%s: rotating certificates of services %v that are expiring soon on machines %v", controlPlane.Namespace, controlPlane.Name, services, machines)
+	runtime := capr.GetRuntime(controlPlane.Spec.KubernetesVersion)
+	for _, service := range services {
+		if !certificateRotationServices[service] && service != runtime+"-controller" && service != runtime+"-server" {
+			services = nil
+			break
+		}
+	}
+
+	generation := status.CertificateRotationGeneration
+	if lastRotation != nil && lastRotation.Generation > generation {
+		generation = lastRotation.Generation
+	}
+
+	status.CertificateExpiryRotation = &rkev1.RotateCertificates{
+		Generation: generation + 1,
+		Services:   services,
+	}
+	return status, errWaiting("requesting rotation of expiring certificates")
+}
+
+// shortLivedCertificateServices returns the services with a certificate that expires less than the given window after
+// the last certificate rotation finished. Only the expiry that was collected after the rotation finished is considered.
+func shortLivedCertificateServices(expiry []rkev1.MachineCertificateExpiry, rotationTime *metav1.Time, window time.Duration) map[string]bool {
+	services := map[string]bool{}
+	if rotationTime == nil {
+		return services
+	}
+	for _, m := range expiry {
+		if m.LastUpdateTime == nil || !m.LastUpdateTime.After(rotationTime.Time) {
+			continue
+		}
+		for _, s := range m.Services {
+			if s.ExpiryTime.Time.Sub(rotationTime.Time) < window {
+				services[s.Service] = true
+			}
+		}
+	}
+	return services
+}
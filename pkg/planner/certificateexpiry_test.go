@@ -0,0 +1,125 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCertificateExpiryWindow(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{}
+	assert.Equal(t, 30*24*time.Hour, CertificateExpiryWindow(cp))
+
+	cp.Spec.CertificateExpiry = &rkev1.CertificateExpiry{WindowDays: 7}
+	assert.Equal(t, 7*24*time.Hour, CertificateExpiryWindow(cp))
+}
+
+func TestExpiringCertificates(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(t time.Time) *metav1.Time {
+		return &metav1.Time{Time: t}
+	}
+	expiry := []rkev1.MachineCertificateExpiry{
+		{
+			MachineName:    "a",
+			LastUpdateTime: at(now.Add(-time.Hour)),
+			Services: []rkev1.ServiceCertificateExpiry{
+				{Service: "api-server", ExpiryTime: metav1.Time{Time: now.Add(24 * time.Hour)}},
+				{Service: "etcd", ExpiryTime: metav1.Time{Time: now.Add(365 * 24 * time.Hour)}},
+			},
+		},
+		{
+			MachineName:    "b",
+			LastUpdateTime: at(now.Add(-3 * time.Hour)),
+			Services: []rkev1.ServiceCertificateExpiry{
+				{Service: "kubelet", ExpiryTime: metav1.Time{Time: now.Add(-24 * time.Hour)}},
+			},
+		},
+		{
+			// the expiry was not collected successfully yet
+			MachineName: "c",
+			Services: []rkev1.ServiceCertificateExpiry{
+				{Service: "scheduler", ExpiryTime: metav1.Time{Time: now}},
+			},
+		},
+	}
+
+	machines, services := ExpiringCertificates(expiry, nil, 30*24*time.Hour, now)
+	assert.Equal(t, []string{"a", "b"}, machines)
+	assert.Equal(t, []string{"api-server", "kubelet"}, services)
+
+	// the expiry of machine b was collected before the last rotation finished
+	machines, services = ExpiringCertificates(expiry, at(now.Add(-2*time.Hour)), 30*24*time.Hour, now)
+	assert.Equal(t, []string{"a"}, machines)
+	assert.Equal(t, []string{"api-server"}, services)
+
+	machines, services = ExpiringCertificates(expiry, nil, time.Hour, now)
+	assert.Equal(t, []string{"b"}, machines)
+	assert.Equal(t, []string{"kubelet"}, services)
+}
+
+func TestRotateExpiringCertificates(t *testing.T) {
+	now := time.Now()
+	rotated := &metav1.Time{Time: now.Add(-2 * time.Hour)}
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.KubernetesVersion = "v1.28.5+rke2r1"
+	cp.Spec.CertificateExpiry = &rkev1.CertificateExpiry{AutomaticRotation: true}
+	cp.Spec.RotateCertificates = &rkev1.RotateCertificates{Generation: 2}
+	status := rkev1.RKEControlPlaneStatus{
+		Initialized:                   true,
+		CertificateRotationGeneration: 2,
+		CertificateRotationTime:       rotated,
+		CertificateExpiry: []rkev1.MachineCertificateExpiry{
+			{
+				MachineName:    "a",
+				LastUpdateTime: &metav1.Time{Time: now.Add(-time.Hour)},
+				Services: []rkev1.ServiceCertificateExpiry{
+					{Service: "kubelet", ExpiryTime: metav1.Time{Time: now.Add(24 * time.Hour)}},
+				},
+			},
+		},
+	}
+
+	// the rotation is requested in status, the spec of the controlplane is not updated
+	p := &Planner{}
+	cp.Spec.RotateCertificates.Services = []string{"etcd"}
+	newStatus, err := p.rotateExpiringCertificates(cp, status)
+	assert.True(t, IsErrWaiting(err))
+	assert.Equal(t, &rkev1.RotateCertificates{Generation: 3, Services: []string{"kubelet"}}, newStatus.CertificateExpiryRotation)
+	assert.Equal(t, int64(2), cp.Spec.RotateCertificates.Generation)
+	assert.Same(t, newStatus.CertificateExpiryRotation, certificateRotation(cp, newStatus))
+
+	// the certificates of the kubelet were renewed by the last rotation, but still expire within the window
+	cp.Spec.RotateCertificates.Services = nil
+	newStatus, err = p.rotateExpiringCertificates(cp, status)
+	assert.NoError(t, err)
+	assert.Nil(t, newStatus.CertificateExpiryRotation)
+}
+
+func TestShortLivedCertificateServices(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiry := []rkev1.MachineCertificateExpiry{
+		{
+			MachineName:    "a",
+			LastUpdateTime: &metav1.Time{Time: now.Add(time.Hour)},
+			Services: []rkev1.ServiceCertificateExpiry{
+				{Service: "kubelet", ExpiryTime: metav1.Time{Time: now.Add(10 * 24 * time.Hour)}},
+				{Service: "etcd", ExpiryTime: metav1.Time{Time: now.Add(365 * 24 * time.Hour)}},
+			},
+		},
+		{
+			// the expiry was collected before the rotation finished
+			MachineName:    "b",
+			LastUpdateTime: &metav1.Time{Time: now.Add(-time.Hour)},
+			Services: []rkev1.ServiceCertificateExpiry{
+				{Service: "scheduler", ExpiryTime: metav1.Time{Time: now}},
+			},
+		},
+	}
+
+	assert.Equal(t, map[string]bool{"kubelet": true}, shortLivedCertificateServices(expiry, &metav1.Time{Time: now}, 30*24*time.Hour))
+	assert.Empty(t, shortLivedCertificateServices(expiry, nil, 30*24*time.Hour))
+}
@@ -5,17 +5,20 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rancher/cluster-api-provider-rancher/pkg"
 	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // rotateCertificates checks if there is a need to rotate any certificates and updates the plan accordingly.
 func (p *Planner) rotateCertificates(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
-	if !shouldRotate(controlPlane) {
-		return status, nil
+	rotation := certificateRotation(controlPlane, status)
+	if !shouldRotate(controlPlane, rotation) {
+		return p.rotateExpiringCertificates(controlPlane, status)
 	}
 
 	found, joinServer, _, err := p.findInitNode(controlPlane, clusterPlan)
@@ -32,11 +35,11 @@ func (p *Planner) rotateCertificates(controlPlane *rkev1.RKEControlPlane, status
 	orderedEntriesToRotate := collectOrderedCertificateRotationEntries(clusterPlan)
 
 	for _, node := range orderedEntriesToRotate {
-		if !shouldRotateEntry(controlPlane.Spec.RotateCertificates, node) {
+		if !shouldRotateEntry(rotation, node) {
 			continue
 		}
 
-		rotatePlan, joinedServer, err := p.rotateCertificatesPlan(controlPlane, tokensSecret, controlPlane.Spec.RotateCertificates, node, joinServer)
+		rotatePlan, joinedServer, err := p.rotateCertificatesPlan(controlPlane, tokensSecret, rotation, node, joinServer)
 		if err != nil {
 			return status, err
 		}
@@ -55,7 +58,8 @@ func (p *Planner) rotateCertificates(controlPlane *rkev1.RKEControlPlane, status
 		return status, errWaiting("unpausing CAPI cluster")
 	}
 
-	status.CertificateRotationGeneration = controlPlane.Spec.RotateCertificates.Generation
+	status.CertificateRotationGeneration = rotation.Generation
+	status.CertificateRotationTime = &metav1.Time{Time: time.Now().UTC().Truncate(time.Second)}
 	return status, errWaiting("certificate rotation done")
 }
 
@@ -67,10 +71,20 @@ func collectOrderedCertificateRotationEntries(clusterPlan *plan.Plan) []*planEnt
 	return orderedEntriesToRotate
 }
 
-// shouldRotate `true` if the cluster is ready and the generation is stale
-func shouldRotate(cp *rkev1.RKEControlPlane) bool {
+// certificateRotation returns the certificate rotation that is run for the controlplane. This is the certificate
+// rotation of the spec, unless the automatic rotation of expiring certificates requested a newer rotation in status.
+func certificateRotation(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) *rkev1.RotateCertificates {
+	if expiryRotation := status.CertificateExpiryRotation; expiryRotation != nil &&
+		(cp.Spec.RotateCertificates == nil || cp.Spec.RotateCertificates.Generation < expiryRotation.Generation) {
+		return expiryRotation
+	}
+	return cp.Spec.RotateCertificates
+}
+
+// shouldRotate `true` if the cluster is ready and the generation of the given rotation is stale
+func shouldRotate(cp *rkev1.RKEControlPlane, rotation *rkev1.RotateCertificates) bool {
 	// if a spec is not defined there is nothing to do
-	if cp.Spec.RotateCertificates == nil {
+	if rotation == nil {
 		return false
 	}
 
@@ -81,7 +95,7 @@ func shouldRotate(cp *rkev1.RKEControlPlane) bool {
 	}
 
 	// if this generation has already been applied there is no work
-	return cp.Status.CertificateRotationGeneration != cp.Spec.RotateCertificates.Generation
+	return cp.Status.CertificateRotationGeneration != rotation.Generation
 }
 
 // rotateCertificatesPlan rotates the certificates for the services specified, if any, and restarts the service.  If no services are specified
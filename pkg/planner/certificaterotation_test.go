@@ -5,10 +5,8 @@ import (
 	"testing"
 
 	"github.com/rancher/cluster-api-provider-rancher/pkg"
-	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
-	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
-	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
-	"github.com/rancher/rancher/pkg/provisioningv2/image"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -246,10 +244,7 @@ func Test_rotateCertificatesPlan(t *testing.T) {
 		joinServer string
 	}
 
-	genericSetup := func(mp *mockPlanner) {
-		mp.clusterRegistrationTokenCache.EXPECT().GetByIndex(clusterRegToken, "somecluster").Return([]*v3.ClusterRegistrationToken{{Status: v3.ClusterRegistrationTokenStatus{Token: "lol"}}}, nil)
-		mp.managementClusters.EXPECT().Get("somecluster").Return(&v3.Cluster{}, nil)
-	}
+	genericSetup := func(mp *mockPlanner) {}
 
 	tests := []struct {
 		name                string
@@ -424,7 +419,7 @@ func Test_rotateCertificatesPlan(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockPlanner := newMockPlanner(t, InfoFunctions{
 				SystemAgentImage: func() string { return "system-agent" },
-				ImageResolver:    image.ResolveWithControlPlane,
+				ImageResolver:    resolveWithControlPlane,
 			})
 			if tt.setup != nil {
 				tt.setup(mockPlanner)
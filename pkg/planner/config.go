@@ -53,7 +53,10 @@ func (p *Planner) addETCD(config map[string]interface{}, controlPlane *rkev1.RKE
 		config["etcd-snapshot-schedule-cron"] = controlPlane.Spec.ETCD.SnapshotScheduleCron
 	}
 
-	if renderS3 {
+	// the distro would upload its snapshots to S3 unencrypted, so S3 is only configured for it if snapshots are not encrypted.
+	// The validation of the controlplane requires the scheduled snapshots of the distro to be disabled in that case, so that
+	// they don't silently stay local.
+	if renderS3 && !etcdSnapshotEncryptionEnabled(controlPlane) {
 		args, _, files, err := p.etcdS3Args.ToArgs(controlPlane.Spec.ETCD.S3, controlPlane, "etcd-", false)
 		if err != nil {
 			return nil, err
@@ -79,7 +82,7 @@ func addDefaults(config map[string]interface{}, controlPlane *rkev1.RKEControlPl
 	}
 }
 
-func addUserConfig(config map[string]interface{}, controlPlane *rkev1.RKEControlPlane, entry *planEntry) error {
+func (p *Planner) addUserConfig(config map[string]interface{}, controlPlane *rkev1.RKEControlPlane, entry *planEntry) error {
 	for k, v := range controlPlane.Spec.MachineGlobalConfig.Data {
 		config[k] = v
 	}
@@ -96,7 +99,7 @@ func addUserConfig(config map[string]interface{}, controlPlane *rkev1.RKEControl
 		}
 	}
 
-	filterConfigData(config, controlPlane, entry)
+	filterConfigData(config, p.retrievalFunctions.ReleaseData(p.ctx, controlPlane), entry)
 
 	// "data-dir" is explicitly not added to KDM for filtering because it is mapped to a field in the provisioning cluster
 	// CRD. While technically possible to add feature gates and update KDM, there is nothing to be gained from such an
@@ -181,16 +184,70 @@ func addLocalClusterAuthenticationEndpointConfig(config map[string]interface{},
 		return
 	}
 
+	if oidc := controlPlane.Spec.LocalClusterAuthEndpoint.OIDC; oidc != nil {
+		addLocalClusterAuthenticationEndpointOIDCConfig(config, controlPlane, oidc)
+		return
+	}
+
 	authFile := path.Join(capr.GetDistroDataDir(controlPlane), authnWebhookFileName)
 	config["kube-apiserver-arg"] = append(convert.ToStringSlice(config["kube-apiserver-arg"]),
 		fmt.Sprintf("authentication-token-webhook-config-file=%s", authFile))
 }
 
+// addLocalClusterAuthenticationEndpointOIDCConfig configures the kube-apiserver to authenticate users with the ID tokens
+// of the given OpenID provider, and mounts the certificate authority bundle of the provider into the kube-apiserver of
+// rke2.
+func addLocalClusterAuthenticationEndpointOIDCConfig(config map[string]interface{}, controlPlane *rkev1.RKEControlPlane, oidc *rkev1.LocalClusterAuthEndpointOIDC) {
+	args := []string{
+		fmt.Sprintf("oidc-issuer-url=%s", oidc.IssuerURL),
+		fmt.Sprintf("oidc-client-id=%s", oidc.ClientID),
+	}
+	if oidc.UsernameClaim != "" {
+		args = append(args, fmt.Sprintf("oidc-username-claim=%s", oidc.UsernameClaim))
+	}
+	if oidc.UsernamePrefix != "" {
+		args = append(args, fmt.Sprintf("oidc-username-prefix=%s", oidc.UsernamePrefix))
+	}
+	if oidc.GroupsClaim != "" {
+		args = append(args, fmt.Sprintf("oidc-groups-claim=%s", oidc.GroupsClaim))
+	}
+	if oidc.GroupsPrefix != "" {
+		args = append(args, fmt.Sprintf("oidc-groups-prefix=%s", oidc.GroupsPrefix))
+	}
+	claims := make([]string, 0, len(oidc.RequiredClaims))
+	for claim := range oidc.RequiredClaims {
+		claims = append(claims, claim)
+	}
+	sort.Strings(claims)
+	for _, claim := range claims {
+		args = append(args, fmt.Sprintf("oidc-required-claim=%s=%s", claim, oidc.RequiredClaims[claim]))
+	}
+	if oidc.CACerts != "" {
+		caFile := path.Join(capr.GetDistroDataDir(controlPlane), oidcCAFileName)
+		args = append(args, fmt.Sprintf("oidc-ca-file=%s", caFile))
+		if capr.GetRuntime(controlPlane.Spec.KubernetesVersion) == capr.RuntimeRKE2 {
+			config["kube-apiserver-extra-mount"] = append(convert.ToStringSlice(config["kube-apiserver-extra-mount"]),
+				fmt.Sprintf("%s:%s:ro", caFile, caFile))
+		}
+	}
+	config["kube-apiserver-arg"] = append(convert.ToStringSlice(config["kube-apiserver-arg"]), args...)
+}
+
 func addLocalClusterAuthenticationEndpointFile(nodePlan plan.NodePlan, controlPlane *rkev1.RKEControlPlane, entry *planEntry) plan.NodePlan {
 	if isOnlyWorker(entry) || !controlPlane.Spec.LocalClusterAuthEndpoint.Enabled {
 		return nodePlan
 	}
 
+	if oidc := controlPlane.Spec.LocalClusterAuthEndpoint.OIDC; oidc != nil {
+		if oidc.CACerts != "" {
+			nodePlan.Files = append(nodePlan.Files, plan.File{
+				Content: base64.StdEncoding.EncodeToString([]byte(oidc.CACerts)),
+				Path:    path.Join(capr.GetDistroDataDir(controlPlane), oidcCAFileName),
+			})
+		}
+		return nodePlan
+	}
+
 	loopbackAddress := capr.GetLoopbackAddress(controlPlane)
 	authFile := path.Join(capr.GetDistroDataDir(controlPlane), authnWebhookFileName)
 	nodePlan.Files = append(nodePlan.Files, plan.File{
@@ -211,16 +268,16 @@ func (p *Planner) addManifests(nodePlan plan.NodePlan, controlPlane *rkev1.RKECo
 	return nodePlan, nil
 }
 
-func isVSphereProvider(controlPlane *rkev1.RKEControlPlane, entry *planEntry) (bool, error) {
+func (p *Planner) isVSphereProvider(controlPlane *rkev1.RKEControlPlane, entry *planEntry) (bool, error) {
 	data := map[string]interface{}{}
-	if err := addUserConfig(data, controlPlane, entry); err != nil {
+	if err := p.addUserConfig(data, controlPlane, entry); err != nil {
 		return false, err
 	}
 	return data["cloud-provider-name"] == "rancher-vsphere", nil
 }
 
-func addVSphereCharts(controlPlane *rkev1.RKEControlPlane, entry *planEntry) (map[string]interface{}, error) {
-	if isVSphere, err := isVSphereProvider(controlPlane, entry); err != nil {
+func (p *Planner) addVSphereCharts(controlPlane *rkev1.RKEControlPlane, entry *planEntry) (map[string]interface{}, error) {
+	if isVSphere, err := p.isVSphereProvider(controlPlane, entry); err != nil {
 		return nil, err
 	} else if isVSphere && controlPlane.Spec.ChartValues.Data["rancher-vsphere-csi"] == nil {
 		// ensure we have this chart config so that the global.cattle.clusterId is set
@@ -255,7 +312,7 @@ func (p *Planner) addChartConfigs(nodePlan plan.NodePlan, controlPlane *rkev1.RK
 		return nodePlan, nil
 	}
 
-	chartValues, err := addVSphereCharts(controlPlane, entry)
+	chartValues, err := p.addVSphereCharts(controlPlane, entry)
 	if err != nil {
 		return nodePlan, err
 	}
@@ -573,7 +630,7 @@ func (p *Planner) addConfigFile(nodePlan plan.NodePlan, controlPlane *rkev1.RKEC
 	addDefaults(config, controlPlane)
 
 	// Must call addUserConfig first because it will filter out non-kdm data
-	if err := addUserConfig(config, controlPlane, entry); err != nil {
+	if err := p.addUserConfig(config, controlPlane, entry); err != nil {
 		return nodePlan, config, "", err
 	}
 
@@ -589,8 +646,21 @@ func (p *Planner) addConfigFile(nodePlan plan.NodePlan, controlPlane *rkev1.RKEC
 	}
 
 	addLocalClusterAuthenticationEndpointConfig(config, controlPlane, entry)
+	if err := addSecretsEncryptionKMSConfig(config, controlPlane, entry); err != nil {
+		return nodePlan, config, joinedServer, err
+	}
+	addSecretsEncryptionConfig(config, controlPlane, entry)
+	if err := addHardeningProfileConfig(config, controlPlane, entry); err != nil {
+		return nodePlan, config, joinedServer, err
+	}
 	addToken(config, entry, tokensSecret)
 
+	files, err = p.addAuditLogConfig(config, controlPlane, entry)
+	if err != nil {
+		return nodePlan, config, joinedServer, err
+	}
+	nodePlan.Files = append(nodePlan.Files, files...)
+
 	if err := addAddresses(p.secretCache, config, entry); err != nil {
 		return nodePlan, config, joinedServer, err
 	}
@@ -0,0 +1,54 @@
+package planner
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddLocalClusterAuthenticationEndpointOIDC(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.KubernetesVersion = "v1.30.4+rke2r1"
+	cp.Spec.LocalClusterAuthEndpoint = rkev1.LocalClusterAuthEndpoint{
+		Enabled: true,
+		OIDC: &rkev1.LocalClusterAuthEndpointOIDC{
+			IssuerURL:      "https://issuer.example.com",
+			ClientID:       "kubernetes",
+			UsernameClaim:  "email",
+			GroupsClaim:    "groups",
+			GroupsPrefix:   "oidc:",
+			RequiredClaims: map[string]string{"hd": "example.com", "aud": "kubernetes"},
+			CACerts:        "ca",
+		},
+	}
+	controlPlaneEntry := &planEntry{Metadata: &plan.Metadata{Labels: map[string]string{capr.ControlPlaneRoleLabel: "true"}}}
+	workerEntry := &planEntry{Metadata: &plan.Metadata{Labels: map[string]string{capr.WorkerRoleLabel: "true"}}}
+
+	config := map[string]interface{}{}
+	addLocalClusterAuthenticationEndpointConfig(config, cp, controlPlaneEntry)
+	assert.Equal(t, []string{
+		"oidc-issuer-url=https://issuer.example.com",
+		"oidc-client-id=kubernetes",
+		"oidc-username-claim=email",
+		"oidc-groups-claim=groups",
+		"oidc-groups-prefix=oidc:",
+		"oidc-required-claim=aud=kubernetes",
+		"oidc-required-claim=hd=example.com",
+		"oidc-ca-file=/var/lib/rancher/rke2/kube-api-oidc-ca.crt",
+	}, config["kube-apiserver-arg"])
+	assert.Equal(t, []string{"/var/lib/rancher/rke2/kube-api-oidc-ca.crt:/var/lib/rancher/rke2/kube-api-oidc-ca.crt:ro"}, config["kube-apiserver-extra-mount"])
+
+	nodePlan := addLocalClusterAuthenticationEndpointFile(plan.NodePlan{}, cp, controlPlaneEntry)
+	if assert.Len(t, nodePlan.Files, 1) {
+		assert.Equal(t, "/var/lib/rancher/rke2/kube-api-oidc-ca.crt", nodePlan.Files[0].Path)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("ca")), nodePlan.Files[0].Content)
+	}
+
+	config = map[string]interface{}{}
+	addLocalClusterAuthenticationEndpointConfig(config, cp, workerEntry)
+	assert.Empty(t, config)
+}
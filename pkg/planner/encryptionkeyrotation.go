@@ -6,6 +6,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/blang/semver"
 	"github.com/pkg/errors"
@@ -15,6 +16,7 @@ import (
 	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -93,12 +95,18 @@ exit 1
 `
 
 	encryptionKeyRotationEndpointEnv = "CONTAINER_RUNTIME_ENDPOINT=unix:///var/run/k3s/containerd/containerd.sock"
+
+	// maxEncryptionKeyRotationHistory is the number of past encryption key rotations that are kept in status.
+	maxEncryptionKeyRotationHistory = 10
 )
 
 func (p *Planner) setEncryptionKeyRotateState(status rkev1.RKEControlPlaneStatus, rotate *rkev1.RotateEncryptionKeys, phase rkev1.RotateEncryptionKeysPhase) (rkev1.RKEControlPlaneStatus, error) {
 	if equality.Semantic.DeepEqual(status.RotateEncryptionKeys, rotate) && equality.Semantic.DeepEqual(status.RotateEncryptionKeysPhase, phase) {
 		return status, nil
 	}
+	if phase == rkev1.RotateEncryptionKeysPhaseDone || phase == rkev1.RotateEncryptionKeysPhaseFailed {
+		status = recordEncryptionKeyRotation(status, rotate, phase, time.Now())
+	}
 	status.RotateEncryptionKeys = rotate
 	status.RotateEncryptionKeysPhase = phase
 	return status, errWaiting("refreshing encryption key rotation state")
@@ -118,15 +126,24 @@ func (p *Planner) rotateEncryptionKeys(controlPlane *rkev1.RKEControlPlane, stat
 		return status, fmt.Errorf("cannot pass nil parameters to rotateEncryptionKeys")
 	}
 
+	var err error
+	if status, err = p.scheduleEncryptionKeyRotation(controlPlane, status); err != nil {
+		return status, err
+	}
+	controlPlane = withEncryptionKeyRotation(controlPlane, status)
+
 	if controlPlane.Spec.RotateEncryptionKeys == nil {
 		return p.resetEncryptionKeyRotateState(status)
 	}
 
-	if supported, err := encryptionKeyRotationSupported(releaseData); err != nil {
-		return status, err
-	} else if !supported {
-		logrus.Debugf("rkecluster %s/%s: marking encryption key rotation phase as failed as it was not supported by version: %s", controlPlane.Namespace, controlPlane.Name, controlPlane.Spec.KubernetesVersion)
-		return p.setEncryptionKeyRotateState(status, controlPlane.Spec.RotateEncryptionKeys, rkev1.RotateEncryptionKeysPhaseFailed)
+	// the encryption keys of KMS-backed clusters are not rotated by the distro
+	if !secretsEncryptionKMSEnabled(controlPlane) {
+		if supported, err := encryptionKeyRotationSupported(releaseData); err != nil {
+			return status, err
+		} else if !supported {
+			logrus.Debugf("rkecluster %s/%s: marking encryption key rotation phase as failed as it was not supported by version: %s", controlPlane.Namespace, controlPlane.Name, controlPlane.Spec.KubernetesVersion)
+			return p.setEncryptionKeyRotateState(status, controlPlane.Spec.RotateEncryptionKeys, rkev1.RotateEncryptionKeysPhaseFailed)
+		}
 	}
 
 	if !canRotateEncryptionKeys(controlPlane) {
@@ -166,6 +183,10 @@ func (p *Planner) rotateEncryptionKeys(controlPlane *rkev1.RKEControlPlane, stat
 
 	logrus.Debugf("[planner] rkecluster %s/%s: current encryption key rotation phase: [%s]", controlPlane.Namespace, controlPlane.Spec.ClusterName, controlPlane.Status.RotateEncryptionKeysPhase)
 
+	if secretsEncryptionKMSEnabled(controlPlane) {
+		return p.rotateKMSEncryptionKeys(controlPlane, status, tokensSecret, joinServer, leader)
+	}
+
 	switch controlPlane.Status.RotateEncryptionKeysPhase {
 	case rkev1.RotateEncryptionKeysPhasePrepare:
 		if err := p.pauseCAPICluster(controlPlane, true); err != nil {
@@ -215,6 +236,46 @@ func (p *Planner) rotateEncryptionKeys(controlPlane *rkev1.RKEControlPlane, stat
 	return status, fmt.Errorf("encountered unknown encryption key rotation phase: %s", controlPlane.Status.RotateEncryptionKeysPhase)
 }
 
+// rotateKMSEncryptionKeys reencrypts all secrets on the leader with the current key of the KMS, as the keys of
+// KMS-backed clusters are rotated by the KMS instead of the distro. The phases are Prepare -> Reencrypt -> Done, the
+// distro does not need to be restarted.
+func (p *Planner) rotateKMSEncryptionKeys(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, joinServer string, leader *planEntry) (rkev1.RKEControlPlaneStatus, error) {
+	switch controlPlane.Status.RotateEncryptionKeysPhase {
+	case rkev1.RotateEncryptionKeysPhasePrepare:
+		return p.setEncryptionKeyRotateState(status, controlPlane.Spec.RotateEncryptionKeys, rkev1.RotateEncryptionKeysPhaseReencrypt)
+	case rkev1.RotateEncryptionKeysPhaseReencrypt:
+		nodePlan, joinedServer, err := p.desiredPlan(controlPlane, tokensSecret, leader, joinServer)
+		if err != nil {
+			return status, err
+		}
+		kubectl := kubectlCommand(controlPlane)
+		nodePlan.Instructions = append(nodePlan.Instructions, idempotentInstruction(
+			controlPlane,
+			"encryption-key-rotation/kms-reencrypt",
+			strconv.FormatInt(controlPlane.Spec.RotateEncryptionKeys.Generation, 10),
+			"/bin/sh",
+			[]string{
+				"-c",
+				fmt.Sprintf("%s get secrets --all-namespaces -o json | %s replace -f -", kubectl, kubectl),
+			},
+			[]string{},
+		))
+		err = assignAndCheckPlan(p.store, fmt.Sprintf("encryption key rotation [%s] for machine [%s]", controlPlane.Status.RotateEncryptionKeysPhase, leader.Machine.Name), leader, nodePlan, joinedServer, 1, 1)
+		if err != nil {
+			if IsErrWaiting(err) {
+				return status, err
+			}
+			status, _ = p.setEncryptionKeyRotateState(status, controlPlane.Spec.RotateEncryptionKeys, rkev1.RotateEncryptionKeysPhaseFailed)
+			return status, fmt.Errorf("reencrypting secrets with the current key of the KMS failed: %w", err)
+		}
+		logrus.Infof("[planner] rkecluster %s/%s: reencrypted secrets with the current key of the KMS", controlPlane.Namespace, controlPlane.Name)
+		status.RotateEncryptionKeysLeader = ""
+		return p.setEncryptionKeyRotateState(status, controlPlane.Spec.RotateEncryptionKeys, rkev1.RotateEncryptionKeysPhaseDone)
+	}
+
+	return status, fmt.Errorf("encountered unknown encryption key rotation phase for KMS-backed cluster: %s", controlPlane.Status.RotateEncryptionKeysPhase)
+}
+
 // encryptionKeyRotationSupported returns a boolean indicating whether encryption key rotation is supported by the release,
 // and an error if one was encountered.
 func encryptionKeyRotationSupported(releaseData *model.Release) (bool, error) {
@@ -750,6 +811,9 @@ func encryptionKeyRotationIsCurrentStageAllowed(leaderStage string, currentPhase
 // encryptionKeyRotationFailed updates the various status objects on the control plane, allowing the cluster to
 // continue the reconciliation loop. Encryption key rotation will not be restarted again until requested.
 func (p *Planner) encryptionKeyRotationFailed(status rkev1.RKEControlPlaneStatus, err error) (rkev1.RKEControlPlaneStatus, error) {
+	if status.RotateEncryptionKeysPhase != rkev1.RotateEncryptionKeysPhaseFailed {
+		status = recordEncryptionKeyRotation(status, status.RotateEncryptionKeys, rkev1.RotateEncryptionKeysPhaseFailed, time.Now())
+	}
 	status.RotateEncryptionKeysPhase = rkev1.RotateEncryptionKeysPhaseFailed
 	return status, errors.Wrap(err, "encryption key rotation failed, please perform an etcd restore")
 }
@@ -757,3 +821,89 @@ func (p *Planner) encryptionKeyRotationFailed(status rkev1.RKEControlPlaneStatus
 func encryptionKeyRotationScriptPath(controlPlane *rkev1.RKEControlPlane, file string) string {
 	return path.Join(capr.GetDistroDataDir(controlPlane), encryptionKeyRotationBinPrefix, file)
 }
+
+// recordEncryptionKeyRotation adds the outcome of the given encryption key rotation to the history of encryption key
+// rotations, dropping the oldest records beyond maxEncryptionKeyRotationHistory.
+func recordEncryptionKeyRotation(status rkev1.RKEControlPlaneStatus, rotate *rkev1.RotateEncryptionKeys, phase rkev1.RotateEncryptionKeysPhase, now time.Time) rkev1.RKEControlPlaneStatus {
+	if rotate == nil {
+		return status
+	}
+	history := append([]rkev1.EncryptionKeyRotationRecord{}, status.RotateEncryptionKeysHistory...)
+	history = append(history, rkev1.EncryptionKeyRotationRecord{
+		Generation: rotate.Generation,
+		Phase:      phase,
+		Time:       metav1.Time{Time: now.UTC().Truncate(time.Second)},
+	})
+	if len(history) > maxEncryptionKeyRotationHistory {
+		history = history[len(history)-maxEncryptionKeyRotationHistory:]
+	}
+	status.RotateEncryptionKeysHistory = history
+	return status
+}
+
+// encryptionKeyRotationDue returns true if the interval of the encryption key rotation policy has passed since the last
+// encryption key rotation ended, regardless of its outcome, or since the controlplane was created if the encryption keys
+// were never rotated.
+func encryptionKeyRotationDue(controlPlane *rkev1.RKEControlPlane, history []rkev1.EncryptionKeyRotationRecord, now time.Time) bool {
+	last := controlPlane.CreationTimestamp.Time
+	if len(history) > 0 {
+		last = history[len(history)-1].Time.Time
+	}
+	return now.Sub(last) >= time.Duration(controlPlane.Spec.EncryptionKeyRotationPolicy.IntervalDays)*24*time.Hour
+}
+
+// encryptionKeyRotation returns the encryption key rotation that is run for the controlplane. This is the encryption
+// key rotation of the spec, unless the encryption key rotation policy requested a newer rotation in status.
+func encryptionKeyRotation(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) *rkev1.RotateEncryptionKeys {
+	if scheduled := status.ScheduledRotateEncryptionKeys; scheduled != nil &&
+		(controlPlane.Spec.RotateEncryptionKeys == nil || controlPlane.Spec.RotateEncryptionKeys.Generation < scheduled.Generation) {
+		return scheduled
+	}
+	return controlPlane.Spec.RotateEncryptionKeys
+}
+
+// withEncryptionKeyRotation returns the controlplane with the encryption key rotation that is run for it set in its
+// spec. The returned controlplane is only used to run the rotation, and must never be updated.
+func withEncryptionKeyRotation(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) *rkev1.RKEControlPlane {
+	rotation := encryptionKeyRotation(controlPlane, status)
+	if rotation == controlPlane.Spec.RotateEncryptionKeys {
+		return controlPlane
+	}
+	controlPlane = controlPlane.DeepCopy()
+	controlPlane.Spec.RotateEncryptionKeys = rotation.DeepCopy()
+	return controlPlane
+}
+
+// scheduleEncryptionKeyRotation requests an encryption key rotation by incrementing its generation if the encryption key
+// rotation policy of the controlplane is due, secrets are encrypted, the cluster is ready and no encryption key rotation
+// is pending. The rotation is requested in status, as the spec of the controlplane is owned by the user. It returns an
+// errWaiting after the rotation was requested.
+func (p *Planner) scheduleEncryptionKeyRotation(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) (rkev1.RKEControlPlaneStatus, error) {
+	policy := controlPlane.Spec.EncryptionKeyRotationPolicy
+	if policy == nil || policy.IntervalDays <= 0 || !status.Initialized || !capr.Ready.IsTrue(controlPlane) || rotateEncryptionKeyInProgress(controlPlane) {
+		return status, nil
+	}
+	if !secretsEncryptionEnabled(controlPlane, status) {
+		logrus.Debugf("[planner] rkecluster %s/%s: skipping scheduled encryption key rotation as secrets encryption is not enabled", controlPlane.Namespace, controlPlane.Name)
+		return status, nil
+	}
+
+	generation := int64(0)
+	if rotate := encryptionKeyRotation(controlPlane, status); rotate != nil {
+		if status.RotateEncryptionKeys == nil || status.RotateEncryptionKeys.Generation != rotate.Generation {
+			// the requested rotation was not started yet
+			return status, nil
+		}
+		generation = rotate.Generation
+	}
+
+	if !encryptionKeyRotationDue(controlPlane, status.RotateEncryptionKeysHistory, time.Now()) {
+		return status, nil
+	}
+
+	logrus.Infof("[planner] rkecluster %s/%s: requesting encryption key rotation as the rotation interval of %d days has passed", controlPlane.Namespace, controlPlane.Name, policy.IntervalDays)
+	status.ScheduledRotateEncryptionKeys = &rkev1.RotateEncryptionKeys{
+		Generation: generation + 1,
+	}
+	return status, errWaiting("requesting scheduled encryption key rotation")
+}
@@ -0,0 +1,84 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecordEncryptionKeyRotation(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	status := recordEncryptionKeyRotation(rkev1.RKEControlPlaneStatus{}, nil, rkev1.RotateEncryptionKeysPhaseDone, now)
+	assert.Empty(t, status.RotateEncryptionKeysHistory)
+
+	for i := int64(1); i <= maxEncryptionKeyRotationHistory+2; i++ {
+		status = recordEncryptionKeyRotation(status, &rkev1.RotateEncryptionKeys{Generation: i}, rkev1.RotateEncryptionKeysPhaseDone, now)
+	}
+	status = recordEncryptionKeyRotation(status, &rkev1.RotateEncryptionKeys{Generation: 13}, rkev1.RotateEncryptionKeysPhaseFailed, now)
+
+	assert.Len(t, status.RotateEncryptionKeysHistory, maxEncryptionKeyRotationHistory)
+	assert.Equal(t, int64(4), status.RotateEncryptionKeysHistory[0].Generation)
+	assert.Equal(t, rkev1.EncryptionKeyRotationRecord{
+		Generation: 13,
+		Phase:      rkev1.RotateEncryptionKeysPhaseFailed,
+		Time:       metav1.Time{Time: now},
+	}, status.RotateEncryptionKeysHistory[maxEncryptionKeyRotationHistory-1])
+}
+
+func TestEncryptionKeyRotationDue(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cp := &rkev1.RKEControlPlane{}
+	cp.CreationTimestamp = metav1.Time{Time: created}
+	cp.Spec.EncryptionKeyRotationPolicy = &rkev1.EncryptionKeyRotationPolicy{IntervalDays: 30}
+
+	assert.False(t, encryptionKeyRotationDue(cp, nil, created.Add(29*24*time.Hour)))
+	assert.True(t, encryptionKeyRotationDue(cp, nil, created.Add(30*24*time.Hour)))
+
+	history := []rkev1.EncryptionKeyRotationRecord{
+		{Generation: 1, Phase: rkev1.RotateEncryptionKeysPhaseDone, Time: metav1.Time{Time: created.Add(20 * 24 * time.Hour)}},
+	}
+	assert.False(t, encryptionKeyRotationDue(cp, history, created.Add(30*24*time.Hour)))
+	assert.True(t, encryptionKeyRotationDue(cp, history, created.Add(50*24*time.Hour)))
+}
+
+func TestScheduleEncryptionKeyRotation(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{}
+	cp.CreationTimestamp = metav1.Time{Time: time.Now().Add(-60 * 24 * time.Hour)}
+	cp.Spec.KubernetesVersion = "v1.28.5+rke2r1"
+	cp.Spec.EncryptionKeyRotationPolicy = &rkev1.EncryptionKeyRotationPolicy{IntervalDays: 30}
+	cp.Spec.RotateEncryptionKeys = &rkev1.RotateEncryptionKeys{Generation: 1}
+	capr.Ready.True(cp)
+	status := rkev1.RKEControlPlaneStatus{
+		Initialized:              true,
+		RotateEncryptionKeys:     &rkev1.RotateEncryptionKeys{Generation: 1},
+		SecretsEncryptionEnabled: &[]bool{true}[0],
+	}
+
+	// the rotation is requested in status, the spec of the controlplane is not updated
+	p := &Planner{}
+	newStatus, err := p.scheduleEncryptionKeyRotation(cp, status)
+	assert.True(t, IsErrWaiting(err))
+	assert.Equal(t, &rkev1.RotateEncryptionKeys{Generation: 2}, newStatus.ScheduledRotateEncryptionKeys)
+	assert.Equal(t, int64(1), cp.Spec.RotateEncryptionKeys.Generation)
+	assert.Equal(t, int64(2), withEncryptionKeyRotation(cp, newStatus).Spec.RotateEncryptionKeys.Generation)
+
+	// the older rotation of the spec does not replace the scheduled rotation once it was run
+	newStatus.RotateEncryptionKeys = &rkev1.RotateEncryptionKeys{Generation: 2}
+	newStatus.RotateEncryptionKeysHistory = []rkev1.EncryptionKeyRotationRecord{
+		{Generation: 2, Phase: rkev1.RotateEncryptionKeysPhaseDone, Time: metav1.Now()},
+	}
+	assert.Same(t, newStatus.ScheduledRotateEncryptionKeys, encryptionKeyRotation(cp, newStatus))
+	_, err = p.scheduleEncryptionKeyRotation(cp, newStatus)
+	assert.NoError(t, err)
+
+	// the keys are not rotated if secrets are not encrypted
+	status.SecretsEncryptionEnabled = &[]bool{false}[0]
+	newStatus, err = p.scheduleEncryptionKeyRotation(cp, status)
+	assert.NoError(t, err)
+	assert.Nil(t, newStatus.ScheduledRotateEncryptionKeys)
+}
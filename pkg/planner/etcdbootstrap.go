@@ -0,0 +1,256 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/wrangler/v3/pkg/name"
+	"github.com/rancher/wrangler/v3/pkg/randomtoken"
+	"github.com/sirupsen/logrus"
+)
+
+const ETCDBootstrapMessage = "etcd snapshot bootstrap"
+
+// etcdSnapshotBootstrapInProgress returns true if the controlplane is or should be bootstrapped from an etcd snapshot.
+// A bootstrap is only started if the cluster was never initialized.
+func etcdSnapshotBootstrapInProgress(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) bool {
+	if cp.Spec.ETCDSnapshotBootstrap == nil || status.ETCDSnapshotBootstrapPhase == rkev1.ETCDSnapshotPhaseFinished {
+		return false
+	}
+	if status.ETCDSnapshotBootstrapPhase == "" && (status.Initialized || capr.Bootstrapped.IsTrue(&status)) {
+		return false
+	}
+	return true
+}
+
+// bootstrapEtcdSnapshot restores the etcd snapshot referenced by the controlplane on the init node of a new cluster. The
+// phases are in order:
+// Restore -> The snapshot is restored on the init node, which resets the etcd membership of the snapshot.
+// RestartCluster -> The init node is started with the tokens of the cluster the snapshot was taken from, the server token
+// is rotated if requested, and the nodes of the cluster the snapshot was taken from are removed.
+// Finished -> The cluster is reconciled as usual.
+func (p *Planner) bootstrapEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
+	if !etcdSnapshotBootstrapInProgress(cp, status) {
+		if cp.Spec.ETCDSnapshotBootstrap != nil && status.ETCDSnapshotBootstrapPhase == "" {
+			logrus.Debugf("[planner] rkecluster %s/%s: ignoring etcd snapshot bootstrap as cluster was already initialized", cp.Namespace, cp.Name)
+		}
+		return status, nil
+	}
+
+	sourceTokens, err := p.etcdSnapshotBootstrapTokens(cp)
+	if err != nil {
+		return status, err
+	}
+
+	switch status.ETCDSnapshotBootstrapPhase {
+	case rkev1.ETCDSnapshotPhaseRestore:
+		snapshotName, s3, err := p.etcdSnapshotBootstrapSource(cp)
+		if err != nil {
+			return status, err
+		}
+		if err := p.runEtcdSnapshotBootstrapRestorePlan(cp, snapshotName, s3, sourceTokens, clusterPlan); err != nil {
+			return status, err
+		}
+		status.ETCDSnapshotBootstrapPhase = rkev1.ETCDSnapshotPhaseRestartCluster
+		return status, errWaiting("etcd snapshot restored, restarting init node")
+	case rkev1.ETCDSnapshotPhaseRestartCluster:
+		if err := p.runEtcdSnapshotBootstrapRestartPlan(cp, sourceTokens, tokensSecret, clusterPlan); err != nil {
+			return status, err
+		}
+		status.ETCDSnapshotBootstrapPhase = rkev1.ETCDSnapshotPhaseFinished
+		return status, errWaiting("etcd snapshot bootstrap complete, reconciling cluster")
+	default:
+		logrus.Infof("[planner] rkecluster %s/%s: bootstrapping cluster from etcd snapshot", cp.Namespace, cp.Name)
+		status.ETCDSnapshotBootstrapPhase = rkev1.ETCDSnapshotPhaseRestore
+		return status, errWaiting("bootstrapping cluster from etcd snapshot")
+	}
+}
+
+// etcdSnapshotBootstrapSource returns the file name and S3 location of the snapshot the controlplane is bootstrapped
+// from. Only S3 snapshots can be restored into a new cluster, as local snapshots are stored on the machines of the
+// cluster they were taken from.
+func (p *Planner) etcdSnapshotBootstrapSource(cp *rkev1.RKEControlPlane) (string, *rkev1.ETCDSnapshotS3, error) {
+	bootstrap := cp.Spec.ETCDSnapshotBootstrap
+	if bootstrap.Name != "" {
+		snapshot, err := p.etcdSnapshotCache.Get(cp.Namespace, bootstrap.Name)
+		if err != nil {
+			return "", nil, err
+		}
+		if snapshot.SnapshotFile.S3 == nil {
+			return "", nil, fmt.Errorf("etcd snapshot %s/%s is not stored in S3 and can not be restored into a new cluster", snapshot.Namespace, snapshot.Name)
+		}
+		return snapshot.SnapshotFile.Name, snapshot.SnapshotFile.S3, nil
+	}
+	if bootstrap.SnapshotName == "" {
+		return "", nil, fmt.Errorf("either name or snapshotName must be set for etcd snapshot bootstrap of rkecontrolplane %s/%s", cp.Namespace, cp.Name)
+	}
+	s3 := bootstrap.S3
+	if !S3Enabled(s3) && cp.Spec.ETCD != nil {
+		s3 = cp.Spec.ETCD.S3
+	}
+	if !S3Enabled(s3) {
+		return "", nil, fmt.Errorf("S3 must be configured to bootstrap rkecontrolplane %s/%s from etcd snapshot %s", cp.Namespace, cp.Name, bootstrap.SnapshotName)
+	}
+	return bootstrap.SnapshotName, s3, nil
+}
+
+// etcdSnapshotBootstrapTokens returns the tokens of the cluster the snapshot the controlplane is bootstrapped from was
+// taken from. If the snapshot is referenced by name, the tokens that were in use when it was taken are returned, as the
+// join token of the cluster may have been rotated since.
+func (p *Planner) etcdSnapshotBootstrapTokens(cp *rkev1.RKEControlPlane) (plan.Secret, error) {
+	bootstrap := cp.Spec.ETCDSnapshotBootstrap
+	var snapshot *rkev1.ETCDSnapshot
+	if bootstrap.Name != "" {
+		var err error
+		if snapshot, err = p.etcdSnapshotCache.Get(cp.Namespace, bootstrap.Name); err != nil {
+			return plan.Secret{}, err
+		}
+	}
+	secretName := bootstrap.TokenSecretName
+	if secretName == "" {
+		if snapshot == nil {
+			return plan.Secret{}, fmt.Errorf("tokenSecretName must be set to bootstrap rkecontrolplane %s/%s from etcd snapshot %s", cp.Namespace, cp.Name, bootstrap.SnapshotName)
+		}
+		secretName = name.SafeConcatName(snapshot.Spec.ClusterName, "rke", "state")
+	}
+
+	secret, err := p.secretCache.Get(cp.Namespace, secretName)
+	if err != nil {
+		return plan.Secret{}, err
+	}
+	tokens := plan.Secret{
+		ServerToken: string(secret.Data["serverToken"]),
+		AgentToken:  string(secret.Data["agentToken"]),
+	}
+	if snapshot != nil {
+		if tokens, err = joinTokensAt(secret, snapshot.SnapshotFile.CreatedAt); err != nil {
+			return plan.Secret{}, err
+		}
+	}
+	if tokens.ServerToken == "" {
+		return plan.Secret{}, fmt.Errorf("secret %s/%s did not contain a serverToken", secret.Namespace, secret.Name)
+	}
+	if tokens.AgentToken == "" {
+		// the distro uses the server token for agents if no agent token was configured
+		tokens.AgentToken = tokens.ServerToken
+	}
+	return tokens, nil
+}
+
+// bootstrapClusterTokens returns the tokens of a new cluster that is bootstrapped from an etcd snapshot. The agent token
+// of the cluster the snapshot was taken from is always retained, as it is stored in the bootstrap data of the snapshot.
+func bootstrapClusterTokens(sourceTokens plan.Secret, rotateToken bool) (plan.Secret, error) {
+	if !rotateToken {
+		return sourceTokens, nil
+	}
+	serverToken, err := randomtoken.Generate()
+	if err != nil {
+		return plan.Secret{}, err
+	}
+	return plan.Secret{
+		ServerToken: serverToken,
+		AgentToken:  sourceTokens.AgentToken,
+	}, nil
+}
+
+// runEtcdSnapshotBootstrapRestorePlan elects an init node and delivers a plan to it that restores the etcd snapshot.
+func (p *Planner) runEtcdSnapshotBootstrapRestorePlan(cp *rkev1.RKEControlPlane, snapshotName string, s3 *rkev1.ETCDSnapshotS3, sourceTokens plan.Secret, clusterPlan *plan.Plan) error {
+	joinServer, err := p.electInitNode(cp, clusterPlan, true)
+	if err != nil {
+		return err
+	}
+
+	servers := collect(clusterPlan, isInitNode)
+	if len(servers) != 1 {
+		return errWaitingf("waiting for a single init node to restore etcd snapshot %s", snapshotName)
+	}
+
+	nodePlan, _, joinedServer, err := p.generatePlanWithConfigFiles(cp, sourceTokens, servers[0], joinServer, false)
+	if err != nil {
+		return err
+	}
+
+	s3Args, s3Env, s3Files, err := p.etcdS3Args.ToArgs(s3, cp, "etcd-", true)
+	if err != nil {
+		return err
+	}
+	value, err := etcdSnapshotBootstrapHash(cp)
+	if err != nil {
+		return err
+	}
+	args := append(generateClusterResetArgs(cp), fmt.Sprintf("--cluster-reset-restore-path=%s", snapshotName))
+	args = append(args, s3Args...)
+	nodePlan.Files = append(nodePlan.Files, s3Files...)
+
+	nodePlan.Instructions = append(nodePlan.Instructions,
+		p.generateInstallInstructionWithSkipStart(cp, servers[0]),
+		idempotentInstruction(
+			cp,
+			"etcd-bootstrap/restore",
+			value,
+			capr.GetRuntimeCommand(cp.Spec.KubernetesVersion),
+			args,
+			s3Env),
+	)
+
+	return assignAndCheckPlan(p.store, ETCDBootstrapMessage, servers[0], nodePlan, joinedServer, 1, 1)
+}
+
+// etcdSnapshotBootstrapHash returns a hash of the etcd snapshot bootstrap of the controlplane that is used as the value of
+// its idempotent instructions. The spec is hashed as JSON, as formatting it would print the addresses of its nested
+// pointers, which change whenever the controlplane is decoded.
+func etcdSnapshotBootstrapHash(cp *rkev1.RKEControlPlane) (string, error) {
+	data, err := json.Marshal(cp.Spec.ETCDSnapshotBootstrap)
+	if err != nil {
+		return "", err
+	}
+	return PlanHash(data), nil
+}
+
+// runEtcdSnapshotBootstrapRestartPlan starts the init node with the tokens of the cluster the snapshot was taken from,
+// rotates the server token to the token of the cluster if they differ, and removes the nodes of the cluster the
+// snapshot was taken from.
+func (p *Planner) runEtcdSnapshotBootstrapRestartPlan(cp *rkev1.RKEControlPlane, sourceTokens, tokensSecret plan.Secret, clusterPlan *plan.Plan) error {
+	initNodes := collect(clusterPlan, isInitNode)
+	if len(initNodes) != 1 {
+		return errWaiting("waiting for a single init node to restart after etcd snapshot restore")
+	}
+	initNode := initNodes[0]
+
+	nodePlan, _, err := p.desiredPlan(cp, sourceTokens, initNode, "")
+	if err != nil {
+		return err
+	}
+
+	value, err := etcdSnapshotBootstrapHash(cp)
+	if err != nil {
+		return err
+	}
+	if tokensSecret.ServerToken != "" && tokensSecret.ServerToken != sourceTokens.ServerToken {
+		nodePlan.Instructions = append(nodePlan.Instructions, tokenRotateInstruction(
+			cp,
+			"etcd-bootstrap/rotate-token",
+			value,
+			sourceTokens.ServerToken,
+			tokensSecret.ServerToken))
+	}
+
+	if isControlPlane(initNode) {
+		var allMachineUIDs []string
+		for _, n := range collect(clusterPlan, isNotDeleting) {
+			if n.Machine != nil && n.Machine.UID != "" {
+				allMachineUIDs = append(allMachineUIDs, string(n.Machine.UID))
+			}
+		}
+		cleanupScriptFiles, cleanupInstructions := p.generateEtcdRestoreNodeCleanupFilesAndInstruction(cp, allMachineUIDs, nil, name.Hex(value, 10), value)
+		nodePlan.Files = append(nodePlan.Files, cleanupScriptFiles...)
+		nodePlan.Instructions = append(nodePlan.Instructions, cleanupInstructions...)
+	} else {
+		logrus.Warnf("[planner] rkecluster %s/%s: init node is not a controlplane node, nodes of the cluster the etcd snapshot was taken from must be removed manually", cp.Namespace, cp.Name)
+	}
+
+	return assignAndCheckPlan(p.store, ETCDBootstrapMessage, initNode, nodePlan, "", 5, 5)
+}
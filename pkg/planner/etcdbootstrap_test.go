@@ -0,0 +1,113 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtcdSnapshotBootstrapInProgress(t *testing.T) {
+	bootstrapped := rkev1.RKEControlPlaneStatus{}
+	capr.Bootstrapped.True(&bootstrapped)
+
+	tests := []struct {
+		name      string
+		bootstrap *rkev1.ETCDSnapshotBootstrap
+		status    rkev1.RKEControlPlaneStatus
+		expected  bool
+	}{
+		{
+			name:     "no bootstrap",
+			expected: false,
+		},
+		{
+			name:      "new cluster",
+			bootstrap: &rkev1.ETCDSnapshotBootstrap{Name: "snapshot"},
+			expected:  true,
+		},
+		{
+			name:      "initialized cluster",
+			bootstrap: &rkev1.ETCDSnapshotBootstrap{Name: "snapshot"},
+			status:    rkev1.RKEControlPlaneStatus{Initialized: true},
+			expected:  false,
+		},
+		{
+			name:      "bootstrapped cluster",
+			bootstrap: &rkev1.ETCDSnapshotBootstrap{Name: "snapshot"},
+			status:    bootstrapped,
+			expected:  false,
+		},
+		{
+			name:      "restarting cluster",
+			bootstrap: &rkev1.ETCDSnapshotBootstrap{Name: "snapshot"},
+			status:    rkev1.RKEControlPlaneStatus{ETCDSnapshotBootstrapPhase: rkev1.ETCDSnapshotPhaseRestartCluster},
+			expected:  true,
+		},
+		{
+			name:      "finished",
+			bootstrap: &rkev1.ETCDSnapshotBootstrap{Name: "snapshot"},
+			status:    rkev1.RKEControlPlaneStatus{ETCDSnapshotBootstrapPhase: rkev1.ETCDSnapshotPhaseFinished},
+			expected:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := &rkev1.RKEControlPlane{
+				Spec: rkev1.RKEControlPlaneSpec{
+					ETCDSnapshotBootstrap: tt.bootstrap,
+				},
+			}
+			assert.Equal(t, tt.expected, etcdSnapshotBootstrapInProgress(cp, tt.status))
+		})
+	}
+}
+
+func TestEtcdSnapshotBootstrapHash(t *testing.T) {
+	newControlPlane := func() *rkev1.RKEControlPlane {
+		return &rkev1.RKEControlPlane{
+			Spec: rkev1.RKEControlPlaneSpec{
+				ETCDSnapshotBootstrap: &rkev1.ETCDSnapshotBootstrap{
+					SnapshotName: "on-demand-node1-1700000000",
+					S3: &rkev1.ETCDSnapshotS3{
+						Bucket:   "bucket",
+						Endpoint: "s3.example.com",
+					},
+				},
+			},
+		}
+	}
+
+	// every decode of the controlplane allocates new nested pointers, which must not change the hash
+	first, err := etcdSnapshotBootstrapHash(newControlPlane())
+	assert.NoError(t, err)
+	second, err := etcdSnapshotBootstrapHash(newControlPlane())
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	changed := newControlPlane()
+	changed.Spec.ETCDSnapshotBootstrap.S3.Bucket = "other"
+	third, err := etcdSnapshotBootstrapHash(changed)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, third)
+}
+
+func TestBootstrapClusterTokens(t *testing.T) {
+	source := plan.Secret{
+		ServerToken: "server",
+		AgentToken:  "agent",
+	}
+
+	tokens, err := bootstrapClusterTokens(source, false)
+	assert.NoError(t, err)
+	assert.Equal(t, source, tokens)
+
+	tokens, err = bootstrapClusterTokens(source, true)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.ServerToken)
+	assert.NotEqual(t, source.ServerToken, tokens.ServerToken)
+	assert.Equal(t, source.AgentToken, tokens.AgentToken)
+}
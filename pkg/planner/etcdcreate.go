@@ -1,6 +1,7 @@
 package planner
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -110,16 +111,168 @@ func (p *Planner) generateEtcdSnapshotCreatePlan(controlPlane *rkev1.RKEControlP
 		args = append(args, "save")
 	}
 
+	snapshot := controlPlane.Spec.ETCDSnapshotCreate
+	if snapshot == nil {
+		snapshot = &rkev1.ETCDSnapshotCreate{}
+	}
+
+	targetArgs, err := etcdSnapshotTargetArgs(controlPlane, snapshot.Target)
+	if err != nil {
+		return plan.NodePlan{}, "", err
+	}
+	s3Targets, err := etcdSnapshotS3Targets(controlPlane, snapshot)
+	if err != nil {
+		return plan.NodePlan{}, "", err
+	}
+	if snapshot.Name != "" {
+		args = append(args, fmt.Sprintf("--name=%s", snapshot.Name))
+	}
+	args = append(args, targetArgs...)
+
+	encrypted := etcdSnapshotEncryptionEnabled(controlPlane)
+	uploadEncrypted := encrypted && snapshot.Target != rkev1.ETCDSnapshotTargetLocal && S3Enabled(controlPlane.Spec.ETCD.S3)
+	snapshotName := first(snapshot.Name, defaultETCDSnapshotName)
+
+	create := plan.OneTimeInstruction{
+		Name:    "create",
+		Command: capr.GetRuntimeCommand(controlPlane.Spec.KubernetesVersion),
+		Args:    args,
+	}
 	createPlan, _, joinedServer, err := p.generatePlanWithConfigFiles(controlPlane, tokensSecret, entry, joinServer, true)
-	createPlan.Instructions = append(createPlan.Instructions, p.generateInstallInstructionWithSkipStart(controlPlane, entry),
-		plan.OneTimeInstruction{
-			Name:    "create",
-			Command: capr.GetRuntimeCommand(controlPlane.Spec.KubernetesVersion),
-			Args:    args,
-		})
+	if uploadEncrypted || len(s3Targets) > 0 {
+		// the snapshot is uploaded afterwards, so the name of the snapshot that was saved is recorded
+		createPlan.Files = append(createPlan.Files, generateEtcdSnapshotSaveScriptFile(controlPlane))
+		create = withEtcdSnapshotSaveRecord(controlPlane, create, snapshotName)
+	}
+	createPlan.Instructions = append(createPlan.Instructions, p.generateInstallInstructionWithSkipStart(controlPlane, entry), create)
+
+	if encrypted {
+		createPlan.Files = append(createPlan.Files, generateEtcdSnapshotUploadScriptFile(controlPlane))
+	}
+	if uploadEncrypted {
+		// S3 is not configured for the distro while snapshots are encrypted, so the snapshot is saved locally, and encrypted
+		// and uploaded afterwards.
+		s3Args, s3Env, s3Files, err := p.etcdS3Args.ToArgs(controlPlane.Spec.ETCD.S3, controlPlane, "etcd-", true)
+		if err != nil {
+			return plan.NodePlan{}, "", err
+		}
+		uploadEnv, err := p.encryptedEtcdSnapshotUploadEnv(controlPlane, entry, s3Args, s3Env)
+		if err != nil {
+			return plan.NodePlan{}, "", err
+		}
+		createPlan.Files = append(createPlan.Files, s3Files...)
+		createPlan.Instructions = append(createPlan.Instructions, generateEtcdSnapshotUploadLatestInstruction(controlPlane, "upload-encrypted", snapshotName, uploadEnv))
+		if snapshot.Name != "" && snapshot.Retention > 0 {
+			prune := generateEtcdSnapshotPruneInstruction(controlPlane, "prune-s3", snapshot.Name, snapshot.Retention, s3Args)
+			prune.Env = s3Env
+			createPlan.Instructions = append(createPlan.Instructions, prune)
+		}
+	}
+
+	if snapshot.Name != "" && snapshot.Retention > 0 {
+		createPlan.Instructions = append(createPlan.Instructions, generateEtcdSnapshotPruneInstruction(controlPlane, "prune", snapshot.Name, snapshot.Retention, targetArgs))
+	}
+	if snapshot.Name != "" && snapshot.Target == rkev1.ETCDSnapshotTargetS3 {
+		// the distro always writes a local copy of the snapshot before uploading it, so only keep the most recent one.
+		createPlan.Instructions = append(createPlan.Instructions, generateEtcdSnapshotPruneInstruction(controlPlane, "prune-local", snapshot.Name, 1, []string{"--etcd-s3=false"}))
+	}
+
+	if len(s3Targets) > 0 && !encrypted {
+		createPlan.Files = append(createPlan.Files, generateEtcdSnapshotUploadScriptFile(controlPlane))
+	}
+	for _, target := range s3Targets {
+		s3Args, s3Env, s3Files, err := p.etcdS3Args.ToTargetArgs(target, controlPlane, "etcd-")
+		if err != nil {
+			return plan.NodePlan{}, "", err
+		}
+		// the snapshot that was created is uploaded to every target, as the distro only uploads it to the S3 location of
+		// the etcd configuration of the cluster
+		uploadEnv, err := p.encryptedEtcdSnapshotUploadEnv(controlPlane, entry, s3Args, s3Env)
+		if err != nil {
+			return plan.NodePlan{}, "", err
+		}
+		createPlan.Files = append(createPlan.Files, s3Files...)
+		createPlan.Instructions = append(createPlan.Instructions, generateEtcdSnapshotUploadLatestInstruction(controlPlane, "upload-s3-target-"+target.Name, snapshotName, uploadEnv))
+		retention := target.Retention
+		if retention == 0 {
+			retention = snapshot.Retention
+		}
+		if retention > 0 {
+			prune := withEtcdS3TargetSecretKey(generateEtcdSnapshotPruneInstruction(controlPlane, "prune-s3-target-"+target.Name, snapshotName, retention, s3Args))
+			prune.Env = s3Env
+			createPlan.Instructions = append(createPlan.Instructions, prune)
+		}
+	}
 	return createPlan, joinedServer, err
 }
 
+// defaultETCDSnapshotName is the default name prefix of the distro for on-demand etcd snapshots.
+const defaultETCDSnapshotName = "on-demand"
+
+// etcdSnapshotS3Targets returns the S3 targets of the controlplane that the given etcd snapshot is uploaded to, and an
+// error if a selected target does not exist.
+func etcdSnapshotS3Targets(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshotCreate) ([]*rkev1.ETCDSnapshotS3Target, error) {
+	if snapshot.Target == rkev1.ETCDSnapshotTargetLocal || controlPlane.Spec.ETCD == nil {
+		return nil, nil
+	}
+	var targets []*rkev1.ETCDSnapshotS3Target
+	if len(snapshot.S3Targets) == 0 {
+		for i := range controlPlane.Spec.ETCD.S3Targets {
+			targets = append(targets, &controlPlane.Spec.ETCD.S3Targets[i])
+		}
+		return targets, nil
+	}
+	for _, name := range snapshot.S3Targets {
+		target, ok := S3Target(controlPlane, name)
+		if !ok {
+			return nil, fmt.Errorf("etcd snapshot S3 target %s does not exist", name)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// etcdSnapshotTargetArgs returns the arguments that are required to save an etcd snapshot to the given target, and an
+// error if the target requires S3 but S3 is not configured for the controlplane.
+func etcdSnapshotTargetArgs(controlPlane *rkev1.RKEControlPlane, target rkev1.ETCDSnapshotTarget) ([]string, error) {
+	switch target {
+	case "":
+		return nil, nil
+	case rkev1.ETCDSnapshotTargetLocal:
+		return []string{"--etcd-s3=false"}, nil
+	case rkev1.ETCDSnapshotTargetS3, rkev1.ETCDSnapshotTargetBoth:
+		if controlPlane.Spec.ETCD == nil || !S3Enabled(controlPlane.Spec.ETCD.S3) {
+			return nil, fmt.Errorf("etcd snapshot target %s requires S3 to be configured for the cluster", target)
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("invalid etcd snapshot target %s", target)
+}
+
+// generateEtcdSnapshotPruneInstruction generates an instruction that prunes the etcd snapshots with the given name prefix
+// down to the given retention.
+func generateEtcdSnapshotPruneInstruction(controlPlane *rkev1.RKEControlPlane, instructionName, snapshotName string, retention int, extraArgs []string) plan.OneTimeInstruction {
+	args := []string{
+		"etcd-snapshot",
+		"prune",
+		fmt.Sprintf("--name=%s", snapshotName),
+		fmt.Sprintf("--etcd-snapshot-retention=%d", retention),
+	}
+	return plan.OneTimeInstruction{
+		Name:    instructionName,
+		Command: capr.GetRuntimeCommand(controlPlane.Spec.KubernetesVersion),
+		Args:    append(args, extraArgs...),
+	}
+}
+
+// withEtcdS3TargetSecretKey returns the given instruction of the distro run through the shell with
+// etcdS3TargetSecretKeyArg, so that the secret key of an S3 target is passed in the environment of the instruction.
+func withEtcdS3TargetSecretKey(instruction plan.OneTimeInstruction) plan.OneTimeInstruction {
+	instruction.Args = append([]string{"-c", `exec "$@" ` + etcdS3TargetSecretKeyArg, "--", instruction.Command}, instruction.Args...)
+	instruction.Command = "/bin/sh"
+	return instruction
+}
+
 func (p *Planner) createEtcdSnapshot(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
 	var err error
 	if controlPlane.Spec.ETCDSnapshotCreate == nil {
@@ -195,3 +348,173 @@ func (p *Planner) createEtcdSnapshot(controlPlane *rkev1.RKEControlPlane, status
 		return status, nil
 	}
 }
+
+// defaultETCDSnapshotBeforeChangeName is the default prefix of the names of snapshots that are created before changes.
+const defaultETCDSnapshotBeforeChangeName = "pre-change"
+
+// snapshotBeforeChange creates an etcd snapshot through the etcd snapshot creation of the controlplane if the Kubernetes
+// version or selected configuration of the controlplane changed since it was last applied, and the etcd snapshot before
+// change policy is enabled. It returns an errWaiting until the snapshot was created, which prevents the change from
+// being applied to the init node and etcd plans, and an error if the snapshot creation failed. The snapshot is requested
+// in status, and created by createEtcdSnapshot, which must be called before.
+func (p *Planner) snapshotBeforeChange(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) (rkev1.RKEControlPlaneStatus, error) {
+	policy := controlPlane.Spec.ETCDSnapshotBeforeChange
+	if policy == nil || !policy.Enabled || status.AppliedSpec == nil || !status.Initialized || !capr.Bootstrapped.IsTrue(&status) {
+		return status, nil
+	}
+	if etcdSnapshotRestore(controlPlane, status) != nil && status.ETCDSnapshotRestorePhase != rkev1.ETCDSnapshotPhaseFinished {
+		// a restore replaces the entire etcd state, so there is nothing to roll back to
+		return status, nil
+	}
+
+	hash, err := snapshotBeforeChangeHash(&controlPlane.Spec, policy.ConfigKeys)
+	if err != nil {
+		return status, err
+	}
+	appliedHash, err := snapshotBeforeChangeHash(status.AppliedSpec, policy.ConfigKeys)
+	if err != nil {
+		return status, err
+	}
+	if hash == appliedHash {
+		return status, nil
+	}
+
+	record := status.ETCDSnapshotBeforeChange
+	if record != nil && record.Hash == hash && record.SnapshotName != "" {
+		// the snapshot was already created for this change
+		return status, nil
+	}
+
+	if record == nil || record.Hash != hash {
+		current := etcdSnapshotCreate(controlPlane, status)
+		if etcdSnapshotCreateInProgress(current, status) {
+			return status, errWaiting("waiting for etcd snapshot creation in progress before creating etcd snapshot before change")
+		}
+		generation := 1
+		if current != nil {
+			generation = current.Generation + 1
+		}
+		logrus.Infof("[planner] rkecluster %s/%s: creating etcd snapshot before applying change", controlPlane.Namespace, controlPlane.Name)
+		status.ETCDSnapshotBeforeChange = &rkev1.ETCDSnapshotBeforeChangeStatus{
+			Hash:       hash,
+			Generation: generation,
+		}
+		return status, errWaiting("creating etcd snapshot before applying change")
+	}
+
+	snapshot := etcdSnapshotCreate(controlPlane, status)
+	if snapshot == nil || snapshot.Generation != record.Generation {
+		// another etcd snapshot creation superseded the one that was requested for the change, so request a new one
+		status.ETCDSnapshotBeforeChange = nil
+		return status, errWaiting("etcd snapshot creation before change was superseded")
+	}
+
+	if status.ETCDSnapshotCreate == nil || status.ETCDSnapshotCreate.Generation != record.Generation {
+		return status, errWaiting("waiting for etcd snapshot creation before applying change to start")
+	}
+	switch status.ETCDSnapshotCreatePhase {
+	case rkev1.ETCDSnapshotPhaseFinished:
+		record = record.DeepCopy()
+		record.SnapshotName = snapshot.Name
+		status.ETCDSnapshotBeforeChange = record
+		return status, errWaiting(fmt.Sprintf("created etcd snapshot %s before applying change", snapshot.Name))
+	case rkev1.ETCDSnapshotPhaseFailed:
+		return status, fmt.Errorf("etcd snapshot creation before applying change failed, refusing to apply change until a snapshot was created or the etcd snapshot before change policy was disabled")
+	}
+	return status, errWaiting(fmt.Sprintf("waiting for etcd snapshot %s before applying change", snapshot.Name))
+}
+
+// snapshotBeforeChangeHash returns a hash of the Kubernetes version and the values of the given machineGlobalConfig keys
+// of the controlplane spec.
+func snapshotBeforeChangeHash(spec *rkev1.RKEControlPlaneSpec, configKeys []string) (string, error) {
+	config := map[string]interface{}{}
+	for _, key := range configKeys {
+		if v, ok := spec.MachineGlobalConfig.Data[key]; ok {
+			config[key] = v
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"kubernetesVersion": spec.KubernetesVersion,
+		"config":            config,
+	})
+	if err != nil {
+		return "", err
+	}
+	return PlanHash(data), nil
+}
+
+// snapshotBeforeChangeName returns the prefix of the names of snapshots that are created before changes.
+func snapshotBeforeChangeName(policy *rkev1.ETCDSnapshotBeforeChange) string {
+	if policy.Name != "" {
+		return policy.Name
+	}
+	return defaultETCDSnapshotBeforeChangeName
+}
+
+// etcdSnapshotCreate returns the etcd snapshot creation that is run for the controlplane. This is the etcd snapshot
+// creation of the spec, unless an etcd snapshot before change was requested in status and not created yet, or a newer
+// etcd snapshot creation was already run, in which case the older creation of the spec must not be run again.
+func etcdSnapshotCreate(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) *rkev1.ETCDSnapshotCreate {
+	create := cp.Spec.ETCDSnapshotCreate
+	if policy, record := cp.Spec.ETCDSnapshotBeforeChange, status.ETCDSnapshotBeforeChange; policy != nil && policy.Enabled && record != nil &&
+		record.SnapshotName == "" && record.Generation > 0 && (create == nil || create.Generation < record.Generation) {
+		return &rkev1.ETCDSnapshotCreate{
+			Generation: record.Generation,
+			Name:       fmt.Sprintf("%s-%d", snapshotBeforeChangeName(policy), record.Generation),
+			Target:     policy.Target,
+		}
+	}
+	if create != nil && status.ETCDSnapshotCreate != nil && create.Generation < status.ETCDSnapshotCreate.Generation {
+		return status.ETCDSnapshotCreate
+	}
+	return create
+}
+
+// withEtcdSnapshotCreate returns the controlplane with the etcd snapshot creation that is run for it set in its spec. An
+// etcd snapshot before change is created with the spec that was last applied, so that neither the snapshot plan nor the
+// restart of the etcd nodes afterwards applies the change before the snapshot was taken. The returned controlplane is
+// only used to create the snapshot, and must never be updated.
+func withEtcdSnapshotCreate(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) *rkev1.RKEControlPlane {
+	create := etcdSnapshotCreate(cp, status)
+	if record := status.ETCDSnapshotBeforeChange; create != nil && record != nil && record.SnapshotName == "" &&
+		record.Generation == create.Generation && status.AppliedSpec != nil {
+		cp = cp.DeepCopy()
+		cp.Spec = *status.AppliedSpec.DeepCopy()
+		cp.Spec.ETCDSnapshotCreate = create.DeepCopy()
+		return cp
+	}
+	if create == cp.Spec.ETCDSnapshotCreate {
+		return cp
+	}
+	cp = cp.DeepCopy()
+	cp.Spec.ETCDSnapshotCreate = create.DeepCopy()
+	return cp
+}
+
+// ETCDSnapshotCreateGeneration returns the generation of the etcd snapshot creation that is run for the controlplane,
+// which a newly requested etcd snapshot creation must exceed.
+func ETCDSnapshotCreateGeneration(controlPlane *rkev1.RKEControlPlane) int {
+	if snapshot := etcdSnapshotCreate(controlPlane, controlPlane.Status); snapshot != nil {
+		return snapshot.Generation
+	}
+	return 0
+}
+
+// ETCDSnapshotCreateInProgress returns true if an etcd snapshot creation was requested for the controlplane and has not
+// finished or failed yet.
+func ETCDSnapshotCreateInProgress(controlPlane *rkev1.RKEControlPlane) bool {
+	return etcdSnapshotCreateInProgress(etcdSnapshotCreate(controlPlane, controlPlane.Status), controlPlane.Status)
+}
+
+// etcdSnapshotCreateInProgress returns true if the given etcd snapshot creation has not finished or failed yet in the
+// given status.
+func etcdSnapshotCreateInProgress(snapshot *rkev1.ETCDSnapshotCreate, status rkev1.RKEControlPlaneStatus) bool {
+	if snapshot == nil {
+		return false
+	}
+	if status.ETCDSnapshotCreate == nil || status.ETCDSnapshotCreate.Generation != snapshot.Generation {
+		return true
+	}
+	return status.ETCDSnapshotCreatePhase != rkev1.ETCDSnapshotPhaseFinished &&
+		status.ETCDSnapshotCreatePhase != rkev1.ETCDSnapshotPhaseFailed
+}
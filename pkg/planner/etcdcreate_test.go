@@ -0,0 +1,320 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSnapshotBeforeChangeHash(t *testing.T) {
+	spec := func(version string, config map[string]interface{}) *rkev1.RKEControlPlaneSpec {
+		spec := &rkev1.RKEControlPlaneSpec{
+			KubernetesVersion: version,
+		}
+		spec.MachineGlobalConfig = rkev1.GenericMap{Data: config}
+		return spec
+	}
+	keys := []string{"cni"}
+
+	base, err := snapshotBeforeChangeHash(spec("v1.28.3+rke2r1", map[string]interface{}{"cni": "calico", "debug": false}), keys)
+	assert.NoError(t, err)
+
+	unrelated, err := snapshotBeforeChangeHash(spec("v1.28.3+rke2r1", map[string]interface{}{"cni": "calico", "debug": true}), keys)
+	assert.NoError(t, err)
+	assert.Equal(t, base, unrelated)
+
+	config, err := snapshotBeforeChangeHash(spec("v1.28.3+rke2r1", map[string]interface{}{"cni": "cilium", "debug": false}), keys)
+	assert.NoError(t, err)
+	assert.NotEqual(t, base, config)
+
+	version, err := snapshotBeforeChangeHash(spec("v1.29.0+rke2r1", map[string]interface{}{"cni": "calico", "debug": false}), keys)
+	assert.NoError(t, err)
+	assert.NotEqual(t, base, version)
+}
+
+func TestSnapshotBeforeChangeDisabled(t *testing.T) {
+	status := rkev1.RKEControlPlaneStatus{
+		Initialized: true,
+		AppliedSpec: &rkev1.RKEControlPlaneSpec{KubernetesVersion: "v1.28.3+rke2r1"},
+	}
+	capr.Bootstrapped.True(&status)
+	cp := &rkev1.RKEControlPlane{
+		Spec: rkev1.RKEControlPlaneSpec{
+			KubernetesVersion:        "v1.29.0+rke2r1",
+			ETCDSnapshotBeforeChange: &rkev1.ETCDSnapshotBeforeChange{Enabled: false},
+		},
+	}
+
+	p := &Planner{}
+	newStatus, err := p.snapshotBeforeChange(cp, status)
+	assert.NoError(t, err)
+	assert.Nil(t, newStatus.ETCDSnapshotBeforeChange)
+
+	cp.Spec.ETCDSnapshotBeforeChange.Enabled = true
+	newStatus, err = p.snapshotBeforeChange(cp, status)
+	assert.True(t, IsErrWaiting(err))
+	if assert.NotNil(t, newStatus.ETCDSnapshotBeforeChange) {
+		assert.Equal(t, 1, newStatus.ETCDSnapshotBeforeChange.Generation)
+		assert.Empty(t, newStatus.ETCDSnapshotBeforeChange.SnapshotName)
+	}
+}
+
+func TestSnapshotBeforeChangeCreation(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{
+		Spec: rkev1.RKEControlPlaneSpec{
+			KubernetesVersion:        "v1.29.0+rke2r1",
+			ETCDSnapshotBeforeChange: &rkev1.ETCDSnapshotBeforeChange{Enabled: true},
+		},
+	}
+	hash, err := snapshotBeforeChangeHash(&cp.Spec, nil)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		phase        rkev1.ETCDSnapshotPhase
+		expectedErr  bool
+		expectedName string
+	}{
+		{
+			name:  "creation in progress",
+			phase: rkev1.ETCDSnapshotPhaseStarted,
+		},
+		{
+			name:         "creation finished",
+			phase:        rkev1.ETCDSnapshotPhaseFinished,
+			expectedName: "pre-change-1",
+		},
+		{
+			name:        "creation failed",
+			phase:       rkev1.ETCDSnapshotPhaseFailed,
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := rkev1.RKEControlPlaneStatus{
+				Initialized:              true,
+				AppliedSpec:              &rkev1.RKEControlPlaneSpec{KubernetesVersion: "v1.28.3+rke2r1"},
+				ETCDSnapshotBeforeChange: &rkev1.ETCDSnapshotBeforeChangeStatus{Hash: hash, Generation: 1},
+				ETCDSnapshotCreate:       &rkev1.ETCDSnapshotCreate{Generation: 1},
+				ETCDSnapshotCreatePhase:  tt.phase,
+			}
+			capr.Bootstrapped.True(&status)
+
+			p := &Planner{}
+			newStatus, err := p.snapshotBeforeChange(cp, status)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				assert.False(t, IsErrWaiting(err))
+				return
+			}
+			// the change is not applied until the snapshot was recorded
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, tt.expectedName, newStatus.ETCDSnapshotBeforeChange.SnapshotName)
+		})
+	}
+}
+
+func TestSnapshotBeforeChangeRequest(t *testing.T) {
+	status := rkev1.RKEControlPlaneStatus{
+		Initialized:             true,
+		AppliedSpec:             &rkev1.RKEControlPlaneSpec{KubernetesVersion: "v1.28.3+rke2r1"},
+		ETCDSnapshotCreate:      &rkev1.ETCDSnapshotCreate{Generation: 4},
+		ETCDSnapshotCreatePhase: rkev1.ETCDSnapshotPhaseFinished,
+	}
+	capr.Bootstrapped.True(&status)
+	cp := &rkev1.RKEControlPlane{
+		Spec: rkev1.RKEControlPlaneSpec{
+			KubernetesVersion:        "v1.29.0+rke2r1",
+			ETCDSnapshotBeforeChange: &rkev1.ETCDSnapshotBeforeChange{Enabled: true, Name: "upgrade"},
+			ETCDSnapshotCreate:       &rkev1.ETCDSnapshotCreate{Generation: 4},
+		},
+	}
+
+	p := &Planner{}
+	status, err := p.snapshotBeforeChange(cp, status)
+	assert.True(t, IsErrWaiting(err))
+	if !assert.NotNil(t, status.ETCDSnapshotBeforeChange) {
+		return
+	}
+	assert.Equal(t, 5, status.ETCDSnapshotBeforeChange.Generation)
+
+	// the snapshot is requested in status and the spec of the controlplane is left untouched
+	assert.Equal(t, 4, cp.Spec.ETCDSnapshotCreate.Generation)
+	assert.Equal(t, &rkev1.ETCDSnapshotCreate{Generation: 5, Name: "upgrade-5"}, etcdSnapshotCreate(cp, status))
+	cp.Status = status
+	assert.True(t, ETCDSnapshotCreateInProgress(cp))
+	assert.Equal(t, 5, ETCDSnapshotCreateGeneration(cp))
+
+	// the snapshot and the restart of the etcd nodes afterwards are rendered from the spec that was last applied
+	snapshotCP := withEtcdSnapshotCreate(cp, status)
+	assert.Equal(t, "v1.28.3+rke2r1", snapshotCP.Spec.KubernetesVersion)
+	assert.Equal(t, 5, snapshotCP.Spec.ETCDSnapshotCreate.Generation)
+	assert.Equal(t, "v1.29.0+rke2r1", cp.Spec.KubernetesVersion)
+
+	// once the snapshot was created, the older etcd snapshot creation of the spec is not run again
+	status.ETCDSnapshotCreate = etcdSnapshotCreate(cp, status)
+	status, err = p.snapshotBeforeChange(cp, status)
+	assert.True(t, IsErrWaiting(err))
+	assert.Equal(t, "upgrade-5", status.ETCDSnapshotBeforeChange.SnapshotName)
+	assert.Equal(t, status.ETCDSnapshotCreate, etcdSnapshotCreate(cp, status))
+	assert.Equal(t, "v1.29.0+rke2r1", withEtcdSnapshotCreate(cp, status).Spec.KubernetesVersion)
+}
+
+func TestETCDSnapshotCreateInProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     *rkev1.ETCDSnapshotCreate
+		status   *rkev1.ETCDSnapshotCreate
+		phase    rkev1.ETCDSnapshotPhase
+		expected bool
+	}{
+		{
+			name:     "no snapshot requested",
+			expected: false,
+		},
+		{
+			name:     "snapshot not picked up by planner",
+			spec:     &rkev1.ETCDSnapshotCreate{Generation: 2},
+			status:   &rkev1.ETCDSnapshotCreate{Generation: 1},
+			phase:    rkev1.ETCDSnapshotPhaseFinished,
+			expected: true,
+		},
+		{
+			name:     "snapshot started",
+			spec:     &rkev1.ETCDSnapshotCreate{Generation: 1},
+			status:   &rkev1.ETCDSnapshotCreate{Generation: 1},
+			phase:    rkev1.ETCDSnapshotPhaseStarted,
+			expected: true,
+		},
+		{
+			name:     "snapshot finished",
+			spec:     &rkev1.ETCDSnapshotCreate{Generation: 1},
+			status:   &rkev1.ETCDSnapshotCreate{Generation: 1},
+			phase:    rkev1.ETCDSnapshotPhaseFinished,
+			expected: false,
+		},
+		{
+			name:     "snapshot failed",
+			spec:     &rkev1.ETCDSnapshotCreate{Generation: 1},
+			status:   &rkev1.ETCDSnapshotCreate{Generation: 1},
+			phase:    rkev1.ETCDSnapshotPhaseFailed,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := &rkev1.RKEControlPlane{
+				Spec: rkev1.RKEControlPlaneSpec{
+					ETCDSnapshotCreate: tt.spec,
+				},
+				Status: rkev1.RKEControlPlaneStatus{
+					ETCDSnapshotCreate:      tt.status,
+					ETCDSnapshotCreatePhase: tt.phase,
+				},
+			}
+			assert.Equal(t, tt.expected, ETCDSnapshotCreateInProgress(cp))
+		})
+	}
+}
+
+func TestEtcdSnapshotS3Targets(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.ETCD = &rkev1.ETCD{
+		S3Targets: []rkev1.ETCDSnapshotS3Target{
+			{Name: "primary-region"},
+			{Name: "offsite"},
+		},
+	}
+
+	targets, err := etcdSnapshotS3Targets(cp, &rkev1.ETCDSnapshotCreate{})
+	assert.NoError(t, err)
+	assert.Len(t, targets, 2)
+
+	targets, err = etcdSnapshotS3Targets(cp, &rkev1.ETCDSnapshotCreate{S3Targets: []string{"offsite"}})
+	assert.NoError(t, err)
+	if assert.Len(t, targets, 1) {
+		assert.Equal(t, "offsite", targets[0].Name)
+	}
+
+	targets, err = etcdSnapshotS3Targets(cp, &rkev1.ETCDSnapshotCreate{Target: rkev1.ETCDSnapshotTargetLocal})
+	assert.NoError(t, err)
+	assert.Empty(t, targets)
+
+	_, err = etcdSnapshotS3Targets(cp, &rkev1.ETCDSnapshotCreate{S3Targets: []string{"missing"}})
+	assert.Error(t, err)
+}
+
+func TestToTargetArgs(t *testing.T) {
+	mp := newMockPlanner(t, InfoFunctions{})
+	mp.secretCache.EXPECT().Get("fleet-default", "offsite-credential").Return(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "offsite-credential"},
+		Data: map[string][]byte{
+			"accessKey": []byte("access"),
+			"secretKey": []byte("secret"),
+		},
+	}, nil).AnyTimes()
+	s := &mp.planner.etcdS3Args
+	cp := &rkev1.RKEControlPlane{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default"}}
+
+	args, env, _, err := s.ToTargetArgs(&rkev1.ETCDSnapshotS3Target{
+		Name: "offsite",
+		ETCDSnapshotS3: rkev1.ETCDSnapshotS3{
+			Bucket:              "backups",
+			Endpoint:            "minio.example.com",
+			CloudCredentialName: "offsite-credential",
+		},
+	}, cp, "etcd-")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"AWS_SECRET_ACCESS_KEY=secret"}, env, "the secret key is passed in the environment")
+	assert.ElementsMatch(t, []string{
+		"--etcd-s3-bucket=backups",
+		"--etcd-s3-access-key=access",
+		"--etcd-s3-endpoint=minio.example.com",
+		"--etcd-s3",
+		"--etcd-s3-region=us-east-1",
+		"--etcd-s3-folder=",
+		"--etcd-s3-endpoint-ca=",
+		"--etcd-s3-skip-ssl-verify=false",
+	}, args)
+
+	_, _, _, err = s.ToTargetArgs(&rkev1.ETCDSnapshotS3Target{
+		Name:           "offsite",
+		ETCDSnapshotS3: rkev1.ETCDSnapshotS3{Region: "eu-west-1", CloudCredentialName: "offsite-credential"},
+	}, cp, "etcd-")
+	assert.Error(t, err, "the bucket is required")
+
+	_, _, _, err = s.ToTargetArgs(&rkev1.ETCDSnapshotS3Target{
+		Name:           "offsite",
+		ETCDSnapshotS3: rkev1.ETCDSnapshotS3{Bucket: "backups", UseInstanceCredentials: true},
+	}, cp, "etcd-")
+	assert.Error(t, err, "instance credentials are not supported")
+
+	cp.Spec.ETCD = &rkev1.ETCD{S3: &rkev1.ETCDSnapshotS3{Bucket: "primary", UseInstanceCredentials: true}}
+	_, _, _, err = s.ToTargetArgs(&rkev1.ETCDSnapshotS3Target{
+		Name:           "offsite",
+		ETCDSnapshotS3: rkev1.ETCDSnapshotS3{Bucket: "backups"},
+	}, cp, "etcd-")
+	assert.Error(t, err, "the instance credentials of the etcd configuration are not inherited")
+}
+
+func TestWithEtcdS3TargetSecretKey(t *testing.T) {
+	instruction := withEtcdS3TargetSecretKey(plan.OneTimeInstruction{
+		Name:    "prune-s3-target-offsite",
+		Command: "rke2",
+		Args:    []string{"etcd-snapshot", "prune", "--etcd-s3-bucket=backups"},
+		Env:     []string{"AWS_SECRET_ACCESS_KEY=secret"},
+	})
+	assert.Equal(t, "/bin/sh", instruction.Command)
+	assert.Equal(t, []string{
+		"-c", `exec "$@" --etcd-s3-secret-key="${AWS_SECRET_ACCESS_KEY}"`, "--",
+		"rke2", "etcd-snapshot", "prune", "--etcd-s3-bucket=backups",
+	}, instruction.Args)
+	assert.Equal(t, []string{"AWS_SECRET_ACCESS_KEY=secret"}, instruction.Env)
+}
@@ -0,0 +1,266 @@
+package planner
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// etcdSnapshotStatusFailed is the status the distro reports for snapshot files whose creation failed.
+const etcdSnapshotStatusFailed = "failed"
+
+// etcdPlaneLost returns true if the cluster was initialized, but no etcd machine that is neither deleting nor failed
+// has received a plan, i.e. every etcd machine the cluster was initialized with was lost.
+func etcdPlaneLost(status rkev1.RKEControlPlaneStatus, clusterPlan *plan.Plan) bool {
+	if !capr.Bootstrapped.IsTrue(&status) && len(collect(clusterPlan, roleOr(hasJoinURL, hasJoinedTo))) == 0 {
+		return false
+	}
+	return len(collect(clusterPlan, roleAnd(isEtcd, roleAnd(anyPlanDataExists, roleNot(roleOr(isDeleting, isFailed)))))) == 0
+}
+
+// etcdSnapshotRestore returns the etcd snapshot restore that is run for the controlplane. This is the etcd snapshot
+// restore of the spec, unless the etcd disaster recovery requested a newer restore in status, or such a restore was
+// already run, in which case the older restore of the spec must not be run again.
+func etcdSnapshotRestore(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) *rkev1.ETCDSnapshotRestore {
+	restore := cp.Spec.ETCDSnapshotRestore
+	if record := status.ETCDDisasterRecovery; record != nil && record.Phase == rkev1.ETCDDisasterRecoveryPhaseRestore && record.RestoreGeneration > 0 &&
+		(restore == nil || restore.Generation < record.RestoreGeneration) {
+		return &rkev1.ETCDSnapshotRestore{
+			Name:             record.SnapshotName,
+			Generation:       record.RestoreGeneration,
+			RestoreRKEConfig: "none",
+		}
+	}
+	if restore != nil && status.ETCDSnapshotRestore != nil && restore.Generation < status.ETCDSnapshotRestore.Generation {
+		return status.ETCDSnapshotRestore
+	}
+	return restore
+}
+
+// withEtcdSnapshotRestore returns the controlplane with the etcd snapshot restore that is run for it set in its spec.
+// The returned controlplane is only used to run the restore, and must never be updated.
+func withEtcdSnapshotRestore(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) *rkev1.RKEControlPlane {
+	restore := etcdSnapshotRestore(cp, status)
+	if restore == cp.Spec.ETCDSnapshotRestore {
+		return cp
+	}
+	cp = cp.DeepCopy()
+	cp.Spec.ETCDSnapshotRestore = restore.DeepCopy()
+	return cp
+}
+
+// etcdSnapshotRestoreInProgress returns true if an etcd snapshot restore was requested and has not completed yet.
+func etcdSnapshotRestoreInProgress(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) bool {
+	if restore := etcdSnapshotRestore(cp, status); restore == nil || restore.Name == "" {
+		return false
+	}
+	switch status.ETCDSnapshotRestorePhase {
+	case rkev1.ETCDSnapshotPhaseFinished, rkev1.ETCDSnapshotPhaseValidationFailed, rkev1.ETCDSnapshotPhaseFailed:
+		return false
+	}
+	return true
+}
+
+// setEtcdDisasterRecoveryPhase sets the phase of the given etcd disaster recovery record, which must not be shared with
+// the cached controlplane, records the step, and sets the record on the status.
+func setEtcdDisasterRecoveryPhase(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, record *rkev1.ETCDDisasterRecoveryStatus, phase rkev1.ETCDDisasterRecoveryPhase, message string) rkev1.RKEControlPlaneStatus {
+	logrus.Infof("[planner] rkecluster %s/%s: etcd disaster recovery: %s", cp.Namespace, cp.Name, message)
+	record.Phase = phase
+	record.Steps = append(record.Steps, rkev1.ETCDDisasterRecoveryStep{
+		Phase:   phase,
+		Message: message,
+		Time:    metav1.Now(),
+	})
+	status.ETCDDisasterRecovery = record
+	return status
+}
+
+// recoverEtcd recovers the etcd plane of the cluster from the newest valid S3 etcd snapshot if every etcd machine was
+// lost. The phases are in order:
+// Provision -> The lost etcd machines are deleted, so that the controller that owns them provisions replacements, and
+// the recovery waits until a new etcd machine can become the init node.
+// Restore -> An etcd snapshot restore of the snapshot is requested and run through the restore phases. If the restore
+// is refused during validation, the next newest snapshot is restored instead.
+// Rejoin -> The control plane and worker machines are reconciled until their plans are in sync.
+// Finished -> The etcd plane was recovered, and a new recovery is started if it is lost again.
+func (p *Planner) recoverEtcd(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
+	if cp.Spec.ETCDDisasterRecovery == nil || !cp.Spec.ETCDDisasterRecovery.Enabled {
+		status.ETCDDisasterRecovery = nil
+		return status, nil
+	}
+
+	record := status.ETCDDisasterRecovery.DeepCopy()
+	if record == nil {
+		record = &rkev1.ETCDDisasterRecoveryStatus{}
+	}
+
+	switch record.Phase {
+	case rkev1.ETCDDisasterRecoveryPhaseProvision:
+		return p.runEtcdDisasterRecoveryProvision(cp, status, record, clusterPlan)
+	case rkev1.ETCDDisasterRecoveryPhaseRestore:
+		return p.runEtcdDisasterRecoveryRestore(cp, status, record)
+	case rkev1.ETCDDisasterRecoveryPhaseRejoin:
+		if !etcdDisasterRecoveryRejoined(status, clusterPlan) {
+			// the cluster is reconciled as usual until every machine joined the restored cluster
+			return status, nil
+		}
+		return setEtcdDisasterRecoveryPhase(cp, status, record, rkev1.ETCDDisasterRecoveryPhaseFinished, "all machines rejoined the cluster, etcd plane recovered"), nil
+	case rkev1.ETCDDisasterRecoveryPhaseFailed:
+		return status, nil
+	}
+
+	if etcdSnapshotBootstrapInProgress(cp, status) || etcdSnapshotRestoreInProgress(cp, status) || !etcdPlaneLost(status, clusterPlan) {
+		return status, nil
+	}
+
+	snapshot, err := p.newestValidS3EtcdSnapshot(cp, nil)
+	if err != nil {
+		return status, err
+	}
+	if snapshot == nil {
+		return status, errWaiting("all etcd machines were lost, but no S3 etcd snapshot exists to recover the etcd plane from")
+	}
+
+	status = setEtcdDisasterRecoveryPhase(cp, status, &rkev1.ETCDDisasterRecoveryStatus{SnapshotName: snapshot.Name}, rkev1.ETCDDisasterRecoveryPhaseProvision,
+		fmt.Sprintf("all etcd machines were lost, recovering etcd plane from etcd snapshot %s", snapshot.Name))
+	return status, errWaiting("recovering etcd plane")
+}
+
+// runEtcdDisasterRecoveryProvision deletes the lost etcd machines, and waits for a new etcd machine that can become the
+// init node.
+func (p *Planner) runEtcdDisasterRecoveryProvision(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, record *rkev1.ETCDDisasterRecoveryStatus, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
+	var deleted []string
+	for _, entry := range collect(clusterPlan, roleAnd(isEtcd, roleAnd(isFailed, isNotDeleting))) {
+		if err := p.machines.Delete(entry.Machine.Namespace, entry.Machine.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return status, err
+		}
+		deleted = append(deleted, entry.Machine.Name)
+	}
+	if len(deleted) > 0 {
+		sort.Strings(deleted)
+		status = setEtcdDisasterRecoveryPhase(cp, status, record, rkev1.ETCDDisasterRecoveryPhaseProvision,
+			fmt.Sprintf("deleted failed etcd machines [%s] to provision replacements", strings.Join(deleted, ", ")))
+		return status, errWaiting("deleting failed etcd machines")
+	}
+
+	// etcd machines that are stuck deleting would otherwise block the restore
+	if etcdDeleting, err := p.forceDeleteAllDeletingEtcdMachines(cp, clusterPlan); err != nil {
+		return status, err
+	} else if etcdDeleting != 0 {
+		return status, errWaiting("waiting for all lost etcd machines to be deleted")
+	}
+
+	candidates := collect(clusterPlan, canBeInitNode)
+	if len(candidates) == 0 {
+		return status, errWaiting("waiting for a new etcd machine to be provisioned to recover the etcd plane")
+	}
+
+	status = setEtcdDisasterRecoveryPhase(cp, status, record, rkev1.ETCDDisasterRecoveryPhaseRestore,
+		fmt.Sprintf("etcd machine %s was provisioned, restoring etcd snapshot %s", candidates[0].Machine.Name, record.SnapshotName))
+	return status, errWaiting("restoring etcd snapshot to recover etcd plane")
+}
+
+// runEtcdDisasterRecoveryRestore requests the restore of the etcd snapshot of the recovery, and waits for the restore to
+// complete. The restore is requested through the restore generation of the recovery in status, and is run with a
+// generation that is newer than the etcd snapshot restore of the spec, which is not modified.
+func (p *Planner) runEtcdDisasterRecoveryRestore(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, record *rkev1.ETCDDisasterRecoveryStatus) (rkev1.RKEControlPlaneStatus, error) {
+	if record.RestoreGeneration == 0 {
+		record.RestoreGeneration = 1
+		for _, restore := range []*rkev1.ETCDSnapshotRestore{cp.Spec.ETCDSnapshotRestore, status.ETCDSnapshotRestore} {
+			if restore != nil && restore.Generation >= record.RestoreGeneration {
+				record.RestoreGeneration = restore.Generation + 1
+			}
+		}
+		status.ETCDDisasterRecovery = record
+		return status, errWaiting("requesting etcd snapshot restore to recover etcd plane")
+	}
+
+	if restore := cp.Spec.ETCDSnapshotRestore; restore != nil && (restore.Generation > record.RestoreGeneration ||
+		restore.Generation == record.RestoreGeneration && restore.Name != record.SnapshotName) {
+		return setEtcdDisasterRecoveryPhase(cp, status, record, rkev1.ETCDDisasterRecoveryPhaseFailed,
+			fmt.Sprintf("etcd snapshot restore was superseded by the restore of etcd snapshot %s", restore.Name)), nil
+	}
+
+	if status.ETCDSnapshotRestore == nil || status.ETCDSnapshotRestore.Generation != record.RestoreGeneration {
+		// the etcd snapshot restore has not been started yet
+		return status, nil
+	}
+
+	switch status.ETCDSnapshotRestorePhase {
+	case rkev1.ETCDSnapshotPhaseFinished:
+		status = setEtcdDisasterRecoveryPhase(cp, status, record, rkev1.ETCDDisasterRecoveryPhaseRejoin,
+			fmt.Sprintf("etcd snapshot %s was restored, rejoining control plane and worker machines", record.SnapshotName))
+		return status, errWaiting("rejoining machines to recovered etcd plane")
+	case rkev1.ETCDSnapshotPhaseValidationFailed:
+		refused := fmt.Sprintf("restore of etcd snapshot %s was refused: %s", record.SnapshotName, status.ETCDSnapshotRestoreMessage)
+		record.RejectedSnapshotNames = append(record.RejectedSnapshotNames, record.SnapshotName)
+		snapshot, err := p.newestValidS3EtcdSnapshot(cp, record.RejectedSnapshotNames)
+		if err != nil {
+			return status, err
+		}
+		if snapshot == nil {
+			return setEtcdDisasterRecoveryPhase(cp, status, record, rkev1.ETCDDisasterRecoveryPhaseFailed,
+				refused+", no other S3 etcd snapshot exists to recover the etcd plane from"), nil
+		}
+		record.SnapshotName = snapshot.Name
+		record.RestoreGeneration = 0
+		status = setEtcdDisasterRecoveryPhase(cp, status, record, rkev1.ETCDDisasterRecoveryPhaseRestore,
+			fmt.Sprintf("%s, restoring etcd snapshot %s instead", refused, snapshot.Name))
+		return status, errWaiting("restoring next newest etcd snapshot to recover etcd plane")
+	case rkev1.ETCDSnapshotPhaseFailed:
+		return setEtcdDisasterRecoveryPhase(cp, status, record, rkev1.ETCDDisasterRecoveryPhaseFailed,
+			fmt.Sprintf("restore of etcd snapshot %s failed", record.SnapshotName)), nil
+	}
+	return status, nil
+}
+
+// newestValidS3EtcdSnapshot returns the newest etcd snapshot of the cluster that is stored in S3, was created
+// successfully, and is not missing from S3, skipping the snapshots with the given names. It returns nil if no such
+// snapshot exists.
+func (p *Planner) newestValidS3EtcdSnapshot(cp *rkev1.RKEControlPlane, skip []string) (*rkev1.ETCDSnapshot, error) {
+	snapshots, err := p.etcdSnapshotCache.List(cp.Namespace, labels.SelectorFromSet(map[string]string{
+		capr.ClusterNameLabel: cp.Name,
+		capr.NodeNameLabel:    "s3",
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return newestValidS3EtcdSnapshot(snapshots, skip), nil
+}
+
+func newestValidS3EtcdSnapshot(snapshots []*rkev1.ETCDSnapshot, skip []string) *rkev1.ETCDSnapshot {
+	var newest *rkev1.ETCDSnapshot
+	for _, snapshot := range snapshots {
+		file := snapshot.SnapshotFile
+		if file.S3 == nil || file.CreatedAt == nil || file.Status == etcdSnapshotStatusFailed || snapshot.Status.Missing || slices.Contains(skip, snapshot.Name) {
+			continue
+		}
+		if newest == nil || file.CreatedAt.After(newest.SnapshotFile.CreatedAt.Time) {
+			newest = snapshot
+		}
+	}
+	return newest
+}
+
+// etcdDisasterRecoveryRejoined returns true if the controlplane is ready and the plans of all machines that are not
+// deleting are in sync.
+func etcdDisasterRecoveryRejoined(status rkev1.RKEControlPlaneStatus, clusterPlan *plan.Plan) bool {
+	if !status.Initialized || !status.Ready {
+		return false
+	}
+	for _, entry := range collect(clusterPlan, roleAnd(anyRole, isNotDeleting)) {
+		if entry.Plan == nil || !entry.Plan.InSync {
+			return false
+		}
+	}
+	return true
+}
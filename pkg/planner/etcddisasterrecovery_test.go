@@ -0,0 +1,161 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestNewestValidS3EtcdSnapshot(t *testing.T) {
+	now := time.Now()
+	snapshot := func(name string, age time.Duration, s3 bool, status string, missing bool) *rkev1.ETCDSnapshot {
+		s := &rkev1.ETCDSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			SnapshotFile: rkev1.ETCDSnapshotFile{
+				CreatedAt: &metav1.Time{Time: now.Add(-age)},
+				Status:    status,
+			},
+			Status: rkev1.ETCDSnapshotStatus{Missing: missing},
+		}
+		if s3 {
+			s.SnapshotFile.S3 = &rkev1.ETCDSnapshotS3{Bucket: "backups"}
+		}
+		return s
+	}
+
+	snapshots := []*rkev1.ETCDSnapshot{
+		snapshot("old", 3*time.Hour, true, "successful", false),
+		snapshot("newer", 2*time.Hour, true, "successful", false),
+		snapshot("local", 0, false, "successful", false),
+		snapshot("failed", 0, true, etcdSnapshotStatusFailed, false),
+		snapshot("missing", time.Hour, true, "successful", true),
+	}
+
+	if newest := newestValidS3EtcdSnapshot(snapshots, nil); assert.NotNil(t, newest) {
+		assert.Equal(t, "newer", newest.Name)
+	}
+	if newest := newestValidS3EtcdSnapshot(snapshots, []string{"newer"}); assert.NotNil(t, newest) {
+		assert.Equal(t, "old", newest.Name)
+	}
+	assert.Nil(t, newestValidS3EtcdSnapshot(snapshots, []string{"newer", "old"}))
+}
+
+func TestEtcdPlaneLost(t *testing.T) {
+	bootstrapped := rkev1.RKEControlPlaneStatus{}
+	capr.Bootstrapped.True(&bootstrapped)
+
+	clusterPlan := func(etcdPlanData bool, etcdPhase capi.MachinePhase) *plan.Plan {
+		return &plan.Plan{
+			Machines: map[string]*capi.Machine{
+				"etcd": {Status: capi.MachineStatus{Phase: string(etcdPhase)}},
+			},
+			Nodes: map[string]*plan.Node{
+				"etcd": {PlanDataExists: etcdPlanData},
+			},
+			Metadata: map[string]*plan.Metadata{
+				"etcd": {Labels: map[string]string{capr.EtcdRoleLabel: "true"}},
+			},
+		}
+	}
+
+	assert.False(t, etcdPlaneLost(rkev1.RKEControlPlaneStatus{}, clusterPlan(false, capi.MachinePhaseRunning)), "new cluster")
+	assert.False(t, etcdPlaneLost(bootstrapped, clusterPlan(true, capi.MachinePhaseRunning)), "healthy etcd machine")
+	assert.True(t, etcdPlaneLost(bootstrapped, clusterPlan(false, capi.MachinePhaseRunning)), "replaced etcd machine")
+	assert.True(t, etcdPlaneLost(bootstrapped, clusterPlan(true, capi.MachinePhaseFailed)), "failed etcd machine")
+}
+
+func TestRecoverEtcdRestore(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.ETCDDisasterRecovery = &rkev1.ETCDDisasterRecovery{Enabled: true}
+	cp.Spec.ETCDSnapshotRestore = &rkev1.ETCDSnapshotRestore{Name: "user", Generation: 1}
+	status := rkev1.RKEControlPlaneStatus{
+		ETCDDisasterRecovery: &rkev1.ETCDDisasterRecoveryStatus{
+			Phase:             rkev1.ETCDDisasterRecoveryPhaseRestore,
+			SnapshotName:      "snapshot",
+			RestoreGeneration: 2,
+		},
+		ETCDSnapshotRestore:      &rkev1.ETCDSnapshotRestore{Name: "snapshot", Generation: 2},
+		ETCDSnapshotRestorePhase: rkev1.ETCDSnapshotPhaseRestore,
+	}
+
+	p := &Planner{}
+	newStatus, err := p.recoverEtcd(cp, status, &plan.Plan{})
+	assert.NoError(t, err)
+	assert.Equal(t, rkev1.ETCDDisasterRecoveryPhaseRestore, newStatus.ETCDDisasterRecovery.Phase)
+
+	status.ETCDSnapshotRestorePhase = rkev1.ETCDSnapshotPhaseFinished
+	newStatus, err = p.recoverEtcd(cp, status, &plan.Plan{})
+	assert.True(t, IsErrWaiting(err))
+	assert.Equal(t, rkev1.ETCDDisasterRecoveryPhaseRejoin, newStatus.ETCDDisasterRecovery.Phase)
+	assert.Len(t, newStatus.ETCDDisasterRecovery.Steps, 1)
+	assert.Empty(t, status.ETCDDisasterRecovery.Steps, "the status of the cached controlplane must not be modified")
+
+	cp.Spec.ETCDSnapshotRestore = &rkev1.ETCDSnapshotRestore{Name: "other", Generation: 3}
+	newStatus, err = p.recoverEtcd(cp, status, &plan.Plan{})
+	assert.NoError(t, err)
+	assert.Equal(t, rkev1.ETCDDisasterRecoveryPhaseFailed, newStatus.ETCDDisasterRecovery.Phase)
+
+	cp.Spec.ETCDDisasterRecovery.Enabled = false
+	newStatus, err = p.recoverEtcd(cp, status, &plan.Plan{})
+	assert.NoError(t, err)
+	assert.Nil(t, newStatus.ETCDDisasterRecovery)
+}
+
+func TestRecoverEtcdRequestRestore(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.ETCDDisasterRecovery = &rkev1.ETCDDisasterRecovery{Enabled: true}
+	cp.Spec.ETCDSnapshotRestore = &rkev1.ETCDSnapshotRestore{Name: "user", Generation: 1}
+	status := rkev1.RKEControlPlaneStatus{
+		ETCDDisasterRecovery: &rkev1.ETCDDisasterRecoveryStatus{
+			Phase:        rkev1.ETCDDisasterRecoveryPhaseRestore,
+			SnapshotName: "snapshot",
+		},
+		ETCDSnapshotRestore:      &rkev1.ETCDSnapshotRestore{Name: "user", Generation: 3},
+		ETCDSnapshotRestorePhase: rkev1.ETCDSnapshotPhaseFinished,
+	}
+
+	// the restore is requested in status, the spec of the controlplane is not updated
+	p := &Planner{}
+	newStatus, err := p.recoverEtcd(cp, status, &plan.Plan{})
+	assert.True(t, IsErrWaiting(err))
+	assert.Equal(t, 4, newStatus.ETCDDisasterRecovery.RestoreGeneration)
+	assert.Equal(t, &rkev1.ETCDSnapshotRestore{Name: "user", Generation: 1}, cp.Spec.ETCDSnapshotRestore)
+
+	assert.Equal(t, &rkev1.ETCDSnapshotRestore{
+		Name:             "snapshot",
+		Generation:       4,
+		RestoreRKEConfig: "none",
+	}, withEtcdSnapshotRestore(cp, newStatus).Spec.ETCDSnapshotRestore)
+	assert.Equal(t, &rkev1.ETCDSnapshotRestore{Name: "user", Generation: 1}, cp.Spec.ETCDSnapshotRestore)
+}
+
+func TestEtcdSnapshotRestore(t *testing.T) {
+	spec := &rkev1.ETCDSnapshotRestore{Name: "user", Generation: 2}
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.ETCDSnapshotRestore = spec
+
+	// the restore of the spec is run
+	assert.Same(t, spec, etcdSnapshotRestore(cp, rkev1.RKEControlPlaneStatus{}))
+
+	// a newer restore that was requested by the etcd disaster recovery and already run is not replaced by the older
+	// restore of the spec
+	ran := &rkev1.ETCDSnapshotRestore{Name: "snapshot", Generation: 3, RestoreRKEConfig: "none"}
+	assert.Same(t, ran, etcdSnapshotRestore(cp, rkev1.RKEControlPlaneStatus{ETCDSnapshotRestore: ran}))
+
+	// a newer restore of the spec supersedes the restore of the etcd disaster recovery
+	cp.Spec.ETCDSnapshotRestore = &rkev1.ETCDSnapshotRestore{Name: "user", Generation: 4}
+	assert.Same(t, cp.Spec.ETCDSnapshotRestore, etcdSnapshotRestore(cp, rkev1.RKEControlPlaneStatus{
+		ETCDDisasterRecovery: &rkev1.ETCDDisasterRecoveryStatus{
+			Phase:             rkev1.ETCDDisasterRecoveryPhaseRestore,
+			SnapshotName:      "snapshot",
+			RestoreGeneration: 3,
+		},
+		ETCDSnapshotRestore: ran,
+	}))
+}
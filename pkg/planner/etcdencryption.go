@@ -0,0 +1,362 @@
+package planner
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ETCDSnapshotEncryptionKeyIDMetadataKey is the key of the snapshot metadata that records the identifier of the key an
+	// etcd snapshot in S3 was encrypted with. It is added to the metadata by the etcd snapshot upload script.
+	ETCDSnapshotEncryptionKeyIDMetadataKey = "encryption-key-id"
+	// ETCDSnapshotEncryptionKeyIDEnvVar is the environment variable that passes the identifier of the encryption key to
+	// the etcd snapshot scripts.
+	ETCDSnapshotEncryptionKeyIDEnvVar = "ETCD_SNAPSHOT_ENCRYPTION_KEY_ID"
+
+	etcdSnapshotEncryptionSecretKey   = "key"
+	etcdSnapshotEncryptionSecretKeyID = "keyID"
+
+	// etcdSnapshotHMACFunctions are the shell functions of the etcd snapshot scripts that authenticate encrypted
+	// snapshots, as the encryption of openssl enc is not authenticated. hmac prints the HMAC-SHA256 of the given file with
+	// a key that is derived from the encryption key, which is computed with the shell, so that no key is ever passed as an
+	// argument. The HMAC is appended to the encrypted snapshot as hexadecimal string.
+	etcdSnapshotHMACFunctions = `
+etcd_snapshot_hmac_length=64
+
+sha256_hex() {
+	openssl dgst -sha256 -binary | od -An -v -tx1 | tr -d ' \n'
+}
+
+# hex_to_bin prints the bytes of the given hexadecimal string, xored with the given byte.
+hex_to_bin() {
+	hex=$1
+	while [ -n "${hex}" ]; do
+		rest=${hex#??}
+		printf "\\$(printf '%03o' "$((0x${hex%"${rest}"} ^ ${2:-0}))")"
+		hex=${rest}
+	done
+}
+
+hmac() {
+	mac_key=$(printf 'etcd-snapshot-hmac:%s' "${ETCD_SNAPSHOT_ENCRYPTION_KEY}" | sha256_hex) || return 1
+	# the key is padded to the block size of SHA-256
+	mac_key="${mac_key}0000000000000000000000000000000000000000000000000000000000000000"
+	inner=$({ hex_to_bin "${mac_key}" 54; cat "$1"; } | sha256_hex) || return 1
+	{ hex_to_bin "${mac_key}" 92; hex_to_bin "${inner}"; } | sha256_hex
+}
+`
+
+	etcdSnapshotDownloadScriptPath = "etcd_snapshot_download.sh"
+	// etcdSnapshotDownloadScript downloads the given encrypted etcd snapshot from S3 with the S3 configuration of the
+	// distro that is passed as environment variables, verifies its HMAC, and decrypts it to the given path, so that the
+	// distro can restore it like a local snapshot. A snapshot whose HMAC does not match is not decrypted.
+	etcdSnapshotDownloadScript = "#!/bin/sh\n" + etcdSnapshotHMACFunctions + `
+ENDPOINT=${ETCD_S3_ENDPOINT:-s3.amazonaws.com}
+REGION=${ETCD_S3_REGION:-us-east-1}
+SCHEME=https
+if [ "${ETCD_S3_INSECURE}" = "true" ]; then
+	SCHEME=http
+fi
+key=$1
+destination=$2
+
+for command in curl openssl; do
+	if ! command -v "${command}" >/dev/null 2>&1; then
+		echo "${command} is required to download encrypted etcd snapshots" >&2
+		exit 1
+	fi
+done
+
+if [ -n "${ETCD_S3_FOLDER}" ]; then
+	key="${ETCD_S3_FOLDER}/${key}"
+fi
+set -- --fail --silent --show-error --aws-sigv4 "aws:amz:${REGION}:s3" --user "${ETCD_S3_ACCESS_KEY}:${AWS_SECRET_ACCESS_KEY}" \
+	-H "x-amz-content-sha256: UNSIGNED-PAYLOAD"
+if [ "${SCHEME}" = "https" ] && [ "${ETCD_S3_SKIP_SSL_VERIFY}" = "true" ]; then
+	set -- "$@" --insecure
+fi
+if [ "${SCHEME}" = "https" ] && [ -n "${ETCD_S3_ENDPOINT_CA}" ]; then
+	set -- "$@" --cacert "${ETCD_S3_ENDPOINT_CA}"
+fi
+
+mkdir -p "$(dirname "${destination}")" || exit 1
+trap 'rm -f "${destination}.download" "${destination}.encrypted"' EXIT
+curl "$@" --output "${destination}.download" "${SCHEME}://${ENDPOINT}/${ETCD_S3_BUCKET}/${key}" || exit 1
+
+size=$(wc -c <"${destination}.download")
+if [ "${size}" -le "${etcd_snapshot_hmac_length}" ]; then
+	echo "etcd snapshot ${key} is not authenticated" >&2
+	exit 1
+fi
+expected=$(tail -c "${etcd_snapshot_hmac_length}" "${destination}.download")
+head -c "$((size - etcd_snapshot_hmac_length))" "${destination}.download" >"${destination}.encrypted" || exit 1
+actual=$(hmac "${destination}.encrypted") || exit 1
+if [ "${actual}" != "${expected}" ]; then
+	echo "etcd snapshot ${key} failed authentication, it was not encrypted with the configured key or was modified" >&2
+	exit 1
+fi
+
+openssl enc -d -aes-256-cbc -pbkdf2 -iter 100000 -pass env:ETCD_SNAPSHOT_ENCRYPTION_KEY \
+	-in "${destination}.encrypted" -out "${destination}"
+`
+
+	etcdSavedSnapshotsPrefix   = "capr/etcd/saved"
+	etcdSnapshotSaveScriptPath = "etcd_snapshot_save.sh"
+	// etcdSnapshotSaveScript runs the given etcd snapshot save command of the distro, and records the name of the local
+	// snapshot file with the given name prefix that was created by it in the given file, so that exactly that snapshot is
+	// uploaded afterwards, even if the names of other snapshots start with the same prefix.
+	etcdSnapshotSaveScript = `#!/bin/sh
+RECORD=$1
+DIR=$2
+PREFIX=$3
+shift 3
+
+before=$(ls -1 "${DIR}" 2>/dev/null)
+"$@" || exit 1
+
+saved=""
+for name in $(ls -1 "${DIR}" 2>/dev/null); do
+	case "${name}" in
+	"${PREFIX}"-*) ;;
+	*) continue ;;
+	esac
+	if printf '%s\n' "${before}" | grep -qxF "${name}"; then
+		continue
+	fi
+	if [ -n "${saved}" ]; then
+		echo "more than one etcd snapshot with name ${PREFIX} was saved" >&2
+		exit 1
+	fi
+	saved=${name}
+done
+if [ -z "${saved}" ]; then
+	echo "no etcd snapshot with name ${PREFIX} was saved" >&2
+	exit 1
+fi
+mkdir -p "$(dirname "${RECORD}")" || exit 1
+printf '%s\n' "${saved}" >"${RECORD}"
+`
+)
+
+// etcdSnapshotEncryptionKeyIDPattern restricts the identifiers of encryption keys, as they are written into the JSON
+// metadata of snapshots by a shell script.
+var etcdSnapshotEncryptionKeyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// etcdSnapshotEncryptionEnabled returns true if etcd snapshots of the controlplane are encrypted before they are uploaded
+// to S3.
+func etcdSnapshotEncryptionEnabled(controlPlane *rkev1.RKEControlPlane) bool {
+	return controlPlane.Spec.ETCD != nil && controlPlane.Spec.ETCD.SnapshotEncryption != nil
+}
+
+// etcdSnapshotEncryptionKey returns the etcd snapshot encryption key of the controlplane and its identifier.
+func (p *Planner) etcdSnapshotEncryptionKey(controlPlane *rkev1.RKEControlPlane) (string, string, error) {
+	if !etcdSnapshotEncryptionEnabled(controlPlane) {
+		return "", "", fmt.Errorf("etcd snapshot encryption is not configured")
+	}
+	secretName := controlPlane.Spec.ETCD.SnapshotEncryption.SecretName
+	secret, err := p.secretCache.Get(controlPlane.Namespace, secretName)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get etcd snapshot encryption secret %s/%s: %w", controlPlane.Namespace, secretName, err)
+	}
+	key, keyID, err := etcdSnapshotEncryptionKeyFromSecret(secret)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid etcd snapshot encryption secret %s/%s: %w", controlPlane.Namespace, secretName, err)
+	}
+	return key, keyID, nil
+}
+
+// etcdSnapshotEncryptionKeyFromSecret returns the encryption key and its identifier from the given secret. The
+// identifier is derived from the key if the secret does not set one.
+func etcdSnapshotEncryptionKeyFromSecret(secret *corev1.Secret) (string, string, error) {
+	key := string(secret.Data[etcdSnapshotEncryptionSecretKey])
+	if key == "" {
+		return "", "", fmt.Errorf("%s is not set", etcdSnapshotEncryptionSecretKey)
+	}
+	keyID := string(secret.Data[etcdSnapshotEncryptionSecretKeyID])
+	if keyID == "" {
+		sum := sha256.Sum256([]byte(key))
+		keyID = hex.EncodeToString(sum[:])[:16]
+	}
+	if !etcdSnapshotEncryptionKeyIDPattern.MatchString(keyID) {
+		return "", "", fmt.Errorf("%s %q must consist of at most 64 alphanumeric characters, '.', '_' or '-'", etcdSnapshotEncryptionSecretKeyID, keyID)
+	}
+	return key, keyID, nil
+}
+
+// etcdSnapshotEncryptionEnv returns the environment variables that make the etcd snapshot scripts encrypt or decrypt
+// snapshots with the encryption key of the controlplane, or nothing if etcd snapshot encryption is not configured.
+func (p *Planner) etcdSnapshotEncryptionEnv(controlPlane *rkev1.RKEControlPlane) ([]string, error) {
+	if !etcdSnapshotEncryptionEnabled(controlPlane) {
+		return nil, nil
+	}
+	key, keyID, err := p.etcdSnapshotEncryptionKey(controlPlane)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		fmt.Sprintf("ETCD_SNAPSHOT_ENCRYPTION_KEY=%s", key),
+		fmt.Sprintf("%s=%s", ETCDSnapshotEncryptionKeyIDEnvVar, keyID),
+	}, nil
+}
+
+// etcdSnapshotEncryptionKeyID returns the identifier of the key the given etcd snapshot was encrypted with from its
+// metadata, or an empty string if the snapshot is not encrypted.
+func etcdSnapshotEncryptionKeyID(snapshot *rkev1.ETCDSnapshot) (string, error) {
+	if snapshot == nil || snapshot.SnapshotFile.S3 == nil || snapshot.SnapshotFile.Metadata == "" {
+		return "", nil
+	}
+	b, err := base64.StdEncoding.DecodeString(snapshot.SnapshotFile.Metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode metadata of etcd snapshot %s: %w", snapshot.SnapshotFile.Name, err)
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal(b, &metadata); err != nil {
+		return "", fmt.Errorf("failed to unmarshal metadata of etcd snapshot %s: %w", snapshot.SnapshotFile.Name, err)
+	}
+	return metadata[ETCDSnapshotEncryptionKeyIDMetadataKey], nil
+}
+
+// validateEtcdSnapshotEncryption returns the reason the given etcd snapshot can not be decrypted with the encryption key
+// of the controlplane, or an empty string if the snapshot is not encrypted or the key matches. While etcd snapshot
+// encryption is configured, an S3 snapshot whose metadata was not listed is refused, as its key can not be found.
+func (p *Planner) validateEtcdSnapshotEncryption(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot) string {
+	snapshotKeyID, err := etcdSnapshotEncryptionKeyID(snapshot)
+	if err != nil {
+		return err.Error()
+	}
+	if snapshotKeyID == "" {
+		if etcdSnapshotEncryptionEnabled(controlPlane) && snapshot != nil && snapshot.SnapshotFile.S3 != nil && snapshot.SnapshotFile.Metadata == "" {
+			return fmt.Sprintf("the encryption key of etcd snapshot %s can not be found as its metadata is unknown, etcd snapshot encryption must be removed to restore it if it is not encrypted", snapshot.SnapshotFile.Name)
+		}
+		return ""
+	}
+	if !etcdSnapshotEncryptionEnabled(controlPlane) {
+		return fmt.Sprintf("etcd snapshot %s is encrypted with key %s, but etcd snapshot encryption is not configured", snapshot.SnapshotFile.Name, snapshotKeyID)
+	}
+	_, keyID, err := p.etcdSnapshotEncryptionKey(controlPlane)
+	if err != nil {
+		return err.Error()
+	}
+	if keyID != snapshotKeyID {
+		return fmt.Sprintf("etcd snapshot %s is encrypted with key %s, but the configured encryption key is %s", snapshot.SnapshotFile.Name, snapshotKeyID, keyID)
+	}
+	return ""
+}
+
+// etcdSnapshotLocalPath returns the path of the local snapshot with the given name in the snapshot directory of the
+// distro.
+func etcdSnapshotLocalPath(controlPlane *rkev1.RKEControlPlane, snapshotName string) string {
+	return path.Join(capr.GetDistroDataDir(controlPlane), "server/db/snapshots", snapshotName)
+}
+
+// generateEtcdSnapshotDownloadInstruction generates the files and the instruction that download the given encrypted S3
+// snapshot and decrypt it into the snapshot directory of the distro.
+func (p *Planner) generateEtcdSnapshotDownloadInstruction(controlPlane *rkev1.RKEControlPlane, entry *planEntry, snapshot *rkev1.ETCDSnapshot) ([]plan.File, plan.OneTimeInstruction, error) {
+	args, env, files, err := p.etcdS3Args.ToArgs(snapshot.SnapshotFile.S3, controlPlane, "etcd-", true)
+	if err != nil {
+		return nil, plan.OneTimeInstruction{}, err
+	}
+	if env, err = etcdS3ArgsToEnv(args, env); err != nil {
+		return nil, plan.OneTimeInstruction{}, err
+	}
+	insecureEnv, err := p.etcdS3InsecureEnv(controlPlane, entry)
+	if err != nil {
+		return nil, plan.OneTimeInstruction{}, err
+	}
+	env = append(env, insecureEnv...)
+	encryptionEnv, err := p.etcdSnapshotEncryptionEnv(controlPlane)
+	if err != nil {
+		return nil, plan.OneTimeInstruction{}, err
+	}
+	files = append(files, plan.File{
+		Content: base64.StdEncoding.EncodeToString([]byte(etcdSnapshotDownloadScript)),
+		Path:    etcdScriptPath(controlPlane, etcdSnapshotDownloadScriptPath),
+		Dynamic: true,
+	})
+	return files, plan.OneTimeInstruction{
+		Name:    "download-encrypted-snapshot",
+		Command: "/bin/sh",
+		Args: []string{
+			etcdScriptPath(controlPlane, etcdSnapshotDownloadScriptPath),
+			snapshot.SnapshotFile.Name,
+			etcdSnapshotLocalPath(controlPlane, snapshot.SnapshotFile.Name),
+		},
+		Env: append(env, encryptionEnv...),
+	}, nil
+}
+
+// encryptedEtcdSnapshotUploadEnv returns the environment variables of the instruction that uploads etcd snapshots to the
+// S3 location of the given S3 arguments and environment variables of the distro from the given machine, and encrypts
+// them first if etcd snapshot encryption is configured.
+func (p *Planner) encryptedEtcdSnapshotUploadEnv(controlPlane *rkev1.RKEControlPlane, entry *planEntry, s3Args, s3Env []string) ([]string, error) {
+	env, err := etcdS3ArgsToEnv(s3Args, append([]string{}, s3Env...))
+	if err != nil {
+		return nil, err
+	}
+	insecureEnv, err := p.etcdS3InsecureEnv(controlPlane, entry)
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, insecureEnv...)
+	encryptionEnv, err := p.etcdSnapshotEncryptionEnv(controlPlane)
+	if err != nil {
+		return nil, err
+	}
+	return append(env, encryptionEnv...), nil
+}
+
+// etcdSnapshotSaveRecordPath returns the path of the file that records the name of the local snapshot that was last
+// saved with the given name.
+func etcdSnapshotSaveRecordPath(controlPlane *rkev1.RKEControlPlane, snapshotName string) string {
+	return path.Join(capr.GetDistroDataDir(controlPlane), etcdSavedSnapshotsPrefix, snapshotName)
+}
+
+// generateEtcdSnapshotSaveScriptFile generates the file of the etcd snapshot save script.
+func generateEtcdSnapshotSaveScriptFile(controlPlane *rkev1.RKEControlPlane) plan.File {
+	return plan.File{
+		Content: base64.StdEncoding.EncodeToString([]byte(etcdSnapshotSaveScript)),
+		Path:    etcdScriptPath(controlPlane, etcdSnapshotSaveScriptPath),
+		Dynamic: true,
+	}
+}
+
+// withEtcdSnapshotSaveRecord returns the given etcd snapshot save instruction run through the etcd snapshot save script,
+// which records the name of the local snapshot that was saved with the given name.
+func withEtcdSnapshotSaveRecord(controlPlane *rkev1.RKEControlPlane, instruction plan.OneTimeInstruction, snapshotName string) plan.OneTimeInstruction {
+	instruction.Args = append([]string{
+		etcdScriptPath(controlPlane, etcdSnapshotSaveScriptPath),
+		etcdSnapshotSaveRecordPath(controlPlane, snapshotName),
+		etcdSnapshotLocalPath(controlPlane, ""),
+		snapshotName,
+		instruction.Command,
+	}, instruction.Args...)
+	instruction.Command = "/bin/sh"
+	return instruction
+}
+
+// generateEtcdSnapshotUploadLatestInstruction generates the instruction that uploads the local snapshot that was last
+// saved with the given name, as recorded by the etcd snapshot save script, to the S3 location of the given environment
+// variables, and encrypts it first if the environment variables contain an encryption key.
+func generateEtcdSnapshotUploadLatestInstruction(controlPlane *rkev1.RKEControlPlane, instructionName, snapshotName string, env []string) plan.OneTimeInstruction {
+	return plan.OneTimeInstruction{
+		Name:    instructionName,
+		Command: "/bin/sh",
+		Args: []string{
+			"-c",
+			fmt.Sprintf(`exec /bin/sh %s "%s/$(cat %s)"`,
+				etcdScriptPath(controlPlane, etcdSnapshotUploadScriptPath), etcdSnapshotLocalPath(controlPlane, ""),
+				etcdSnapshotSaveRecordPath(controlPlane, snapshotName)),
+		},
+		Env: env,
+	}
+}
@@ -0,0 +1,145 @@
+package planner
+
+import (
+	"encoding/base64"
+	"testing"
+
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestEtcdSnapshotEncryptionKeyFromSecret(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          map[string][]byte
+		expectedKey   string
+		expectedKeyID string
+		expectErr     bool
+	}{
+		{
+			name:          "key with identifier",
+			data:          map[string][]byte{"key": []byte("secret"), "keyID": []byte("2024-01")},
+			expectedKey:   "secret",
+			expectedKeyID: "2024-01",
+		},
+		{
+			name:          "identifier derived from key",
+			data:          map[string][]byte{"key": []byte("secret")},
+			expectedKey:   "secret",
+			expectedKeyID: "2bb80d537b1da3e3",
+		},
+		{
+			name:      "missing key",
+			data:      map[string][]byte{"keyID": []byte("2024-01")},
+			expectErr: true,
+		},
+		{
+			name:      "invalid identifier",
+			data:      map[string][]byte{"key": []byte("secret"), "keyID": []byte(`"2024"`)},
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, keyID, err := etcdSnapshotEncryptionKeyFromSecret(&corev1.Secret{Data: tt.data})
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedKey, key)
+			assert.Equal(t, tt.expectedKeyID, keyID)
+		})
+	}
+}
+
+func TestEtcdSnapshotEncryptionKeyID(t *testing.T) {
+	metadata := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	s3 := &rkev1.ETCDSnapshotS3{Bucket: "backups"}
+
+	keyID, err := etcdSnapshotEncryptionKeyID(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "", keyID)
+
+	keyID, err = etcdSnapshotEncryptionKeyID(&rkev1.ETCDSnapshot{SnapshotFile: rkev1.ETCDSnapshotFile{
+		S3:       s3,
+		Metadata: metadata(`{"encryption-key-id":"2024-01","provisioning-cluster-spec":"spec"}`),
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-01", keyID)
+
+	keyID, err = etcdSnapshotEncryptionKeyID(&rkev1.ETCDSnapshot{SnapshotFile: rkev1.ETCDSnapshotFile{
+		S3:       s3,
+		Metadata: metadata(`{"provisioning-cluster-spec":"spec"}`),
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "", keyID)
+
+	// local snapshots are never encrypted
+	keyID, err = etcdSnapshotEncryptionKeyID(&rkev1.ETCDSnapshot{SnapshotFile: rkev1.ETCDSnapshotFile{
+		Metadata: metadata(`{"encryption-key-id":"2024-01"}`),
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "", keyID)
+
+	_, err = etcdSnapshotEncryptionKeyID(&rkev1.ETCDSnapshot{SnapshotFile: rkev1.ETCDSnapshotFile{
+		S3:       s3,
+		Metadata: metadata("not json"),
+	}})
+	assert.Error(t, err)
+}
+
+func TestValidateEtcdSnapshotEncryption(t *testing.T) {
+	p := &Planner{}
+	cp := &rkev1.RKEControlPlane{}
+	snapshot := &rkev1.ETCDSnapshot{SnapshotFile: rkev1.ETCDSnapshotFile{
+		Name:     "on-demand-machine-1-1700000000",
+		S3:       &rkev1.ETCDSnapshotS3{Bucket: "backups"},
+		Metadata: base64.StdEncoding.EncodeToString([]byte(`{"encryption-key-id":"2024-01"}`)),
+	}}
+
+	assert.Equal(t, "", p.validateEtcdSnapshotEncryption(cp, &rkev1.ETCDSnapshot{}))
+	assert.Equal(t, "etcd snapshot on-demand-machine-1-1700000000 is encrypted with key 2024-01, but etcd snapshot encryption is not configured",
+		p.validateEtcdSnapshotEncryption(cp, snapshot))
+
+	unknown := snapshot.DeepCopy()
+	unknown.SnapshotFile.Metadata = ""
+	assert.Equal(t, "", p.validateEtcdSnapshotEncryption(cp, unknown), "snapshots without metadata are not encrypted without encryption")
+
+	cp.Spec.ETCD = &rkev1.ETCD{SnapshotEncryption: &rkev1.ETCDSnapshotEncryption{SecretName: "etcd-snapshot-key"}}
+	assert.Equal(t, "the encryption key of etcd snapshot on-demand-machine-1-1700000000 can not be found as its metadata is unknown, etcd snapshot encryption must be removed to restore it if it is not encrypted",
+		p.validateEtcdSnapshotEncryption(cp, unknown))
+
+	unencrypted := snapshot.DeepCopy()
+	unencrypted.SnapshotFile.Metadata = base64.StdEncoding.EncodeToString([]byte(`{"owner":"ops"}`))
+	assert.Equal(t, "", p.validateEtcdSnapshotEncryption(cp, unencrypted), "snapshots whose metadata has no key are not encrypted")
+}
+
+func TestWithEtcdSnapshotSaveRecord(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{Spec: rkev1.RKEControlPlaneSpec{KubernetesVersion: "v1.30.4+rke2r1"}}
+
+	instruction := withEtcdSnapshotSaveRecord(cp, plan.OneTimeInstruction{
+		Name:    "etcd-snapshot",
+		Command: "rke2",
+		Args:    []string{"etcd-snapshot", "save", "--name=on-demand-3"},
+	}, "on-demand-3")
+	assert.Equal(t, "/bin/sh", instruction.Command)
+	assert.Equal(t, []string{
+		"/var/lib/rancher/rke2/capr/etcd/bin/etcd_snapshot_save.sh",
+		"/var/lib/rancher/rke2/capr/etcd/saved/on-demand-3",
+		"/var/lib/rancher/rke2/server/db/snapshots",
+		"on-demand-3",
+		"rke2", "etcd-snapshot", "save", "--name=on-demand-3",
+	}, instruction.Args)
+
+	upload := generateEtcdSnapshotUploadLatestInstruction(cp, "etcd-snapshot-upload", "on-demand-3", []string{"ETCD_S3_BUCKET=backups"})
+	assert.Equal(t, []string{
+		"-c",
+		`exec /bin/sh /var/lib/rancher/rke2/capr/etcd/bin/etcd_snapshot_upload.sh "/var/lib/rancher/rke2/server/db/snapshots/$(cat /var/lib/rancher/rke2/capr/etcd/saved/on-demand-3)"`,
+	}, upload.Args, "the snapshot recorded by the save instruction is uploaded")
+	assert.Equal(t, []string{"ETCD_S3_BUCKET=backups"}, upload.Env)
+}
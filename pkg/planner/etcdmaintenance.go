@@ -0,0 +1,309 @@
+package planner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+const (
+	ETCDMaintenanceMessage = "etcd maintenance"
+
+	etcdBinPrefix = "capr/etcd/bin"
+
+	etcdctlScriptPath = "etcdctl.sh"
+	// etcdctlScript runs etcdctl against the local etcd member with the etcd client certificate of the distro. etcdctl is
+	// used from the PATH of the machine if it is installed, otherwise it is run in the etcd container of rke2. k3s embeds
+	// etcd, so etcdctl must be installed on its etcd machines.
+	etcdctlScript = `#!/bin/sh
+DATA_DIR=$1
+ENDPOINT=$2
+shift 2
+
+CERT_DIR="${DATA_DIR}/server/tls/etcd"
+set -- --endpoints="${ENDPOINT}" --cacert="${CERT_DIR}/server-ca.crt" --cert="${CERT_DIR}/server-client.crt" --key="${CERT_DIR}/server-client.key" "$@"
+
+if command -v etcdctl >/dev/null 2>&1; then
+	exec etcdctl "$@"
+fi
+
+CRICTL="${DATA_DIR}/bin/crictl"
+if [ -x "${CRICTL}" ]; then
+	CONTAINER=$("${CRICTL}" --runtime-endpoint unix:///run/k3s/containerd/containerd.sock ps --name '^etcd$' --state running -q | head -n 1)
+	if [ -n "${CONTAINER}" ]; then
+		exec "${CRICTL}" --runtime-endpoint unix:///run/k3s/containerd/containerd.sock exec "${CONTAINER}" etcdctl "$@"
+	fi
+fi
+
+echo "etcdctl was not found in PATH and no running etcd container exists" >&2
+exit 1
+`
+
+	etcdMaintenanceInstructionName = "etcd-maintenance"
+	etcdMaintenanceScriptPath      = "etcd_maintenance.sh"
+	// etcdMaintenanceScript prints the size of the etcd database of the local member before and after it was
+	// defragmented, and compacts the keyspace to the current revision first if requested.
+	etcdMaintenanceScript = `#!/bin/sh
+ETCDCTL=$1
+
+field() {
+	${ETCDCTL} endpoint status -w fields | awk -F ' : ' -v field="\"$1\"" '$1 == field {print $2; exit}'
+}
+
+before=$(field DBSize)
+if [ -z "${before}" ]; then
+	echo "unable to determine etcd database size" >&2
+	exit 1
+fi
+echo "before=${before}"
+
+if [ "${ETCD_MAINTENANCE_COMPACT}" = "true" ]; then
+	revision=$(field Revision)
+	if [ -z "${revision}" ]; then
+		echo "unable to determine etcd revision" >&2
+		exit 1
+	fi
+	${ETCDCTL} compact "${revision}" >&2 || exit 1
+fi
+
+${ETCDCTL} defrag --command-timeout=5m >&2 || exit 1
+
+echo "after=$(field DBSize)"
+`
+)
+
+func etcdScriptPath(controlPlane *rkev1.RKEControlPlane, file string) string {
+	return path.Join(capr.GetDistroDataDir(controlPlane), etcdBinPrefix, file)
+}
+
+// etcdctlCommand returns the command that runs etcdctl against the local etcd member with the etcdctl script, which must
+// be delivered to the machine with generateEtcdctlFile.
+func etcdctlCommand(controlPlane *rkev1.RKEControlPlane) string {
+	return fmt.Sprintf("/bin/sh %s %s %s",
+		etcdScriptPath(controlPlane, etcdctlScriptPath),
+		capr.GetDistroDataDir(controlPlane),
+		fmt.Sprintf("https://%s:2379", capr.GetLoopbackAddress(controlPlane)))
+}
+
+// generateEtcdctlFile generates the file that contains the etcdctl script.
+func generateEtcdctlFile(controlPlane *rkev1.RKEControlPlane) plan.File {
+	return plan.File{
+		Content: base64.StdEncoding.EncodeToString([]byte(etcdctlScript)),
+		Path:    etcdScriptPath(controlPlane, etcdctlScriptPath),
+		Dynamic: true,
+	}
+}
+
+func (p *Planner) setEtcdMaintenanceState(status rkev1.RKEControlPlaneStatus, maintenance *rkev1.ETCDMaintenance, phase rkev1.ETCDMaintenancePhase, members []rkev1.ETCDMaintenanceMember) (rkev1.RKEControlPlaneStatus, error) {
+	if status.ETCDMaintenancePhase != phase || !equality.Semantic.DeepEqual(status.ETCDMaintenance, maintenance) || !equality.Semantic.DeepEqual(status.ETCDMaintenanceMembers, members) {
+		status.ETCDMaintenancePhase = phase
+		status.ETCDMaintenance = maintenance
+		status.ETCDMaintenanceMembers = members
+		return status, errWaiting("refreshing etcd maintenance state")
+	}
+	return status, nil
+}
+
+func (p *Planner) resetEtcdMaintenanceState(status rkev1.RKEControlPlaneStatus) (rkev1.RKEControlPlaneStatus, error) {
+	if status.ETCDMaintenance == nil && status.ETCDMaintenancePhase == "" && len(status.ETCDMaintenanceMembers) == 0 {
+		return status, nil
+	}
+	return p.setEtcdMaintenanceState(status, nil, "", nil)
+}
+
+func (p *Planner) startOrRestartEtcdMaintenance(status rkev1.RKEControlPlaneStatus, maintenance *rkev1.ETCDMaintenance) (rkev1.RKEControlPlaneStatus, error) {
+	if status.ETCDMaintenance == nil || !equality.Semantic.DeepEqual(maintenance, status.ETCDMaintenance) {
+		return p.setEtcdMaintenanceState(status, maintenance, rkev1.ETCDMaintenancePhaseStarted, nil)
+	}
+	return status, nil
+}
+
+// maintainEtcd defragments the etcd members of the controlplane when the generation of the etcd maintenance changes. The
+// phases are in order:
+// Started -> The etcd members to defragment are recorded in status.
+// Defragment -> The members are defragmented one at a time. The next member is only defragmented once the plan of the
+// previous member was applied and its probes passed, and the size of the etcd database of every member before and after
+// defragmentation is recorded in status.
+// Finished -> All members were defragmented.
+// k3s members are only defragmented if etcdctl is installed on their machines, the etcd maintenance fails otherwise.
+func (p *Planner) maintainEtcd(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
+	if controlPlane.Spec.ETCDMaintenance == nil {
+		return p.resetEtcdMaintenanceState(status)
+	}
+
+	// Don't run an etcd maintenance if the cluster is not initialized or bootstrapped.
+	if !status.Initialized || !capr.Bootstrapped.IsTrue(&status) {
+		logrus.Warnf("[planner] rkecluster %s/%s: skipping etcd maintenance as cluster has not yet been initialized or bootstrapped", controlPlane.Namespace, controlPlane.Name)
+		return status, nil
+	}
+
+	maintenance := controlPlane.Spec.ETCDMaintenance
+
+	var err error
+	if status, err = p.startOrRestartEtcdMaintenance(status, maintenance); err != nil {
+		return status, err
+	}
+
+	switch status.ETCDMaintenancePhase {
+	case rkev1.ETCDMaintenancePhaseStarted:
+		var members []rkev1.ETCDMaintenanceMember
+		for _, entry := range collect(clusterPlan, roleAnd(isEtcd, roleNot(isDeleting))) {
+			member := rkev1.ETCDMaintenanceMember{
+				MachineName: entry.Machine.Name,
+			}
+			if entry.Machine.Status.NodeRef != nil {
+				member.NodeName = entry.Machine.Status.NodeRef.Name
+			}
+			members = append(members, member)
+		}
+		if len(members) == 0 {
+			return status, errWaiting("waiting for etcd members to defragment")
+		}
+		logrus.Infof("[planner] rkecluster %s/%s: starting etcd maintenance of %d members", controlPlane.Namespace, controlPlane.Name, len(members))
+		return p.setEtcdMaintenanceState(status, maintenance, rkev1.ETCDMaintenancePhaseDefragment, members)
+	case rkev1.ETCDMaintenancePhaseDefragment:
+		return p.runEtcdMaintenance(controlPlane, status, clusterPlan)
+	case rkev1.ETCDMaintenancePhaseFailed:
+		fallthrough
+	case rkev1.ETCDMaintenancePhaseFinished:
+		return status, nil
+	default:
+		return p.setEtcdMaintenanceState(status, maintenance, rkev1.ETCDMaintenancePhaseStarted, nil)
+	}
+}
+
+// runEtcdMaintenance defragments the next etcd member that was not yet defragmented, and records the size of its etcd
+// database in status once the plan was applied and its probes passed.
+func (p *Planner) runEtcdMaintenance(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
+	maintenance := controlPlane.Spec.ETCDMaintenance
+	members := append([]rkev1.ETCDMaintenanceMember{}, status.ETCDMaintenanceMembers...)
+
+	for i, member := range members {
+		if member.Defragmented {
+			continue
+		}
+
+		machine, ok := clusterPlan.Machines[member.MachineName]
+		if !ok || machine.DeletionTimestamp != nil {
+			logrus.Infof("[planner] rkecluster %s/%s: skipping etcd maintenance of removed machine %s", controlPlane.Namespace, controlPlane.Name, member.MachineName)
+			members = append(members[:i], members[i+1:]...)
+			return p.setEtcdMaintenanceState(status, maintenance, rkev1.ETCDMaintenancePhaseDefragment, members)
+		}
+		entry := &planEntry{
+			Machine:  machine,
+			Plan:     clusterPlan.Nodes[member.MachineName],
+			Metadata: clusterPlan.Metadata[member.MachineName],
+		}
+
+		if entry.Plan == nil {
+			return status, errWaitingf("waiting for plan of machine %s/%s to run etcd maintenance", machine.Namespace, machine.Name)
+		}
+		maintenancePlan := generateEtcdMaintenancePlan(controlPlane, entry.Plan.Plan)
+
+		msg := fmt.Sprintf("%s on machine %s/%s", ETCDMaintenanceMessage, machine.Namespace, machine.Name)
+		if err := assignAndCheckPlan(p.store, msg, entry, maintenancePlan, entry.Plan.JoinedTo, 1, 1); err != nil {
+			if IsErrWaiting(err) {
+				return status, err
+			}
+			logrus.Errorf("[planner] rkecluster %s/%s: etcd maintenance failed on machine %s: %v", controlPlane.Namespace, controlPlane.Name, machine.Name, err)
+			status, _ = p.setEtcdMaintenanceState(status, maintenance, rkev1.ETCDMaintenancePhaseFailed, members)
+			return status, err
+		}
+
+		before, after, err := parseEtcdMaintenanceOutput(entry.Plan.Output[etcdMaintenanceInstructionName])
+		if err != nil {
+			logrus.Warnf("[planner] rkecluster %s/%s: unable to determine etcd database size of machine %s: %v", controlPlane.Namespace, controlPlane.Name, machine.Name, err)
+		}
+		members[i].DBSizeBefore = before
+		members[i].DBSizeAfter = after
+		members[i].Defragmented = true
+		return p.setEtcdMaintenanceState(status, maintenance, rkev1.ETCDMaintenancePhaseDefragment, members)
+	}
+
+	logrus.Infof("[planner] rkecluster %s/%s: etcd maintenance finished", controlPlane.Namespace, controlPlane.Name)
+	return p.setEtcdMaintenanceState(status, maintenance, rkev1.ETCDMaintenancePhaseFinished, members)
+}
+
+// generateEtcdMaintenancePlan returns a copy of the given current plan of an etcd member with an instruction that
+// defragments the member, replacing the instruction of a previous etcd maintenance. The current plan is used so that the
+// member is defragmented without applying pending changes of its configuration, and its probes are checked after it
+// was defragmented.
+func generateEtcdMaintenancePlan(controlPlane *rkev1.RKEControlPlane, nodePlan plan.NodePlan) plan.NodePlan {
+	files := []plan.File{generateEtcdctlFile(controlPlane), {
+		Content: base64.StdEncoding.EncodeToString([]byte(etcdMaintenanceScript)),
+		Path:    etcdScriptPath(controlPlane, etcdMaintenanceScriptPath),
+		Dynamic: true,
+	}}
+	maintenanceFiles := map[string]bool{}
+	for _, file := range files {
+		maintenanceFiles[file.Path] = true
+	}
+
+	result := nodePlan
+	result.Files = nil
+	for _, file := range nodePlan.Files {
+		if !maintenanceFiles[file.Path] {
+			result.Files = append(result.Files, file)
+		}
+	}
+	result.Files = append(result.Files, files...)
+	result.Instructions = nil
+	for _, instruction := range nodePlan.Instructions {
+		if instruction.Name != etcdMaintenanceInstructionName {
+			result.Instructions = append(result.Instructions, instruction)
+		}
+	}
+	result.Instructions = append(result.Instructions, plan.OneTimeInstruction{
+		Name:    etcdMaintenanceInstructionName,
+		Command: "/bin/sh",
+		Args: []string{
+			etcdScriptPath(controlPlane, etcdMaintenanceScriptPath),
+			etcdctlCommand(controlPlane),
+		},
+		Env: []string{
+			fmt.Sprintf("ETCD_MAINTENANCE_COMPACT=%t", controlPlane.Spec.ETCDMaintenance.Compact),
+			// make sure that the member is defragmented again for every generation
+			fmt.Sprintf("ETCD_MAINTENANCE_GENERATION=%d", controlPlane.Spec.ETCDMaintenance.Generation),
+		},
+		SaveOutput: true,
+	})
+	return result
+}
+
+// parseEtcdMaintenanceOutput parses the size of the etcd database before and after defragmentation from the output of the
+// etcd maintenance script.
+func parseEtcdMaintenanceOutput(output []byte) (int64, int64, error) {
+	var before, after int64
+	var foundBefore, foundAfter bool
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return before, after, fmt.Errorf("invalid etcd database size %q: %w", value, err)
+		}
+		switch key {
+		case "before":
+			before, foundBefore = size, true
+		case "after":
+			after, foundAfter = size, true
+		}
+	}
+	if !foundBefore || !foundAfter {
+		return before, after, fmt.Errorf("etcd database size was not found in output")
+	}
+	return before, after, nil
+}
@@ -0,0 +1,74 @@
+package planner
+
+import (
+	"testing"
+
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEtcdMaintenanceOutput(t *testing.T) {
+	before, after, err := parseEtcdMaintenanceOutput([]byte("before=104857600\nafter=20971520\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(104857600), before)
+	assert.Equal(t, int64(20971520), after)
+
+	_, _, err = parseEtcdMaintenanceOutput([]byte("before=104857600\nafter=\n"))
+	assert.Error(t, err)
+
+	_, _, err = parseEtcdMaintenanceOutput([]byte("action has already been reconciled"))
+	assert.Error(t, err)
+}
+
+func TestMaintainEtcdReset(t *testing.T) {
+	p := &Planner{}
+	status := rkev1.RKEControlPlaneStatus{
+		ETCDMaintenance:      &rkev1.ETCDMaintenance{Generation: 1},
+		ETCDMaintenancePhase: rkev1.ETCDMaintenancePhaseFinished,
+		ETCDMaintenanceMembers: []rkev1.ETCDMaintenanceMember{
+			{MachineName: "etcd-0", DBSizeBefore: 2, DBSizeAfter: 1, Defragmented: true},
+		},
+	}
+
+	status, err := p.maintainEtcd(&rkev1.RKEControlPlane{}, status, plan.Secret{}, nil)
+	assert.True(t, IsErrWaiting(err))
+	assert.Nil(t, status.ETCDMaintenance)
+	assert.Empty(t, status.ETCDMaintenancePhase)
+	assert.Empty(t, status.ETCDMaintenanceMembers)
+
+	_, err = p.maintainEtcd(&rkev1.RKEControlPlane{}, status, plan.Secret{}, nil)
+	assert.NoError(t, err)
+}
+
+func TestGenerateEtcdMaintenancePlan(t *testing.T) {
+	controlPlane := &rkev1.RKEControlPlane{
+		Spec: rkev1.RKEControlPlaneSpec{
+			KubernetesVersion: "v1.28.5+k3s1",
+			ETCDMaintenance:   &rkev1.ETCDMaintenance{Generation: 1},
+		},
+	}
+	current := plan.NodePlan{
+		Files:        []plan.File{{Path: "/etc/rancher/k3s/config.yaml.d/50-capr.yaml"}},
+		Instructions: []plan.OneTimeInstruction{{Name: "install"}},
+	}
+
+	// the instruction is appended to the current plan of the member
+	maintenancePlan := generateEtcdMaintenancePlan(controlPlane, current)
+	assert.Len(t, current.Instructions, 1)
+	if assert.Len(t, maintenancePlan.Instructions, 2) {
+		assert.Equal(t, "install", maintenancePlan.Instructions[0].Name)
+		assert.Equal(t, etcdMaintenanceInstructionName, maintenancePlan.Instructions[1].Name)
+		assert.Contains(t, maintenancePlan.Instructions[1].Env, "ETCD_MAINTENANCE_GENERATION=1")
+	}
+	assert.Len(t, maintenancePlan.Files, 3)
+	assert.Equal(t, "/var/lib/rancher/k3s/capr/etcd/bin/etcdctl.sh", maintenancePlan.Files[1].Path)
+
+	// the instruction of a previous etcd maintenance is replaced
+	controlPlane.Spec.ETCDMaintenance.Generation = 2
+	maintenancePlan = generateEtcdMaintenancePlan(controlPlane, maintenancePlan)
+	if assert.Len(t, maintenancePlan.Instructions, 2) {
+		assert.Contains(t, maintenancePlan.Instructions[1].Env, "ETCD_MAINTENANCE_GENERATION=2")
+	}
+	assert.Len(t, maintenancePlan.Files, 3)
+}
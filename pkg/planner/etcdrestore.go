@@ -12,6 +12,7 @@ import (
 	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/rancher/rancher/pkg/utils"
+	"github.com/rancher/wrangler/v3/pkg/merr"
 	"github.com/rancher/wrangler/v3/pkg/name"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/api/equality"
@@ -168,11 +169,18 @@ rm "$TMPALLREADY"
 
 const ETCDRestoreMessage = "etcd restore"
 
+// etcdRestoreValidationPassed is the output of an etcd restore validation instruction if the validation passed. Any
+// other output is the reason the validation failed.
+const etcdRestoreValidationPassed = "ok"
+
 // setEtcdSnapshotRestoreState sets the restore schema and phase to the given restore and phase and returns an errWaiting if a change was made. Notably this function does not persist the change.
 func (p *Planner) setEtcdSnapshotRestoreState(status rkev1.RKEControlPlaneStatus, restore *rkev1.ETCDSnapshotRestore, phase rkev1.ETCDSnapshotPhase) (rkev1.RKEControlPlaneStatus, error) {
 	if !equality.Semantic.DeepEqual(status.ETCDSnapshotRestore, restore) || status.ETCDSnapshotRestorePhase != phase {
 		status.ETCDSnapshotRestore = restore
 		status.ETCDSnapshotRestorePhase = phase
+		if phase != rkev1.ETCDSnapshotPhaseValidationFailed {
+			status.ETCDSnapshotRestoreMessage = ""
+		}
 		return status, errWaiting("refreshing etcd restore state")
 	}
 	return status, nil
@@ -294,7 +302,8 @@ func (p *Planner) runEtcdSnapshotPostRestoreNodeCleanupPlan(controlPlane *rkev1.
 		}
 	}
 
-	cleanupScriptFiles, cleanupInstructions := p.generateEtcdRestoreNodeCleanupFilesAndInstruction(controlPlane, allMachineUIDs, allNodeNames)
+	identifier := name.Hex(controlPlane.Spec.ETCDSnapshotRestore.Name+controlPlane.Spec.ETCDSnapshotRestore.RestoreRKEConfig+strconv.Itoa(controlPlane.Spec.ETCDSnapshotRestore.Generation), 10)
+	cleanupScriptFiles, cleanupInstructions := p.generateEtcdRestoreNodeCleanupFilesAndInstruction(controlPlane, allMachineUIDs, allNodeNames, identifier, fmt.Sprintf("%v", controlPlane.Status.ETCDSnapshotRestore))
 	initNodePlan.Files = append(initNodePlan.Files, cleanupScriptFiles...)
 	initNodePlan.Instructions = append(initNodePlan.Instructions, cleanupInstructions...)
 	return assignAndCheckPlan(p.store, ETCDRestoreMessage, initNode, initNodePlan, "", 5, 5)
@@ -312,26 +321,31 @@ func (p *Planner) generateEtcdSnapshotRestorePlan(controlPlane *rkev1.RKEControl
 		return nodePlan, joinedServer, err
 	}
 
-	loopbackAddress := capr.GetLoopbackAddress(controlPlane)
+	args := generateClusterResetArgs(controlPlane)
 
-	if utils.IsPlainIPV6(loopbackAddress) {
-		loopbackAddress = fmt.Sprintf("[%s]", loopbackAddress)
-	}
+	var env []string
+	var downloadInstruction *plan.OneTimeInstruction
 
-	args := []string{
-		"server",
-		"--cluster-reset",
-		fmt.Sprintf("--etcd-arg=advertise-client-urls=https://%s:2379", loopbackAddress), // this is a workaround for: https://github.com/rancher/rke2/issues/4052 and can likely remain indefinitely (unless IPv6-only becomes a requirement)
-		"--etcd-disable-snapshots=false",                                                 // this is a workaround for https://github.com/k3s-io/k3s/issues/8031
+	keyID, err := etcdSnapshotEncryptionKeyID(snapshot)
+	if err != nil {
+		return plan.NodePlan{}, "", err
 	}
 
-	var env []string
-
 	if snapshot == nil {
 		// If the snapshot is nil, then we will assume the passed in snapshot name is a local snapshot.
 		args = append(args, fmt.Sprintf("--cluster-reset-restore-path=db/snapshots/%s", snapshotName), "--etcd-s3=false")
 	} else if snapshot.SnapshotFile.S3 == nil {
 		args = append(args, fmt.Sprintf("--cluster-reset-restore-path=db/snapshots/%s", snapshot.SnapshotFile.Name), "--etcd-s3=false")
+	} else if keyID != "" {
+		// the distro can not decrypt snapshots, so the encrypted snapshot is downloaded and decrypted first, and then
+		// restored like a local snapshot.
+		files, instruction, err := p.generateEtcdSnapshotDownloadInstruction(controlPlane, entry, snapshot)
+		if err != nil {
+			return plan.NodePlan{}, "", err
+		}
+		nodePlan.Files = append(nodePlan.Files, files...)
+		downloadInstruction = &instruction
+		args = append(args, fmt.Sprintf("--cluster-reset-restore-path=db/snapshots/%s", snapshot.SnapshotFile.Name), "--etcd-s3=false")
 	} else {
 		args = append(args, fmt.Sprintf("--cluster-reset-restore-path=%s", snapshot.SnapshotFile.Name))
 		s3, s3Env, s3Files, err := p.etcdS3Args.ToArgs(snapshot.SnapshotFile.S3, controlPlane, "etcd-", true)
@@ -373,7 +387,17 @@ func (p *Planner) generateEtcdSnapshotRestorePlan(controlPlane *rkev1.RKEControl
 				Args: []string{
 					"-rf",
 					path.Join(capr.GetDistroDataDir(controlPlane), "server/db/etcd"),
-				}}),
+				}}))
+
+	if downloadInstruction != nil {
+		nodePlan.Instructions = append(nodePlan.Instructions, convertToIdempotentInstruction(
+			controlPlane,
+			"etcd-restore/download-encrypted-snapshot",
+			fmt.Sprintf("%v", controlPlane.Status.ETCDSnapshotRestore),
+			*downloadInstruction))
+	}
+
+	nodePlan.Instructions = append(nodePlan.Instructions,
 		idempotentInstruction(
 			controlPlane,
 			"etcd-restore/restore",
@@ -386,6 +410,23 @@ func (p *Planner) generateEtcdSnapshotRestorePlan(controlPlane *rkev1.RKEControl
 	return nodePlan, joinedServer, nil
 }
 
+// generateClusterResetArgs returns the arguments of the server command that reset the etcd cluster membership before a
+// snapshot is restored.
+func generateClusterResetArgs(controlPlane *rkev1.RKEControlPlane) []string {
+	loopbackAddress := capr.GetLoopbackAddress(controlPlane)
+
+	if utils.IsPlainIPV6(loopbackAddress) {
+		loopbackAddress = fmt.Sprintf("[%s]", loopbackAddress)
+	}
+
+	return []string{
+		"server",
+		"--cluster-reset",
+		fmt.Sprintf("--etcd-arg=advertise-client-urls=https://%s:2379", loopbackAddress), // this is a workaround for: https://github.com/rancher/rke2/issues/4052 and can likely remain indefinitely (unless IPv6-only becomes a requirement)
+		"--etcd-disable-snapshots=false",                                                 // this is a workaround for https://github.com/k3s-io/k3s/issues/8031
+	}
+}
+
 func (p *Planner) generateStopServiceAndKillAllPlan(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret, server *planEntry, joinServer string) (plan.NodePlan, string, error) {
 	nodePlan, _, joinedServer, err := p.generatePlanWithConfigFiles(controlPlane, tokensSecret, server, joinServer, true)
 	if err != nil {
@@ -535,7 +576,9 @@ func (p *Planner) generateEtcdRestorePodCleanupFilesAndInstruction(controlPlane
 }
 
 // generateEtcdRestorePodCleanupFilesAndInstruction generates a file that contains a script that checks API server health and a slice of instructions that cleans up system pods on etcd restore.
-func (p *Planner) generateEtcdRestoreNodeCleanupFilesAndInstruction(controlPlane *rkev1.RKEControlPlane, allMachineUIDs []string, allNodeNames []string) ([]plan.File, []plan.OneTimeInstruction) {
+// The identifier is used to name the files that contain the machine IDs and node names, and the value is the target value
+// of the idempotent cleanup instruction.
+func (p *Planner) generateEtcdRestoreNodeCleanupFilesAndInstruction(controlPlane *rkev1.RKEControlPlane, allMachineUIDs []string, allNodeNames []string, identifier, value string) ([]plan.File, []plan.OneTimeInstruction) {
 	kubectl, kubeconfig := capr.GetKubectlAndKubeconfigPaths(controlPlane)
 	if kubectl == "" || kubeconfig == "" {
 		return nil, nil
@@ -551,8 +594,6 @@ func (p *Planner) generateEtcdRestoreNodeCleanupFilesAndInstruction(controlPlane
 		nodeNames = fmt.Appendf(nodeNames, "%s\n", nodeName)
 	}
 
-	identifier := name.Hex(controlPlane.Spec.ETCDSnapshotRestore.Name+controlPlane.Spec.ETCDSnapshotRestore.RestoreRKEConfig+strconv.Itoa(controlPlane.Spec.ETCDSnapshotRestore.Generation), 10)
-
 	machineIDsFile := fmt.Sprintf("machine-ids-%s", identifier)
 	nodeNamesFile := fmt.Sprintf("node-names-%s", identifier)
 
@@ -560,7 +601,7 @@ func (p *Planner) generateEtcdRestoreNodeCleanupFilesAndInstruction(controlPlane
 		idempotentInstruction(
 			controlPlane,
 			"etcd-restore/cleanup-nodes",
-			fmt.Sprintf("%v", controlPlane.Status.ETCDSnapshotRestore),
+			value,
 			"/bin/sh",
 			[]string{etcdRestoreScriptPath(controlPlane, etcdRestoreNodeCleanUpPath), etcdRestoreScriptPath(controlPlane, machineIDsFile), etcdRestoreScriptPath(controlPlane, nodeNamesFile)},
 			[]string{
@@ -701,6 +742,232 @@ func (p *Planner) runEtcdRestoreServiceStop(controlPlane *rkev1.RKEControlPlane,
 	return nil
 }
 
+// runEtcdRestoreValidation validates that the etcd snapshot of the restore can be restored before any node is stopped.
+// It checks that the snapshot was taken with the current distro minor version, checks on the node the snapshot would be
+// restored on that the snapshot exists, and checks that all etcd nodes have enough free disk space for the snapshot. The
+// init node is not elected and the configuration of the nodes is not changed until the validation passed, so the node
+// checks are added to the plans the nodes already run. It returns the reason the restore is refused, or an empty string
+// if the validation passed.
+func (p *Planner) runEtcdRestoreValidation(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot, clusterPlan *plan.Plan) (string, error) {
+	if reason := validateEtcdSnapshotVersion(controlPlane, snapshot); reason != "" {
+		return reason, nil
+	}
+	if reason := p.validateEtcdSnapshotEncryption(controlPlane, snapshot); reason != "" {
+		return reason, nil
+	}
+
+	restoreNode, reason, err := etcdRestoreValidationNode(controlPlane, snapshot, clusterPlan)
+	if err != nil || reason != "" {
+		return reason, err
+	}
+
+	var existsInstruction plan.OneTimeInstruction
+	var existsFiles []plan.File
+	if snapshot == nil || snapshot.SnapshotFile.S3 == nil {
+		snapshotName := controlPlane.Spec.ETCDSnapshotRestore.Name
+		if snapshot != nil {
+			snapshotName = snapshot.SnapshotFile.Name
+		}
+		existsInstruction = generateLocalSnapshotExistsInstruction(controlPlane, snapshotName)
+	} else {
+		s3, s3Env, s3Files, err := p.etcdS3Args.ToArgs(snapshot.SnapshotFile.S3, controlPlane, "etcd-", true)
+		if err != nil {
+			return fmt.Sprintf("unable to render S3 configuration for etcd snapshot %s: %v", snapshot.SnapshotFile.Name, err), nil
+		}
+		existsInstruction = generateS3SnapshotExistsInstruction(controlPlane, snapshot.SnapshotFile.Name, s3, s3Env)
+		existsFiles = s3Files
+	}
+
+	var snapshotSize int64
+	if snapshot != nil {
+		snapshotSize = snapshot.SnapshotFile.Size
+	}
+
+	var errs []error
+	for _, entry := range collect(clusterPlan, roleAnd(isEtcd, roleNot(isDeleting))) {
+		var instructions []plan.OneTimeInstruction
+		var files []plan.File
+		if entry.Machine.Name == restoreNode.Machine.Name {
+			instructions = append(instructions, existsInstruction)
+			files = existsFiles
+		}
+		if snapshotSize > 0 {
+			instructions = append(instructions, generateDiskSpaceInstruction(controlPlane, snapshotSize))
+		}
+		if len(instructions) == 0 {
+			continue
+		}
+		for i := range instructions {
+			// make sure that the validation is run again when the restore is retried
+			instructions[i].Env = append(instructions[i].Env, fmt.Sprintf("ETCD_RESTORE_GENERATION=%d", controlPlane.Spec.ETCDSnapshotRestore.Generation))
+		}
+
+		if entry.Plan == nil {
+			errs = append(errs, errWaitingf("waiting for plan of machine %s/%s to validate etcd snapshot restore", entry.Machine.Namespace, entry.Machine.Name))
+			continue
+		}
+		validationPlan := withEtcdRestoreValidation(entry.Plan.Plan, instructions, files)
+		if err := assignAndCheckPlan(p.store, "etcd restore validation", entry, validationPlan, entry.Plan.JoinedTo, 1, 1); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, instruction := range instructions {
+			if output := strings.TrimSpace(string(entry.Plan.Output[instruction.Name])); output != etcdRestoreValidationPassed {
+				return fmt.Sprintf("machine %s/%s: %s", entry.Machine.Namespace, entry.Machine.Name, output), nil
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return "", errWaiting(merr.NewErrors(errs...).Error())
+	}
+	return "", nil
+}
+
+// etcdRestoreValidationNode returns the etcd node the snapshot of the restore would be restored on, without electing it
+// as init node. A local snapshot is restored on the node it is stored on, while an S3 snapshot can be restored on any
+// etcd node that can be an init node, in which case the current init node is preferred. It returns the reason the
+// restore is refused if the node of a local snapshot does not exist anymore.
+func etcdRestoreValidationNode(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot, clusterPlan *plan.Plan) (*planEntry, string, error) {
+	candidates := collect(clusterPlan, canBeInitNode)
+	if snapshot == nil {
+		if len(candidates) != 1 {
+			return nil, "", fmt.Errorf("%d init node candidates existed and no corresponding etcd snapshot CR found, no assumption can be made for the machine that contains the snapshot", len(candidates))
+		}
+		return candidates[0], "", nil
+	}
+
+	if snapshot.SnapshotFile.S3 == nil {
+		id, ok := snapshot.Labels[capr.MachineIDLabel]
+		if !ok {
+			return nil, fmt.Sprintf("etcd snapshot %s/%s does not have label %s", snapshot.Namespace, snapshot.Name, capr.MachineIDLabel), nil
+		}
+		for _, entry := range collect(clusterPlan, roleAnd(isEtcd, roleNot(isDeleting))) {
+			if entry.Machine.Labels[capr.MachineIDLabel] == id {
+				return entry, "", nil
+			}
+		}
+		return nil, fmt.Sprintf("etcd machine with machine ID %s that stores etcd snapshot %s/%s does not exist", id, snapshot.Namespace, snapshot.Name), nil
+	}
+
+	for _, entry := range candidates {
+		if isInitNode(entry) {
+			return entry, "", nil
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, "", errWaitingf("waiting for an etcd machine to validate the restore of etcd snapshot %s/%s", snapshot.Namespace, snapshot.Name)
+	}
+	return candidates[0], "", nil
+}
+
+// withEtcdRestoreValidation returns a copy of the given node plan with the given validation instructions and files
+// added, replacing the validation instructions and files of a previous validation.
+func withEtcdRestoreValidation(nodePlan plan.NodePlan, instructions []plan.OneTimeInstruction, files []plan.File) plan.NodePlan {
+	validation := map[string]bool{}
+	for _, instruction := range instructions {
+		validation[instruction.Name] = true
+	}
+	validationFiles := map[string]bool{}
+	for _, file := range files {
+		validationFiles[file.Path] = true
+	}
+
+	result := nodePlan
+	result.Instructions = nil
+	for _, instruction := range nodePlan.Instructions {
+		if !validation[instruction.Name] {
+			result.Instructions = append(result.Instructions, instruction)
+		}
+	}
+	result.Instructions = append(result.Instructions, instructions...)
+	result.Files = nil
+	for _, file := range nodePlan.Files {
+		if !validationFiles[file.Path] {
+			result.Files = append(result.Files, file)
+		}
+	}
+	result.Files = append(result.Files, files...)
+	return result
+}
+
+// validateEtcdSnapshotVersion returns the reason an etcd snapshot can not be restored if its metadata indicates it was
+// taken with another distro or distro minor version than the current version of the controlplane.
+func validateEtcdSnapshotVersion(controlPlane *rkev1.RKEControlPlane, snapshot *rkev1.ETCDSnapshot) string {
+	if snapshot == nil {
+		return ""
+	}
+	clusterSpec, err := capr.ParseSnapshotClusterSpecOrError(snapshot)
+	if err != nil || clusterSpec == nil || clusterSpec.KubernetesVersion == "" {
+		logrus.Debugf("[planner] rkecluster %s/%s: skipping version validation of etcd snapshot %s/%s as its metadata could not be parsed: %v", controlPlane.Namespace, controlPlane.Name, snapshot.Namespace, snapshot.Name, err)
+		return ""
+	}
+	if snapshotRuntime, runtime := capr.GetRuntime(clusterSpec.KubernetesVersion), capr.GetRuntime(controlPlane.Spec.KubernetesVersion); snapshotRuntime != runtime {
+		return fmt.Sprintf("etcd snapshot %s was taken with %s, but the cluster runs %s", snapshot.SnapshotFile.Name, snapshotRuntime, runtime)
+	}
+	snapshotVersion, err := semver.NewVersion(clusterSpec.KubernetesVersion)
+	if err != nil {
+		return fmt.Sprintf("unable to parse Kubernetes version %s of etcd snapshot %s", clusterSpec.KubernetesVersion, snapshot.SnapshotFile.Name)
+	}
+	currentVersion, err := semver.NewVersion(controlPlane.Spec.KubernetesVersion)
+	if err != nil {
+		return fmt.Sprintf("unable to parse Kubernetes version %s of the cluster", controlPlane.Spec.KubernetesVersion)
+	}
+	if snapshotVersion.Major() != currentVersion.Major() || snapshotVersion.Minor() != currentVersion.Minor() {
+		return fmt.Sprintf("etcd snapshot %s was taken with Kubernetes version %s, which does not match the minor version of the cluster version %s", snapshot.SnapshotFile.Name, clusterSpec.KubernetesVersion, controlPlane.Spec.KubernetesVersion)
+	}
+	return ""
+}
+
+// generateLocalSnapshotExistsInstruction generates an instruction that outputs whether the local snapshot with the given
+// name exists on the node.
+func generateLocalSnapshotExistsInstruction(controlPlane *rkev1.RKEControlPlane, snapshotName string) plan.OneTimeInstruction {
+	snapshotPath := path.Join(capr.GetDistroDataDir(controlPlane), "server/db/snapshots", snapshotName)
+	return plan.OneTimeInstruction{
+		Name:    "validate-snapshot-exists",
+		Command: "/bin/sh",
+		Args: []string{
+			"-c",
+			fmt.Sprintf(`if [ -f "%s" ]; then echo %s; else echo "etcd snapshot %s does not exist"; fi`, snapshotPath, etcdRestoreValidationPassed, snapshotPath),
+		},
+		SaveOutput: true,
+	}
+}
+
+// generateS3SnapshotExistsInstruction generates an instruction that outputs whether the snapshot with the given name can
+// be listed in S3 with the given S3 arguments.
+func generateS3SnapshotExistsInstruction(controlPlane *rkev1.RKEControlPlane, snapshotName string, s3Args, s3Env []string) plan.OneTimeInstruction {
+	return plan.OneTimeInstruction{
+		Name:    "validate-snapshot-exists",
+		Command: "/bin/sh",
+		Env:     s3Env,
+		Args: append([]string{
+			"-c",
+			fmt.Sprintf(`if %s etcd-snapshot list "$@" 2>/dev/null | grep -qF -- "%s"; then echo %s; else echo "etcd snapshot %s was not found in S3, or S3 could not be reached with the configured credentials"; fi`,
+				capr.GetRuntimeCommand(controlPlane.Spec.KubernetesVersion), snapshotName, etcdRestoreValidationPassed, snapshotName),
+			"--",
+		}, s3Args...),
+		SaveOutput: true,
+	}
+}
+
+// generateDiskSpaceInstruction generates an instruction that outputs whether the filesystem of the distro data directory
+// has enough free space to restore a snapshot of the given size, which requires room for both the snapshot and the
+// restored database.
+func generateDiskSpaceInstruction(controlPlane *rkev1.RKEControlPlane, snapshotSize int64) plan.OneTimeInstruction {
+	dataDir := capr.GetDistroDataDir(controlPlane)
+	required := snapshotSize * 2
+	return plan.OneTimeInstruction{
+		Name:    "validate-disk-space",
+		Command: "/bin/sh",
+		Args: []string{
+			"-c",
+			fmt.Sprintf(`available=$(df -Pk "%[1]s" 2>/dev/null | awk 'NR==2 {print $4}'); if [ -z "$available" ]; then echo "unable to determine free disk space of %[1]s"; elif [ $((available * 1024)) -lt %[2]d ]; then echo "insufficient disk space in %[1]s, $((available * 1024)) bytes available but %[2]d bytes required"; else echo %[3]s; fi`,
+				dataDir, required, etcdRestoreValidationPassed),
+		},
+		SaveOutput: true,
+	}
+}
+
 // retrieveEtcdSnapshot attempts to retrieve the etcdsnapshot CR that corresponds to the etcd snapshot restore name specified on the controlplane.
 func (p *Planner) retrieveEtcdSnapshot(controlPlane *rkev1.RKEControlPlane) (*rkev1.ETCDSnapshot, error) {
 	if controlPlane == nil {
@@ -770,8 +1037,12 @@ func (p *Planner) forceDeleteAllDeletingEtcdMachines(cp *rkev1.RKEControlPlane,
 // restoreEtcdSnapshot is called multiple times during an etcd snapshot restoration.
 // restoreEtcdSnapshot utilizes the status of the corresponding control plane object of the cluster to track state
 // The phases are in order:
-// Started -> When the phase is started, it gets set to shutdown
-// Shutdown -> When the phase is shutdown, it attempts to shut down etcd on all nodes (stop etcd)
+// Started -> When the phase is started, it gets set to validate
+// Validate -> When the phase is validate, it validates that the snapshot can be restored before any node is stopped, and
+// refuses the restore by setting the phase to validation failed otherwise
+// Shutdown -> When the phase is shutdown, it restores the join tokens the snapshot was taken with if the join token was
+// rotated since, and requests a join token rotation back to the current tokens, which runs once the restore finished.
+// It then attempts to shut down etcd on all nodes (stop etcd)
 // Restore ->  When the phase is restore, it attempts to restore etcd
 // Finished -> When the phase is finished, Restore returns nil.
 func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan, currentVersion *semver.Version) (rkev1.RKEControlPlaneStatus, error) {
@@ -806,6 +1077,17 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 
 	switch cp.Status.ETCDSnapshotRestorePhase {
 	case rkev1.ETCDSnapshotPhaseStarted:
+		return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseValidate)
+	case rkev1.ETCDSnapshotPhaseValidate:
+		reason, err := p.runEtcdRestoreValidation(cp, snapshot, clusterPlan)
+		if err != nil {
+			return status, err
+		}
+		if reason != "" {
+			logrus.Errorf("[planner] rkecluster %s/%s: refusing etcd snapshot restore: %s", cp.Namespace, cp.Name, reason)
+			status.ETCDSnapshotRestoreMessage = reason
+			return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseValidationFailed)
+		}
 		if status.Initialized || status.Ready {
 			status.Initialized = false
 			status.Ready = false
@@ -814,6 +1096,9 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 		status, _ = p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseShutdown)
 		return status, errWaitingf("shutting down cluster")
 	case rkev1.ETCDSnapshotPhaseShutdown:
+		if status, err = p.ensureEtcdSnapshotJoinTokens(cp, status, snapshot, tokensSecret); err != nil {
+			return status, err
+		}
 		if err = p.runEtcdRestoreServiceStop(cp, snapshot, tokensSecret, clusterPlan); err != nil {
 			return status, err
 		}
@@ -823,6 +1108,9 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 		status, _ = p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseRestore)
 		return status, errWaiting("cluster shutdown complete, running etcd restore")
 	case rkev1.ETCDSnapshotPhaseRestore:
+		if status, err = p.ensureEtcdSnapshotJoinTokens(cp, status, snapshot, tokensSecret); err != nil {
+			return status, err
+		}
 		if err = p.runEtcdSnapshotRestorePlan(cp, snapshot, cp.Spec.ETCDSnapshotRestore.Name, tokensSecret, clusterPlan); err != nil {
 			return status, err
 		}
@@ -858,6 +1146,8 @@ func (p *Planner) restoreEtcdSnapshot(cp *rkev1.RKEControlPlane, status rkev1.RK
 			return status, err
 		}
 		return p.setEtcdSnapshotRestoreState(status, cp.Spec.ETCDSnapshotRestore, rkev1.ETCDSnapshotPhaseFinished)
+	case rkev1.ETCDSnapshotPhaseValidationFailed:
+		fallthrough
 	case rkev1.ETCDSnapshotPhaseFinished:
 		return status, nil
 	default:
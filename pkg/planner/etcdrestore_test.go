@@ -5,9 +5,8 @@ import (
 	"time"
 
 	"github.com/rancher/cluster-api-provider-rancher/pkg"
-	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
-	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
-	"github.com/rancher/rancher/pkg/provisioningv2/image"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	corev1 "k8s.io/api/core/v1"
@@ -268,7 +267,7 @@ func TestForceDeleteAllDeletingEtcdMachines(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mp := newMockPlanner(t, InfoFunctions{
 				SystemAgentImage: func() string { return "system-agent" },
-				ImageResolver:    image.ResolveWithControlPlane,
+				ImageResolver:    resolveWithControlPlane,
 			})
 			if tt.setup != nil {
 				tt.setup(t, mp)
@@ -279,3 +278,148 @@ func TestForceDeleteAllDeletingEtcdMachines(t *testing.T) {
 		})
 	}
 }
+
+func TestRunEtcdRestoreValidation(t *testing.T) {
+	t.Parallel()
+
+	controlPlane := &rkev1.RKEControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "fleet-default",
+			Name:      "test",
+		},
+		Spec: rkev1.RKEControlPlaneSpec{
+			KubernetesVersion: "v1.28.5+rke2r1",
+			ETCDSnapshotRestore: &rkev1.ETCDSnapshotRestore{
+				Name:       "snapshot",
+				Generation: 2,
+			},
+		},
+	}
+	snapshot := &rkev1.ETCDSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "fleet-default",
+			Name:      "snapshot",
+			Labels: map[string]string{
+				capr.MachineIDLabel: "machine-id-2",
+			},
+		},
+		SnapshotFile: rkev1.ETCDSnapshotFile{
+			Name: "snapshot-file",
+		},
+	}
+
+	validationInstruction := generateLocalSnapshotExistsInstruction(controlPlane, "snapshot-file")
+	validationInstruction.Env = append(validationInstruction.Env, "ETCD_RESTORE_GENERATION=2")
+	currentPlan := plan.NodePlan{
+		Files: []plan.File{
+			{Path: "/etc/rancher/rke2/config.yaml"},
+		},
+		Instructions: []plan.OneTimeInstruction{
+			{Name: "install"},
+		},
+	}
+	validationPlan := withEtcdRestoreValidation(currentPlan, []plan.OneTimeInstruction{validationInstruction}, nil)
+
+	newPlan := func(output string) *plan.Plan {
+		clusterPlan := &plan.Plan{
+			Nodes:    map[string]*plan.Node{},
+			Machines: map[string]*capi.Machine{},
+			Metadata: map[string]*plan.Metadata{},
+		}
+		for i, name := range []string{"etcd-1", "etcd-2"} {
+			labels := map[string]string{
+				capr.EtcdRoleLabel: "true",
+			}
+			if i == 0 {
+				labels[capr.InitNodeLabel] = "true"
+			}
+			clusterPlan.Machines[name] = &capi.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "fleet-default",
+					Name:      name,
+					Labels: map[string]string{
+						capr.MachineIDLabel: "machine-id-" + name[len(name)-1:],
+					},
+				},
+			}
+			clusterPlan.Metadata[name] = &plan.Metadata{
+				Labels: labels,
+			}
+			clusterPlan.Nodes[name] = &plan.Node{
+				Plan:    currentPlan,
+				InSync:  true,
+				Healthy: true,
+			}
+		}
+		clusterPlan.Nodes["etcd-2"].Plan = validationPlan
+		clusterPlan.Nodes["etcd-2"].Output = map[string][]byte{
+			validationInstruction.Name: []byte(output),
+		}
+		return clusterPlan
+	}
+
+	tests := []struct {
+		name           string
+		output         string
+		expectedReason string
+	}{
+		{
+			name:           "refused",
+			output:         "etcd snapshot /var/lib/rancher/rke2/server/db/snapshots/snapshot-file does not exist\n",
+			expectedReason: "machine fleet-default/etcd-2: etcd snapshot /var/lib/rancher/rke2/server/db/snapshots/snapshot-file does not exist",
+		},
+		{
+			name:   "accepted",
+			output: etcdRestoreValidationPassed + "\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// no plan secret is updated as the validation plan is already assigned to the machine that stores the
+			// snapshot and the other etcd machine has nothing to validate
+			mp := newMockPlanner(t, InfoFunctions{})
+			clusterPlan := newPlan(tt.output)
+
+			reason, err := mp.planner.runEtcdRestoreValidation(controlPlane, snapshot, clusterPlan)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedReason, reason)
+
+			// the init node is not changed by the validation
+			assert.Equal(t, "true", clusterPlan.Metadata["etcd-1"].Labels[capr.InitNodeLabel])
+			assert.Empty(t, clusterPlan.Metadata["etcd-2"].Labels[capr.InitNodeLabel])
+			assert.Equal(t, currentPlan, clusterPlan.Nodes["etcd-1"].Plan)
+		})
+	}
+}
+
+func TestWithEtcdRestoreValidation(t *testing.T) {
+	t.Parallel()
+
+	nodePlan := plan.NodePlan{
+		Files: []plan.File{
+			{Path: "/etc/rancher/rke2/config.yaml"},
+			{Path: "/var/lib/rancher/s3-ca.pem", Content: "old"},
+		},
+		Instructions: []plan.OneTimeInstruction{
+			{Name: "install"},
+			{Name: "validate-disk-space", Env: []string{"ETCD_RESTORE_GENERATION=1"}},
+		},
+	}
+	result := withEtcdRestoreValidation(nodePlan,
+		[]plan.OneTimeInstruction{{Name: "validate-disk-space", Env: []string{"ETCD_RESTORE_GENERATION=2"}}},
+		[]plan.File{{Path: "/var/lib/rancher/s3-ca.pem", Content: "new"}})
+
+	assert.Equal(t, []plan.OneTimeInstruction{
+		{Name: "install"},
+		{Name: "validate-disk-space", Env: []string{"ETCD_RESTORE_GENERATION=2"}},
+	}, result.Instructions)
+	assert.Equal(t, []plan.File{
+		{Path: "/etc/rancher/rke2/config.yaml"},
+		{Path: "/var/lib/rancher/s3-ca.pem", Content: "new"},
+	}, result.Files)
+	// the given node plan is not modified
+	assert.Equal(t, "ETCD_RESTORE_GENERATION=1", nodePlan.Instructions[1].Env[0])
+	assert.Equal(t, "old", nodePlan.Files[1].Content)
+}
@@ -0,0 +1,486 @@
+package planner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/wrangler/v3/pkg/data/convert"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	ETCDSnapshotUploadMessage = "etcd snapshot upload"
+
+	// ETCDSnapshotUploadInstructionName is the name of the instruction that uploads local etcd snapshots to S3.
+	ETCDSnapshotUploadInstructionName = "etcd-snapshot-upload"
+
+	etcdSnapshotUploadScriptPath = "etcd_snapshot_upload.sh"
+	// etcdSnapshotUploadScript uploads the given local etcd snapshot files and their metadata to S3 with the S3
+	// configuration of the distro that is passed as environment variables, and prints the name of every uploaded
+	// snapshot. The distro has no command to upload existing snapshots, so the objects are put with the AWS signature
+	// support of curl, at the same keys the distro uploads snapshots to. Like the distro, the endpoint is accessed over
+	// http if it is insecure, and its CA and TLS verification settings are honoured otherwise. If an encryption key is
+	// passed, the snapshots are encrypted before they are uploaded, the HMAC of the encrypted snapshot is appended to it,
+	// and the identifier of the key is added to their metadata.
+	etcdSnapshotUploadScript = "#!/bin/sh\n" + etcdSnapshotHMACFunctions + `
+ENDPOINT=${ETCD_S3_ENDPOINT:-s3.amazonaws.com}
+REGION=${ETCD_S3_REGION:-us-east-1}
+SCHEME=https
+if [ "${ETCD_S3_INSECURE}" = "true" ]; then
+	SCHEME=http
+fi
+
+if ! command -v curl >/dev/null 2>&1; then
+	echo "curl is required to upload etcd snapshots" >&2
+	exit 1
+fi
+if ! curl --help all 2>/dev/null | grep -q -- --aws-sigv4; then
+	echo "curl 7.75.0 or newer with AWS signature support is required to upload etcd snapshots" >&2
+	exit 1
+fi
+
+upload() {
+	key=$1
+	file=$2
+	if [ -n "${ETCD_S3_FOLDER}" ]; then
+		key="${ETCD_S3_FOLDER}/${key}"
+	fi
+	set -- --fail --silent --show-error --aws-sigv4 "aws:amz:${REGION}:s3" --user "${ETCD_S3_ACCESS_KEY}:${AWS_SECRET_ACCESS_KEY}" \
+		-H "x-amz-content-sha256: UNSIGNED-PAYLOAD" --upload-file "${file}"
+	if [ "${SCHEME}" = "https" ] && [ "${ETCD_S3_SKIP_SSL_VERIFY}" = "true" ]; then
+		set -- "$@" --insecure
+	fi
+	if [ "${SCHEME}" = "https" ] && [ -n "${ETCD_S3_ENDPOINT_CA}" ]; then
+		set -- "$@" --cacert "${ETCD_S3_ENDPOINT_CA}"
+	fi
+	curl "$@" "${SCHEME}://${ENDPOINT}/${ETCD_S3_BUCKET}/${key}"
+}
+
+# with_key_id prints the given JSON metadata file with the identifier of the encryption key added to it.
+with_key_id() {
+	content=""
+	if [ -f "$1" ]; then
+		content=$(cat "$1")
+	fi
+	content=${content#\{}
+	if [ -n "${content}" ] && [ "${content}" != "}" ]; then
+		content=",${content}"
+	else
+		content="}"
+	fi
+	printf '{"encryption-key-id":"%s"%s' "${ETCD_SNAPSHOT_ENCRYPTION_KEY_ID}" "${content}"
+}
+
+if [ -n "${ETCD_SNAPSHOT_ENCRYPTION_KEY}" ]; then
+	if ! command -v openssl >/dev/null 2>&1; then
+		echo "openssl is required to encrypt etcd snapshots" >&2
+		exit 1
+	fi
+	tmpdir=$(mktemp -d "${TMPDIR:-/var/tmp}/etcd-snapshot-upload.XXXXXX") || exit 1
+	trap 'rm -rf "${tmpdir}"' EXIT
+fi
+
+for file in "$@"; do
+	name=$(basename "${file}")
+	if [ ! -f "${file}" ]; then
+		echo "etcd snapshot ${file} does not exist" >&2
+		exit 1
+	fi
+	metadata="$(dirname "$(dirname "${file}")")/.metadata/${name}"
+	if [ -n "${ETCD_SNAPSHOT_ENCRYPTION_KEY}" ]; then
+		openssl enc -aes-256-cbc -salt -pbkdf2 -iter 100000 -pass env:ETCD_SNAPSHOT_ENCRYPTION_KEY \
+			-in "${file}" -out "${tmpdir}/${name}" >&2 || exit 1
+		mac=$(hmac "${tmpdir}/${name}") || exit 1
+		printf '%s' "${mac}" >>"${tmpdir}/${name}" || exit 1
+		upload "${name}" "${tmpdir}/${name}" >&2 || exit 1
+		rm -f "${tmpdir}/${name}"
+		with_key_id "${metadata}" > "${tmpdir}/metadata" || exit 1
+		upload ".metadata/${name}" "${tmpdir}/metadata" >&2 || exit 1
+	else
+		upload "${name}" "${file}" >&2 || exit 1
+		if [ -f "${metadata}" ]; then
+			upload ".metadata/${name}" "${metadata}" >&2 || exit 1
+		fi
+	fi
+	echo "uploaded=${name}"
+done
+`
+)
+
+func (p *Planner) setEtcdSnapshotUploadState(status rkev1.RKEControlPlaneStatus, upload *rkev1.ETCDSnapshotUpload, phase rkev1.ETCDSnapshotPhase, files []rkev1.ETCDSnapshotUploadFile) (rkev1.RKEControlPlaneStatus, error) {
+	if status.ETCDSnapshotUploadPhase != phase || !equality.Semantic.DeepEqual(status.ETCDSnapshotUpload, upload) || !equality.Semantic.DeepEqual(status.ETCDSnapshotUploadFiles, files) {
+		status.ETCDSnapshotUploadPhase = phase
+		status.ETCDSnapshotUpload = upload
+		status.ETCDSnapshotUploadFiles = files
+		return status, errWaiting("refreshing etcd snapshot upload state")
+	}
+	return status, nil
+}
+
+func (p *Planner) resetEtcdSnapshotUploadState(status rkev1.RKEControlPlaneStatus) (rkev1.RKEControlPlaneStatus, error) {
+	if status.ETCDSnapshotUpload == nil && status.ETCDSnapshotUploadPhase == "" && len(status.ETCDSnapshotUploadFiles) == 0 {
+		return status, nil
+	}
+	return p.setEtcdSnapshotUploadState(status, nil, "", nil)
+}
+
+func (p *Planner) startOrRestartEtcdSnapshotUpload(status rkev1.RKEControlPlaneStatus, upload *rkev1.ETCDSnapshotUpload) (rkev1.RKEControlPlaneStatus, error) {
+	if status.ETCDSnapshotUpload == nil || !equality.Semantic.DeepEqual(upload, status.ETCDSnapshotUpload) {
+		return p.setEtcdSnapshotUploadState(status, upload, rkev1.ETCDSnapshotPhaseStarted, nil)
+	}
+	return status, nil
+}
+
+// uploadEtcdSnapshots uploads local etcd snapshots to S3 when the generation of the etcd snapshot upload changes. The
+// phases are in order:
+// Started -> The local etcd snapshots to upload and the machines they are stored on are recorded in status.
+// Upload -> The snapshots are uploaded from one machine at a time, and recorded as uploaded in status.
+// Finished -> All snapshots were uploaded.
+func (p *Planner) uploadEtcdSnapshots(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
+	if controlPlane.Spec.ETCDSnapshotUpload == nil {
+		return p.resetEtcdSnapshotUploadState(status)
+	}
+
+	// Don't upload etcd snapshots if the cluster is not initialized or bootstrapped.
+	if !status.Initialized || !capr.Bootstrapped.IsTrue(&status) {
+		logrus.Warnf("[planner] rkecluster %s/%s: skipping etcd snapshot upload as cluster has not yet been initialized or bootstrapped", controlPlane.Namespace, controlPlane.Name)
+		return status, nil
+	}
+
+	upload := controlPlane.Spec.ETCDSnapshotUpload
+
+	var err error
+	if status, err = p.startOrRestartEtcdSnapshotUpload(status, upload); err != nil {
+		return status, err
+	}
+
+	switch status.ETCDSnapshotUploadPhase {
+	case rkev1.ETCDSnapshotPhaseStarted:
+		files, err := p.etcdSnapshotUploadFiles(controlPlane, clusterPlan)
+		if err != nil {
+			logrus.Errorf("[planner] rkecluster %s/%s: refusing etcd snapshot upload: %v", controlPlane.Namespace, controlPlane.Name, err)
+			status, _ = p.setEtcdSnapshotUploadState(status, upload, rkev1.ETCDSnapshotPhaseFailed, nil)
+			return status, err
+		}
+		logrus.Infof("[planner] rkecluster %s/%s: starting upload of %d local etcd snapshots", controlPlane.Namespace, controlPlane.Name, len(files))
+		return p.setEtcdSnapshotUploadState(status, upload, rkev1.ETCDSnapshotPhaseUpload, files)
+	case rkev1.ETCDSnapshotPhaseUpload:
+		return p.runEtcdSnapshotUpload(controlPlane, status, tokensSecret, clusterPlan)
+	case rkev1.ETCDSnapshotPhaseFailed:
+		fallthrough
+	case rkev1.ETCDSnapshotPhaseFinished:
+		return status, nil
+	default:
+		return p.setEtcdSnapshotUploadState(status, upload, rkev1.ETCDSnapshotPhaseStarted, nil)
+	}
+}
+
+// etcdSnapshotUploadFiles returns the local etcd snapshots of the etcd snapshot upload, sorted by machine so that the
+// snapshots of a machine are uploaded together. If no names are given, all local snapshots of the cluster that are
+// stored on a machine of the cluster are returned.
+func (p *Planner) etcdSnapshotUploadFiles(controlPlane *rkev1.RKEControlPlane, clusterPlan *plan.Plan) ([]rkev1.ETCDSnapshotUploadFile, error) {
+	upload := controlPlane.Spec.ETCDSnapshotUpload
+
+	var snapshots []*rkev1.ETCDSnapshot
+	if len(upload.Names) == 0 {
+		all, err := p.etcdSnapshotCache.List(controlPlane.Namespace, labels.SelectorFromSet(map[string]string{
+			capr.ClusterNameLabel: controlPlane.Name,
+		}))
+		if err != nil {
+			return nil, err
+		}
+		for _, snapshot := range all {
+			if snapshot.SnapshotFile.S3 == nil && !snapshot.Status.Missing && snapshot.SnapshotFile.Status != etcdSnapshotStatusFailed {
+				snapshots = append(snapshots, snapshot)
+			}
+		}
+	} else {
+		for _, name := range upload.Names {
+			snapshot, err := p.etcdSnapshotCache.Get(controlPlane.Namespace, name)
+			if apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("etcd snapshot %s/%s does not exist", controlPlane.Namespace, name)
+			} else if err != nil {
+				return nil, err
+			}
+			if snapshot.SnapshotFile.S3 != nil {
+				return nil, fmt.Errorf("etcd snapshot %s/%s is not a local snapshot", snapshot.Namespace, snapshot.Name)
+			}
+			if snapshot.Status.Missing {
+				return nil, fmt.Errorf("etcd snapshot %s/%s is missing on its machine", snapshot.Namespace, snapshot.Name)
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	machineNames := map[string]string{}
+	for _, entry := range collect(clusterPlan, roleAnd(isEtcd, isNotDeleting)) {
+		if id := entry.Machine.Labels[capr.MachineIDLabel]; id != "" {
+			machineNames[id] = entry.Machine.Name
+		}
+	}
+
+	var files []rkev1.ETCDSnapshotUploadFile
+	for _, snapshot := range snapshots {
+		machineName := machineNames[snapshot.Labels[capr.MachineIDLabel]]
+		if machineName == "" {
+			if len(upload.Names) == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("etcd snapshot %s/%s is not stored on an etcd machine of the cluster", snapshot.Namespace, snapshot.Name)
+		}
+		files = append(files, rkev1.ETCDSnapshotUploadFile{
+			Name:         snapshot.Name,
+			SnapshotName: snapshot.SnapshotFile.Name,
+			Location:     snapshot.SnapshotFile.Location,
+			MachineName:  machineName,
+		})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no local etcd snapshots to upload")
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].MachineName < files[j].MachineName
+	})
+	return files, nil
+}
+
+// runEtcdSnapshotUpload uploads the snapshots of the next machine whose snapshots were not yet uploaded, and records them
+// as uploaded in status once the plan was applied.
+func (p *Planner) runEtcdSnapshotUpload(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
+	upload := controlPlane.Spec.ETCDSnapshotUpload
+	files := append([]rkev1.ETCDSnapshotUploadFile{}, status.ETCDSnapshotUploadFiles...)
+
+	machineName := ""
+	for _, file := range files {
+		if !file.Uploaded {
+			machineName = file.MachineName
+			break
+		}
+	}
+	if machineName == "" {
+		logrus.Infof("[planner] rkecluster %s/%s: etcd snapshot upload finished", controlPlane.Namespace, controlPlane.Name)
+		return p.setEtcdSnapshotUploadState(status, upload, rkev1.ETCDSnapshotPhaseFinished, files)
+	}
+
+	machine, ok := clusterPlan.Machines[machineName]
+	if !ok || machine.DeletionTimestamp != nil {
+		logrus.Errorf("[planner] rkecluster %s/%s: etcd snapshot upload failed as machine %s was removed", controlPlane.Namespace, controlPlane.Name, machineName)
+		status, _ = p.setEtcdSnapshotUploadState(status, upload, rkev1.ETCDSnapshotPhaseFailed, files)
+		return status, fmt.Errorf("machine %s that stores etcd snapshots to upload was removed", machineName)
+	}
+	entry := &planEntry{
+		Machine:  machine,
+		Plan:     clusterPlan.Nodes[machineName],
+		Metadata: clusterPlan.Metadata[machineName],
+	}
+
+	var machineFiles []rkev1.ETCDSnapshotUploadFile
+	for _, file := range files {
+		if file.MachineName == machineName && !file.Uploaded {
+			machineFiles = append(machineFiles, file)
+		}
+	}
+
+	uploadPlan, joinedServer, err := p.generateEtcdSnapshotUploadPlan(controlPlane, tokensSecret, clusterPlan, entry, machineFiles)
+	if err != nil {
+		return status, err
+	}
+
+	msg := fmt.Sprintf("%s on machine %s/%s", ETCDSnapshotUploadMessage, machine.Namespace, machine.Name)
+	if err := assignAndCheckPlan(p.store, msg, entry, uploadPlan, joinedServer, 1, 1); err != nil {
+		if IsErrWaiting(err) {
+			return status, err
+		}
+		logrus.Errorf("[planner] rkecluster %s/%s: etcd snapshot upload failed on machine %s: %v", controlPlane.Namespace, controlPlane.Name, machine.Name, err)
+		status, _ = p.setEtcdSnapshotUploadState(status, upload, rkev1.ETCDSnapshotPhaseFailed, files)
+		return status, err
+	}
+
+	uploaded := ParseEtcdSnapshotUploadOutput(entry.Plan.Output[ETCDSnapshotUploadInstructionName])
+	for i, file := range files {
+		if file.MachineName != machineName || file.Uploaded {
+			continue
+		}
+		if !uploaded[file.SnapshotName] {
+			logrus.Errorf("[planner] rkecluster %s/%s: etcd snapshot %s was not uploaded from machine %s", controlPlane.Namespace, controlPlane.Name, file.SnapshotName, machine.Name)
+			status, _ = p.setEtcdSnapshotUploadState(status, upload, rkev1.ETCDSnapshotPhaseFailed, files)
+			return status, fmt.Errorf("etcd snapshot %s was not uploaded from machine %s", file.SnapshotName, machine.Name)
+		}
+		files[i].Uploaded = true
+	}
+	return p.setEtcdSnapshotUploadState(status, upload, rkev1.ETCDSnapshotPhaseUpload, files)
+}
+
+// generateEtcdSnapshotUploadPlan generates the desired plan of the given etcd machine with an instruction that uploads
+// the given local snapshots to the S3 location of the etcd snapshot upload.
+func (p *Planner) generateEtcdSnapshotUploadPlan(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret, clusterPlan *plan.Plan, entry *planEntry, files []rkev1.ETCDSnapshotUploadFile) (plan.NodePlan, string, error) {
+	env, s3Files, err := p.etcdSnapshotUploadEnv(controlPlane)
+	if err != nil {
+		return plan.NodePlan{}, "", err
+	}
+	insecureEnv, err := p.etcdS3InsecureEnv(controlPlane, entry)
+	if err != nil {
+		return plan.NodePlan{}, "", err
+	}
+	env = append(env, insecureEnv...)
+	encryptionEnv, err := p.etcdSnapshotEncryptionEnv(controlPlane)
+	if err != nil {
+		return plan.NodePlan{}, "", err
+	}
+	env = append(env, encryptionEnv...)
+
+	joinServer := ""
+	if !isInitNode(entry) {
+		_, initNodeJoinServer, _, err := p.findInitNode(controlPlane, clusterPlan)
+		if err != nil {
+			return plan.NodePlan{}, "", err
+		}
+		if initNodeJoinServer == "" {
+			return plan.NodePlan{}, "", errWaiting("waiting for init node to upload etcd snapshots")
+		}
+		joinServer = initNodeJoinServer
+	}
+
+	uploadPlan, joinedServer, err := p.desiredPlan(controlPlane, tokensSecret, entry, joinServer)
+	if err != nil {
+		return plan.NodePlan{}, "", err
+	}
+
+	args := []string{etcdScriptPath(controlPlane, etcdSnapshotUploadScriptPath)}
+	for _, file := range files {
+		args = append(args, etcdSnapshotUploadFilePath(controlPlane, file))
+	}
+
+	uploadPlan.Files = append(uploadPlan.Files, s3Files...)
+	uploadPlan.Files = append(uploadPlan.Files, generateEtcdSnapshotUploadScriptFile(controlPlane))
+	uploadPlan.Instructions = append(uploadPlan.Instructions, plan.OneTimeInstruction{
+		Name:    ETCDSnapshotUploadInstructionName,
+		Command: "/bin/sh",
+		Args:    args,
+		Env: append(env,
+			// make sure that the snapshots are uploaded again for every generation
+			fmt.Sprintf("ETCD_SNAPSHOT_UPLOAD_GENERATION=%d", controlPlane.Spec.ETCDSnapshotUpload.Generation)),
+		SaveOutput: true,
+	})
+	return uploadPlan, joinedServer, nil
+}
+
+// generateEtcdSnapshotUploadScriptFile generates the file of the etcd snapshot upload script.
+func generateEtcdSnapshotUploadScriptFile(controlPlane *rkev1.RKEControlPlane) plan.File {
+	return plan.File{
+		Content: base64.StdEncoding.EncodeToString([]byte(etcdSnapshotUploadScript)),
+		Path:    etcdScriptPath(controlPlane, etcdSnapshotUploadScriptPath),
+		Dynamic: true,
+	}
+}
+
+// etcdSnapshotUploadFilePath returns the path of the given local snapshot on its machine. The location of the snapshot
+// is a file URL, and the snapshot is expected in the default snapshot directory of the distro if it has no location.
+func etcdSnapshotUploadFilePath(controlPlane *rkev1.RKEControlPlane, file rkev1.ETCDSnapshotUploadFile) string {
+	if filePath, ok := strings.CutPrefix(file.Location, "file://"); ok && filePath != "" {
+		return filePath
+	}
+	return etcdSnapshotLocalPath(controlPlane, file.SnapshotName)
+}
+
+// etcdSnapshotUploadEnv returns the environment variables and files of the S3 location of the etcd snapshot upload.
+func (p *Planner) etcdSnapshotUploadEnv(controlPlane *rkev1.RKEControlPlane) ([]string, []plan.File, error) {
+	var (
+		args, env []string
+		files     []plan.File
+		err       error
+	)
+	if targetName := controlPlane.Spec.ETCDSnapshotUpload.S3Target; targetName != "" {
+		target, ok := S3Target(controlPlane, targetName)
+		if !ok {
+			return nil, nil, fmt.Errorf("S3 target %s is not configured", targetName)
+		}
+		args, env, files, err = p.etcdS3Args.ToTargetArgs(target, controlPlane, "etcd-")
+	} else {
+		if controlPlane.Spec.ETCD == nil || !S3Enabled(controlPlane.Spec.ETCD.S3) {
+			return nil, nil, fmt.Errorf("S3 is not configured to upload etcd snapshots to")
+		}
+		args, env, files, err = p.etcdS3Args.ToArgs(controlPlane.Spec.ETCD.S3, controlPlane, "etcd-", true)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	env, err = etcdS3ArgsToEnv(args, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	return env, files, nil
+}
+
+// etcdS3ArgsToEnv converts the given S3 arguments of the distro to the environment variables of the scripts that
+// transfer etcd snapshots with curl, e.g. --etcd-s3-bucket=name to ETCD_S3_BUCKET=name, and appends them to the given
+// environment variables.
+func etcdS3ArgsToEnv(args, env []string) ([]string, error) {
+	for _, arg := range args {
+		key, value, ok := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !ok {
+			value = "true"
+		}
+		if key == "etcd-s3" {
+			continue
+		}
+		if key == "etcd-s3-secret-key" {
+			env = append(env, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", value))
+			continue
+		}
+		env = append(env, fmt.Sprintf("%s=%s", strings.ToUpper(strings.ReplaceAll(key, "-", "_")), value))
+	}
+	if !hasEnv(env, "ETCD_S3_BUCKET") {
+		return nil, fmt.Errorf("bucket is not set to transfer etcd snapshots with")
+	}
+	if !hasEnv(env, "ETCD_S3_ACCESS_KEY") {
+		return nil, fmt.Errorf("an S3 access key is required to transfer etcd snapshots, instance credentials are not supported")
+	}
+	return env, nil
+}
+
+// etcdS3InsecureEnv returns the environment variable that makes the scripts that transfer etcd snapshots with curl access
+// the S3 endpoint over http, if the distro of the given machine is configured to do so with etcd-s3-insecure. The
+// setting is not part of the S3 configuration of the controlplane, so it is read from the config of the machine.
+func (p *Planner) etcdS3InsecureEnv(controlPlane *rkev1.RKEControlPlane, entry *planEntry) ([]string, error) {
+	config := map[string]interface{}{}
+	if err := p.addUserConfig(config, controlPlane, entry); err != nil {
+		return nil, err
+	}
+	if convert.ToBool(config["etcd-s3-insecure"]) {
+		return []string{"ETCD_S3_INSECURE=true"}, nil
+	}
+	return nil, nil
+}
+
+// hasEnv returns true if the given environment variables contain a non-empty variable with the given name.
+func hasEnv(env []string, name string) bool {
+	for _, e := range env {
+		if k, v, _ := strings.Cut(e, "="); k == name && v != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseEtcdSnapshotUploadOutput returns the names of the snapshots the upload script reported as uploaded.
+func ParseEtcdSnapshotUploadOutput(output []byte) map[string]bool {
+	uploaded := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "uploaded="); ok {
+			uploaded[name] = true
+		}
+	}
+	return uploaded
+}
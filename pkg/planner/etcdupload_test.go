@@ -0,0 +1,59 @@
+package planner
+
+import (
+	"testing"
+
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEtcdSnapshotUploadOutput(t *testing.T) {
+	uploaded := ParseEtcdSnapshotUploadOutput([]byte("uploaded=etcd-snapshot-machine-1-1700000000\nunrelated\n uploaded=on-demand-machine-1-1700000001 \n"))
+	assert.Equal(t, map[string]bool{
+		"etcd-snapshot-machine-1-1700000000": true,
+		"on-demand-machine-1-1700000001":     true,
+	}, uploaded)
+}
+
+func TestEtcdSnapshotUploadFilePath(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{
+		Spec: rkev1.RKEControlPlaneSpec{KubernetesVersion: "v1.28.3+rke2r1"},
+	}
+
+	assert.Equal(t, "/opt/snapshots/on-demand", etcdSnapshotUploadFilePath(cp, rkev1.ETCDSnapshotUploadFile{
+		SnapshotName: "on-demand",
+		Location:     "file:///opt/snapshots/on-demand",
+	}))
+	assert.Equal(t, "/var/lib/rancher/rke2/server/db/snapshots/on-demand", etcdSnapshotUploadFilePath(cp, rkev1.ETCDSnapshotUploadFile{
+		SnapshotName: "on-demand",
+	}))
+}
+
+func TestEtcdSnapshotUploadEnv(t *testing.T) {
+	p := &Planner{}
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.ETCD = &rkev1.ETCD{
+		S3Targets: []rkev1.ETCDSnapshotS3Target{
+			{
+				Name: "offsite",
+				ETCDSnapshotS3: rkev1.ETCDSnapshotS3{
+					Bucket:   "backups",
+					Endpoint: "minio.example.com",
+				},
+			},
+		},
+	}
+	cp.Spec.ETCDSnapshotUpload = &rkev1.ETCDSnapshotUpload{Generation: 1, S3Target: "offsite"}
+
+	// the target has no cloud credential and no access key
+	_, _, err := p.etcdSnapshotUploadEnv(cp)
+	assert.Error(t, err)
+
+	cp.Spec.ETCDSnapshotUpload.S3Target = "missing"
+	_, _, err = p.etcdSnapshotUploadEnv(cp)
+	assert.Error(t, err)
+
+	cp.Spec.ETCDSnapshotUpload.S3Target = ""
+	_, _, err = p.etcdSnapshotUploadEnv(cp)
+	assert.Error(t, err)
+}
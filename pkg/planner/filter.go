@@ -1,19 +1,12 @@
 package planner
 
 import (
-	"context"
-
 	"github.com/rancher/channelserver/pkg/model"
-	"github.com/rancher/cluster-api-provider-rancher/pkg"
-	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/norman/types/convert"
 )
 
-func filterConfigData(config map[string]interface{}, controlPlane *rkev1.RKEControlPlane, entry *planEntry) {
-	var (
-		isServer = isControlPlane(entry) || isEtcd(entry)
-		release  = capr.GetKDMReleaseData(context.TODO(), controlPlane)
-	)
+func filterConfigData(config map[string]interface{}, release *model.Release, entry *planEntry) {
+	isServer := isControlPlane(entry) || isEtcd(entry)
 
 	if release == nil {
 		return
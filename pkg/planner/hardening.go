@@ -0,0 +1,227 @@
+package planner
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/wrangler/v3/pkg/data/convert"
+)
+
+const (
+	hardeningBinPrefix = "capr/hardening/bin"
+
+	cisSysctlPath               = "/etc/sysctl.d/90-capr-cis.conf"
+	cisPodSecurityAdmissionPath = "server/cis-pod-security-admission.json"
+
+	cisHardeningScriptPath = "cis_hardening.sh"
+	// cisHardeningScript prepares a machine for the CIS profile of the distro, or validates that its kernel parameters
+	// match the kernel parameters that are required by the profile. The etcd user is only created if "etcd" is passed.
+	cisHardeningScript = `#!/bin/sh
+MODE=$1
+SYSCTL_FILE=$2
+
+case "${MODE}" in
+prepare)
+	if [ "$3" = "etcd" ] && ! getent passwd etcd >/dev/null 2>&1; then
+		useradd -r -c "etcd user" -s /sbin/nologin -M -U etcd || exit 1
+	fi
+	exec sysctl -p "${SYSCTL_FILE}"
+	;;
+preflight)
+	failed=0
+	while IFS='=' read -r key expected; do
+		key=$(echo "${key}" | tr -d '[:space:]')
+		expected=$(echo "${expected}" | tr -d '[:space:]')
+		case "${key}" in
+		'' | '#'*) continue ;;
+		esac
+		actual=$(sysctl -n "${key}" 2>/dev/null)
+		if [ "${actual}" != "${expected}" ]; then
+			echo "kernel parameter ${key} is [${actual}], expected [${expected}]" >&2
+			failed=1
+		fi
+	done <"${SYSCTL_FILE}"
+	exit ${failed}
+	;;
+esac
+echo "unknown mode ${MODE}" >&2
+exit 1
+`
+
+	// rke2 profiles of the CIS benchmark versions that are expected by rke2 versions that do not accept the generic
+	// profile.
+	rke2CIS16Profile  = "cis-1.6"
+	rke2CIS123Profile = "cis-1.23"
+
+	// cisSysctl are the kernel parameters that are required by the kubelet when it protects the kernel defaults.
+	cisSysctl = `vm.panic_on_oom=0
+vm.overcommit_memory=1
+kernel.panic=10
+kernel.panic_on_oops=1
+`
+)
+
+// rke2GenericCISProfileVersion is the first rke2 version that only accepts the generic CIS profile. The generic profile
+// was backported to patch releases of v1.25 to v1.28, which keep accepting the CIS 1.23 profile, so the CIS 1.23 profile
+// is used for all of them.
+var rke2GenericCISProfileVersion = semver.MustParse("v1.29.0")
+
+// cisExemptNamespaces are the system namespaces of the distro and Rancher that are exempt from the restricted pod
+// security standard.
+var cisExemptNamespaces = []string{
+	"calico-system",
+	"cattle-fleet-system",
+	"cattle-impersonation-system",
+	"cattle-system",
+	"cis-operator-system",
+	"kube-system",
+	"tigera-operator",
+}
+
+func hardeningScriptPath(controlPlane *rkev1.RKEControlPlane, file string) string {
+	return path.Join(capr.GetDistroDataDir(controlPlane), hardeningBinPrefix, file)
+}
+
+func cisPodSecurityAdmissionConfigPath(controlPlane *rkev1.RKEControlPlane) string {
+	return path.Join(capr.GetDistroDataDir(controlPlane), cisPodSecurityAdmissionPath)
+}
+
+// cisPodSecurityAdmissionConfig renders the admission configuration that enforces the restricted pod security standard
+// on all namespaces but the exempt ones.
+func cisPodSecurityAdmissionConfig(profile *rkev1.HardeningProfile) ([]byte, error) {
+	exempt := map[string]bool{}
+	for _, namespace := range append(append([]string{}, cisExemptNamespaces...), profile.ExemptNamespaces...) {
+		exempt[namespace] = true
+	}
+	namespaces := make([]string, 0, len(exempt))
+	for namespace := range exempt {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": "apiserver.config.k8s.io/v1",
+		"kind":       "AdmissionConfiguration",
+		"plugins": []interface{}{
+			map[string]interface{}{
+				"name": "PodSecurity",
+				"configuration": map[string]interface{}{
+					"apiVersion": "pod-security.admission.config.k8s.io/v1",
+					"kind":       "PodSecurityConfiguration",
+					"defaults": map[string]interface{}{
+						"enforce":         "restricted",
+						"enforce-version": "latest",
+						"audit":           "restricted",
+						"audit-version":   "latest",
+						"warn":            "restricted",
+						"warn-version":    "latest",
+					},
+					"exemptions": map[string]interface{}{
+						"usernames":      []string{},
+						"runtimeClasses": []string{},
+						"namespaces":     namespaces,
+					},
+				},
+			},
+		},
+	})
+}
+
+// rke2CISProfile returns the CIS profile that is expected by the given rke2 version. Versions before v1.25 implement
+// the CIS 1.6 benchmark with pod security policies, and versions before v1.29 the CIS 1.23 benchmark.
+func rke2CISProfile(kubernetesVersion string) (string, error) {
+	version, err := semver.NewVersion(kubernetesVersion)
+	if err != nil {
+		return "", err
+	}
+	if version.LessThan(capr.Kubernetes125) {
+		return rke2CIS16Profile, nil
+	}
+	if version.LessThan(rke2GenericCISProfileVersion) {
+		return rke2CIS123Profile, nil
+	}
+	return rkev1.HardeningProfileCIS, nil
+}
+
+// addHardeningProfileConfig switches the distro to the hardening profile of the controlplane. rke2 has a CIS profile
+// of its own, k3s is configured to protect the kernel defaults and enforce pod security admission instead. The pod
+// security admission configuration is not passed to rke2 versions whose profile enforces pod security policies.
+func addHardeningProfileConfig(config map[string]interface{}, controlPlane *rkev1.RKEControlPlane, entry *planEntry) error {
+	if controlPlane.Spec.HardeningProfile == nil || windows(entry) {
+		return nil
+	}
+
+	if capr.GetRuntime(controlPlane.Spec.KubernetesVersion) == capr.RuntimeRKE2 {
+		profile, err := rke2CISProfile(controlPlane.Spec.KubernetesVersion)
+		if err != nil {
+			return err
+		}
+		config["profile"] = profile
+		if !isOnlyWorker(entry) && profile != rke2CIS16Profile {
+			config["pod-security-admission-config-file"] = cisPodSecurityAdmissionConfigPath(controlPlane)
+		}
+		return nil
+	}
+
+	config["protect-kernel-defaults"] = true
+	if !isOnlyWorker(entry) {
+		config["kube-apiserver-arg"] = append(convert.ToStringSlice(config["kube-apiserver-arg"]),
+			fmt.Sprintf("admission-control-config-file=%s", cisPodSecurityAdmissionConfigPath(controlPlane)))
+	}
+	return nil
+}
+
+// addHardeningProfileFiles adds the kernel parameters and the pod security admission configuration of the hardening
+// profile to the plan, along with the instructions that prepare the machine and validate its kernel parameters. The
+// instructions run before the distro is started with the profile by the install instruction, so that a machine whose
+// kernel parameters can not be set fails its plan instead of the distro.
+func addHardeningProfileFiles(nodePlan plan.NodePlan, controlPlane *rkev1.RKEControlPlane, entry *planEntry) (plan.NodePlan, error) {
+	profile := controlPlane.Spec.HardeningProfile
+	if profile == nil || windows(entry) {
+		return nodePlan, nil
+	}
+
+	nodePlan.Files = append(nodePlan.Files, plan.File{
+		Content: base64.StdEncoding.EncodeToString([]byte(cisSysctl)),
+		Path:    cisSysctlPath,
+		Dynamic: true,
+	}, plan.File{
+		Content: base64.StdEncoding.EncodeToString([]byte(cisHardeningScript)),
+		Path:    hardeningScriptPath(controlPlane, cisHardeningScriptPath),
+		Dynamic: true,
+	})
+
+	if !isOnlyWorker(entry) {
+		admissionConfig, err := cisPodSecurityAdmissionConfig(profile)
+		if err != nil {
+			return nodePlan, err
+		}
+		nodePlan.Files = append(nodePlan.Files, plan.File{
+			Content: base64.StdEncoding.EncodeToString(admissionConfig),
+			Path:    cisPodSecurityAdmissionConfigPath(controlPlane),
+		})
+	}
+
+	prepareArgs := []string{hardeningScriptPath(controlPlane, cisHardeningScriptPath), "prepare", cisSysctlPath}
+	if isEtcd(entry) && capr.GetRuntime(controlPlane.Spec.KubernetesVersion) == capr.RuntimeRKE2 {
+		// etcd of rke2 runs as the etcd user with the CIS profile
+		prepareArgs = append(prepareArgs, "etcd")
+	}
+	nodePlan.Instructions = append(nodePlan.Instructions, plan.OneTimeInstruction{
+		Name:    "cis-prepare",
+		Command: "/bin/sh",
+		Args:    prepareArgs,
+	}, plan.OneTimeInstruction{
+		Name:    "cis-preflight",
+		Command: "/bin/sh",
+		Args:    []string{hardeningScriptPath(controlPlane, cisHardeningScriptPath), "preflight", cisSysctlPath},
+	})
+	return nodePlan, nil
+}
@@ -0,0 +1,155 @@
+package planner
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCISPodSecurityAdmissionConfig(t *testing.T) {
+	data, err := cisPodSecurityAdmissionConfig(&rkev1.HardeningProfile{
+		Profile:          rkev1.HardeningProfileCIS,
+		ExemptNamespaces: []string{"monitoring", "kube-system"},
+	})
+	assert.NoError(t, err)
+
+	config := struct {
+		Plugins []struct {
+			Configuration struct {
+				Exemptions struct {
+					Namespaces []string `json:"namespaces"`
+				} `json:"exemptions"`
+			} `json:"configuration"`
+		} `json:"plugins"`
+	}{}
+	assert.NoError(t, json.Unmarshal(data, &config))
+	if assert.Len(t, config.Plugins, 1) {
+		assert.Equal(t, []string{
+			"calico-system",
+			"cattle-fleet-system",
+			"cattle-impersonation-system",
+			"cattle-system",
+			"cis-operator-system",
+			"kube-system",
+			"monitoring",
+			"tigera-operator",
+		}, config.Plugins[0].Configuration.Exemptions.Namespaces)
+	}
+}
+
+func TestAddHardeningProfile(t *testing.T) {
+	etcdEntry := &planEntry{Metadata: &plan.Metadata{Labels: map[string]string{capr.EtcdRoleLabel: "true"}}}
+	workerEntry := &planEntry{Metadata: &plan.Metadata{Labels: map[string]string{capr.WorkerRoleLabel: "true"}}}
+
+	tests := []struct {
+		name                   string
+		kubernetesVersion      string
+		entry                  *planEntry
+		expectedConfig         map[string]interface{}
+		expectedFiles          int
+		expectedPrepareLastArg string
+	}{
+		{
+			name:              "rke2 etcd",
+			kubernetesVersion: "v1.30.4+rke2r1",
+			entry:             etcdEntry,
+			expectedConfig: map[string]interface{}{
+				"profile":                            rkev1.HardeningProfileCIS,
+				"pod-security-admission-config-file": "/var/lib/rancher/rke2/server/cis-pod-security-admission.json",
+			},
+			expectedFiles:          3,
+			expectedPrepareLastArg: "etcd",
+		},
+		{
+			name:              "rke2 worker",
+			kubernetesVersion: "v1.30.4+rke2r1",
+			entry:             workerEntry,
+			expectedConfig: map[string]interface{}{
+				"profile": rkev1.HardeningProfileCIS,
+			},
+			expectedFiles:          2,
+			expectedPrepareLastArg: cisSysctlPath,
+		},
+		{
+			name:              "rke2 etcd with the CIS 1.23 profile",
+			kubernetesVersion: "v1.26.9+rke2r1",
+			entry:             etcdEntry,
+			expectedConfig: map[string]interface{}{
+				"profile":                            rke2CIS123Profile,
+				"pod-security-admission-config-file": "/var/lib/rancher/rke2/server/cis-pod-security-admission.json",
+			},
+			expectedFiles:          3,
+			expectedPrepareLastArg: "etcd",
+		},
+		{
+			name:              "rke2 etcd with the CIS 1.6 profile",
+			kubernetesVersion: "v1.24.17+rke2r1",
+			entry:             etcdEntry,
+			expectedConfig: map[string]interface{}{
+				"profile": rke2CIS16Profile,
+			},
+			expectedFiles:          3,
+			expectedPrepareLastArg: "etcd",
+		},
+		{
+			name:              "k3s etcd",
+			kubernetesVersion: "v1.30.4+k3s1",
+			entry:             etcdEntry,
+			expectedConfig: map[string]interface{}{
+				"protect-kernel-defaults": true,
+				"kube-apiserver-arg":      []string{"admission-control-config-file=/var/lib/rancher/k3s/server/cis-pod-security-admission.json"},
+			},
+			expectedFiles:          3,
+			expectedPrepareLastArg: cisSysctlPath,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := &rkev1.RKEControlPlane{}
+			cp.Spec.KubernetesVersion = tt.kubernetesVersion
+			cp.Spec.HardeningProfile = &rkev1.HardeningProfile{Profile: rkev1.HardeningProfileCIS}
+
+			config := map[string]interface{}{}
+			assert.NoError(t, addHardeningProfileConfig(config, cp, tt.entry))
+			assert.Equal(t, tt.expectedConfig, config)
+
+			nodePlan, err := addHardeningProfileFiles(plan.NodePlan{}, cp, tt.entry)
+			assert.NoError(t, err)
+			assert.Len(t, nodePlan.Files, tt.expectedFiles)
+			if assert.Len(t, nodePlan.Instructions, 2) {
+				prepare := nodePlan.Instructions[0]
+				assert.Equal(t, "cis-prepare", prepare.Name)
+				assert.Equal(t, "prepare", prepare.Args[1])
+				assert.Equal(t, tt.expectedPrepareLastArg, prepare.Args[len(prepare.Args)-1])
+				// the kernel parameters are validated after they were applied and before the profile is enabled
+				preflight := nodePlan.Instructions[1]
+				assert.Equal(t, "cis-preflight", preflight.Name)
+				assert.Equal(t, []string{prepare.Args[0], "preflight", cisSysctlPath}, preflight.Args)
+			}
+		})
+	}
+}
+
+func TestRKE2CISProfile(t *testing.T) {
+	tests := map[string]string{
+		"v1.24.17+rke2r1": rke2CIS16Profile,
+		"v1.25.14+rke2r1": rke2CIS123Profile,
+		"v1.25.15+rke2r1": rke2CIS123Profile,
+		"v1.28.15+rke2r1": rke2CIS123Profile,
+		"v1.29.0+rke2r1":  rkev1.HardeningProfileCIS,
+		"v1.30.4+rke2r1":  rkev1.HardeningProfileCIS,
+	}
+	for version, expected := range tests {
+		profile, err := rke2CISProfile(version)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, profile, version)
+	}
+
+	_, err := rke2CISProfile("invalid")
+	assert.Error(t, err)
+}
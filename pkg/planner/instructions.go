@@ -5,6 +5,7 @@ import (
 	"path"
 	"strings"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/rancher/cluster-api-provider-rancher/pkg"
 	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
@@ -13,8 +14,34 @@ import (
 const (
 	captureAddressInstructionName = "capture-address"
 	etcdNameInstructionName       = "etcd-name"
+
+	// ETCDSnapshotListS3TargetInstructionPrefix is the prefix of the names of the periodic instructions that list the etcd
+	// snapshots in the S3 targets of a cluster, which are suffixed with the name of the target.
+	ETCDSnapshotListS3TargetInstructionPrefix = "etcd-snapshot-list-s3-target-"
+
+	// ETCDEndpointStatusInstructionName is the name of the periodic instruction that collects the status of the etcd
+	// member of a machine.
+	ETCDEndpointStatusInstructionName = "etcd-endpoint-status"
+	// ETCDEndpointStatusPeriodSeconds is the period of the etcd endpoint status instruction.
+	ETCDEndpointStatusPeriodSeconds = 300
+
+	// CertificateExpiryInstructionName is the name of the periodic instruction that collects the expiry of the
+	// certificates on a machine.
+	CertificateExpiryInstructionName = "certificate-expiry"
+	// CertificateExpiryPeriodSeconds is the period of the certificate expiry instruction.
+	CertificateExpiryPeriodSeconds = 3600
 )
 
+// etcdSnapshotListJSONVersions are the first distro versions of each minor version whose etcd snapshot list command
+// supports JSON output, which was introduced together with the ETCDSnapshotFile resource of the distros. All versions
+// of later minor versions support it.
+var etcdSnapshotListJSONVersions = map[uint64]*semver.Version{
+	28: semver.MustParse("v1.28.3"),
+	27: semver.MustParse("v1.27.7"),
+	26: semver.MustParse("v1.26.10"),
+	25: semver.MustParse("v1.25.15"),
+}
+
 // generateInstallInstruction generates the instruction necessary to install the desired tool.
 func (p *Planner) generateInstallInstruction(controlPlane *rkev1.RKEControlPlane, entry *planEntry, env []string) plan.OneTimeInstruction {
 	var instruction plan.OneTimeInstruction
@@ -135,8 +162,8 @@ func (p *Planner) addEtcdSnapshotListLocalPeriodicInstruction(nodePlan plan.Node
 		Args: []string{
 			"-c",
 			// the grep here is to make the command fail if we don't get the output we expect, like empty string.
-			fmt.Sprintf("%s etcd-snapshot list --etcd-s3=false 2>/dev/null",
-				capr.GetRuntime(controlPlane.Spec.KubernetesVersion)),
+			fmt.Sprintf("%s etcd-snapshot list --etcd-s3=false%s 2>/dev/null",
+				capr.GetRuntime(controlPlane.Spec.KubernetesVersion), etcdSnapshotListOutputArg(controlPlane)),
 		},
 		PeriodSeconds: 600,
 	})
@@ -144,20 +171,129 @@ func (p *Planner) addEtcdSnapshotListLocalPeriodicInstruction(nodePlan plan.Node
 }
 
 func (p *Planner) addEtcdSnapshotListS3PeriodicInstruction(nodePlan plan.NodePlan, controlPlane *rkev1.RKEControlPlane) (plan.NodePlan, error) {
+	if etcdSnapshotEncryptionEnabled(controlPlane) {
+		// S3 is not configured for the distro while snapshots are encrypted, so the S3 location is passed explicitly.
+		args, env, files, err := p.etcdS3Args.ToArgs(controlPlane.Spec.ETCD.S3, controlPlane, "etcd-", true)
+		if err != nil {
+			return nodePlan, err
+		}
+		nodePlan.Files = append(nodePlan.Files, files...)
+		nodePlan.PeriodicInstructions = append(nodePlan.PeriodicInstructions, plan.PeriodicInstruction{
+			Name:    "etcd-snapshot-list-s3",
+			Command: "sh",
+			Args: append([]string{
+				"-c",
+				fmt.Sprintf(`%s etcd-snapshot list "$@"%s 2>/dev/null`,
+					capr.GetRuntime(controlPlane.Spec.KubernetesVersion), etcdSnapshotListOutputArg(controlPlane)),
+				"--",
+			}, args...),
+			Env:           env,
+			PeriodSeconds: 600,
+		})
+		return nodePlan, nil
+	}
 	nodePlan.PeriodicInstructions = append(nodePlan.PeriodicInstructions, plan.PeriodicInstruction{
 		Name:    "etcd-snapshot-list-s3",
 		Command: "sh",
 		Args: []string{
 			"-c",
 			// the grep here is to make the command fail if we don't get the output we expect, like empty string.
-			fmt.Sprintf("%s etcd-snapshot list --etcd-s3 2>/dev/null",
-				capr.GetRuntime(controlPlane.Spec.KubernetesVersion)),
+			fmt.Sprintf("%s etcd-snapshot list --etcd-s3%s 2>/dev/null",
+				capr.GetRuntime(controlPlane.Spec.KubernetesVersion), etcdSnapshotListOutputArg(controlPlane)),
 		},
 		PeriodSeconds: 600,
 	})
 	return nodePlan, nil
 }
 
+// addEtcdSnapshotListS3TargetPeriodicInstruction adds a periodic instruction that lists the etcd snapshots in the given
+// S3 target.
+func (p *Planner) addEtcdSnapshotListS3TargetPeriodicInstruction(nodePlan plan.NodePlan, controlPlane *rkev1.RKEControlPlane, target *rkev1.ETCDSnapshotS3Target) (plan.NodePlan, error) {
+	args, env, files, err := p.etcdS3Args.ToTargetArgs(target, controlPlane, "etcd-")
+	if err != nil {
+		return nodePlan, err
+	}
+	nodePlan.Files = append(nodePlan.Files, files...)
+	nodePlan.PeriodicInstructions = append(nodePlan.PeriodicInstructions, plan.PeriodicInstruction{
+		Name:    ETCDSnapshotListS3TargetInstructionPrefix + target.Name,
+		Command: "sh",
+		Args: append([]string{
+			"-c",
+			fmt.Sprintf(`%s etcd-snapshot list "$@" %s%s 2>/dev/null`,
+				capr.GetRuntime(controlPlane.Spec.KubernetesVersion), etcdS3TargetSecretKeyArg, etcdSnapshotListOutputArg(controlPlane)),
+			"--",
+		}, args...),
+		Env:           env,
+		PeriodSeconds: 600,
+	})
+	return nodePlan, nil
+}
+
+// addCertificateExpiryPeriodicInstruction adds a periodic instruction that prints the path and the expiry date of every
+// certificate of the distro on the machine, except for the CA certificates, separated by a tab.
+func (p *Planner) addCertificateExpiryPeriodicInstruction(nodePlan plan.NodePlan, controlPlane *rkev1.RKEControlPlane) (plan.NodePlan, error) {
+	dataDir := capr.GetDistroDataDir(controlPlane)
+	var dirs []string
+	for _, dir := range []string{"server/tls", "server/tls/etcd", "server/tls/kube-controller-manager", "server/tls/kube-scheduler", "agent"} {
+		dirs = append(dirs, path.Join(dataDir, dir, "*.crt"))
+	}
+	nodePlan.PeriodicInstructions = append(nodePlan.PeriodicInstructions, plan.PeriodicInstruction{
+		Name:    CertificateExpiryInstructionName,
+		Command: "sh",
+		Args: []string{
+			"-c",
+			fmt.Sprintf(`command -v openssl >/dev/null 2>&1 || { echo "openssl is required to collect the expiry of certificates" >&2; exit 1; }
+for f in %s; do
+	[ -f "$f" ] || continue
+	case "${f##*/}" in ca.crt|*-ca.crt) continue ;; esac
+	end=$(openssl x509 -noout -enddate -in "$f") || exit 1
+	printf '%%s\t%%s\n' "$f" "${end#notAfter=}"
+done`, strings.Join(dirs, " ")),
+		},
+		PeriodSeconds: CertificateExpiryPeriodSeconds,
+	})
+	return nodePlan, nil
+}
+
+// addEtcdEndpointStatusPeriodicInstruction adds a periodic instruction that collects the status of the local etcd member
+// with etcdctl, together with the etcdctl script it runs.
+func (p *Planner) addEtcdEndpointStatusPeriodicInstruction(nodePlan plan.NodePlan, controlPlane *rkev1.RKEControlPlane) (plan.NodePlan, error) {
+	nodePlan.Files = append(nodePlan.Files, generateEtcdctlFile(controlPlane))
+	nodePlan.PeriodicInstructions = append(nodePlan.PeriodicInstructions, plan.PeriodicInstruction{
+		Name:    ETCDEndpointStatusInstructionName,
+		Command: "sh",
+		Args: []string{
+			"-c",
+			fmt.Sprintf("%s endpoint status -w json", etcdctlCommand(controlPlane)),
+		},
+		PeriodSeconds: ETCDEndpointStatusPeriodSeconds,
+	})
+	return nodePlan, nil
+}
+
+// etcdSnapshotListOutputArg returns the argument that requests JSON output from the etcd snapshot list command if the
+// distro version supports it, and an empty string otherwise, in which case the column output is parsed.
+func etcdSnapshotListOutputArg(controlPlane *rkev1.RKEControlPlane) string {
+	if etcdSnapshotListJSONSupported(controlPlane.Spec.KubernetesVersion) {
+		return " --output=json"
+	}
+	return ""
+}
+
+// etcdSnapshotListJSONSupported returns a boolean indicating whether the etcd snapshot list command of the given distro
+// version supports JSON output.
+func etcdSnapshotListJSONSupported(kubernetesVersion string) bool {
+	version, err := semver.NewVersion(kubernetesVersion)
+	if err != nil {
+		return false
+	}
+	if version.Minor() > 28 {
+		return true
+	}
+	first, ok := etcdSnapshotListJSONVersions[version.Minor()]
+	return ok && !version.LessThan(first)
+}
+
 // TODO: lol..... this is horrible
 func (p *Planner) addKubeconfigDumpPeriodicInstruction(nodePlan plan.NodePlan) (plan.NodePlan, error) {
 	nodePlan.PeriodicInstructions = append(nodePlan.PeriodicInstructions, plan.PeriodicInstruction{
@@ -4,9 +4,8 @@ import (
 	"testing"
 
 	"github.com/rancher/cluster-api-provider-rancher/pkg"
-	v1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
-	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
-	"github.com/rancher/rancher/pkg/provisioningv2/image"
+	v1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -95,7 +94,7 @@ func TestPlanner_generateInstallInstruction(t *testing.T) {
 			entry := createTestPlanEntry(tt.args.os)
 			var planner Planner
 			planner.retrievalFunctions.SystemAgentImage = func() string { return tt.args.image }
-			planner.retrievalFunctions.ImageResolver = image.ResolveWithControlPlane
+			planner.retrievalFunctions.ImageResolver = resolveWithControlPlane
 			// act
 			p := planner.generateInstallInstruction(controlPlane, entry, []string{})
 
@@ -161,7 +160,7 @@ func TestPlanner_addInstallInstructionWithRestartStamp(t *testing.T) {
 			a := assert.New(t)
 			var planner Planner
 			planner.retrievalFunctions.SystemAgentImage = func() string { return tt.args.image }
-			planner.retrievalFunctions.ImageResolver = image.ResolveWithControlPlane
+			planner.retrievalFunctions.ImageResolver = resolveWithControlPlane
 			controlPlane := createTestControlPlane(tt.args.version)
 			entry := createTestPlanEntry(tt.args.os)
 
@@ -236,7 +235,7 @@ func TestPlanner_generateInstallInstructionWithSkipStart(t *testing.T) {
 			a := assert.New(t)
 			var planner Planner
 			planner.retrievalFunctions.SystemAgentImage = func() string { return tt.args.image }
-			planner.retrievalFunctions.ImageResolver = image.ResolveWithControlPlane
+			planner.retrievalFunctions.ImageResolver = resolveWithControlPlane
 			controlPlane := createTestControlPlane(tt.args.version)
 			entry := createTestPlanEntry(tt.args.os)
 
@@ -258,3 +257,24 @@ func TestPlanner_generateInstallInstructionWithSkipStart(t *testing.T) {
 		})
 	}
 }
+
+func TestEtcdSnapshotListJSONSupported(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{version: "v1.24.17+rke2r1", expected: false},
+		{version: "v1.25.14+k3s1", expected: false},
+		{version: "v1.25.15+k3s1", expected: true},
+		{version: "v1.27.6+rke2r1", expected: false},
+		{version: "v1.27.7+rke2r1", expected: true},
+		{version: "v1.28.3+k3s2", expected: true},
+		{version: "v1.30.4+rke2r1", expected: true},
+		{version: "invalid", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			assert.Equal(t, tt.expected, etcdSnapshotListJSONSupported(tt.version))
+		})
+	}
+}
@@ -0,0 +1,475 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/wrangler/v3/pkg/name"
+	"github.com/rancher/wrangler/v3/pkg/randomtoken"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// the new tokens are kept in the RKE state secret until the server token was rotated by the distro
+	pendingServerTokenKey = "pendingServerToken"
+	pendingAgentTokenKey  = "pendingAgentToken"
+
+	// joinTokenRotationGenerationAnnotation is the generation of the join token rotation whose tokens were applied to the
+	// RKE state secret.
+	joinTokenRotationGenerationAnnotation = "rke.cattle.io/join-token-rotation-generation"
+
+	// replacedJoinTokensKey is the key of the RKE state secret that records the tokens that were replaced by join token
+	// rotations and etcd snapshot restores, as the bootstrap data of an etcd snapshot can only be decrypted with the
+	// server token it was taken with.
+	replacedJoinTokensKey = "replacedJoinTokens"
+
+	// maxReplacedJoinTokens is the number of replaced tokens that are recorded in the RKE state secret. Older tokens are
+	// pruned, so that a leaked token is not kept indefinitely.
+	maxReplacedJoinTokens = 5
+)
+
+// replacedJoinTokens are the tokens of the cluster until they were replaced at the given time. The tokens are empty if
+// they were pruned.
+type replacedJoinTokens struct {
+	ReplacedAt  time.Time `json:"replacedAt"`
+	ServerToken string    `json:"serverToken"`
+	AgentToken  string    `json:"agentToken"`
+}
+
+func rkeStateSecretName(controlPlane *rkev1.RKEControlPlane) string {
+	return name.SafeConcatName(controlPlane.Name, "rke", "state")
+}
+
+func (p *Planner) setJoinTokenRotationState(status rkev1.RKEControlPlaneStatus, rotation *rkev1.RotateJoinToken, phase rkev1.RotateJoinTokenPhase) (rkev1.RKEControlPlaneStatus, error) {
+	if equality.Semantic.DeepEqual(status.RotateJoinToken, rotation) && status.RotateJoinTokenPhase == phase {
+		return status, nil
+	}
+	status.RotateJoinToken = rotation
+	status.RotateJoinTokenPhase = phase
+	return status, errWaiting("refreshing join token rotation state")
+}
+
+func (p *Planner) resetJoinTokenRotationState(status rkev1.RKEControlPlaneStatus) (rkev1.RKEControlPlaneStatus, error) {
+	if status.RotateJoinToken == nil && status.RotateJoinTokenPhase == "" {
+		return status, nil
+	}
+	return p.setJoinTokenRotationState(status, nil, "")
+}
+
+func (p *Planner) startOrRestartJoinTokenRotation(status rkev1.RKEControlPlaneStatus, rotation *rkev1.RotateJoinToken) (rkev1.RKEControlPlaneStatus, error) {
+	if status.RotateJoinToken == nil || !equality.Semantic.DeepEqual(rotation, status.RotateJoinToken) {
+		return p.setJoinTokenRotationState(status, rotation, rkev1.RotateJoinTokenPhasePrepare)
+	}
+	return status, nil
+}
+
+// rotateJoinToken replaces the server and agent token of the controlplane when the generation of the join token
+// rotation changes. The phases are in order:
+// Prepare -> New tokens are generated and stored as pending tokens in the RKE state secret.
+// Rotate -> The server token is rotated to the pending server token on the init node with the token rotate command of
+// the distro, and the pending tokens replace the tokens in the RKE state secret.
+// RestartServers -> The servers are restarted one at a time with the new tokens, which invalidates the previous agent
+// token.
+// RestartAgents -> The agents are restarted one at a time with the new agent token.
+// Done -> All machines were restarted.
+// The machines are not drained, as restarting the distro does not restart the workloads.
+func (p *Planner) rotateJoinToken(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan) (rkev1.RKEControlPlaneStatus, error) {
+	if controlPlane.Spec.RotateJoinToken == nil {
+		return p.resetJoinTokenRotationState(status)
+	}
+
+	if !status.Initialized {
+		logrus.Warnf("[planner] rkecluster %s/%s: skipping join token rotation as cluster was not initialized", controlPlane.Namespace, controlPlane.Name)
+		return status, nil
+	}
+
+	rotation := controlPlane.Spec.RotateJoinToken
+
+	var err error
+	if status, err = p.startOrRestartJoinTokenRotation(status, rotation); err != nil {
+		return status, err
+	}
+
+	switch status.RotateJoinTokenPhase {
+	case rkev1.RotateJoinTokenPhaseDone, rkev1.RotateJoinTokenPhaseFailed:
+		return status, nil
+	}
+
+	if tokensSecret.ServerToken == "" {
+		logrus.Errorf("[planner] rkecluster %s/%s: join token can not be rotated as the tokens of the cluster are not managed", controlPlane.Namespace, controlPlane.Name)
+		return p.setJoinTokenRotationState(status, rotation, rkev1.RotateJoinTokenPhaseFailed)
+	}
+
+	found, joinServer, initNode, err := p.findInitNode(controlPlane, clusterPlan)
+	if err != nil {
+		logrus.Errorf("[planner] rkecluster %s/%s: error encountered while searching for init node during join token rotation: %v", controlPlane.Namespace, controlPlane.Name, err)
+		return status, err
+	}
+	if !found || joinServer == "" {
+		logrus.Warnf("[planner] rkecluster %s/%s: skipping join token rotation as cluster does not have an init node", controlPlane.Namespace, controlPlane.Name)
+		return status, nil
+	}
+
+	switch status.RotateJoinTokenPhase {
+	case rkev1.RotateJoinTokenPhasePrepare:
+		if err := p.pauseCAPICluster(controlPlane, true); err != nil {
+			return status, errWaiting("pausing CAPI cluster")
+		}
+		if err := p.ensurePendingJoinTokens(controlPlane, rotation.Generation); err != nil {
+			return status, err
+		}
+		return p.setJoinTokenRotationState(status, rotation, rkev1.RotateJoinTokenPhaseRotate)
+	case rkev1.RotateJoinTokenPhaseRotate:
+		secret, err := p.secretCache.Get(controlPlane.Namespace, rkeStateSecretName(controlPlane))
+		if err != nil {
+			return status, err
+		}
+		if !joinTokensApplied(secret, rotation.Generation) {
+			pendingServerToken := string(secret.Data[pendingServerTokenKey])
+			if pendingServerToken == "" || len(secret.Data[pendingAgentTokenKey]) == 0 {
+				return status, errWaiting("waiting for pending join tokens")
+			}
+			rotatePlan, _, joinedServer, err := p.generatePlanWithConfigFiles(controlPlane, tokensSecret, initNode, joinServer, true)
+			if err != nil {
+				return status, err
+			}
+			rotatePlan.Instructions = append(rotatePlan.Instructions, tokenRotateInstruction(
+				controlPlane,
+				"join-token-rotation/rotate",
+				strconv.FormatInt(rotation.Generation, 10),
+				tokensSecret.ServerToken,
+				pendingServerToken))
+			if err := assignAndCheckPlan(p.store, fmt.Sprintf("join token rotation [%s] for machine [%s]", status.RotateJoinTokenPhase, initNode.Machine.Name), initNode, rotatePlan, joinedServer, 1, 1); err != nil {
+				return p.joinTokenRotationFailed(controlPlane, status, initNode, err)
+			}
+			if err := p.applyPendingJoinTokens(secret, rotation.Generation, time.Now()); err != nil {
+				return status, err
+			}
+		}
+		// the pending tokens were applied, the machines are restarted with them once the RKE state secret is reloaded
+		return p.setJoinTokenRotationState(status, rotation, rkev1.RotateJoinTokenPhaseRestartServers)
+	case rkev1.RotateJoinTokenPhaseRestartServers:
+		if err := p.waitForRotatedJoinTokens(controlPlane, tokensSecret); err != nil {
+			return status, err
+		}
+		for _, entry := range collectOrderedCertificateRotationEntries(clusterPlan) {
+			if isOnlyWorker(entry) {
+				continue
+			}
+			if status, err = p.joinTokenRotationRestart(controlPlane, status, tokensSecret, entry, joinServer); err != nil {
+				return status, err
+			}
+		}
+		return p.setJoinTokenRotationState(status, rotation, rkev1.RotateJoinTokenPhaseRestartAgents)
+	case rkev1.RotateJoinTokenPhaseRestartAgents:
+		if err := p.waitForRotatedJoinTokens(controlPlane, tokensSecret); err != nil {
+			return status, err
+		}
+		for _, entry := range collect(clusterPlan, isOnlyWorker) {
+			if status, err = p.joinTokenRotationRestart(controlPlane, status, tokensSecret, entry, joinServer); err != nil {
+				return status, err
+			}
+		}
+		if err := p.pauseCAPICluster(controlPlane, false); err != nil {
+			return status, errWaiting("unpausing CAPI cluster")
+		}
+		logrus.Infof("[planner] rkecluster %s/%s: join token rotation finished", controlPlane.Namespace, controlPlane.Name)
+		return p.setJoinTokenRotationState(status, rotation, rkev1.RotateJoinTokenPhaseDone)
+	default:
+		return p.setJoinTokenRotationState(status, rotation, rkev1.RotateJoinTokenPhasePrepare)
+	}
+}
+
+// joinTokenRotationFailed sets the phase of the join token rotation to failed if the plan of the given machine failed,
+// otherwise the error is returned as is.
+func (p *Planner) joinTokenRotationFailed(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, entry *planEntry, err error) (rkev1.RKEControlPlaneStatus, error) {
+	if IsErrWaiting(err) {
+		return status, err
+	}
+	logrus.Errorf("[planner] rkecluster %s/%s: join token rotation failed on machine %s: %v", controlPlane.Namespace, controlPlane.Name, entry.Machine.Name, err)
+	if pauseErr := p.pauseCAPICluster(controlPlane, false); pauseErr != nil {
+		return status, pauseErr
+	}
+	status, _ = p.setJoinTokenRotationState(status, controlPlane.Spec.RotateJoinToken, rkev1.RotateJoinTokenPhaseFailed)
+	return status, err
+}
+
+// joinTokensApplied returns true if the tokens of the join token rotation with the given generation were applied to the
+// RKE state secret.
+func joinTokensApplied(secret *corev1.Secret, generation int64) bool {
+	return secret.Annotations[joinTokenRotationGenerationAnnotation] == strconv.FormatInt(generation, 10)
+}
+
+// ensurePendingJoinTokens generates the pending tokens of the RKE state secret. Pending tokens of a previous join token
+// rotation are kept, as the distro may already use the pending server token.
+func (p *Planner) ensurePendingJoinTokens(controlPlane *rkev1.RKEControlPlane, generation int64) error {
+	secret, err := p.secretCache.Get(controlPlane.Namespace, rkeStateSecretName(controlPlane))
+	if err != nil {
+		return err
+	}
+	if joinTokensApplied(secret, generation) || (len(secret.Data[pendingServerTokenKey]) > 0 && len(secret.Data[pendingAgentTokenKey]) > 0) {
+		return nil
+	}
+
+	serverToken, err := randomtoken.Generate()
+	if err != nil {
+		return err
+	}
+	agentToken, err := randomtoken.Generate()
+	if err != nil {
+		return err
+	}
+
+	secret = secret.DeepCopy()
+	secret.Data[pendingServerTokenKey] = []byte(serverToken)
+	secret.Data[pendingAgentTokenKey] = []byte(agentToken)
+	_, err = p.secretClient.Update(secret)
+	return err
+}
+
+// applyPendingJoinTokens replaces the tokens of the RKE state secret with the pending tokens, and records the generation
+// of the join token rotation they were applied for.
+func (p *Planner) applyPendingJoinTokens(secret *corev1.Secret, generation int64, now time.Time) error {
+	secret = secret.DeepCopy()
+	if err := replaceJoinTokens(secret, plan.Secret{
+		ServerToken: string(secret.Data[pendingServerTokenKey]),
+		AgentToken:  string(secret.Data[pendingAgentTokenKey]),
+	}, now); err != nil {
+		return err
+	}
+	delete(secret.Data, pendingServerTokenKey)
+	delete(secret.Data, pendingAgentTokenKey)
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[joinTokenRotationGenerationAnnotation] = strconv.FormatInt(generation, 10)
+	_, err := p.secretClient.Update(secret)
+	return err
+}
+
+// replaceJoinTokens replaces the tokens of the given RKE state secret, and records the replaced tokens with the time
+// they were replaced at. Only the most recently replaced tokens are kept, the time the last of the pruned tokens were
+// replaced at is kept without them, so that tokens that were in use before are known to be unavailable.
+func replaceJoinTokens(secret *corev1.Secret, tokens plan.Secret, now time.Time) error {
+	replaced, err := replacedJoinTokensFromSecret(secret)
+	if err != nil {
+		return err
+	}
+	replaced = append(replaced, replacedJoinTokens{
+		ReplacedAt:  now.UTC(),
+		ServerToken: string(secret.Data["serverToken"]),
+		AgentToken:  string(secret.Data["agentToken"]),
+	})
+	if pruned := len(replaced) - maxReplacedJoinTokens + 1; pruned > 1 {
+		replaced = append([]replacedJoinTokens{{ReplacedAt: replaced[pruned-1].ReplacedAt}}, replaced[pruned:]...)
+	}
+	data, err := json.Marshal(replaced)
+	if err != nil {
+		return err
+	}
+	secret.Data[replacedJoinTokensKey] = data
+	secret.Data["serverToken"] = []byte(tokens.ServerToken)
+	secret.Data["agentToken"] = []byte(tokens.AgentToken)
+	return nil
+}
+
+// replacedJoinTokensFromSecret returns the tokens that were replaced in the given RKE state secret, ordered by the time
+// they were replaced at.
+func replacedJoinTokensFromSecret(secret *corev1.Secret) ([]replacedJoinTokens, error) {
+	var replaced []replacedJoinTokens
+	if data := secret.Data[replacedJoinTokensKey]; len(data) > 0 {
+		if err := json.Unmarshal(data, &replaced); err != nil {
+			return nil, fmt.Errorf("failed to parse replaced join tokens of secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+	}
+	sort.SliceStable(replaced, func(i, j int) bool {
+		return replaced[i].ReplacedAt.Before(replaced[j].ReplacedAt)
+	})
+	return replaced, nil
+}
+
+// joinTokensAt returns the tokens of the given RKE state secret that were in use at the given time, which are the tokens
+// that were replaced first after it, or the current tokens if none were replaced since. The current tokens are returned
+// if the time is unknown, and an error if the tokens were pruned.
+func joinTokensAt(secret *corev1.Secret, at *metav1.Time) (plan.Secret, error) {
+	tokens := plan.Secret{
+		ServerToken: string(secret.Data["serverToken"]),
+		AgentToken:  string(secret.Data["agentToken"]),
+	}
+	if at == nil {
+		return tokens, nil
+	}
+	replaced, err := replacedJoinTokensFromSecret(secret)
+	if err != nil {
+		return tokens, err
+	}
+	for _, r := range replaced {
+		if r.ReplacedAt.After(at.Time) {
+			if r.ServerToken == "" {
+				return tokens, fmt.Errorf("join tokens that were in use at %s were pruned from secret %s/%s", at.UTC().Format(time.RFC3339), secret.Namespace, secret.Name)
+			}
+			return plan.Secret{
+				ServerToken: r.ServerToken,
+				AgentToken:  r.AgentToken,
+			}, nil
+		}
+	}
+	return tokens, nil
+}
+
+// ensureEtcdSnapshotJoinTokens replaces the tokens of the cluster with the tokens that were in use when the etcd snapshot
+// was taken if the join token was rotated since, as the bootstrap data of the snapshot can only be decrypted with the
+// server token it was taken with. The replaced tokens are kept as the pending tokens of a join token rotation that is
+// requested in status, which rotates the join token back to them once the snapshot was restored, so that the previous
+// tokens are not used after the restore. An errWaiting is returned until the plans are rendered with the tokens of the
+// snapshot.
+func (p *Planner) ensureEtcdSnapshotJoinTokens(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, snapshot *rkev1.ETCDSnapshot, tokensSecret plan.Secret) (rkev1.RKEControlPlaneStatus, error) {
+	if snapshot == nil || tokensSecret.ServerToken == "" {
+		return status, nil
+	}
+	secret, err := p.secretCache.Get(controlPlane.Namespace, rkeStateSecretName(controlPlane))
+	if err != nil {
+		return status, err
+	}
+	tokens, err := joinTokensAt(secret, snapshot.SnapshotFile.CreatedAt)
+	if err != nil {
+		return status, err
+	}
+	if string(secret.Data["serverToken"]) != tokens.ServerToken {
+		logrus.Infof("[planner] rkecluster %s/%s: restoring join tokens of etcd snapshot %s/%s as the join token was rotated since it was taken", controlPlane.Namespace, controlPlane.Name, snapshot.Namespace, snapshot.Name)
+		secret = secret.DeepCopy()
+		if len(secret.Data[pendingServerTokenKey]) == 0 || len(secret.Data[pendingAgentTokenKey]) == 0 {
+			secret.Data[pendingServerTokenKey] = secret.Data["serverToken"]
+			secret.Data[pendingAgentTokenKey] = secret.Data["agentToken"]
+		}
+		if err := replaceJoinTokens(secret, tokens, time.Now()); err != nil {
+			return status, err
+		}
+		if _, err := p.secretClient.Update(secret); err != nil {
+			return status, err
+		}
+	}
+	if len(secret.Data[pendingServerTokenKey]) > 0 && !joinTokenRotationInProgress(controlPlane, status) {
+		rotation := &rkev1.RotateJoinToken{Generation: 1}
+		if current := joinTokenRotation(controlPlane, status); current != nil {
+			rotation.Generation = current.Generation + 1
+		}
+		logrus.Infof("[planner] rkecluster %s/%s: requesting join token rotation with generation %d after etcd snapshot %s/%s was restored", controlPlane.Namespace, controlPlane.Name, rotation.Generation, snapshot.Namespace, snapshot.Name)
+		status.RotateJoinToken = rotation
+		status.RotateJoinTokenPhase = rkev1.RotateJoinTokenPhasePrepare
+	}
+	if tokens.ServerToken == tokensSecret.ServerToken {
+		return status, nil
+	}
+	return status, errWaitingf("waiting for join tokens of etcd snapshot %s/%s", snapshot.Namespace, snapshot.Name)
+}
+
+// joinTokenRotation returns the join token rotation that is run for the controlplane. This is the join token rotation
+// of the spec, unless a newer join token rotation was requested in status by an etcd snapshot restore.
+func joinTokenRotation(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) *rkev1.RotateJoinToken {
+	rotation := controlPlane.Spec.RotateJoinToken
+	if status.RotateJoinToken != nil && (rotation == nil || rotation.Generation < status.RotateJoinToken.Generation) {
+		return status.RotateJoinToken
+	}
+	return rotation
+}
+
+// withJoinTokenRotation returns the controlplane with the join token rotation that is run for it set in its spec. The
+// returned controlplane is only used to rotate the join token, and must never be updated.
+func withJoinTokenRotation(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) *rkev1.RKEControlPlane {
+	rotation := joinTokenRotation(controlPlane, status)
+	if rotation == controlPlane.Spec.RotateJoinToken {
+		return controlPlane
+	}
+	controlPlane = controlPlane.DeepCopy()
+	controlPlane.Spec.RotateJoinToken = rotation.DeepCopy()
+	return controlPlane
+}
+
+// joinTokenRotationInProgress returns true if the join token rotation that is run for the controlplane has not finished
+// or failed yet.
+func joinTokenRotationInProgress(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) bool {
+	rotation := joinTokenRotation(controlPlane, status)
+	if rotation == nil {
+		return false
+	}
+	if !equality.Semantic.DeepEqual(rotation, status.RotateJoinToken) {
+		return true
+	}
+	return status.RotateJoinTokenPhase != rkev1.RotateJoinTokenPhaseDone && status.RotateJoinTokenPhase != rkev1.RotateJoinTokenPhaseFailed
+}
+
+// tokenRotateInstruction returns an idempotent instruction that rotates the server token of the distro. The tokens are
+// passed as environment variables, so that they are not exposed in the arguments of the process.
+func tokenRotateInstruction(controlPlane *rkev1.RKEControlPlane, identifier, value, token, newToken string) plan.OneTimeInstruction {
+	runtimeEnv := capr.GetRuntimeEnv(controlPlane.Spec.KubernetesVersion)
+	return idempotentInstruction(
+		controlPlane,
+		identifier,
+		value,
+		capr.GetRuntimeCommand(controlPlane.Spec.KubernetesVersion),
+		[]string{
+			"token",
+			"rotate",
+		},
+		[]string{
+			fmt.Sprintf("%s_TOKEN=%s", runtimeEnv, token),
+			fmt.Sprintf("%s_NEW_TOKEN=%s", runtimeEnv, newToken),
+		})
+}
+
+// waitForRotatedJoinTokens returns an errWaiting until the tokens the plans are rendered with are the tokens that were
+// applied to the RKE state secret by the join token rotation, as the restart instructions only run once per rotation.
+func (p *Planner) waitForRotatedJoinTokens(controlPlane *rkev1.RKEControlPlane, tokensSecret plan.Secret) error {
+	secret, err := p.secretCache.Get(controlPlane.Namespace, rkeStateSecretName(controlPlane))
+	if err != nil {
+		return err
+	}
+	if !joinTokensApplied(secret, controlPlane.Spec.RotateJoinToken.Generation) || string(secret.Data["serverToken"]) != tokensSecret.ServerToken {
+		return errWaiting("waiting for rotated join tokens")
+	}
+	return nil
+}
+
+// joinTokenRotationRestart restarts the distro on the given machine with the current tokens and waits for its probes to
+// pass.
+func (p *Planner) joinTokenRotationRestart(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, entry *planEntry, joinServer string) (rkev1.RKEControlPlaneStatus, error) {
+	if isOnlyWorker(entry) {
+		// Don't overwrite the joinURL annotation.
+		joinServer = ""
+	}
+	restartPlan, config, joinedServer, err := p.generatePlanWithConfigFiles(controlPlane, tokensSecret, entry, joinServer, true)
+	if err != nil {
+		return status, err
+	}
+
+	unit := capr.GetRuntimeServerUnit(controlPlane.Spec.KubernetesVersion)
+	if isOnlyWorker(entry) {
+		unit = capr.GetRuntimeAgentUnit(controlPlane.Spec.KubernetesVersion)
+	}
+	restartPlan.Instructions = append(restartPlan.Instructions, idempotentRestartInstructions(
+		controlPlane,
+		"join-token-rotation/restart",
+		strconv.FormatInt(controlPlane.Spec.RotateJoinToken.Generation, 10),
+		unit)...)
+
+	probes, err := p.generateProbes(controlPlane, entry, config)
+	if err != nil {
+		return status, err
+	}
+	restartPlan.Probes = probes
+
+	if err := assignAndCheckPlan(p.store, fmt.Sprintf("join token rotation [%s] for machine [%s]", status.RotateJoinTokenPhase, entry.Machine.Name), entry, restartPlan, joinedServer, 5, 5); err != nil {
+		return p.joinTokenRotationFailed(controlPlane, status, entry, err)
+	}
+	return status, nil
+}
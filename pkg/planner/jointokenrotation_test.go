@@ -0,0 +1,208 @@
+package planner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRotateJoinTokenState(t *testing.T) {
+	p := &Planner{}
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.RotateJoinToken = &rkev1.RotateJoinToken{Generation: 2}
+	status := rkev1.RKEControlPlaneStatus{
+		Initialized:          true,
+		RotateJoinToken:      &rkev1.RotateJoinToken{Generation: 1},
+		RotateJoinTokenPhase: rkev1.RotateJoinTokenPhaseDone,
+	}
+
+	// a new generation restarts the rotation
+	status, err := p.rotateJoinToken(cp, status, plan.Secret{}, nil)
+	assert.True(t, IsErrWaiting(err))
+	assert.Equal(t, cp.Spec.RotateJoinToken, status.RotateJoinToken)
+	assert.Equal(t, rkev1.RotateJoinTokenPhasePrepare, status.RotateJoinTokenPhase)
+
+	// the rotation fails if the tokens are not managed
+	status, err = p.rotateJoinToken(cp, status, plan.Secret{}, nil)
+	assert.True(t, IsErrWaiting(err))
+	assert.Equal(t, rkev1.RotateJoinTokenPhaseFailed, status.RotateJoinTokenPhase)
+
+	// a finished rotation is not repeated
+	status, err = p.rotateJoinToken(cp, status, plan.Secret{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, rkev1.RotateJoinTokenPhaseFailed, status.RotateJoinTokenPhase)
+
+	// removing the rotation resets the state
+	cp.Spec.RotateJoinToken = nil
+	status, err = p.rotateJoinToken(cp, status, plan.Secret{}, nil)
+	assert.True(t, IsErrWaiting(err))
+	assert.Nil(t, status.RotateJoinToken)
+	assert.Empty(t, status.RotateJoinTokenPhase)
+}
+
+func TestJoinTokensApplied(t *testing.T) {
+	secret := &corev1.Secret{}
+	assert.False(t, joinTokensApplied(secret, 1))
+	secret.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{joinTokenRotationGenerationAnnotation: "1"}}
+	assert.True(t, joinTokensApplied(secret, 1))
+	assert.False(t, joinTokensApplied(secret, 2))
+}
+
+func TestJoinTokensAt(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			"serverToken": []byte("server-1"),
+			"agentToken":  []byte("agent-1"),
+		},
+	}
+	at := func(d time.Duration) *metav1.Time {
+		return &metav1.Time{Time: created.Add(d)}
+	}
+
+	tokens, err := joinTokensAt(secret, at(0))
+	assert.NoError(t, err)
+	assert.Equal(t, plan.Secret{ServerToken: "server-1", AgentToken: "agent-1"}, tokens)
+
+	// the join token is rotated twice
+	assert.NoError(t, replaceJoinTokens(secret, plan.Secret{ServerToken: "server-2", AgentToken: "agent-2"}, created.Add(time.Hour)))
+	assert.NoError(t, replaceJoinTokens(secret, plan.Secret{ServerToken: "server-3", AgentToken: "agent-3"}, created.Add(2*time.Hour)))
+	assert.Equal(t, "server-3", string(secret.Data["serverToken"]))
+	assert.Equal(t, "agent-3", string(secret.Data["agentToken"]))
+
+	tests := []struct {
+		name     string
+		at       *metav1.Time
+		expected string
+	}{
+		{name: "unknown time", expected: "server-3"},
+		{name: "before the first rotation", at: at(30 * time.Minute), expected: "server-1"},
+		{name: "between the rotations", at: at(90 * time.Minute), expected: "server-2"},
+		{name: "after the rotations", at: at(3 * time.Hour), expected: "server-3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := joinTokensAt(secret, tt.at)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, tokens.ServerToken)
+			assert.Equal(t, strings.Replace(tt.expected, "server", "agent", 1), tokens.AgentToken)
+		})
+	}
+
+	// restoring a snapshot taken before the first rotation reverts the tokens, the snapshots taken since can still be
+	// restored with the tokens they were taken with
+	tokens, err = joinTokensAt(secret, at(30*time.Minute))
+	assert.NoError(t, err)
+	assert.NoError(t, replaceJoinTokens(secret, tokens, created.Add(4*time.Hour)))
+	for d, expected := range map[time.Duration]string{
+		30 * time.Minute: "server-1",
+		90 * time.Minute: "server-2",
+		3 * time.Hour:    "server-3",
+		5 * time.Hour:    "server-1",
+	} {
+		tokens, err := joinTokensAt(secret, at(d))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, tokens.ServerToken)
+	}
+}
+
+func TestReplaceJoinTokensPrunes(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			"serverToken": []byte("server-0"),
+			"agentToken":  []byte("agent-0"),
+		},
+	}
+	for i := 1; i <= maxReplacedJoinTokens+2; i++ {
+		assert.NoError(t, replaceJoinTokens(secret, plan.Secret{
+			ServerToken: fmt.Sprintf("server-%d", i),
+			AgentToken:  fmt.Sprintf("agent-%d", i),
+		}, created.Add(time.Duration(i)*time.Hour)))
+	}
+
+	replaced, err := replacedJoinTokensFromSecret(secret)
+	assert.NoError(t, err)
+	if assert.Len(t, replaced, maxReplacedJoinTokens) {
+		// only the time the last pruned tokens were replaced at is kept
+		assert.Equal(t, replacedJoinTokens{ReplacedAt: created.Add(3 * time.Hour)}, replaced[0])
+		assert.Equal(t, "server-3", replaced[1].ServerToken)
+	}
+
+	_, err = joinTokensAt(secret, &metav1.Time{Time: created.Add(90 * time.Minute)})
+	assert.Error(t, err)
+	_, err = joinTokensAt(secret, &metav1.Time{Time: created.Add(150 * time.Minute)})
+	assert.Error(t, err)
+	tokens, err := joinTokensAt(secret, &metav1.Time{Time: created.Add(210 * time.Minute)})
+	assert.NoError(t, err)
+	assert.Equal(t, "server-3", tokens.ServerToken)
+}
+
+func TestEnsureEtcdSnapshotJoinTokens(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "test-rke-state"},
+		Data: map[string][]byte{
+			"serverToken": []byte("server-1"),
+			"agentToken":  []byte("agent-1"),
+		},
+	}
+	assert.NoError(t, replaceJoinTokens(secret, plan.Secret{ServerToken: "server-2", AgentToken: "agent-2"}, created.Add(time.Hour)))
+
+	cp := &rkev1.RKEControlPlane{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "test"}}
+	cp.Spec.RotateJoinToken = &rkev1.RotateJoinToken{Generation: 3}
+	status := rkev1.RKEControlPlaneStatus{
+		RotateJoinToken:      &rkev1.RotateJoinToken{Generation: 3},
+		RotateJoinTokenPhase: rkev1.RotateJoinTokenPhaseDone,
+	}
+	snapshot := &rkev1.ETCDSnapshot{
+		ObjectMeta:   metav1.ObjectMeta{Namespace: "fleet-default", Name: "snapshot"},
+		SnapshotFile: rkev1.ETCDSnapshotFile{CreatedAt: &metav1.Time{Time: created.Add(30 * time.Minute)}},
+	}
+
+	mp := newMockPlanner(t, InfoFunctions{})
+	mp.secretCache.EXPECT().Get("fleet-default", "test-rke-state").DoAndReturn(func(string, string) (*corev1.Secret, error) {
+		return secret, nil
+	}).AnyTimes()
+	mp.secretClient.EXPECT().Update(gomock.Any()).DoAndReturn(func(updated *corev1.Secret) (*corev1.Secret, error) {
+		secret = updated
+		return updated, nil
+	})
+
+	// the snapshot is restored with the tokens it was taken with, and the current tokens are kept as the pending tokens
+	// of a join token rotation that is requested in status
+	status, err := mp.planner.ensureEtcdSnapshotJoinTokens(cp, status, snapshot, plan.Secret{ServerToken: "server-2"})
+	assert.True(t, IsErrWaiting(err))
+	assert.Equal(t, "server-1", string(secret.Data["serverToken"]))
+	assert.Equal(t, "server-2", string(secret.Data[pendingServerTokenKey]))
+	assert.Equal(t, "agent-2", string(secret.Data[pendingAgentTokenKey]))
+	assert.Equal(t, &rkev1.RotateJoinToken{Generation: 4}, status.RotateJoinToken)
+	assert.Equal(t, rkev1.RotateJoinTokenPhasePrepare, status.RotateJoinTokenPhase)
+	assert.Equal(t, &rkev1.RotateJoinToken{Generation: 4}, withJoinTokenRotation(cp, status).Spec.RotateJoinToken)
+	assert.Equal(t, int64(3), cp.Spec.RotateJoinToken.Generation)
+
+	// once the plans are rendered with the tokens of the snapshot, the requested rotation is not requested again
+	status, err = mp.planner.ensureEtcdSnapshotJoinTokens(cp, status, snapshot, plan.Secret{ServerToken: "server-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, &rkev1.RotateJoinToken{Generation: 4}, status.RotateJoinToken)
+}
+
+func TestTokenRotateInstruction(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.KubernetesVersion = "v1.28.5+rke2r1"
+
+	instruction := tokenRotateInstruction(cp, "join-token-rotation/rotate", "1", "old-token", "new-token")
+	assert.Equal(t, []string{"token", "rotate"}, instruction.Args[len(instruction.Args)-2:])
+	assert.Equal(t, []string{"RKE2_TOKEN=old-token", "RKE2_NEW_TOKEN=new-token"}, instruction.Env)
+	for _, arg := range instruction.Args {
+		assert.NotContains(t, arg, "token=")
+	}
+}
@@ -23,7 +23,6 @@ import (
 	capicontrollers "github.com/rancher/cluster-api-provider-rancher/pkg/generated/controllers/cluster.x-k8s.io/v1beta1"
 	rkecontrollers "github.com/rancher/cluster-api-provider-rancher/pkg/generated/controllers/rke.cattle.io/v1"
 	corecontrollers "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
-	"github.com/rancher/wrangler/v3/pkg/name"
 	"github.com/rancher/wrangler/v3/pkg/randomtoken"
 	"github.com/rancher/wrangler/v3/pkg/summary"
 	"github.com/sirupsen/logrus"
@@ -56,6 +55,7 @@ const (
 	TLSCertFileArgument                           = "tls-cert-file"
 
 	authnWebhookFileName = "kube-api-authn-webhook.yaml"
+	oidcCAFileName       = "kube-api-oidc-ca.crt"
 	ConfigYamlFileName   = "/etc/rancher/%s/config.yaml.d/50-rancher.yaml"
 
 	bootstrapTier    = "bootstrap"
@@ -174,7 +174,7 @@ func (p *Planner) setMachineConditionStatus(clusterPlan *plan.Plan, machineNames
 			if capr.Reconciled.GetMessage(machine) == msg {
 				continue
 			}
-			conditions.MarkUnknown(machine, capi.ConditionType(capr.Reconciled), "Waiting", msg)
+			conditions.MarkUnknown(machine, capi.ConditionType(capr.Reconciled), "Waiting", "%s", msg)
 		} else if !capr.Reconciled.IsTrue(machine) {
 			// Since there is no status message, then the condition should be set to true.
 			conditions.MarkTrue(machine, capi.ConditionType(capr.Reconciled))
@@ -249,6 +249,12 @@ func (p *Planner) Process(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlan
 		return status, err
 	}
 
+	// The etcd plane is recovered before the sanity of the cluster is checked, as the lost etcd machines have to be
+	// replaced for the cluster to become sane.
+	if status, err = p.recoverEtcd(cp, status, plan); err != nil {
+		return status, err
+	}
+
 	// Check for cluster sanity to ensure we can properly deliver plans to this cluster.
 	if !clusterIsSane(plan) {
 		// Set the Stable condition on the controlplane to False. This will be used to indicate that the Ready condition
@@ -291,11 +297,31 @@ func (p *Planner) Process(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlan
 		return status, err
 	}
 
-	if status, err = p.createEtcdSnapshot(cp, status, clusterSecretTokens, plan); err != nil {
+	if status, err = p.bootstrapEtcdSnapshot(cp, status, clusterSecretTokens, plan); err != nil {
+		return status, err
+	}
+
+	if status, err = p.createEtcdSnapshot(withEtcdSnapshotCreate(cp, status), status, clusterSecretTokens, plan); err != nil {
+		return status, err
+	}
+
+	if status, err = p.snapshotBeforeChange(cp, status); err != nil {
+		return status, err
+	}
+
+	if status, err = p.uploadEtcdSnapshots(cp, status, clusterSecretTokens, plan); err != nil {
+		return status, err
+	}
+
+	if status, err = p.restoreEtcdSnapshot(withEtcdSnapshotRestore(cp, status), status, clusterSecretTokens, plan, currentVersion); err != nil {
+		return status, err
+	}
+
+	if status, err = p.maintainEtcd(cp, status, clusterSecretTokens, plan); err != nil {
 		return status, err
 	}
 
-	if status, err = p.restoreEtcdSnapshot(cp, status, clusterSecretTokens, plan, currentVersion); err != nil {
+	if status, err = p.rotateCertificateAuthorities(cp, status, clusterSecretTokens, plan); err != nil {
 		return status, err
 	}
 
@@ -303,10 +329,18 @@ func (p *Planner) Process(cp *rkev1.RKEControlPlane, status rkev1.RKEControlPlan
 		return status, err
 	}
 
+	if status, err = p.changeSecretsEncryption(cp, status, clusterSecretTokens, plan, releaseData); err != nil {
+		return status, err
+	}
+
 	if status, err = p.rotateEncryptionKeys(cp, status, clusterSecretTokens, plan, releaseData); err != nil {
 		return status, err
 	}
 
+	if status, err = p.rotateJoinToken(withJoinTokenRotation(cp, status), status, clusterSecretTokens, plan); err != nil {
+		return status, err
+	}
+
 	// pausing the control plane only affects machine reconciliation: etcd snapshot/restore, encryption key & cert
 	// rotation are not interruptable processes, and therefore must always be completed when requested
 	if capiannotations.IsPaused(capiCluster, cp) {
@@ -1032,6 +1066,21 @@ func (p *Planner) generatePlanWithConfigFiles(controlPlane *rkev1.RKEControlPlan
 			return nodePlan, config, joinedServer, err
 		}
 
+		nodePlan, err = p.addCertificateAuthorityFiles(nodePlan, controlPlane, entry)
+		if err != nil {
+			return nodePlan, config, joinedServer, err
+		}
+
+		nodePlan, err = addSecretsEncryptionKMSFiles(nodePlan, controlPlane, entry)
+		if err != nil {
+			return nodePlan, config, joinedServer, err
+		}
+
+		nodePlan, err = addHardeningProfileFiles(nodePlan, controlPlane, entry)
+		if err != nil {
+			return nodePlan, config, joinedServer, err
+		}
+
 		nodePlan, err = addOtherFiles(nodePlan, controlPlane, entry)
 
 		idempotentScriptFile := plan.File{
@@ -1083,17 +1132,39 @@ func (p *Planner) desiredPlan(controlPlane *rkev1.RKEControlPlane, tokensSecret
 		}
 	}
 
+	if !windows(entry) {
+		nodePlan, err = p.addCertificateExpiryPeriodicInstruction(nodePlan, controlPlane)
+		if err != nil {
+			return nodePlan, joinedTo, err
+		}
+	}
+
 	if isEtcd(entry) {
 		nodePlan, err = p.addEtcdSnapshotListLocalPeriodicInstruction(nodePlan, controlPlane)
 		if err != nil {
 			return nodePlan, joinedTo, err
 		}
+		if capr.GetRuntime(controlPlane.Spec.KubernetesVersion) == capr.RuntimeRKE2 {
+			// etcdctl is run in the etcd container, which k3s doesn't have as etcd is embedded
+			nodePlan, err = p.addEtcdEndpointStatusPeriodicInstruction(nodePlan, controlPlane)
+			if err != nil {
+				return nodePlan, joinedTo, err
+			}
+		}
 		if controlPlane != nil && controlPlane.Spec.ETCD != nil && S3Enabled(controlPlane.Spec.ETCD.S3) && isInitNode(entry) {
 			nodePlan, err = p.addEtcdSnapshotListS3PeriodicInstruction(nodePlan, controlPlane)
 			if err != nil {
 				return nodePlan, joinedTo, err
 			}
 		}
+		if controlPlane != nil && controlPlane.Spec.ETCD != nil && isInitNode(entry) {
+			for i := range controlPlane.Spec.ETCD.S3Targets {
+				nodePlan, err = p.addEtcdSnapshotListS3TargetPeriodicInstruction(nodePlan, controlPlane, &controlPlane.Spec.ETCD.S3Targets[i])
+				if err != nil {
+					return nodePlan, joinedTo, err
+				}
+			}
+		}
 	}
 
 	return nodePlan, joinedTo, nil
@@ -1113,18 +1184,13 @@ func (p *Planner) ensureRKEStateSecret(controlPlane *rkev1.RKEControlPlane, newC
 		return "", plan.Secret{}, nil
 	}
 
-	name := name.SafeConcatName(controlPlane.Name, "rke", "state")
+	name := rkeStateSecretName(controlPlane)
 	secret, err := p.secretCache.Get(controlPlane.Namespace, name)
 	if apierror.IsNotFound(err) {
 		if !newCluster {
 			return "", plan.Secret{}, fmt.Errorf("newCluster was false and secret does not exist: %w", err)
 		}
-		serverToken, err := randomtoken.Generate()
-		if err != nil {
-			return "", plan.Secret{}, err
-		}
-
-		agentToken, err := randomtoken.Generate()
+		serverToken, agentToken, err := p.generateClusterTokens(controlPlane)
 		if err != nil {
 			return "", plan.Secret{}, err
 		}
@@ -1168,6 +1234,30 @@ func (p *Planner) ensureRKEStateSecret(controlPlane *rkev1.RKEControlPlane, newC
 	}, nil
 }
 
+// generateClusterTokens generates the server and agent token of a new cluster. If the cluster is bootstrapped from an
+// etcd snapshot, the tokens of the cluster the snapshot was taken from are used.
+func (p *Planner) generateClusterTokens(controlPlane *rkev1.RKEControlPlane) (string, string, error) {
+	if controlPlane.Spec.ETCDSnapshotBootstrap != nil {
+		sourceTokens, err := p.etcdSnapshotBootstrapTokens(controlPlane)
+		if err != nil {
+			return "", "", err
+		}
+		tokens, err := bootstrapClusterTokens(sourceTokens, controlPlane.Spec.ETCDSnapshotBootstrap.RotateToken)
+		return tokens.ServerToken, tokens.AgentToken, err
+	}
+
+	serverToken, err := randomtoken.Generate()
+	if err != nil {
+		return "", "", err
+	}
+
+	agentToken, err := randomtoken.Generate()
+	if err != nil {
+		return "", "", err
+	}
+	return serverToken, agentToken, nil
+}
+
 // pauseCAPICluster reconciles the given boolean to the owning CAPI cluster. Notably, it retries if there is a conflict
 // editing the CAPI cluster as there are many controllers that may be racing to edit the object, but there is only one
 // controller that should actively be toggling the paused field on the CAPI cluster object.
@@ -4,17 +4,16 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"path"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/rancher/channelserver/pkg/model"
 	"github.com/rancher/cluster-api-provider-rancher/pkg"
-	apisv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
-	apisv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
-	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
-	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
-	"github.com/rancher/rancher/pkg/provisioningv2/image"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/rancher/wrangler/v3/pkg/generic/fake"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
@@ -24,41 +23,41 @@ import (
 )
 
 type mockPlanner struct {
-	planner                       *Planner
-	rkeBootstrap                  *fake.MockClientInterface[*rkev1.RKEBootstrap, *rkev1.RKEBootstrapList]
-	rkeBootstrapCache             *fake.MockCacheInterface[*rkev1.RKEBootstrap]
-	rkeControlPlanes              *fake.MockControllerInterface[*rkev1.RKEControlPlane, *rkev1.RKEControlPlaneList]
-	etcdSnapshotCache             *fake.MockCacheInterface[*rkev1.ETCDSnapshot]
-	secretClient                  *fake.MockClientInterface[*v1.Secret, *v1.SecretList]
-	secretCache                   *fake.MockCacheInterface[*v1.Secret]
-	configMapCache                *fake.MockCacheInterface[*v1.ConfigMap]
-	machines                      *fake.MockClientInterface[*capi.Machine, *capi.MachineList]
-	machinesCache                 *fake.MockCacheInterface[*capi.Machine]
-	clusterRegistrationTokenCache *fake.MockCacheInterface[*apisv3.ClusterRegistrationToken]
-	capiClient                    *fake.MockClientInterface[*capi.Cluster, *capi.ClusterList]
-	capiClusters                  *fake.MockCacheInterface[*capi.Cluster]
-	managementClusters            *fake.MockNonNamespacedCacheInterface[*apisv3.Cluster]
-	rancherClusterCache           *fake.MockCacheInterface[*apisv1.Cluster]
+	planner           *Planner
+	rkeBootstrap      *fake.MockClientInterface[*rkev1.RKEBootstrap, *rkev1.RKEBootstrapList]
+	rkeBootstrapCache *fake.MockCacheInterface[*rkev1.RKEBootstrap]
+	rkeControlPlanes  *fake.MockControllerInterface[*rkev1.RKEControlPlane, *rkev1.RKEControlPlaneList]
+	etcdSnapshotCache *fake.MockCacheInterface[*rkev1.ETCDSnapshot]
+	secretClient      *fake.MockClientInterface[*v1.Secret, *v1.SecretList]
+	secretCache       *fake.MockCacheInterface[*v1.Secret]
+	configMapCache    *fake.MockCacheInterface[*v1.ConfigMap]
+	machines          *fake.MockClientInterface[*capi.Machine, *capi.MachineList]
+	machinesCache     *fake.MockCacheInterface[*capi.Machine]
+	capiClient        *fake.MockClientInterface[*capi.Cluster, *capi.ClusterList]
+	capiClusters      *fake.MockCacheInterface[*capi.Cluster]
 }
 
 // newMockPlanner creates a new mockPlanner that can be used for simulating a functional Planner.
 func newMockPlanner(t *testing.T, functions InfoFunctions) *mockPlanner {
 	ctrl := gomock.NewController(t)
+	if functions.ReleaseData == nil {
+		functions.ReleaseData = func(context.Context, *rkev1.RKEControlPlane) *model.Release { return nil }
+	}
+	if functions.ControlPlaneManifests == nil {
+		functions.ControlPlaneManifests = func(*rkev1.RKEControlPlane, []v1.Taint) ([]plan.File, error) { return nil, nil }
+	}
 	mp := mockPlanner{
-		rkeBootstrap:                  fake.NewMockClientInterface[*rkev1.RKEBootstrap, *rkev1.RKEBootstrapList](ctrl),
-		rkeBootstrapCache:             fake.NewMockCacheInterface[*rkev1.RKEBootstrap](ctrl),
-		rkeControlPlanes:              fake.NewMockControllerInterface[*rkev1.RKEControlPlane, *rkev1.RKEControlPlaneList](ctrl),
-		etcdSnapshotCache:             fake.NewMockCacheInterface[*rkev1.ETCDSnapshot](ctrl),
-		secretClient:                  fake.NewMockClientInterface[*v1.Secret, *v1.SecretList](ctrl),
-		secretCache:                   fake.NewMockCacheInterface[*v1.Secret](ctrl),
-		configMapCache:                fake.NewMockCacheInterface[*v1.ConfigMap](ctrl),
-		machines:                      fake.NewMockClientInterface[*capi.Machine, *capi.MachineList](ctrl),
-		machinesCache:                 fake.NewMockCacheInterface[*capi.Machine](ctrl),
-		clusterRegistrationTokenCache: fake.NewMockCacheInterface[*apisv3.ClusterRegistrationToken](ctrl),
-		capiClient:                    fake.NewMockClientInterface[*capi.Cluster, *capi.ClusterList](ctrl),
-		capiClusters:                  fake.NewMockCacheInterface[*capi.Cluster](ctrl),
-		managementClusters:            fake.NewMockNonNamespacedCacheInterface[*apisv3.Cluster](ctrl),
-		rancherClusterCache:           fake.NewMockCacheInterface[*apisv1.Cluster](ctrl),
+		rkeBootstrap:      fake.NewMockClientInterface[*rkev1.RKEBootstrap, *rkev1.RKEBootstrapList](ctrl),
+		rkeBootstrapCache: fake.NewMockCacheInterface[*rkev1.RKEBootstrap](ctrl),
+		rkeControlPlanes:  fake.NewMockControllerInterface[*rkev1.RKEControlPlane, *rkev1.RKEControlPlaneList](ctrl),
+		etcdSnapshotCache: fake.NewMockCacheInterface[*rkev1.ETCDSnapshot](ctrl),
+		secretClient:      fake.NewMockClientInterface[*v1.Secret, *v1.SecretList](ctrl),
+		secretCache:       fake.NewMockCacheInterface[*v1.Secret](ctrl),
+		configMapCache:    fake.NewMockCacheInterface[*v1.ConfigMap](ctrl),
+		machines:          fake.NewMockClientInterface[*capi.Machine, *capi.MachineList](ctrl),
+		machinesCache:     fake.NewMockCacheInterface[*capi.Machine](ctrl),
+		capiClient:        fake.NewMockClientInterface[*capi.Cluster, *capi.ClusterList](ctrl),
+		capiClusters:      fake.NewMockCacheInterface[*capi.Cluster](ctrl),
 	}
 	store := PlanStore{
 		secrets:      mp.secretClient,
@@ -66,22 +65,19 @@ func newMockPlanner(t *testing.T, functions InfoFunctions) *mockPlanner {
 		machineCache: mp.machinesCache,
 	}
 	p := Planner{
-		ctx:                           context.TODO(),
-		store:                         &store,
-		machines:                      mp.machines,
-		machinesCache:                 mp.machinesCache,
-		secretClient:                  mp.secretClient,
-		secretCache:                   mp.secretCache,
-		configMapCache:                mp.configMapCache,
-		clusterRegistrationTokenCache: mp.clusterRegistrationTokenCache,
-		capiClient:                    mp.capiClient,
-		capiClusters:                  mp.capiClusters,
-		managementClusters:            mp.managementClusters,
-		rancherClusterCache:           mp.rancherClusterCache,
-		rkeControlPlanes:              mp.rkeControlPlanes,
-		rkeBootstrap:                  mp.rkeBootstrap,
-		rkeBootstrapCache:             mp.rkeBootstrapCache,
-		etcdSnapshotCache:             mp.etcdSnapshotCache,
+		ctx:               context.TODO(),
+		store:             &store,
+		machines:          mp.machines,
+		machinesCache:     mp.machinesCache,
+		secretClient:      mp.secretClient,
+		secretCache:       mp.secretCache,
+		configMapCache:    mp.configMapCache,
+		capiClient:        mp.capiClient,
+		capiClusters:      mp.capiClusters,
+		rkeControlPlanes:  mp.rkeControlPlanes,
+		rkeBootstrap:      mp.rkeBootstrap,
+		rkeBootstrapCache: mp.rkeBootstrapCache,
+		etcdSnapshotCache: mp.etcdSnapshotCache,
 		etcdS3Args: s3Args{
 			secretCache: mp.secretCache,
 		},
@@ -91,6 +87,19 @@ func newMockPlanner(t *testing.T, functions InfoFunctions) *mockPlanner {
 	return &mp
 }
 
+// resolveWithControlPlane is an ImageResolver that prefixes the image with the system-default-registry of the given
+// control plane, mirroring the resolver Rancher passes to the planner.
+func resolveWithControlPlane(image string, cp *rkev1.RKEControlPlane) string {
+	reg := capr.GetPrivateRepoURLFromControlPlane(cp)
+	if reg == "" || strings.HasPrefix(image, reg) {
+		return image
+	}
+	if !strings.Contains(image, "/") {
+		image = "rancher/" + image
+	}
+	return path.Join(reg, image)
+}
+
 func TestPlanner_addInstruction(t *testing.T) {
 	type args struct {
 		version         string
@@ -147,7 +156,7 @@ func TestPlanner_addInstruction(t *testing.T) {
 			controlPlane := createTestControlPlane(tt.args.version)
 			entry := createTestPlanEntry(tt.args.os)
 			planner.retrievalFunctions.SystemAgentImage = func() string { return "system-agent" }
-			planner.retrievalFunctions.ImageResolver = image.ResolveWithControlPlane
+			planner.retrievalFunctions.ImageResolver = resolveWithControlPlane
 			// act
 			p, err := planner.addInstallInstructionWithRestartStamp(plan.NodePlan{}, controlPlane, entry)
 
@@ -516,7 +525,7 @@ func Test_getInstallerImage(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			var planner Planner
-			planner.retrievalFunctions.ImageResolver = image.ResolveWithControlPlane
+			planner.retrievalFunctions.ImageResolver = resolveWithControlPlane
 			planner.retrievalFunctions.SystemAgentImage = func() string { return "rancher/system-agent-installer-" }
 
 			assert.Equal(t, tt.expected, planner.getInstallerImage(tt.controlPlane))
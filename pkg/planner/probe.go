@@ -10,6 +10,8 @@ import (
 	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/rancher/wrangler/v3/pkg/data/convert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 var (
@@ -23,6 +25,24 @@ var (
 				URL: "http://%s:9099/liveness",
 			},
 		},
+		"canal": {
+			InitialDelaySeconds: 1,
+			TimeoutSeconds:      5,
+			SuccessThreshold:    1,
+			FailureThreshold:    2,
+			HTTPGetAction: plan.HTTPGetAction{
+				URL: "http://%s:9099/liveness",
+			},
+		},
+		"cilium": {
+			InitialDelaySeconds: 1,
+			TimeoutSeconds:      5,
+			SuccessThreshold:    1,
+			FailureThreshold:    2,
+			HTTPGetAction: plan.HTTPGetAction{
+				URL: "http://%s:9879/healthz",
+			},
+		},
 		"etcd": {
 			InitialDelaySeconds: 1,
 			TimeoutSeconds:      5,
@@ -77,17 +97,55 @@ var (
 	errEmptyAddress = errors.New("address cannot be empty")
 )
 
-// isCalico returns true if the cni is calico or calico+multus, and returns false otherwise.
-func isCalico(controlPlane *rkev1.RKEControlPlane, runtime string) bool {
-	// calico is only supported for rke2
+// machineSelectorProbes returns the user defined probes from the machineSelectorProbes of the controlplane that apply to
+// the given entry, and an error if the label selector is invalid or a probe conflicts with a built-in probe.
+func machineSelectorProbes(controlPlane *rkev1.RKEControlPlane, entry *planEntry) (map[string]plan.Probe, error) {
+	probes := map[string]plan.Probe{}
+	for _, msp := range controlPlane.Spec.MachineSelectorProbes {
+		sel, err := metav1.LabelSelectorAsSelector(msp.MachineLabelSelector)
+		if err != nil {
+			return nil, err
+		}
+		if msp.MachineLabelSelector != nil && !sel.Matches(labels.Set(entry.Machine.Labels)) {
+			continue
+		}
+		for name, probe := range msp.Probes {
+			if _, ok := allProbes[name]; ok {
+				return nil, fmt.Errorf("probe %s conflicts with a built-in probe", name)
+			}
+			probes[name] = probe
+		}
+	}
+	return probes, nil
+}
+
+// getCNI returns the primary cni for the controlplane if it is one that ships with a health endpoint, and returns an
+// empty string otherwise. Multus is a meta plugin and is ignored when determining the primary cni. Flannel is not
+// probed, as it is embedded in the k3s process and the rke2 flannel chart does not expose a health endpoint.
+func getCNI(controlPlane *rkev1.RKEControlPlane, runtime string) string {
+	// the cni charts are only deployed by rke2
 	if runtime != capr.RuntimeRKE2 {
-		return false
+		return ""
 	}
 
-	cni := convert.ToString(controlPlane.Spec.MachineGlobalConfig.Data["cni"])
-	return cni == "" ||
-		cni == "calico" ||
-		cni == "calico+multus"
+	var cnis []string
+	for _, v := range convert.ToStringSlice(controlPlane.Spec.MachineGlobalConfig.Data["cni"]) {
+		for _, cni := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '+' }) {
+			if cni = strings.TrimSpace(cni); cni != "" && cni != "multus" {
+				cnis = append(cnis, cni)
+			}
+		}
+	}
+	if len(cnis) == 0 {
+		// calico is the default cni for rke2
+		return "calico"
+	}
+
+	switch cnis[0] {
+	case "calico", "canal", "cilium":
+		return cnis[0]
+	}
+	return ""
 }
 
 // renderSecureProbe takes the existing argument value and renders a secure probe using the argument values and an error
@@ -135,14 +193,22 @@ func (p *Planner) generateProbes(controlPlane *rkev1.RKEControlPlane, entry *pla
 		// k3s doesn't run the kubelet on etcd only nodes
 		probeNames = append(probeNames, "kubelet")
 	}
-	if !IsOnlyEtcd(entry) && isCalico(controlPlane, runtime) && roleNot(windows)(entry) {
-		probeNames = append(probeNames, "calico")
+	if cni := getCNI(controlPlane, runtime); cni != "" && !IsOnlyEtcd(entry) && roleNot(windows)(entry) {
+		probeNames = append(probeNames, cni)
 	}
 
 	for _, probeName := range probeNames {
 		probes[probeName] = allProbes[probeName]
 	}
 
+	userProbes, err := machineSelectorProbes(controlPlane, entry)
+	if err != nil {
+		return probes, err
+	}
+	for probeName, probe := range userProbes {
+		probes[probeName] = probe
+	}
+
 	probes = insertDataDirForProbes(controlPlane, probes)
 
 	loopbackAddress := capr.GetLoopbackAddress(controlPlane)
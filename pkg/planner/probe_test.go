@@ -4,99 +4,79 @@ import (
 	"testing"
 
 	"github.com/rancher/cluster-api-provider-rancher/pkg"
-	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
-	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
-func TestIsCalico(t *testing.T) {
+func TestGetCNI(t *testing.T) {
 	tests := []struct {
-		name         string
-		controlPlane *rkev1.RKEControlPlane
-		runtime      string
-		expected     bool
+		name     string
+		cni      any
+		runtime  string
+		expected string
 	}{
 		{
-			name: "calico rke2",
-			controlPlane: &rkev1.RKEControlPlane{
-				Spec: rkev1.RKEControlPlaneSpec{
-					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
-						MachineGlobalConfig: rkev1.GenericMap{
-							Data: map[string]any{
-								"cni": "calico",
-							},
-						},
-					},
-				},
-			},
+			name:     "no cni rke2",
+			cni:      nil,
 			runtime:  capr.RuntimeRKE2,
-			expected: true,
+			expected: "calico",
 		},
 		{
-			name: "calico+multus rke2",
-			controlPlane: &rkev1.RKEControlPlane{
-				Spec: rkev1.RKEControlPlaneSpec{
-					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
-						MachineGlobalConfig: rkev1.GenericMap{
-							Data: map[string]any{
-								"cni": "calico+multus",
-							},
-						},
-					},
-				},
-			},
+			name:     "no cni k3s",
+			cni:      nil,
+			runtime:  capr.RuntimeK3S,
+			expected: "",
+		},
+		{
+			name:     "canal rke2",
+			cni:      "canal",
 			runtime:  capr.RuntimeRKE2,
-			expected: true,
+			expected: "canal",
 		},
 		{
-			name: "mispelled calico rke2",
-			controlPlane: &rkev1.RKEControlPlane{
-				Spec: rkev1.RKEControlPlaneSpec{
-					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
-						MachineGlobalConfig: rkev1.GenericMap{
-							Data: map[string]any{
-								"cni": "calicoo",
-							},
-						},
-					},
-				},
-			},
+			name:     "cilium rke2",
+			cni:      "cilium",
 			runtime:  capr.RuntimeRKE2,
-			expected: false,
+			expected: "cilium",
 		},
 		{
-			name: "calico k3s",
-			controlPlane: &rkev1.RKEControlPlane{
-				Spec: rkev1.RKEControlPlaneSpec{
-					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
-						MachineGlobalConfig: rkev1.GenericMap{
-							Data: map[string]any{
-								"cni": "calico",
-							},
-						},
-					},
-				},
-			},
-			runtime:  capr.RuntimeK3S,
-			expected: false,
+			name:     "multus,cilium rke2",
+			cni:      "multus,cilium",
+			runtime:  capr.RuntimeRKE2,
+			expected: "cilium",
 		},
 		{
-			name:         "no cni rke2",
-			controlPlane: &rkev1.RKEControlPlane{},
-			runtime:      capr.RuntimeRKE2,
-			expected:     true,
+			name:     "multus and canal list rke2",
+			cni:      []any{"multus", "canal"},
+			runtime:  capr.RuntimeRKE2,
+			expected: "canal",
 		},
 		{
-			name:         "no cni k3s",
-			controlPlane: &rkev1.RKEControlPlane{},
-			runtime:      capr.RuntimeRKE2,
-			expected:     true,
+			name:     "flannel rke2",
+			cni:      "flannel",
+			runtime:  capr.RuntimeRKE2,
+			expected: "",
+		},
+		{
+			name:     "none rke2",
+			cni:      "none",
+			runtime:  capr.RuntimeRKE2,
+			expected: "",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.expected, isCalico(tt.controlPlane, tt.runtime))
+			controlPlane := &rkev1.RKEControlPlane{}
+			if tt.cni != nil {
+				controlPlane.Spec.MachineGlobalConfig.Data = map[string]any{
+					"cni": tt.cni,
+				}
+			}
+			assert.Equal(t, tt.expected, getCNI(controlPlane, tt.runtime))
 		})
 	}
 }
@@ -401,3 +381,111 @@ func TestReplaceIfFormatSpecifier(t *testing.T) {
 		})
 	}
 }
+
+func TestMachineSelectorProbes(t *testing.T) {
+	ingressProbe := plan.Probe{
+		InitialDelaySeconds: 1,
+		TimeoutSeconds:      5,
+		SuccessThreshold:    1,
+		FailureThreshold:    2,
+		HTTPGetAction: plan.HTTPGetAction{
+			URL: "http://%s:10254/healthz",
+		},
+	}
+	tests := []struct {
+		name         string
+		controlPlane *rkev1.RKEControlPlane
+		labels       map[string]string
+		expected     map[string]plan.Probe
+		expectedErr  bool
+	}{
+		{
+			name:         "no machine selector probes",
+			controlPlane: &rkev1.RKEControlPlane{},
+			expected:     map[string]plan.Probe{},
+		},
+		{
+			name: "nil selector matches all machines",
+			controlPlane: &rkev1.RKEControlPlane{
+				Spec: rkev1.RKEControlPlaneSpec{
+					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
+						MachineSelectorProbes: []rkev1.RKEProvisioningProbes{
+							{
+								Probes: map[string]plan.Probe{"ingress": ingressProbe},
+							},
+						},
+					},
+				},
+			},
+			expected: map[string]plan.Probe{"ingress": ingressProbe},
+		},
+		{
+			name: "matching selector",
+			controlPlane: &rkev1.RKEControlPlane{
+				Spec: rkev1.RKEControlPlaneSpec{
+					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
+						MachineSelectorProbes: []rkev1.RKEProvisioningProbes{
+							{
+								MachineLabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"ingress": "true"}},
+								Probes:               map[string]plan.Probe{"ingress": ingressProbe},
+							},
+						},
+					},
+				},
+			},
+			labels:   map[string]string{"ingress": "true"},
+			expected: map[string]plan.Probe{"ingress": ingressProbe},
+		},
+		{
+			name: "non-matching selector",
+			controlPlane: &rkev1.RKEControlPlane{
+				Spec: rkev1.RKEControlPlaneSpec{
+					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
+						MachineSelectorProbes: []rkev1.RKEProvisioningProbes{
+							{
+								MachineLabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"ingress": "true"}},
+								Probes:               map[string]plan.Probe{"ingress": ingressProbe},
+							},
+						},
+					},
+				},
+			},
+			labels:   map[string]string{"ingress": "false"},
+			expected: map[string]plan.Probe{},
+		},
+		{
+			name: "conflict with built-in probe",
+			controlPlane: &rkev1.RKEControlPlane{
+				Spec: rkev1.RKEControlPlaneSpec{
+					RKEClusterSpecCommon: rkev1.RKEClusterSpecCommon{
+						MachineSelectorProbes: []rkev1.RKEProvisioningProbes{
+							{
+								Probes: map[string]plan.Probe{"kubelet": ingressProbe},
+							},
+						},
+					},
+				},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &planEntry{
+				Machine: &capi.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: tt.labels,
+					},
+				},
+			}
+			probes, err := machineSelectorProbes(tt.controlPlane, entry)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, probes)
+		})
+	}
+}
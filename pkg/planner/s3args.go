@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/rancher/rancher/pkg/namespace"
 	corev1 "k8s.io/api/core/v1"
+	"strconv"
 	"strings"
 
 	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
@@ -32,7 +33,7 @@ func S3Enabled(s3 *rkev1.ETCDSnapshotS3) bool {
 	if s3 == nil {
 		return false
 	}
-	if s3.Bucket != "" || s3.Endpoint != "" || s3.Folder != "" || s3.CloudCredentialName != "" || s3.Region != "" {
+	if s3.Bucket != "" || s3.Endpoint != "" || s3.Folder != "" || s3.CloudCredentialName != "" || s3.Region != "" || s3.UseInstanceCredentials {
 		return true
 	}
 	return false
@@ -55,7 +56,12 @@ func (s *s3Args) ToArgs(s3 *rkev1.ETCDSnapshotS3, controlPlane *rkev1.RKEControl
 	controlPlaneEtcdS3NotNil := controlPlane.Spec.ETCD != nil && controlPlane.Spec.ETCD.S3 != nil
 
 	credName := s3.CloudCredentialName
-	if credName == "" && controlPlaneEtcdS3NotNil {
+	if s3.UseInstanceCredentials {
+		if credName != "" {
+			err = fmt.Errorf("cloudCredentialName %s and useInstanceCredentials must not both be set for etcd snapshots in S3", credName)
+			return
+		}
+	} else if credName == "" && controlPlaneEtcdS3NotNil && !controlPlane.Spec.ETCD.S3.UseInstanceCredentials {
 		credName = controlPlane.Spec.ETCD.S3.CloudCredentialName
 	}
 
@@ -131,6 +137,74 @@ func (s *s3Args) ToArgs(s3 *rkev1.ETCDSnapshotS3, controlPlane *rkev1.RKEControl
 	return
 }
 
+// s3TargetDefaults are the default values of the S3 arguments that are reset for S3 targets if they are not set for the
+// target. The secret key is reset with etcdS3TargetSecretKeyArg instead.
+var s3TargetDefaults = []struct {
+	arg   string
+	value string
+}{
+	{arg: "s3-access-key"},
+	{arg: "s3-region", value: "us-east-1"},
+	{arg: "s3-folder"},
+	{arg: "s3-endpoint", value: "s3.amazonaws.com"},
+	{arg: "s3-endpoint-ca"},
+	{arg: "s3-skip-ssl-verify", value: "false"},
+}
+
+// etcdS3TargetSecretKeyArg is the argument that sets the secret key of an S3 target for the distro from the
+// AWS_SECRET_ACCESS_KEY environment variable when it is expanded by the shell. The environment variable is not
+// sufficient on its own, as the secret key of the config file of the distro takes precedence over it.
+const etcdS3TargetSecretKeyArg = `--etcd-s3-secret-key="${AWS_SECRET_ACCESS_KEY}"`
+
+// ToTargetArgs renders the arguments, environment variables, and files for an S3 target like ToArgs, with the secret key
+// in the AWS_SECRET_ACCESS_KEY environment variable. As the S3 configuration of the cluster is rendered into the config
+// file of the distro, every S3 argument that is not set for the target is reset to its default, so that the target does
+// not inherit it from the S3 configuration of the cluster. Commands of the distro must be run with
+// etcdS3TargetSecretKeyArg for the same reason. An error is returned if the target has no access key, as instance
+// credentials are not supported for S3 targets.
+func (s *s3Args) ToTargetArgs(target *rkev1.ETCDSnapshotS3Target, controlPlane *rkev1.RKEControlPlane, prefix string) (args []string, env []string, files []plan.File, err error) {
+	args, env, files, err = s.ToArgs(&target.ETCDSnapshotS3, controlPlane, prefix, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !hasArg(args, fmt.Sprintf("--%ss3-bucket", prefix)) {
+		return nil, nil, nil, fmt.Errorf("bucket is not set for etcd snapshot S3 target %s", target.Name)
+	}
+	if !hasArg(args, fmt.Sprintf("--%ss3-access-key", prefix)) {
+		return nil, nil, nil, fmt.Errorf("etcd snapshot S3 target %s has no cloud credential, instance credentials are not supported for S3 targets", target.Name)
+	}
+	for _, d := range s3TargetDefaults {
+		if hasArg(args, fmt.Sprintf("--%s%s", prefix, d.arg)) {
+			continue
+		}
+		args = append(args, fmt.Sprintf("--%s%s=%s", prefix, d.arg, d.value))
+	}
+	return args, env, files, nil
+}
+
+// hasArg returns true if the given arguments contain the given argument, with or without a value.
+func hasArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg || strings.HasPrefix(a, arg+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// S3Target returns the S3 target with the given name from the etcd configuration of the controlplane.
+func S3Target(controlPlane *rkev1.RKEControlPlane, name string) (*rkev1.ETCDSnapshotS3Target, bool) {
+	if controlPlane.Spec.ETCD == nil {
+		return nil, false
+	}
+	for i := range controlPlane.Spec.ETCD.S3Targets {
+		if controlPlane.Spec.ETCD.S3Targets[i].Name == name {
+			return &controlPlane.Spec.ETCD.S3Targets[i], true
+		}
+	}
+	return nil, false
+}
+
 func generateEndpointCAFileIfPathMatches(controlPlane *rkev1.RKEControlPlane, existingEndpointCAPath, endpointCA string) *plan.File {
 	s3CAName := fmt.Sprintf("s3-endpoint-ca-%s.crt", name.Hex(endpointCA, 5))
 	filePath := configFile(controlPlane, s3CAName)
@@ -178,20 +252,60 @@ func getS3Credential(secretCache corecontrollers.SecretCache, namespace, name st
 		return result, fmt.Errorf("failed to lookup etcdSnapshotCloudCredentialName: %w", err)
 	}
 
-	data := map[string][]byte{}
+	result, err = s3CredentialFromSecret(secret)
+	if err != nil {
+		return result, fmt.Errorf("invalid S3 credential secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	return result, nil
+}
+
+// s3CredentialFromSecret parses the S3 credential of a secret. Both the layout of Rancher cloud credentials, whose keys
+// are prefixed with the driver config name (e.g. amazonec2credentialConfig-accessKey) and use the default prefix for
+// optional settings (e.g. defaultRegion), and a generic layout are accepted. The keys of the generic layout are:
+// accessKey (or AWS_ACCESS_KEY_ID), secretKey (or AWS_SECRET_ACCESS_KEY), and the optional region, endpoint, endpointCA,
+// skipSSLVerify, bucket, and folder. An error is returned if the secret contains neither layout, or only one of the keys.
+func s3CredentialFromSecret(secret *corev1.Secret) (s3Credential, error) {
+	data := map[string]string{}
 	for k, v := range secret.Data {
 		_, k = kv.RSplit(k, "-")
-		data[k] = v
-	}
-
-	return s3Credential{
-		AccessKey:     string(data["accessKey"]),
-		SecretKey:     string(data["secretKey"]),
-		Region:        string(data["defaultRegion"]),
-		Endpoint:      string(data["defaultEndpoint"]),
-		EndpointCA:    string(data["defaultEndpointCA"]),
-		SkipSSLVerify: string(data["defaultSkipSSLVerify"]) == "true",
-		Bucket:        string(data["defaultBucket"]),
-		Folder:        string(data["defaultFolder"]),
-	}, nil
+		data[k] = string(v)
+	}
+
+	// value returns the value of the first of the given keys that is set
+	value := func(keys ...string) string {
+		for _, k := range keys {
+			if v := data[k]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	result := s3Credential{
+		AccessKey:  value("accessKey", "AWS_ACCESS_KEY_ID"),
+		SecretKey:  value("secretKey", "AWS_SECRET_ACCESS_KEY"),
+		Region:     value("defaultRegion", "region"),
+		Endpoint:   value("defaultEndpoint", "endpoint"),
+		EndpointCA: value("defaultEndpointCA", "endpointCA"),
+		Bucket:     value("defaultBucket", "bucket"),
+		Folder:     value("defaultFolder", "folder"),
+	}
+
+	if v := value("defaultSkipSSLVerify", "skipSSLVerify"); v != "" {
+		skipSSLVerify, err := strconv.ParseBool(v)
+		if err != nil {
+			return result, fmt.Errorf("skipSSLVerify must be true or false, got %q", v)
+		}
+		result.SkipSSLVerify = skipSSLVerify
+	}
+
+	switch {
+	case result.AccessKey == "" && result.SecretKey == "":
+		return result, fmt.Errorf("no accessKey and secretKey found, the secret must contain the keys of a cloud credential or the keys accessKey and secretKey, or useInstanceCredentials must be set instead of a cloud credential")
+	case result.AccessKey == "":
+		return result, fmt.Errorf("secretKey is set but accessKey is missing")
+	case result.SecretKey == "":
+		return result, fmt.Errorf("accessKey is set but secretKey is missing")
+	}
+	return result, nil
 }
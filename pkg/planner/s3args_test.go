@@ -0,0 +1,108 @@
+package planner
+
+import (
+	"testing"
+
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestS3CredentialFromSecret(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string]string
+		expected s3Credential
+		err      bool
+	}{
+		{
+			name: "cloud credential",
+			data: map[string]string{
+				"amazonec2credentialConfig-accessKey":     "access",
+				"amazonec2credentialConfig-secretKey":     "secret",
+				"amazonec2credentialConfig-defaultRegion": "eu-west-1",
+				"amazonec2credentialConfig-defaultBucket": "backups",
+			},
+			expected: s3Credential{AccessKey: "access", SecretKey: "secret", Region: "eu-west-1", Bucket: "backups"},
+		},
+		{
+			name: "generic",
+			data: map[string]string{
+				"accessKey":     "access",
+				"secretKey":     "secret",
+				"region":        "eu-west-1",
+				"endpoint":      "minio.example.com",
+				"skipSSLVerify": "true",
+				"folder":        "etcd",
+			},
+			expected: s3Credential{AccessKey: "access", SecretKey: "secret", Region: "eu-west-1", Endpoint: "minio.example.com", SkipSSLVerify: true, Folder: "etcd"},
+		},
+		{
+			name: "aws environment variables",
+			data: map[string]string{
+				"AWS_ACCESS_KEY_ID":     "access",
+				"AWS_SECRET_ACCESS_KEY": "secret",
+			},
+			expected: s3Credential{AccessKey: "access", SecretKey: "secret"},
+		},
+		{
+			name: "missing secret key",
+			data: map[string]string{"accessKey": "access"},
+			err:  true,
+		},
+		{
+			name: "no keys",
+			data: map[string]string{"username": "admin"},
+			err:  true,
+		},
+		{
+			name: "invalid skipSSLVerify",
+			data: map[string]string{"accessKey": "access", "secretKey": "secret", "skipSSLVerify": "yes please"},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "s3"},
+				Data:       map[string][]byte{},
+			}
+			for k, v := range tt.data {
+				secret.Data[k] = []byte(v)
+			}
+			result, err := s3CredentialFromSecret(secret)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestToArgsInstanceCredentials(t *testing.T) {
+	s := &s3Args{}
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.ETCD = &rkev1.ETCD{
+		S3: &rkev1.ETCDSnapshotS3{
+			Bucket:                 "backups",
+			UseInstanceCredentials: true,
+		},
+	}
+
+	args, env, _, err := s.ToArgs(cp.Spec.ETCD.S3, cp, "etcd-", true)
+	assert.NoError(t, err)
+	assert.Empty(t, env)
+	assert.Contains(t, args, "--etcd-s3-bucket=backups")
+	assert.False(t, hasArg(args, "--etcd-s3-access-key"))
+
+	_, _, _, err = s.ToArgs(&rkev1.ETCDSnapshotS3{
+		Bucket:                 "backups",
+		CloudCredentialName:    "s3",
+		UseInstanceCredentials: true,
+	}, cp, "etcd-", true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,539 @@
+package planner
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/channelserver/pkg/model"
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/rancher/wrangler/v3/pkg/data/convert"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultKMSTimeoutSeconds = 3
+
+	kmsPluginUnit = "capr-kms-plugin.service"
+
+	secretsEncryptionConfigKey      = "secrets-encryption"
+	secretsEncryptionStatusEnabled  = "Encryption Status: Enabled"
+	secretsEncryptionStatusDisabled = "Encryption Status: Disabled"
+
+	kmsEncryptionConfigScriptPath = "kms_encryption_config.sh"
+	// kmsEncryptionConfigScript generates the encryption configuration of the kube-apiserver from the encryption
+	// configuration the distro left behind by adding the given KMS provider in front of its providers, so that secrets
+	// the distro encrypted before KMS was configured remain readable. If the distro never encrypted secrets, the KMS
+	// provider is followed by the identity provider.
+	kmsEncryptionConfigScript = `#!/bin/sh
+PROVIDER_FILE=$1
+DISTRO_CONFIG=$2
+OUTPUT=$3
+
+provider=$(cat "${PROVIDER_FILE}") || exit 1
+umask 077
+if [ -s "${DISTRO_CONFIG}" ]; then
+	sed -E "s|\"providers\"[[:space:]]*:[[:space:]]*\[|&${provider},|" "${DISTRO_CONFIG}" > "${OUTPUT}.tmp" || exit 1
+	if ! grep -qF "${provider}" "${OUTPUT}.tmp"; then
+		echo "unable to add the KMS provider to ${DISTRO_CONFIG}" >&2
+		rm -f "${OUTPUT}.tmp"
+		exit 1
+	fi
+else
+	printf '{"kind":"EncryptionConfiguration","apiVersion":"apiserver.config.k8s.io/v1","resources":[{"resources":["secrets"],"providers":[%s,{"identity":{}}]}]}\n' "${provider}" > "${OUTPUT}.tmp" || exit 1
+fi
+mv -f "${OUTPUT}.tmp" "${OUTPUT}"
+`
+)
+
+// secretsEncryptionKMSEnabled returns true if the secrets of the controlplane are encrypted with a KMS plugin.
+func secretsEncryptionKMSEnabled(controlPlane *rkev1.RKEControlPlane) bool {
+	return controlPlane.Spec.SecretsEncryptionKMS != nil
+}
+
+func kmsEncryptionConfigPath(controlPlane *rkev1.RKEControlPlane) string {
+	return path.Join(capr.GetDistroDataDir(controlPlane), "server/cred/kms-encryption-config.json")
+}
+
+func kmsProviderPath(controlPlane *rkev1.RKEControlPlane) string {
+	return path.Join(capr.GetDistroDataDir(controlPlane), "server/cred/kms-provider.json")
+}
+
+// kmsPluginSocketDir returns the directory of the unix socket of the KMS plugin.
+func kmsPluginSocketDir(kms *rkev1.SecretsEncryptionKMS) string {
+	return path.Dir(strings.TrimPrefix(kms.Endpoint, "unix://"))
+}
+
+// kmsProvider renders the KMS v2 provider of the encryption configuration of the kube-apiserver.
+func kmsProvider(kms *rkev1.SecretsEncryptionKMS) ([]byte, error) {
+	timeout := kms.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultKMSTimeoutSeconds
+	}
+	return json.Marshal(map[string]interface{}{
+		"kms": map[string]interface{}{
+			"apiVersion": "v2",
+			"name":       kms.Name,
+			"endpoint":   kms.Endpoint,
+			"timeout":    fmt.Sprintf("%ds", timeout),
+		},
+	})
+}
+
+// addSecretsEncryptionKMSConfig configures the kube-apiserver of controlplane machines to use the encryption
+// configuration with the KMS provider, and mounts it and the socket of the KMS plugin into the kube-apiserver of rke2.
+// Secrets encryption of the distro is disabled, as the distro would pass its own encryption configuration to the
+// kube-apiserver and report and rotate keys the kube-apiserver does not use. An error is returned if it is enabled by the
+// configuration of the machine.
+func addSecretsEncryptionKMSConfig(config map[string]interface{}, controlPlane *rkev1.RKEControlPlane, entry *planEntry) error {
+	if !secretsEncryptionKMSEnabled(controlPlane) || !isControlPlane(entry) {
+		return nil
+	}
+	if value, ok := config[secretsEncryptionConfigKey]; ok && convert.ToBool(value) {
+		return fmt.Errorf("%s must not be enabled together with secrets encryption with KMS", secretsEncryptionConfigKey)
+	}
+	config[secretsEncryptionConfigKey] = false
+
+	configPath := kmsEncryptionConfigPath(controlPlane)
+	config["kube-apiserver-arg"] = append(convert.ToStringSlice(config["kube-apiserver-arg"]),
+		fmt.Sprintf("encryption-provider-config=%s", configPath))
+	if capr.GetRuntime(controlPlane.Spec.KubernetesVersion) == capr.RuntimeRKE2 {
+		socketDir := kmsPluginSocketDir(controlPlane.Spec.SecretsEncryptionKMS)
+		config["kube-apiserver-extra-mount"] = append(convert.ToStringSlice(config["kube-apiserver-extra-mount"]),
+			fmt.Sprintf("%s:%s:ro", configPath, configPath),
+			fmt.Sprintf("%s:%s", socketDir, socketDir))
+	}
+	return nil
+}
+
+// addSecretsEncryptionKMSFiles adds the KMS provider, the KMS plugin and the instructions that start the KMS plugin and
+// generate the encryption configuration with the KMS provider to the plan of controlplane machines. The instructions run
+// before the distro is started by the install instruction. The KMS provider is not dynamic, so that the kube-apiserver is
+// restarted when it changes.
+func addSecretsEncryptionKMSFiles(nodePlan plan.NodePlan, controlPlane *rkev1.RKEControlPlane, entry *planEntry) (plan.NodePlan, error) {
+	kms := controlPlane.Spec.SecretsEncryptionKMS
+	if kms == nil || !isControlPlane(entry) {
+		return nodePlan, nil
+	}
+
+	provider, err := kmsProvider(kms)
+	if err != nil {
+		return nodePlan, err
+	}
+
+	nodePlan.Files = append(nodePlan.Files, plan.File{
+		Content:     base64.StdEncoding.EncodeToString(provider),
+		Path:        kmsProviderPath(controlPlane),
+		Permissions: "0600",
+	}, plan.File{
+		Content: base64.StdEncoding.EncodeToString([]byte(kmsEncryptionConfigScript)),
+		Path:    encryptionKeyRotationScriptPath(controlPlane, kmsEncryptionConfigScriptPath),
+		Dynamic: true,
+	})
+
+	if kms.StaticPod != "" {
+		nodePlan.Files = append(nodePlan.Files, plan.File{
+			Content: base64.StdEncoding.EncodeToString([]byte(kms.StaticPod)),
+			Path:    path.Join(capr.GetDistroDataDir(controlPlane), "agent/pod-manifests/kms-plugin.yaml"),
+		})
+	}
+	if kms.SystemdUnit != "" {
+		nodePlan.Files = append(nodePlan.Files, plan.File{
+			Content: base64.StdEncoding.EncodeToString([]byte(kms.SystemdUnit)),
+			Path:    path.Join("/etc/systemd/system", kmsPluginUnit),
+		})
+		nodePlan.Instructions = append(nodePlan.Instructions, plan.OneTimeInstruction{
+			Name:    "start-kms-plugin",
+			Command: "/bin/sh",
+			Args: []string{
+				"-c",
+				fmt.Sprintf("systemctl daemon-reload && systemctl enable %s && systemctl restart %s", kmsPluginUnit, kmsPluginUnit),
+			},
+		})
+	}
+
+	nodePlan.Instructions = append(nodePlan.Instructions, plan.OneTimeInstruction{
+		Name:    "generate-kms-encryption-config",
+		Command: "/bin/sh",
+		Args: []string{
+			encryptionKeyRotationScriptPath(controlPlane, kmsEncryptionConfigScriptPath),
+			kmsProviderPath(controlPlane),
+			path.Join(capr.GetDistroDataDir(controlPlane), "server/cred/encryption-config.json"),
+			kmsEncryptionConfigPath(controlPlane),
+		},
+	})
+	return nodePlan, nil
+}
+
+// kubectlCommand returns the command that runs kubectl of the distro with the admin kubeconfig on a controlplane machine.
+func kubectlCommand(controlPlane *rkev1.RKEControlPlane) string {
+	runtime := capr.GetRuntime(controlPlane.Spec.KubernetesVersion)
+	if runtime == capr.RuntimeK3S {
+		return "k3s kubectl"
+	}
+	return fmt.Sprintf("%s --kubeconfig /etc/rancher/%s/%s.yaml", path.Join(capr.GetDistroDataDir(controlPlane), "bin/kubectl"), runtime, runtime)
+}
+
+// secretsEncryptionDesired returns true if secrets encryption is enabled by the MachineGlobalConfig of the controlplane.
+// rke2 encrypts secrets unless it is disabled, k3s only if it is enabled.
+func secretsEncryptionDesired(controlPlane *rkev1.RKEControlPlane) bool {
+	if value, ok := controlPlane.Spec.MachineGlobalConfig.Data[secretsEncryptionConfigKey]; ok {
+		return convert.ToBool(value)
+	}
+	return capr.GetRuntime(controlPlane.Spec.KubernetesVersion) == capr.RuntimeRKE2
+}
+
+// secretsEncryptionEnabled returns true if secrets are currently encrypted in the cluster, either with KMS or by the
+// distro, and no change of secrets encryption is in progress.
+func secretsEncryptionEnabled(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus) bool {
+	if secretsEncryptionKMSEnabled(controlPlane) {
+		return true
+	}
+	if secretsEncryptionChangeInProgress(controlPlane) {
+		return false
+	}
+	if status.SecretsEncryptionEnabled == nil {
+		return secretsEncryptionDesired(controlPlane)
+	}
+	return *status.SecretsEncryptionEnabled
+}
+
+// secretsEncryptionTarget returns whether secrets encryption is enabled once the current change of secrets encryption
+// is done, or the current state if no change is in progress.
+func secretsEncryptionTarget(controlPlane *rkev1.RKEControlPlane) bool {
+	enabled := controlPlane.Status.SecretsEncryptionEnabled != nil && *controlPlane.Status.SecretsEncryptionEnabled
+	if secretsEncryptionChangeInProgress(controlPlane) {
+		return !enabled
+	}
+	return enabled
+}
+
+// secretsEncryptionChangeInProgress returns true if the phase of the change of secrets encryption indicates that the
+// change is in progress.
+func secretsEncryptionChangeInProgress(controlPlane *rkev1.RKEControlPlane) bool {
+	return controlPlane.Status.SecretsEncryptionPhase == rkev1.SecretsEncryptionPhaseToggle ||
+		controlPlane.Status.SecretsEncryptionPhase == rkev1.SecretsEncryptionPhasePostToggleRestart ||
+		controlPlane.Status.SecretsEncryptionPhase == rkev1.SecretsEncryptionPhaseReencrypt ||
+		controlPlane.Status.SecretsEncryptionPhase == rkev1.SecretsEncryptionPhasePostReencryptRestart
+}
+
+// addSecretsEncryptionConfig renders the secrets encryption state of the cluster instead of the secrets-encryption
+// setting of the MachineGlobalConfig until the change of secrets encryption is started, so that the distro is not just
+// restarted with the changed setting.
+func addSecretsEncryptionConfig(config map[string]interface{}, controlPlane *rkev1.RKEControlPlane, entry *planEntry) {
+	if controlPlane.Status.SecretsEncryptionEnabled == nil || isOnlyWorker(entry) || secretsEncryptionKMSEnabled(controlPlane) {
+		return
+	}
+	if target := secretsEncryptionTarget(controlPlane); target != secretsEncryptionDesired(controlPlane) {
+		config[secretsEncryptionConfigKey] = target
+	}
+}
+
+func (p *Planner) setSecretsEncryptionState(status rkev1.RKEControlPlaneStatus, phase rkev1.SecretsEncryptionPhase) (rkev1.RKEControlPlaneStatus, error) {
+	if status.SecretsEncryptionPhase == phase {
+		return status, nil
+	}
+	status.SecretsEncryptionPhase = phase
+	return status, errWaiting("refreshing secrets encryption state")
+}
+
+// changeSecretsEncryption enables or disables secrets encryption on an initialized cluster when the secrets-encryption
+// setting of the MachineGlobalConfig changes. The leader runs secrets-encrypt enable or disable, the control plane
+// machines are restarted, the leader reencrypts all secrets, and the control plane machines are restarted again. The
+// phases are Toggle -> PostToggleRestart -> Reencrypt -> PostReencryptRestart -> Done. A failed change is only retried
+// once the controlplane is updated. Secrets encryption of KMS-backed clusters is not changed.
+func (p *Planner) changeSecretsEncryption(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan, releaseData *model.Release) (rkev1.RKEControlPlaneStatus, error) {
+	if secretsEncryptionKMSEnabled(controlPlane) {
+		return status, nil
+	}
+
+	desired := secretsEncryptionDesired(controlPlane)
+	inProgress := secretsEncryptionChangeInProgress(controlPlane)
+	if status.SecretsEncryptionEnabled == nil || (!status.Initialized && !inProgress) {
+		// the distro is started with the secrets-encryption setting of the MachineGlobalConfig
+		if status.SecretsEncryptionEnabled == nil || *status.SecretsEncryptionEnabled != desired {
+			status.SecretsEncryptionEnabled = &desired
+		}
+		return status, nil
+	}
+
+	if !inProgress {
+		if *status.SecretsEncryptionEnabled == desired ||
+			(status.SecretsEncryptionPhase == rkev1.SecretsEncryptionPhaseFailed && status.SecretsEncryptionGeneration == controlPlane.Generation) ||
+			!capr.Ready.IsTrue(controlPlane) || rotateEncryptionKeyInProgress(controlPlane) {
+			return status, nil
+		}
+		status.SecretsEncryptionGeneration = controlPlane.Generation
+		status.SecretsEncryptionLeader = ""
+		if supported, err := encryptionKeyRotationSupported(releaseData); err != nil {
+			return status, err
+		} else if !supported {
+			logrus.Debugf("rkecluster %s/%s: marking secrets encryption phase as failed as it was not supported by version: %s", controlPlane.Namespace, controlPlane.Name, controlPlane.Spec.KubernetesVersion)
+			return p.setSecretsEncryptionState(status, rkev1.SecretsEncryptionPhaseFailed)
+		}
+		logrus.Infof("[planner] rkecluster %s/%s: changing secrets encryption to enabled=%t", controlPlane.Namespace, controlPlane.Name, desired)
+		return p.setSecretsEncryptionState(status, rkev1.SecretsEncryptionPhaseToggle)
+	}
+
+	found, joinServer, initNode, err := p.findInitNode(controlPlane, clusterPlan)
+	if err != nil {
+		return status, err
+	}
+	if !found || joinServer == "" {
+		return status, errWaiting("waiting for init node to change secrets encryption")
+	}
+
+	leader, err := p.secretsEncryptionFindLeader(status, clusterPlan, initNode)
+	if err != nil {
+		return p.secretsEncryptionFailed(status, err)
+	}
+	if status.SecretsEncryptionLeader != leader.Machine.Name {
+		status.SecretsEncryptionLeader = leader.Machine.Name
+		return status, errWaitingf("elected %s as control plane leader for changing secrets encryption", leader.Machine.Name)
+	}
+
+	target := secretsEncryptionTarget(controlPlane)
+	switch controlPlane.Status.SecretsEncryptionPhase {
+	case rkev1.SecretsEncryptionPhaseToggle:
+		if err := p.pauseCAPICluster(controlPlane, true); err != nil {
+			return status, errWaiting("pausing CAPI cluster")
+		}
+		command := "disable"
+		if target {
+			command = "enable"
+		}
+		if status, err = p.secretsEncryptionLeaderReconcile(controlPlane, status, tokensSecret, joinServer, leader, false, command); err != nil {
+			return status, err
+		}
+		return p.setSecretsEncryptionState(status, rkev1.SecretsEncryptionPhasePostToggleRestart)
+	case rkev1.SecretsEncryptionPhasePostToggleRestart:
+		if status, err = p.secretsEncryptionRestartNodes(controlPlane, status, tokensSecret, clusterPlan, leader, initNode, joinServer); err != nil {
+			return status, err
+		}
+		output, ok := leader.Plan.Output[encryptionKeyRotationSecretsEncryptStatusCommand]
+		if !ok {
+			return status, errWaitingf("waiting for secrets encryption status of [%s]", leader.Machine.Name)
+		}
+		expected := secretsEncryptionStatusDisabled
+		if target {
+			expected = secretsEncryptionStatusEnabled
+		}
+		if !strings.Contains(string(output), expected) {
+			return p.secretsEncryptionFailed(status, fmt.Errorf("leader [%s] did not report %s after restart", leader.Machine.Name, expected))
+		}
+		return p.setSecretsEncryptionState(status, rkev1.SecretsEncryptionPhaseReencrypt)
+	case rkev1.SecretsEncryptionPhaseReencrypt:
+		if status, err = p.secretsEncryptionLeaderReconcile(controlPlane, status, tokensSecret, joinServer, leader, true, "reencrypt", "-f", "--skip"); err != nil {
+			return status, err
+		}
+		stage, err := encryptionKeyRotationSecretsEncryptStageFromPeriodic(leader)
+		if err != nil {
+			return status, err
+		}
+		if stage != encryptionKeyRotationStageReencryptFinished {
+			return status, errWaitingf("waiting for reencryption of secrets to be finished")
+		}
+		return p.setSecretsEncryptionState(status, rkev1.SecretsEncryptionPhasePostReencryptRestart)
+	case rkev1.SecretsEncryptionPhasePostReencryptRestart:
+		if status, err = p.secretsEncryptionRestartNodes(controlPlane, status, tokensSecret, clusterPlan, leader, initNode, joinServer); err != nil {
+			return status, err
+		}
+		if err = p.pauseCAPICluster(controlPlane, false); err != nil {
+			return status, errWaiting("unpausing CAPI cluster")
+		}
+		logrus.Infof("[planner] rkecluster %s/%s: changed secrets encryption to enabled=%t", controlPlane.Namespace, controlPlane.Name, target)
+		status.SecretsEncryptionEnabled = &target
+		status.SecretsEncryptionLeader = ""
+		return p.setSecretsEncryptionState(status, rkev1.SecretsEncryptionPhaseDone)
+	}
+
+	return status, fmt.Errorf("encountered unknown secrets encryption phase: %s", controlPlane.Status.SecretsEncryptionPhase)
+}
+
+// secretsEncryptionFindLeader returns the current leader of the change of secrets encryption if it is still suitable,
+// otherwise it elects the init node, or the first suitable control plane machine if the init node is etcd-only. A new
+// leader can only be elected in the Toggle phase.
+func (p *Planner) secretsEncryptionFindLeader(status rkev1.RKEControlPlaneStatus, clusterPlan *plan.Plan, init *planEntry) (*planEntry, error) {
+	machineName := status.SecretsEncryptionLeader
+	if machine, ok := clusterPlan.Machines[machineName]; ok {
+		entry := &planEntry{
+			Machine:  machine,
+			Plan:     clusterPlan.Nodes[machineName],
+			Metadata: clusterPlan.Metadata[machineName],
+		}
+		if encryptionKeyRotationIsSuitableControlPlane(entry) {
+			return entry, nil
+		}
+	}
+
+	if status.SecretsEncryptionPhase != rkev1.SecretsEncryptionPhaseToggle {
+		return nil, fmt.Errorf("cannot elect control plane leader in phase %s", status.SecretsEncryptionPhase)
+	}
+
+	if isControlPlane(init) {
+		return init, nil
+	}
+	machines := collect(clusterPlan, encryptionKeyRotationIsSuitableControlPlane)
+	if len(machines) == 0 {
+		return nil, fmt.Errorf("no suitable control plane nodes for changing secrets encryption")
+	}
+	return machines[0], nil
+}
+
+// secretsEncryptionRestartNodes restarts the server service of the init node and the etcd-only machines if the leader
+// is not the init node, then of the leader, and finally of the remaining control plane machines.
+func (p *Planner) secretsEncryptionRestartNodes(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, clusterPlan *plan.Plan, leader *planEntry, initNode *planEntry, joinServer string) (rkev1.RKEControlPlaneStatus, error) {
+	var err error
+	entries := []*planEntry{leader}
+	if !isInitNode(leader) {
+		entries = append([]*planEntry{initNode}, collect(clusterPlan, func(entry *planEntry) bool {
+			return isEtcd(entry) && !isControlPlane(entry) && !isInitNode(entry) && entry.Machine.Name != leader.Machine.Name
+		})...)
+		entries = append(entries, leader)
+	}
+	entries = append(entries, collect(clusterPlan, func(entry *planEntry) bool {
+		return isControlPlaneAndNotInitNode(entry) && entry.Machine.Name != leader.Machine.Name
+	})...)
+
+	for _, entry := range entries {
+		if status, err = p.secretsEncryptionRestartService(controlPlane, status, tokensSecret, joinServer, entry); err != nil {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+// secretsEncryptionRestartService restarts the server service of the machine with the current secrets encryption
+// config and waits until it is active again. Control plane machines save the secrets-encrypt status once it can be
+// queried.
+func (p *Planner) secretsEncryptionRestartService(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, joinServer string, entry *planEntry) (rkev1.RKEControlPlaneStatus, error) {
+	nodePlan, config, joinedServer, err := p.generatePlanWithConfigFiles(controlPlane, tokensSecret, entry, joinServer, true)
+	if err != nil {
+		return status, err
+	}
+
+	nodePlan.Files = append(nodePlan.Files, plan.File{
+		Content: base64.StdEncoding.EncodeToString([]byte(encryptionKeyRotationWaitForSystemctlStatus)),
+		Path:    encryptionKeyRotationScriptPath(controlPlane, encryptionKeyRotationWaitForSystemctlStatusPath),
+	})
+
+	phase := strings.ToLower(string(controlPlane.Status.SecretsEncryptionPhase))
+	generation := strconv.FormatInt(controlPlane.Status.SecretsEncryptionGeneration, 10)
+	nodePlan.Instructions = []plan.OneTimeInstruction{}
+	if capr.GetRuntime(controlPlane.Spec.KubernetesVersion) == capr.RuntimeRKE2 {
+		if generated, instruction := generateManifestRemovalInstruction(controlPlane, entry); generated {
+			nodePlan.Instructions = append(nodePlan.Instructions, convertToIdempotentInstruction(
+				controlPlane,
+				fmt.Sprintf("secrets-encryption/manifest-cleanup/%s", phase),
+				generation,
+				instruction))
+		}
+	}
+
+	nodePlan.Instructions = append(nodePlan.Instructions, idempotentRestartInstructions(
+		controlPlane,
+		fmt.Sprintf("secrets-encryption/restart/%s", phase),
+		generation,
+		capr.GetRuntimeServerUnit(controlPlane.Spec.KubernetesVersion))...)
+	nodePlan.Instructions = append(nodePlan.Instructions, secretsEncryptionInstruction(controlPlane, "wait-for-systemctl-status", "sh",
+		"-x", encryptionKeyRotationScriptPath(controlPlane, encryptionKeyRotationWaitForSystemctlStatusPath), capr.GetRuntimeServerUnit(controlPlane.Spec.KubernetesVersion)))
+
+	if isControlPlane(entry) {
+		runtimeCommand := capr.GetRuntimeCommand(controlPlane.Spec.KubernetesVersion)
+		nodePlan.Files = append(nodePlan.Files, plan.File{
+			Content: base64.StdEncoding.EncodeToString([]byte(encryptionKeyRotationWaitForSecretsEncryptStatusScript)),
+			Path:    encryptionKeyRotationScriptPath(controlPlane, encryptionKeyRotationWaitForSecretsEncryptStatusPath),
+		})
+		statusInstruction := secretsEncryptionInstruction(controlPlane, encryptionKeyRotationSecretsEncryptStatusCommand, runtimeCommand, "secrets-encrypt", "status")
+		statusInstruction.SaveOutput = true
+		nodePlan.Instructions = append(nodePlan.Instructions,
+			secretsEncryptionInstruction(controlPlane, "wait-for-secrets-encrypt-status", "sh",
+				"-x", encryptionKeyRotationScriptPath(controlPlane, encryptionKeyRotationWaitForSecretsEncryptStatusPath), runtimeCommand),
+			statusInstruction,
+		)
+	}
+
+	probes, err := p.generateProbes(controlPlane, entry, config)
+	if err != nil {
+		return status, err
+	}
+	nodePlan.Probes = probes
+
+	err = assignAndCheckPlan(p.store, fmt.Sprintf("secrets encryption [%s] for machine [%s]", controlPlane.Status.SecretsEncryptionPhase, entry.Machine.Name), entry, nodePlan, joinedServer, 5, 5)
+	if err != nil {
+		if IsErrWaiting(err) {
+			if planAppliedButWaitingForProbes(entry) {
+				return status, errWaitingf("%s: %s", err.Error(), probesMessage(entry.Plan))
+			}
+			return status, err
+		}
+		return p.secretsEncryptionFailed(status, err)
+	}
+	return status, nil
+}
+
+// secretsEncryptionLeaderReconcile runs the given secrets-encrypt command on the leader. If periodic is true, the
+// secrets-encrypt status of the leader is scraped periodically.
+func (p *Planner) secretsEncryptionLeaderReconcile(controlPlane *rkev1.RKEControlPlane, status rkev1.RKEControlPlaneStatus, tokensSecret plan.Secret, joinServer string, leader *planEntry, periodic bool, args ...string) (rkev1.RKEControlPlaneStatus, error) {
+	nodePlan, _, joinedServer, err := p.generatePlanWithConfigFiles(controlPlane, tokensSecret, leader, joinServer, true)
+	if err != nil {
+		return status, err
+	}
+
+	nodePlan.Instructions = []plan.OneTimeInstruction{
+		idempotentInstruction(
+			controlPlane,
+			fmt.Sprintf("secrets-encryption/%s", strings.ToLower(string(controlPlane.Status.SecretsEncryptionPhase))),
+			strconv.FormatInt(controlPlane.Status.SecretsEncryptionGeneration, 10),
+			capr.GetRuntimeCommand(controlPlane.Spec.KubernetesVersion),
+			append([]string{"secrets-encrypt"}, args...),
+			[]string{},
+		),
+	}
+	if periodic {
+		nodePlan.PeriodicInstructions = []plan.PeriodicInstruction{
+			encryptionKeyRotationSecretsEncryptStatusPeriodicInstruction(controlPlane),
+		}
+	}
+
+	err = assignAndCheckPlan(p.store, fmt.Sprintf("secrets encryption [%s] for machine [%s]", controlPlane.Status.SecretsEncryptionPhase, leader.Machine.Name), leader, nodePlan, joinedServer, 1, 1)
+	if err != nil {
+		if IsErrWaiting(err) {
+			if strings.HasPrefix(err.Error(), "starting") {
+				logrus.Infof("[planner] rkecluster %s/%s: applying secrets encryption command: [secrets-encrypt %s]", controlPlane.Namespace, controlPlane.Name, strings.Join(args, " "))
+			}
+			return status, err
+		}
+		return p.secretsEncryptionFailed(status, err)
+	}
+	return status, nil
+}
+
+// secretsEncryptionInstruction returns a one time instruction with environment variables that force the system-agent
+// to run it again in every phase and change of secrets encryption.
+func secretsEncryptionInstruction(controlPlane *rkev1.RKEControlPlane, name, command string, args ...string) plan.OneTimeInstruction {
+	return plan.OneTimeInstruction{
+		Name:    name,
+		Command: command,
+		Args:    args,
+		Env: []string{
+			encryptionKeyRotationEndpointEnv,
+			fmt.Sprintf("SECRETS_ENCRYPTION_PHASE=%s", controlPlane.Status.SecretsEncryptionPhase),
+			fmt.Sprintf("SECRETS_ENCRYPTION_GENERATION=%d", controlPlane.Status.SecretsEncryptionGeneration),
+		},
+	}
+}
+
+// secretsEncryptionFailed marks the change of secrets encryption as failed. The change is not retried until the
+// controlplane is updated.
+func (p *Planner) secretsEncryptionFailed(status rkev1.RKEControlPlaneStatus, err error) (rkev1.RKEControlPlaneStatus, error) {
+	status.SecretsEncryptionPhase = rkev1.SecretsEncryptionPhaseFailed
+	status.SecretsEncryptionLeader = ""
+	return status, errors.Wrap(err, "changing secrets encryption failed, please perform an etcd restore")
+}
@@ -0,0 +1,535 @@
+package planner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rancher/channelserver/pkg/model"
+	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestKMSProvider(t *testing.T) {
+	provider, err := kmsProvider(&rkev1.SecretsEncryptionKMS{
+		Name:     "vault",
+		Endpoint: "unix:///var/run/kmsplugin/socket.sock",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"kms":{"apiVersion":"v2","endpoint":"unix:///var/run/kmsplugin/socket.sock","name":"vault","timeout":"3s"}}`, string(provider))
+}
+
+func TestAddSecretsEncryptionKMS(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.KubernetesVersion = "v1.30.4+rke2r1"
+	cp.Spec.SecretsEncryptionKMS = &rkev1.SecretsEncryptionKMS{
+		Name:        "vault",
+		Endpoint:    "unix:///var/run/kmsplugin/socket.sock",
+		SystemdUnit: "[Service]\nExecStart=/usr/local/bin/kms-plugin\n",
+	}
+	controlPlaneEntry := &planEntry{Metadata: &plan.Metadata{Labels: map[string]string{capr.ControlPlaneRoleLabel: "true"}}}
+	workerEntry := &planEntry{Metadata: &plan.Metadata{Labels: map[string]string{capr.WorkerRoleLabel: "true"}}}
+
+	config := map[string]interface{}{}
+	assert.NoError(t, addSecretsEncryptionKMSConfig(config, cp, controlPlaneEntry))
+	assert.Equal(t, false, config[secretsEncryptionConfigKey], "the distro must not manage secrets encryption with KMS")
+	assert.Equal(t, []string{"encryption-provider-config=/var/lib/rancher/rke2/server/cred/kms-encryption-config.json"}, config["kube-apiserver-arg"])
+	assert.Equal(t, []string{
+		"/var/lib/rancher/rke2/server/cred/kms-encryption-config.json:/var/lib/rancher/rke2/server/cred/kms-encryption-config.json:ro",
+		"/var/run/kmsplugin:/var/run/kmsplugin",
+	}, config["kube-apiserver-extra-mount"])
+
+	nodePlan, err := addSecretsEncryptionKMSFiles(plan.NodePlan{}, cp, controlPlaneEntry)
+	assert.NoError(t, err)
+	assert.Len(t, nodePlan.Files, 3)
+	if assert.Len(t, nodePlan.Instructions, 2) {
+		assert.Equal(t, "start-kms-plugin", nodePlan.Instructions[0].Name)
+		assert.Equal(t, "generate-kms-encryption-config", nodePlan.Instructions[1].Name)
+	}
+
+	config = map[string]interface{}{secretsEncryptionConfigKey: true}
+	assert.EqualError(t, addSecretsEncryptionKMSConfig(config, cp, controlPlaneEntry), "secrets-encryption must not be enabled together with secrets encryption with KMS")
+
+	config = map[string]interface{}{}
+	assert.NoError(t, addSecretsEncryptionKMSConfig(config, cp, workerEntry))
+	assert.Empty(t, config)
+	nodePlan, err = addSecretsEncryptionKMSFiles(plan.NodePlan{}, cp, workerEntry)
+	assert.NoError(t, err)
+	assert.Empty(t, nodePlan.Files)
+}
+
+func TestSecretsEncryptionDesired(t *testing.T) {
+	cp := &rkev1.RKEControlPlane{}
+	cp.Spec.KubernetesVersion = "v1.30.4+rke2r1"
+	assert.True(t, secretsEncryptionDesired(cp))
+	cp.Spec.KubernetesVersion = "v1.30.4+k3s1"
+	assert.False(t, secretsEncryptionDesired(cp))
+	cp.Spec.MachineGlobalConfig.Data = map[string]interface{}{secretsEncryptionConfigKey: "true"}
+	assert.True(t, secretsEncryptionDesired(cp))
+	cp.Spec.MachineGlobalConfig.Data = map[string]interface{}{secretsEncryptionConfigKey: false}
+	assert.False(t, secretsEncryptionDesired(cp))
+}
+
+func TestAddSecretsEncryptionConfig(t *testing.T) {
+	enabled, disabled := true, false
+	controlPlaneEntry := &planEntry{Metadata: &plan.Metadata{Labels: map[string]string{capr.ControlPlaneRoleLabel: "true"}}}
+	workerEntry := &planEntry{Metadata: &plan.Metadata{Labels: map[string]string{capr.WorkerRoleLabel: "true"}}}
+
+	tests := []struct {
+		name     string
+		enabled  *bool
+		phase    rkev1.SecretsEncryptionPhase
+		entry    *planEntry
+		expected map[string]interface{}
+	}{
+		{
+			name:     "unknown state",
+			entry:    controlPlaneEntry,
+			expected: map[string]interface{}{},
+		},
+		{
+			name:     "unchanged",
+			enabled:  &enabled,
+			entry:    controlPlaneEntry,
+			expected: map[string]interface{}{},
+		},
+		{
+			name:     "change not started",
+			enabled:  &disabled,
+			entry:    controlPlaneEntry,
+			expected: map[string]interface{}{secretsEncryptionConfigKey: false},
+		},
+		{
+			name:     "change failed",
+			enabled:  &disabled,
+			phase:    rkev1.SecretsEncryptionPhaseFailed,
+			entry:    controlPlaneEntry,
+			expected: map[string]interface{}{secretsEncryptionConfigKey: false},
+		},
+		{
+			name:     "change in progress",
+			enabled:  &disabled,
+			phase:    rkev1.SecretsEncryptionPhasePostToggleRestart,
+			entry:    controlPlaneEntry,
+			expected: map[string]interface{}{},
+		},
+		{
+			name:     "worker",
+			enabled:  &disabled,
+			entry:    workerEntry,
+			expected: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := &rkev1.RKEControlPlane{}
+			cp.Spec.KubernetesVersion = "v1.30.4+k3s1"
+			cp.Spec.MachineGlobalConfig.Data = map[string]interface{}{secretsEncryptionConfigKey: true}
+			cp.Status.SecretsEncryptionEnabled = tt.enabled
+			cp.Status.SecretsEncryptionPhase = tt.phase
+			config := map[string]interface{}{}
+			addSecretsEncryptionConfig(config, cp, tt.entry)
+			assert.Equal(t, tt.expected, config)
+		})
+	}
+}
+
+var secretsEncryptionReleaseData = &model.Release{
+	FeatureVersions: map[string]string{"encryption-key-rotation": "2.0.0"},
+}
+
+func TestChangeSecretsEncryptionStart(t *testing.T) {
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name            string
+		desired         bool
+		status          rkev1.RKEControlPlaneStatus
+		notReady        bool
+		releaseData     *model.Release
+		expectedEnabled *bool
+		expectedPhase   rkev1.SecretsEncryptionPhase
+		expectedWaiting bool
+	}{
+		{
+			name:            "unknown state",
+			desired:         true,
+			status:          rkev1.RKEControlPlaneStatus{Initialized: true},
+			expectedEnabled: &enabled,
+		},
+		{
+			name:            "not initialized",
+			desired:         false,
+			status:          rkev1.RKEControlPlaneStatus{SecretsEncryptionEnabled: &enabled},
+			expectedEnabled: &disabled,
+		},
+		{
+			name:            "unchanged",
+			desired:         true,
+			status:          rkev1.RKEControlPlaneStatus{Initialized: true, SecretsEncryptionEnabled: &enabled},
+			expectedEnabled: &enabled,
+		},
+		{
+			name:            "enable",
+			desired:         true,
+			status:          rkev1.RKEControlPlaneStatus{Initialized: true, SecretsEncryptionEnabled: &disabled},
+			expectedEnabled: &disabled,
+			expectedPhase:   rkev1.SecretsEncryptionPhaseToggle,
+			expectedWaiting: true,
+		},
+		{
+			name:            "disable",
+			desired:         false,
+			status:          rkev1.RKEControlPlaneStatus{Initialized: true, SecretsEncryptionEnabled: &enabled},
+			expectedEnabled: &enabled,
+			expectedPhase:   rkev1.SecretsEncryptionPhaseToggle,
+			expectedWaiting: true,
+		},
+		{
+			name:            "not ready",
+			desired:         true,
+			status:          rkev1.RKEControlPlaneStatus{Initialized: true, SecretsEncryptionEnabled: &disabled},
+			notReady:        true,
+			expectedEnabled: &disabled,
+		},
+		{
+			name:    "encryption key rotation in progress",
+			desired: true,
+			status: rkev1.RKEControlPlaneStatus{
+				Initialized:               true,
+				SecretsEncryptionEnabled:  &disabled,
+				RotateEncryptionKeysPhase: rkev1.RotateEncryptionKeysPhaseRotate,
+			},
+			expectedEnabled: &disabled,
+		},
+		{
+			name:    "failed change is not retried",
+			desired: true,
+			status: rkev1.RKEControlPlaneStatus{
+				Initialized:                 true,
+				SecretsEncryptionEnabled:    &disabled,
+				SecretsEncryptionPhase:      rkev1.SecretsEncryptionPhaseFailed,
+				SecretsEncryptionGeneration: 1,
+			},
+			expectedEnabled: &disabled,
+			expectedPhase:   rkev1.SecretsEncryptionPhaseFailed,
+		},
+		{
+			name:            "unsupported version",
+			desired:         true,
+			status:          rkev1.RKEControlPlaneStatus{Initialized: true, SecretsEncryptionEnabled: &disabled},
+			releaseData:     &model.Release{},
+			expectedEnabled: &disabled,
+			expectedPhase:   rkev1.SecretsEncryptionPhaseFailed,
+			expectedWaiting: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := &rkev1.RKEControlPlane{}
+			cp.Generation = 1
+			cp.Spec.KubernetesVersion = "v1.30.4+k3s1"
+			cp.Spec.MachineGlobalConfig.Data = map[string]interface{}{secretsEncryptionConfigKey: tt.desired}
+			cp.Status = tt.status
+			if !tt.notReady {
+				capr.Ready.True(cp)
+			}
+			releaseData := tt.releaseData
+			if releaseData == nil {
+				releaseData = secretsEncryptionReleaseData
+			}
+
+			// the change is only started, no plan is assigned to the machines
+			p := &Planner{}
+			status, err := p.changeSecretsEncryption(cp, cp.Status, plan.Secret{}, &plan.Plan{}, releaseData)
+			if tt.expectedWaiting {
+				assert.True(t, IsErrWaiting(err))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedEnabled, status.SecretsEncryptionEnabled)
+			assert.Equal(t, tt.expectedPhase, status.SecretsEncryptionPhase)
+			if tt.expectedPhase == rkev1.SecretsEncryptionPhaseToggle {
+				assert.Equal(t, int64(1), status.SecretsEncryptionGeneration)
+			}
+		})
+	}
+}
+
+// secretsEncryptionTestPlanner returns a mock planner, a controlplane whose change of secrets encryption is in the
+// Toggle phase and the plan of its only machine, which is the init node.
+func secretsEncryptionTestPlanner(t *testing.T, target bool) (*mockPlanner, *rkev1.RKEControlPlane, *plan.Plan) {
+	enabled := !target
+	cp := &rkev1.RKEControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "fleet-default",
+			Name:       "test",
+			Generation: 1,
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: capi.GroupVersion.String(), Kind: "Cluster", Name: "test", Controller: &[]bool{true}[0]},
+			},
+		},
+		Spec: rkev1.RKEControlPlaneSpec{
+			KubernetesVersion: "v1.30.4+k3s1",
+			UnmanagedConfig:   true,
+		},
+		Status: rkev1.RKEControlPlaneStatus{
+			Initialized:                 true,
+			SecretsEncryptionEnabled:    &enabled,
+			SecretsEncryptionPhase:      rkev1.SecretsEncryptionPhaseToggle,
+			SecretsEncryptionGeneration: 1,
+		},
+	}
+	cp.Spec.MachineGlobalConfig.Data = map[string]interface{}{secretsEncryptionConfigKey: target}
+	capr.Ready.True(cp)
+
+	clusterPlan := &plan.Plan{
+		Machines: map[string]*capi.Machine{
+			"server": {
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "fleet-default",
+					Name:      "server",
+				},
+				Spec: capi.MachineSpec{
+					Bootstrap: capi.Bootstrap{
+						ConfigRef: &corev1.ObjectReference{Kind: "RKEBootstrap", Name: "server"},
+					},
+				},
+				Status: capi.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "server"},
+					Conditions: capi.Conditions{
+						{Type: capi.ReadyCondition, Status: corev1.ConditionTrue},
+						{Type: capi.InfrastructureReadyCondition, Status: corev1.ConditionTrue},
+					},
+				},
+			},
+		},
+		Metadata: map[string]*plan.Metadata{
+			"server": {
+				Labels: map[string]string{
+					capr.EtcdRoleLabel:         "true",
+					capr.ControlPlaneRoleLabel: "true",
+					capr.InitNodeLabel:         "true",
+				},
+				Annotations: map[string]string{
+					capr.JoinURLAnnotation: "https://server:6443",
+				},
+			},
+		},
+		Nodes: map[string]*plan.Node{
+			"server": {InSync: true, Healthy: true},
+		},
+	}
+
+	return newMockPlanner(t, InfoFunctions{}), cp, clusterPlan
+}
+
+// expectPlanUpdate expects the plan secret of the machine to be updated and returns the assigned plan once it is.
+func expectPlanUpdate(mp *mockPlanner) *plan.NodePlan {
+	return expectMachinePlanUpdate(mp, "server")
+}
+
+// expectMachinePlanUpdate expects the plan secret of the given machine to be updated and returns the assigned plan once
+// it is.
+func expectMachinePlanUpdate(mp *mockPlanner, machine string) *plan.NodePlan {
+	nodePlan := &plan.NodePlan{}
+	mp.secretClient.EXPECT().Get("fleet-default", capr.PlanSecretFromBootstrapName(machine), gomock.Any()).Return(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "fleet-default",
+			Name:      capr.PlanSecretFromBootstrapName(machine),
+		},
+		Type: capr.SecretTypeMachinePlan,
+	}, nil)
+	mp.secretClient.EXPECT().Update(gomock.Any()).DoAndReturn(func(secret *corev1.Secret) (*corev1.Secret, error) {
+		return secret, json.Unmarshal(secret.Data["plan"], nodePlan)
+	})
+	return nodePlan
+}
+
+// applyPlan marks the plan as applied by the machine with the given output.
+func applyPlan(clusterPlan *plan.Plan, nodePlan *plan.NodePlan, output map[string][]byte) {
+	applyMachinePlan(clusterPlan, "server", nodePlan, output)
+}
+
+// applyMachinePlan marks the plan as applied by the given machine with the given output.
+func applyMachinePlan(clusterPlan *plan.Plan, machine string, nodePlan *plan.NodePlan, output map[string][]byte) {
+	clusterPlan.Nodes[machine] = &plan.Node{
+		Plan:    *nodePlan,
+		InSync:  true,
+		Healthy: true,
+		Output:  output,
+	}
+}
+
+func TestChangeSecretsEncryptionPhases(t *testing.T) {
+	for _, target := range []bool{true, false} {
+		command, expectedStatus := "disable", secretsEncryptionStatusDisabled
+		if target {
+			command, expectedStatus = "enable", secretsEncryptionStatusEnabled
+		}
+		t.Run(command, func(t *testing.T) {
+			mp, cp, clusterPlan := secretsEncryptionTestPlanner(t, target)
+			reconcile := func() error {
+				status, err := mp.planner.changeSecretsEncryption(cp, cp.Status, plan.Secret{}, clusterPlan, secretsEncryptionReleaseData)
+				cp.Status = status
+				return err
+			}
+
+			// the init node is elected as leader
+			err := reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, "server", cp.Status.SecretsEncryptionLeader)
+
+			// the CAPI cluster is paused and the leader toggles secrets encryption
+			cluster := &capi.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "fleet-default", Name: "test"}}
+			mp.capiClusters.EXPECT().Get("fleet-default", "test").DoAndReturn(func(string, string) (*capi.Cluster, error) {
+				return cluster, nil
+			}).AnyTimes()
+			mp.capiClient.EXPECT().Update(gomock.Any()).DoAndReturn(func(c *capi.Cluster) (*capi.Cluster, error) {
+				cluster = c
+				return c, nil
+			}).Times(2)
+			nodePlan := expectPlanUpdate(mp)
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.True(t, strings.HasPrefix(err.Error(), "starting"), err.Error())
+			assert.True(t, cluster.Spec.Paused)
+			assert.Equal(t, []string{"secrets-encrypt", command}, nodePlan.Instructions[0].Args[len(nodePlan.Instructions[0].Args)-2:])
+			assert.Equal(t, rkev1.SecretsEncryptionPhaseToggle, cp.Status.SecretsEncryptionPhase)
+
+			applyPlan(clusterPlan, nodePlan, nil)
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.SecretsEncryptionPhasePostToggleRestart, cp.Status.SecretsEncryptionPhase)
+
+			// the leader is restarted and reports the toggled secrets encryption status
+			nodePlan = expectPlanUpdate(mp)
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.SecretsEncryptionPhasePostToggleRestart, cp.Status.SecretsEncryptionPhase)
+
+			applyPlan(clusterPlan, nodePlan, map[string][]byte{
+				encryptionKeyRotationSecretsEncryptStatusCommand: []byte(expectedStatus + "\n"),
+			})
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.SecretsEncryptionPhaseReencrypt, cp.Status.SecretsEncryptionPhase)
+
+			// the leader reencrypts the secrets
+			nodePlan = expectPlanUpdate(mp)
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, []string{"secrets-encrypt", "reencrypt", "-f", "--skip"}, nodePlan.Instructions[0].Args[len(nodePlan.Instructions[0].Args)-4:])
+
+			applyPlan(clusterPlan, nodePlan, nil)
+			clusterPlan.Nodes["server"].PeriodicOutput = map[string]plan.PeriodicInstructionOutput{
+				encryptionKeyRotationSecretsEncryptStatusCommand: {Stdout: []byte("Encryption Status: Enabled\nCurrent Rotation Stage: reencrypt_active\n")},
+			}
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.SecretsEncryptionPhaseReencrypt, cp.Status.SecretsEncryptionPhase)
+
+			clusterPlan.Nodes["server"].PeriodicOutput = map[string]plan.PeriodicInstructionOutput{
+				encryptionKeyRotationSecretsEncryptStatusCommand: {Stdout: []byte("Encryption Status: Enabled\nCurrent Rotation Stage: reencrypt_finished\n")},
+			}
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.SecretsEncryptionPhasePostReencryptRestart, cp.Status.SecretsEncryptionPhase)
+
+			// the leader is restarted again, the CAPI cluster is unpaused and the change is done
+			nodePlan = expectPlanUpdate(mp)
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+
+			applyPlan(clusterPlan, nodePlan, nil)
+			err = reconcile()
+			assert.True(t, IsErrWaiting(err))
+			assert.False(t, cluster.Spec.Paused)
+			assert.Equal(t, rkev1.SecretsEncryptionPhaseDone, cp.Status.SecretsEncryptionPhase)
+			assert.Equal(t, &target, cp.Status.SecretsEncryptionEnabled)
+			assert.Empty(t, cp.Status.SecretsEncryptionLeader)
+
+			// the change is not started again
+			err = reconcile()
+			assert.NoError(t, err)
+			assert.Equal(t, rkev1.SecretsEncryptionPhaseDone, cp.Status.SecretsEncryptionPhase)
+		})
+	}
+}
+
+func TestChangeSecretsEncryptionFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		phase  rkev1.SecretsEncryptionPhase
+		setup  func(clusterPlan *plan.Plan)
+		output string
+		failed bool
+	}{
+		{
+			name:   "unexpected status after restart",
+			phase:  rkev1.SecretsEncryptionPhasePostToggleRestart,
+			output: secretsEncryptionStatusDisabled,
+		},
+		{
+			name:   "reencryption failed",
+			phase:  rkev1.SecretsEncryptionPhaseReencrypt,
+			failed: true,
+		},
+		{
+			name:  "leader lost after toggle",
+			phase: rkev1.SecretsEncryptionPhaseReencrypt,
+			setup: func(clusterPlan *plan.Plan) {
+				clusterPlan.Machines["server"].Status.NodeRef = nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp, cp, clusterPlan := secretsEncryptionTestPlanner(t, true)
+			cp.Status.SecretsEncryptionLeader = "server"
+			cp.Status.SecretsEncryptionPhase = tt.phase
+			enabled := cp.Status.SecretsEncryptionEnabled
+			reconcile := func() error {
+				status, err := mp.planner.changeSecretsEncryption(cp, cp.Status, plan.Secret{}, clusterPlan, secretsEncryptionReleaseData)
+				cp.Status = status
+				return err
+			}
+
+			if tt.setup != nil {
+				tt.setup(clusterPlan)
+			} else {
+				// the plan of the phase is assigned to and applied by the leader
+				nodePlan := expectPlanUpdate(mp)
+				assert.True(t, IsErrWaiting(reconcile()))
+				applyPlan(clusterPlan, nodePlan, map[string][]byte{
+					encryptionKeyRotationSecretsEncryptStatusCommand: []byte(tt.output + "\n"),
+				})
+				clusterPlan.Nodes["server"].Failed = tt.failed
+			}
+
+			err := reconcile()
+			assert.Error(t, err)
+			assert.False(t, IsErrWaiting(err))
+			assert.Equal(t, rkev1.SecretsEncryptionPhaseFailed, cp.Status.SecretsEncryptionPhase)
+			assert.Empty(t, cp.Status.SecretsEncryptionLeader)
+			// the secrets encryption state is kept, so the distro is configured with the previous setting
+			assert.Equal(t, enabled, cp.Status.SecretsEncryptionEnabled)
+			config := map[string]interface{}{}
+			addSecretsEncryptionConfig(config, cp, &planEntry{Metadata: clusterPlan.Metadata["server"]})
+			assert.Equal(t, map[string]interface{}{secretsEncryptionConfigKey: false}, config)
+
+			// the failed change is not retried until the controlplane is updated
+			assert.NoError(t, reconcile())
+			assert.Equal(t, rkev1.SecretsEncryptionPhaseFailed, cp.Status.SecretsEncryptionPhase)
+		})
+	}
+}
@@ -5,9 +5,8 @@ import (
 	"testing"
 
 	"github.com/rancher/cluster-api-provider-rancher/pkg"
-	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
-	"github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1/plan"
-	"github.com/rancher/rancher/pkg/provisioningv2/image"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1/plan"
 	"github.com/rancher/wrangler/v3/pkg/generic"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
@@ -952,7 +951,7 @@ func TestSetMachineJoinURL(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mp := newMockPlanner(t, InfoFunctions{
 				SystemAgentImage: func() string { return "system-agent" },
-				ImageResolver:    image.ResolveWithControlPlane,
+				ImageResolver:    resolveWithControlPlane,
 			})
 
 			n, e := SecretToNode(tt.inputSecret)
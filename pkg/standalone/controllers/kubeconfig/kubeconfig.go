@@ -1,8 +1,10 @@
 package kubeconfig
 
 import (
+	"encoding/base64"
 	"fmt"
 	"github.com/rancher/cluster-api-provider-rancher/pkg"
+	rkev1 "github.com/rancher/cluster-api-provider-rancher/pkg/apis/rke.cattle.io/v1"
 	caprcontext "github.com/rancher/cluster-api-provider-rancher/pkg/context"
 	capicontrollers "github.com/rancher/cluster-api-provider-rancher/pkg/generated/controllers/cluster.x-k8s.io/v1beta1"
 	rkev1controllers "github.com/rancher/cluster-api-provider-rancher/pkg/generated/controllers/rke.cattle.io/v1"
@@ -13,6 +15,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"reflect"
 	"regexp"
 	capi "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -37,21 +40,25 @@ var (
 
 type handler struct {
 	secrets             corecontrollers.SecretClient
+	secretsCache        corecontrollers.SecretCache
 	machinesCache       capicontrollers.MachineCache
 	machinesClient      capicontrollers.MachineClient
 	clusterCache        capicontrollers.ClusterCache
 	etcdSnapshotsClient rkev1controllers.ETCDSnapshotClient
 	etcdSnapshotsCache  rkev1controllers.ETCDSnapshotCache
+	controlPlaneCache   rkev1controllers.RKEControlPlaneCache
 }
 
 func Register(wContext *caprcontext.Context) {
 	h := handler{
 		secrets:             wContext.Core.Secret(),
+		secretsCache:        wContext.Core.Secret().Cache(),
 		machinesCache:       wContext.CAPI.Machine().Cache(),
 		machinesClient:      wContext.CAPI.Machine(),
 		clusterCache:        wContext.CAPI.Cluster().Cache(),
 		etcdSnapshotsClient: wContext.RKE.ETCDSnapshot(),
 		etcdSnapshotsCache:  wContext.RKE.ETCDSnapshot().Cache(),
+		controlPlaneCache:   wContext.RKE.RKEControlPlane().Cache(),
 	}
 	wContext.Core.Secret().OnChange(wContext.Ctx, "plan-secret", h.OnChange)
 }
@@ -82,15 +89,41 @@ func (h *handler) reconcileKubeconfig(clusterNamespace, clusterName string, stdo
 		return err
 	}
 
+	controlPlane, err := h.controlPlaneCache.Get(clusterNamespace, clusterName)
+	if err != nil {
+		return err
+	}
+
 	config, err := clientcmd.Load(stdout)
 	if err != nil {
 		return err
 	}
 
+	// the CA the kube-apiserver is verified against by the OIDC kubeconfig, which is removed from the admin kubeconfig below
+	// if the server CA is not trusted.
+	var clusterCA []byte
+	for _, c := range config.Clusters {
+		clusterCA = c.CertificateAuthorityData
+		break
+	}
+
+	// the serving certificate of the kube-apiserver is signed by a server CA that was provided by the user or rotated, so it
+	// can be verified against a name that is always part of the certificate.
+	trustServerCA := controlPlane.Status.ServerCAData != "" ||
+		(controlPlane.Spec.CertificateAuthorities != nil && controlPlane.Spec.CertificateAuthorities.ServerCASecretName != "")
 	for k := range config.Clusters {
-		// TODO: fix this stupid workaround - but we need to do it because the kube-apiserver doesn't have the tls-san for the infracluster endpoint
-		config.Clusters[k].CertificateAuthorityData = nil
-		config.Clusters[k].InsecureSkipTLSVerify = true
+		if trustServerCA {
+			if controlPlane.Status.ServerCAData != "" {
+				// the kubeconfig on the init node is only dumped periodically, so it may predate the last rotation.
+				config.Clusters[k].CertificateAuthorityData = []byte(controlPlane.Status.ServerCAData)
+			}
+			config.Clusters[k].InsecureSkipTLSVerify = false
+			config.Clusters[k].TLSServerName = "kubernetes"
+		} else {
+			// TODO: fix this stupid workaround - but we need to do it because the kube-apiserver doesn't have the tls-san for the infracluster endpoint
+			config.Clusters[k].CertificateAuthorityData = nil
+			config.Clusters[k].InsecureSkipTLSVerify = true
+		}
 		config.Clusters[k].Server = fmt.Sprintf("https://%s:%d", cluster.Spec.ControlPlaneEndpoint.Host, cluster.Spec.ControlPlaneEndpoint.Port)
 	}
 
@@ -99,11 +132,100 @@ func (h *handler) reconcileKubeconfig(clusterNamespace, clusterName string, stdo
 		return err
 	}
 
-	existingKCSecret, err := h.secrets.Get(clusterNamespace, fmt.Sprintf("%s-kubeconfig", clusterName), metav1.GetOptions{})
+	if err := h.reconcileKubeconfigSecret(clusterNamespace, clusterName, fmt.Sprintf("%s-kubeconfig", clusterName), kc); err != nil {
+		return err
+	}
+	return h.reconcileOIDCKubeconfig(controlPlane, clusterName, config, clusterCA)
+}
+
+// reconcileOIDCKubeconfig stores a kubeconfig for the users of the cluster in the <cluster>-oidc-kubeconfig secret if the
+// local cluster auth endpoint authenticates users with OIDC. The kubeconfig obtains ID tokens with the oidc-login
+// plugin of kubectl, and connects to the FQDN of the local cluster auth endpoint if it is set. The kube-apiserver is
+// always verified, against the CA certs of the local cluster auth endpoint if they are set, otherwise against the server
+// CA of the cluster.
+func (h *handler) reconcileOIDCKubeconfig(controlPlane *rkev1.RKEControlPlane, clusterName string, adminConfig *clientcmdapi.Config, clusterCA []byte) error {
+	secretName := fmt.Sprintf("%s-oidc-kubeconfig", clusterName)
+	endpoint := controlPlane.Spec.LocalClusterAuthEndpoint
+	if !endpoint.Enabled || endpoint.OIDC == nil {
+		if _, err := h.secretsCache.Get(controlPlane.Namespace, secretName); apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		err := h.secrets.Delete(controlPlane.Namespace, secretName, &metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	var cluster *clientcmdapi.Cluster
+	for _, c := range adminConfig.Clusters {
+		cluster = c.DeepCopy()
+		break
+	}
+	if cluster == nil {
+		return fmt.Errorf("kubeconfig of cluster %s/%s does not contain a cluster", controlPlane.Namespace, clusterName)
+	}
+	cluster.InsecureSkipTLSVerify = false
+	cluster.CertificateAuthorityData = clusterCA
+	if controlPlane.Status.ServerCAData != "" {
+		cluster.CertificateAuthorityData = []byte(controlPlane.Status.ServerCAData)
+	}
+	// the serving certificate of the kube-apiserver does not contain the infrastructure endpoint, but always the name
+	// kubernetes.
+	cluster.TLSServerName = "kubernetes"
+	if endpoint.FQDN != "" {
+		cluster.Server = fmt.Sprintf("https://%s", endpoint.FQDN)
+		cluster.TLSServerName = ""
+		if endpoint.CACerts != "" {
+			cluster.CertificateAuthorityData = []byte(endpoint.CACerts)
+		}
+	}
+	if len(cluster.CertificateAuthorityData) == 0 {
+		return fmt.Errorf("no CA to verify the kube-apiserver of cluster %s/%s with", controlPlane.Namespace, clusterName)
+	}
+
+	args := []string{
+		"oidc-login",
+		"get-token",
+		fmt.Sprintf("--oidc-issuer-url=%s", endpoint.OIDC.IssuerURL),
+		fmt.Sprintf("--oidc-client-id=%s", endpoint.OIDC.ClientID),
+	}
+	if endpoint.OIDC.CACerts != "" {
+		args = append(args, fmt.Sprintf("--certificate-authority-data=%s", base64.StdEncoding.EncodeToString([]byte(endpoint.OIDC.CACerts))))
+	}
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters[clusterName] = cluster
+	config.AuthInfos["oidc"] = &clientcmdapi.AuthInfo{
+		Exec: &clientcmdapi.ExecConfig{
+			APIVersion:      "client.authentication.k8s.io/v1beta1",
+			Command:         "kubectl",
+			Args:            args,
+			InteractiveMode: clientcmdapi.IfAvailableExecInteractiveMode,
+		},
+	}
+	config.Contexts[clusterName] = &clientcmdapi.Context{
+		Cluster:  clusterName,
+		AuthInfo: "oidc",
+	}
+	config.CurrentContext = clusterName
+
+	kc, err := clientcmd.Write(*config)
+	if err != nil {
+		return err
+	}
+	return h.reconcileKubeconfigSecret(controlPlane.Namespace, clusterName, secretName, kc)
+}
+
+// reconcileKubeconfigSecret creates or updates the secret with the given kubeconfig.
+func (h *handler) reconcileKubeconfigSecret(clusterNamespace, clusterName, secretName string, kc []byte) error {
+	existingKCSecret, err := h.secrets.Get(clusterNamespace, secretName, metav1.GetOptions{})
 	if apierrors.IsNotFound(err) {
 		_, err = h.secrets.Create(&corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("%s-kubeconfig", clusterName),
+				Name:      secretName,
 				Namespace: clusterNamespace,
 				Labels: map[string]string{
 					capi.ClusterNameLabel: clusterName,